@@ -35,9 +35,13 @@ import (
 	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/config"
 	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/database"
 	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/handlers"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/jobs"
 	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/logger"
 	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/middleware"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/outbox"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/realtime"
 	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/routes"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/storage"
 
 	// Swagger imports
 	_ "github.com/Innocent9712/much-to-do/Server/MuchToDo/docs" // This is required for swag to find your docs
@@ -71,18 +75,40 @@ func main() {
 	}()
 	slog.Info("Successfully connected to MongoDB.")
 
-	// 3. Initialize Services (Cache, Auth)
+	if err := database.EnsureIndexes(dbClient.Database(cfg.DBName)); err != nil {
+		slog.Error("could not create MongoDB indexes", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// 3. Initialize Services (Cache, Auth, Storage, Events)
 	cacheService := cache.NewCacheService(cfg)
 	tokenService := auth.NewTokenService(cfg.JWTSecretKey, cfg.JWTExpirationHours)
+	blobStore, err := storage.New(cfg)
+	if err != nil {
+		slog.Error("could not initialize attachment storage", slog.Any("error", err))
+		os.Exit(1)
+	}
+	events := outbox.New(dbClient.Database(cfg.DBName))
+	eventSinks, err := outbox.NewSinks(cfg, cache.RedisClient(cacheService))
+	if err != nil {
+		slog.Error("could not initialize event sinks", slog.Any("error", err))
+		os.Exit(1)
+	}
+	hub := realtime.NewHub(cache.RedisClient(cacheService))
 
 	// Preload usernames into cache if enabled
 	preloadUsernamesIntoCache(dbClient, cacheService, cfg)
 
 	// 4. Set up API router
-	router := setupRouter(dbClient, cfg, tokenService, cacheService)
+	router := setupRouter(dbClient, cfg, tokenService, cacheService, blobStore, events, hub)
 
-	// 5. Start Server with graceful shutdown
-	startServer(router, cfg.ServerPort)
+	// 5. Start background jobs; they stop when the server shuts down
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	startBackgroundJobs(jobsCtx, dbClient, cfg, cacheService, blobStore, events, eventSinks, hub)
+
+	// 6. Start Server with graceful shutdown; event streams are closed so that it can
+	startServer(router, cfg.ServerPort, hub.Close)
 }
 
 // preloadUsernamesIntoCache queries for all usernames and loads them into the cache,
@@ -152,7 +178,7 @@ func preloadUsernamesIntoCache(db *mongo.Client, cacheSvc cache.Cache, cfg confi
 }
 
 // setupRouter initializes the Gin router and sets up the routes.
-func setupRouter(db *mongo.Client, cfg config.Config, tokenSvc *auth.TokenService, cacheSvc cache.Cache) *gin.Engine {
+func setupRouter(db *mongo.Client, cfg config.Config, tokenSvc *auth.TokenService, cacheSvc cache.Cache, blobs storage.BlobStore, events *outbox.Outbox, hub *realtime.Hub) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
 
@@ -161,20 +187,32 @@ func setupRouter(db *mongo.Client, cfg config.Config, tokenSvc *auth.TokenServic
 	userCollection := db.Database(cfg.DBName).Collection("users")
 
 	// Initialize handlers
-	todoHandler := handlers.NewTodoHandler(todoCollection)
-	userHandler := handlers.NewUserHandler(userCollection, todoCollection, tokenSvc, cacheSvc, db, cfg)
+	attachmentHandler := newAttachmentHandler(db, cfg, blobs)
+	webhookHandler := newWebhookHandler(db, cfg)
+	todoHandler := newTodoHandler(db, cfg, attachmentHandler, events)
+	filterHandler := handlers.NewFilterHandler(db.Database(cfg.DBName).Collection("saved_filters"), todoHandler)
+	templateHandler := handlers.NewTemplateHandler(db.Database(cfg.DBName).Collection("templates"), todoHandler)
+	dataExportHandler := newDataExportHandler(db, cfg, blobs)
+	userHandler := handlers.NewUserHandler(userCollection, todoCollection, tokenSvc, cacheSvc, db, cfg, attachmentHandler, dataExportHandler, events)
+	commentHandler := handlers.NewCommentHandler(db.Database(cfg.DBName).Collection("comments"), todoHandler, userHandler)
+	timeHandler := handlers.NewTimeHandler(db.Database(cfg.DBName).Collection("time_entries"), todoHandler)
+	calendarHandler := handlers.NewCalendarHandler(userCollection, todoHandler)
+	appPasswordHandler := handlers.NewAppPasswordHandler(db.Database(cfg.DBName).Collection("app_passwords"), userCollection)
+	caldavHandler := handlers.NewCalDAVHandler(userCollection, todoHandler)
+	eventHandler := handlers.NewEventHandler(db.Database(cfg.DBName).Collection("outbox"), hub)
 	healthHandler := handlers.NewHealthHandler(db, cacheSvc, cfg.EnableCache)
 
 	// Middleware
 	corsMiddleware := middleware.CORSMiddleware(cfg.AllowedOrigins)
 	// corsMiddleware := middleware.CORSMiddleware2()
 	authMiddleware := middleware.AuthMiddleware(tokenSvc, cfg)
+	adminMiddleware := middleware.AdminKeyMiddleware(cfg.AdminAPIKey)
 
 	// Apply CORS middleware to the router
 	router.Use(corsMiddleware)
 
 	// Register all routes
-	routes.RegisterRoutes(router, userHandler, todoHandler, healthHandler, authMiddleware)
+	routes.RegisterRoutes(router, userHandler, todoHandler, filterHandler, templateHandler, attachmentHandler, commentHandler, timeHandler, calendarHandler, appPasswordHandler, caldavHandler, dataExportHandler, webhookHandler, eventHandler, healthHandler, authMiddleware, adminMiddleware)
 
 	// A simple ping route for health checks
 	router.GET("/ping", func(c *gin.Context) {
@@ -199,12 +237,136 @@ func setupRouter(db *mongo.Client, cfg config.Config, tokenSvc *auth.TokenServic
 	return router
 }
 
-// startServer starts the HTTP server and handles graceful shutdown.
-func startServer(router *gin.Engine, port string) {
+// newTodoHandler wires a TodoHandler to its collections.
+func newTodoHandler(db *mongo.Client, cfg config.Config, attachments *handlers.AttachmentHandler, events *outbox.Outbox) *handlers.TodoHandler {
+	return handlers.NewTodoHandler(
+		db.Database(cfg.DBName).Collection("todos"),
+		db.Database(cfg.DBName).Collection("todo_history"),
+		db.Database(cfg.DBName).Collection("undo_operations"),
+		db.Database(cfg.DBName).Collection("todo_tombstones"),
+		time.Duration(cfg.UndoWindowSeconds)*time.Second,
+		attachments,
+		events,
+		db.Database(cfg.DBName).Collection("comments"),
+		db.Database(cfg.DBName).Collection("time_entries"),
+	)
+}
+
+// newAttachmentHandler wires an AttachmentHandler to its collections and blob store.
+func newAttachmentHandler(db *mongo.Client, cfg config.Config, blobs storage.BlobStore) *handlers.AttachmentHandler {
+	return handlers.NewAttachmentHandler(
+		db.Database(cfg.DBName).Collection("attachments"),
+		db.Database(cfg.DBName).Collection("todos"),
+		blobs,
+		cfg.AttachmentMaxBytes,
+		cfg.AttachmentAllowedTypes,
+		time.Duration(cfg.AttachmentURLTTLMinutes)*time.Minute,
+	)
+}
+
+// newWebhookHandler wires a WebhookHandler to its collections.
+func newWebhookHandler(db *mongo.Client, cfg config.Config) *handlers.WebhookHandler {
+	return handlers.NewWebhookHandler(
+		db.Database(cfg.DBName).Collection("webhooks"),
+		db.Database(cfg.DBName).Collection("webhook_deliveries"),
+		cfg.WebhookAllowPrivateHosts,
+	)
+}
+
+// newDataExportHandler wires a DataExportHandler to its collections and blob store.
+func newDataExportHandler(db *mongo.Client, cfg config.Config, blobs storage.BlobStore) *handlers.DataExportHandler {
+	return handlers.NewDataExportHandler(
+		db.Database(cfg.DBName).Collection("data_exports"),
+		db.Database(cfg.DBName),
+		blobs,
+		time.Duration(cfg.DataExportRetentionHours)*time.Hour,
+		time.Duration(cfg.DataExportURLTTLMinutes)*time.Minute,
+	)
+}
+
+// startBackgroundJobs schedules the periodic maintenance jobs and starts the relay that
+// publishes events to users' webhooks, event streams and the configured sinks.
+func startBackgroundJobs(ctx context.Context, db *mongo.Client, cfg config.Config, cacheSvc cache.Cache, blobs storage.BlobStore, events *outbox.Outbox, sinks []outbox.Sink, hub *realtime.Hub) {
+	attachmentHandler := newAttachmentHandler(db, cfg, blobs)
+	webhookHandler := newWebhookHandler(db, cfg)
+	todoHandler := newTodoHandler(db, cfg, attachmentHandler, events)
+
+	relay := events.NewRelay(append([]outbox.Sink{webhookHandler, hub}, sinks...)...)
+	go relay.Run(ctx)
+	go hub.Run(ctx)
+
+	if cfg.TrashRetentionDays > 0 {
+		retention := time.Duration(cfg.TrashRetentionDays) * 24 * time.Hour
+		jobs.Schedule(ctx, "trash-purger", time.Hour, func(ctx context.Context) error {
+			purged, err := todoHandler.PurgeExpiredTrash(ctx, time.Now().Add(-retention))
+			if purged > 0 {
+				slog.Info("Purged expired todos from trash", "count", purged)
+			}
+			return err
+		})
+	}
+
+	if cfg.AutoArchiveDays > 0 {
+		jobs.Schedule(ctx, "auto-archiver", time.Hour, func(ctx context.Context) error {
+			archived, err := todoHandler.ArchiveCompletedBefore(ctx, time.Now().AddDate(0, 0, -cfg.AutoArchiveDays))
+			if archived > 0 {
+				slog.Info("Auto-archived completed todos", "count", archived)
+			}
+			return err
+		})
+	}
+
+	// Webhook deliveries are sent as soon as they are queued; this sends the retries, and
+	// the deliveries a restart interrupted.
+	jobs.Schedule(ctx, "webhook-sender", 30*time.Second, func(ctx context.Context) error {
+		delivered, err := webhookHandler.DeliverDue(ctx)
+		if delivered > 0 {
+			slog.Info("Sent webhook deliveries", "count", delivered)
+		}
+		return err
+	})
+
+	// Exports are normally built as soon as they are requested; this catches the ones a
+	// restart interrupted, and removes expired archives.
+	dataExportHandler := newDataExportHandler(db, cfg, blobs)
+	jobs.Schedule(ctx, "data-exporter", time.Minute, func(ctx context.Context) error {
+		built, err := dataExportHandler.BuildPending(ctx)
+		if built > 0 {
+			slog.Info("Built pending data exports", "count", built)
+		}
+		if err != nil {
+			return err
+		}
+		expired, err := dataExportHandler.ExpireArchives(ctx, time.Now())
+		if expired > 0 {
+			slog.Info("Deleted expired data export archives", "count", expired)
+		}
+		return err
+	})
+
+	// Accounts scheduled for deletion are deleted for good once their grace period ends.
+	userHandler := handlers.NewUserHandler(
+		db.Database(cfg.DBName).Collection("users"),
+		db.Database(cfg.DBName).Collection("todos"),
+		nil, cacheSvc, db, cfg, attachmentHandler, dataExportHandler, events,
+	)
+	jobs.Schedule(ctx, "account-purger", time.Hour, func(ctx context.Context) error {
+		purged, err := userHandler.PurgeScheduledDeletions(ctx, time.Now())
+		if purged > 0 {
+			slog.Info("Deleted accounts whose grace period ended", "count", purged)
+		}
+		return err
+	})
+}
+
+// startServer starts the HTTP server and handles graceful shutdown. onShutdown is
+// called when shutdown begins, to end long-lived requests.
+func startServer(router *gin.Engine, port string, onShutdown func()) {
 	srv := &http.Server{
 		Addr:    ":" + port,
 		Handler: router,
 	}
+	srv.RegisterOnShutdown(onShutdown)
 
 	go func() {
 		// Service connections
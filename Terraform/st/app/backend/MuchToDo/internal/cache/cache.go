@@ -93,6 +93,15 @@ func (r *RedisCache) Ping(ctx context.Context) error {
 	return r.client.Ping(ctx).Err()
 }
 
+// RedisClient returns the Redis connection behind a cache, so that other features can
+// share it, or nil if the cache isn't backed by Redis.
+func RedisClient(c Cache) *redis.Client {
+	if r, ok := c.(*RedisCache); ok {
+		return r.client
+	}
+	return nil
+}
+
 // --- NoOpCache ---
 // A dummy cache implementation that does nothing. Used when caching is disabled.
 
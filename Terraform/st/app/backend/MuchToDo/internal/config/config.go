@@ -21,6 +21,41 @@ type Config struct {
 	CookieDomains      []string `mapstructure:"COOKIE_DOMAINS"`
 	SecureCookie       bool     `mapstructure:"SECURE_COOKIE"`
 	AllowedOrigins     []string `mapstructure:"ALLOWED_ORIGINS"`
+	TrashRetentionDays int      `mapstructure:"TRASH_RETENTION_DAYS"`
+	AutoArchiveDays    int      `mapstructure:"AUTO_ARCHIVE_DAYS"`
+	UndoWindowSeconds  int      `mapstructure:"UNDO_WINDOW_SECONDS"`
+
+	// Accounts
+	AccountDeletionGraceDays int    `mapstructure:"ACCOUNT_DELETION_GRACE_DAYS"` // 0 deletes accounts immediately
+	AdminAPIKey              string `mapstructure:"ADMIN_API_KEY"`               // Authorizes /admin routes; they are disabled when empty
+
+	// Attachments
+	StorageDriver           string   `mapstructure:"STORAGE_DRIVER"` // "local" or "s3"
+	StorageLocalDir         string   `mapstructure:"STORAGE_LOCAL_DIR"`
+	StorageSigningKey       string   `mapstructure:"STORAGE_SIGNING_KEY"` // Signs local download links; defaults to the JWT secret
+	S3Endpoint              string   `mapstructure:"S3_ENDPOINT"`
+	S3Region                string   `mapstructure:"S3_REGION"`
+	S3Bucket                string   `mapstructure:"S3_BUCKET"`
+	S3AccessKey             string   `mapstructure:"S3_ACCESS_KEY"`
+	S3SecretKey             string   `mapstructure:"S3_SECRET_KEY"`
+	S3PathStyle             bool     `mapstructure:"S3_PATH_STYLE"`
+	AttachmentMaxBytes      int64    `mapstructure:"ATTACHMENT_MAX_BYTES"`
+	AttachmentAllowedTypes  []string `mapstructure:"ATTACHMENT_ALLOWED_TYPES"`
+	AttachmentURLTTLMinutes int      `mapstructure:"ATTACHMENT_URL_TTL_MINUTES"`
+
+	// Data exports
+	DataExportRetentionHours int `mapstructure:"DATA_EXPORT_RETENTION_HOURS"` // How long a finished archive can be downloaded
+	DataExportURLTTLMinutes  int `mapstructure:"DATA_EXPORT_URL_TTL_MINUTES"`
+
+	// Webhooks
+	WebhookAllowPrivateHosts bool `mapstructure:"WEBHOOK_ALLOW_PRIVATE_HOSTS"` // Lets webhooks post to loopback and private addresses, for development
+
+	// Event publishing
+	OutboxSinks             []string `mapstructure:"OUTBOX_SINKS"` // Any of "log", "webhook" and "redis"
+	OutboxWebhookURL        string   `mapstructure:"OUTBOX_WEBHOOK_URL"`
+	OutboxWebhookSecret     string   `mapstructure:"OUTBOX_WEBHOOK_SECRET"` // Signs the requests; they are unsigned when empty
+	OutboxRedisStream       string   `mapstructure:"OUTBOX_REDIS_STREAM"`
+	OutboxRedisStreamMaxLen int64    `mapstructure:"OUTBOX_REDIS_STREAM_MAX_LEN"`
 }
 
 // LoadConfig reads configuration from file or environment variables.
@@ -38,6 +73,20 @@ func LoadConfig(path string) (config Config, err error) {
 	viper.SetDefault("COOKIE_DOMAINS", []string{"localhost"})
 	viper.SetDefault("SECURE_COOKIE", false)
 	viper.SetDefault("ALLOWED_ORIGINS", []string{"http://localhost:5173"})
+	viper.SetDefault("TRASH_RETENTION_DAYS", 30)
+	viper.SetDefault("UNDO_WINDOW_SECONDS", 30)
+	viper.SetDefault("ACCOUNT_DELETION_GRACE_DAYS", 30)
+	viper.SetDefault("STORAGE_DRIVER", "local")
+	viper.SetDefault("STORAGE_LOCAL_DIR", "./data/attachments")
+	viper.SetDefault("S3_REGION", "us-east-1")
+	viper.SetDefault("S3_PATH_STYLE", true)
+	viper.SetDefault("ATTACHMENT_MAX_BYTES", 10<<20)
+	viper.SetDefault("ATTACHMENT_ALLOWED_TYPES", []string{"image/*", "application/pdf", "text/plain"})
+	viper.SetDefault("ATTACHMENT_URL_TTL_MINUTES", 15)
+	viper.SetDefault("DATA_EXPORT_RETENTION_HOURS", 7*24)
+	viper.SetDefault("DATA_EXPORT_URL_TTL_MINUTES", 15)
+	viper.SetDefault("OUTBOX_REDIS_STREAM", "muchtodo:events")
+	viper.SetDefault("OUTBOX_REDIS_STREAM_MAX_LEN", 100000)
 
 	err = viper.ReadInConfig()
 	if err != nil {
@@ -80,5 +129,25 @@ func LoadConfig(path string) (config Config, err error) {
 		config.CookieDomains = cleaned
 	}
 
+	if allowedTypes := viper.GetString("ATTACHMENT_ALLOWED_TYPES"); allowedTypes != "" {
+		var cleaned []string
+		for _, p := range strings.Split(allowedTypes, ",") {
+			if trimmed := strings.Trim(strings.TrimSpace(p), "\"'"); trimmed != "" {
+				cleaned = append(cleaned, trimmed)
+			}
+		}
+		config.AttachmentAllowedTypes = cleaned
+	}
+
+	if outboxSinks := viper.GetString("OUTBOX_SINKS"); outboxSinks != "" {
+		var cleaned []string
+		for _, p := range strings.Split(outboxSinks, ",") {
+			if trimmed := strings.Trim(strings.TrimSpace(p), "\"'"); trimmed != "" {
+				cleaned = append(cleaned, trimmed)
+			}
+		}
+		config.OutboxSinks = cleaned
+	}
+
 	return
 }
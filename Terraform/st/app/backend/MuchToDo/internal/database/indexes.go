@@ -0,0 +1,128 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// collectionIndexes lists the indexes each collection needs, keyed by collection name.
+var collectionIndexes = map[string][]mongo.IndexModel{
+	"todos": {
+		// Backs the main list, which only shows live (not archived, not trashed) todos.
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "deletedAt", Value: 1}, {Key: "archivedAt", Value: 1}, {Key: "createdAt", Value: -1}}},
+		// Backs full-text search. A collection can only have one text index, so any new
+		// searchable field has to be added here rather than in a separate index.
+		{
+			Keys:    bson.D{{Key: "title", Value: "text"}, {Key: "description", Value: "text"}},
+			Options: options.Index().SetName("todo_text").SetWeights(bson.D{{Key: "title", Value: 5}, {Key: "description", Value: 1}}),
+		},
+		// Back CalDAV: change tracking, and finding todos by resource name or UID.
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "changeSeq", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "davName", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "icalUid", Value: 1}}, Options: options.Index().SetSparse(true)},
+	},
+	"todo_history": {
+		// Version numbers are allocated per todo and must never repeat.
+		{Keys: bson.D{{Key: "todoId", Value: 1}, {Key: "version", Value: -1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "changedAt", Value: -1}}},
+	},
+	"todo_tombstones": {
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "changeSeq", Value: 1}}},
+		{Keys: bson.D{{Key: "purgedAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(models.TombstoneRetention.Seconds()))},
+	},
+	"app_passwords": {
+		// App passwords are looked up by their hash when a client authenticates.
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}}},
+	},
+	"attachments": {
+		{Keys: bson.D{{Key: "todoId", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}}},
+	},
+	"comments": {
+		{Keys: bson.D{{Key: "todoId", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "parentId", Value: 1}}, Options: options.Index().SetSparse(true)},
+		// Back the activity feed: comments on the user's todos and mentions of the user.
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "mentions.userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	},
+	"data_exports": {
+		// A user can have at most one export waiting or being built.
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}},
+			Options: options.Index().SetName("one_active_export").SetUnique(true).SetPartialFilterExpression(bson.M{"active": true}),
+		},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "requestedAt", Value: -1}}},
+		// Back the background job: exports waiting for a build, and archives to expire.
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "requestedAt", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expiresAt", Value: 1}}},
+	},
+	"outbox": {
+		// Back the relay: records some sink hasn't received, oldest first, and the
+		// records of one aggregate in sequence.
+		{Keys: bson.D{{Key: "publishedAt", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "aggregateType", Value: 1}, {Key: "aggregateId", Value: 1}, {Key: "sequence", Value: 1}}, Options: options.Index().SetUnique(true)},
+		// Backs resuming an event stream: a user's records after the last one received.
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}},
+		// Records every sink has received are removed by MongoDB's TTL monitor.
+		{Keys: bson.D{{Key: "publishedAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(models.OutboxRetention.Seconds()))},
+	},
+	"saved_filters": {
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"templates": {
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	"time_entries": {
+		// A user can have at most one running timer.
+		{
+			Keys:    bson.D{{Key: "userId", Value: 1}},
+			Options: options.Index().SetName("one_running_timer").SetUnique(true).SetPartialFilterExpression(bson.M{"running": true}),
+		},
+		{Keys: bson.D{{Key: "todoId", Value: 1}, {Key: "start", Value: -1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "start", Value: 1}}},
+	},
+	"undo_operations": {
+		{Keys: bson.D{{Key: "token", Value: 1}}, Options: options.Index().SetUnique(true)},
+		// Expired undo operations are removed by MongoDB's TTL monitor.
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	},
+	"webhooks": {
+		// Backs finding the webhooks subscribed to a user's todo events.
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "active", Value: 1}}},
+	},
+	"webhook_deliveries": {
+		{Keys: bson.D{{Key: "webhookId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		// Backs the background job: deliveries whose next attempt is due.
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}}},
+		// The delivery log is removed by MongoDB's TTL monitor.
+		{Keys: bson.D{{Key: "createdAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(int32(models.WebhookDeliveryRetention.Seconds()))},
+	},
+	"users": {
+		// Calendar feeds are looked up by the hash of their token.
+		{Keys: bson.D{{Key: "calendarToken.hash", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+		// Backs the purge of accounts whose deletion grace period has ended.
+		{Keys: bson.D{{Key: "deleteAt", Value: 1}}, Options: options.Index().SetSparse(true)},
+	},
+}
+
+// EnsureIndexes creates any missing indexes. Creating an index that already exists is a no-op.
+func EnsureIndexes(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for name, indexes := range collectionIndexes {
+		if _, err := db.Collection(name).Indexes().CreateMany(ctx, indexes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
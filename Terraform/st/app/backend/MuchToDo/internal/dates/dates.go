@@ -0,0 +1,70 @@
+// Package dates resolves the human-friendly day names accepted by the API
+// ("today", "friday", "2025-03-14") into concrete calendar days.
+package dates
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dayLayout is the format used for explicit calendar days.
+const dayLayout = "2006-01-02"
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// StartOfDay returns midnight at the start of t's day, in t's location.
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// ResolveDay turns a day reference into the start of that day, relative to now and in
+// now's location. It accepts "today", "tomorrow", "yesterday", weekday names (the next
+// such day, today included) and YYYY-MM-DD dates.
+func ResolveDay(ref string, now time.Time) (time.Time, error) {
+	ref = strings.ToLower(strings.TrimSpace(ref))
+	today := StartOfDay(now)
+
+	switch ref {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+
+	if weekday, ok := weekdays[ref]; ok {
+		ahead := (int(weekday) - int(today.Weekday()) + 7) % 7
+		return today.AddDate(0, 0, ahead), nil
+	}
+
+	day, err := time.ParseInLocation(dayLayout, ref, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unknown day %q: use today, tomorrow, yesterday, a weekday or YYYY-MM-DD", ref)
+	}
+	return day, nil
+}
+
+// ParseDate parses a date sent by a client, either as a YYYY-MM-DD day (midnight in loc)
+// or as an RFC 3339 timestamp.
+func ParseDate(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if day, err := time.ParseInLocation(dayLayout, value, loc); err == nil {
+		return day, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD or RFC 3339", value)
+	}
+	return t, nil
+}
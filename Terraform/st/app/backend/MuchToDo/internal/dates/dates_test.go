@@ -0,0 +1,47 @@
+package dates
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestResolveDay provides unit tests for resolving day references.
+func TestResolveDay(t *testing.T) {
+	// A Wednesday afternoon.
+	now := time.Date(2025, 3, 12, 15, 30, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	cases := map[string]time.Time{
+		"today":      day(12),
+		"Tomorrow":   day(13),
+		"yesterday":  day(11),
+		"friday":     day(14),
+		"wed":        day(12), // Today counts as the next Wednesday
+		"tuesday":    day(18),
+		"2025-04-01": time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for ref, want := range cases {
+		got, err := ResolveDay(ref, now)
+		assert.NoError(t, err, ref)
+		assert.Equal(t, want, got, ref)
+	}
+
+	_, err := ResolveDay("someday", now)
+	assert.Error(t, err)
+}
+
+// TestParseDate provides unit tests for parsing client-supplied dates.
+func TestParseDate(t *testing.T) {
+	got, err := ParseDate("2025-03-14", time.UTC)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC), got)
+
+	got, err = ParseDate("2025-03-14T09:00:00+02:00", time.UTC)
+	assert.NoError(t, err)
+	assert.True(t, got.Equal(time.Date(2025, 3, 14, 7, 0, 0, 0, time.UTC)))
+
+	_, err = ParseDate("14/03/2025", time.UTC)
+	assert.Error(t, err)
+}
@@ -0,0 +1,253 @@
+// Package dav reads and writes the XML bodies of WebDAV (RFC 4918) and CalDAV
+// (RFC 4791) requests: PROPFIND and REPORT requests, multi-status responses and
+// precondition errors.
+package dav
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// XML namespaces.
+const (
+	NS               = "DAV:"
+	CalDAVNS         = "urn:ietf:params:xml:ns:caldav"
+	CalendarServerNS = "http://calendarserver.org/ns/" // Apple's extensions, such as getctag
+)
+
+// Property is a WebDAV property or, nested in one, an XML element of its value.
+type Property struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []Property
+}
+
+// Name builds the XML name of a property.
+func Name(space, local string) xml.Name {
+	return xml.Name{Space: space, Local: local}
+}
+
+// Text builds a property with a text value.
+func Text(name xml.Name, text string) Property {
+	return Property{XMLName: name, Text: text}
+}
+
+// Element builds a property whose value is a list of elements.
+func Element(name xml.Name, children ...Property) Property {
+	return Property{XMLName: name, Children: children}
+}
+
+// Href builds a property whose value is a single href, such as owner.
+func Href(name xml.Name, href string) Property {
+	return Element(name, Text(Name(NS, "href"), href))
+}
+
+// Multistatus is the body of a 207 Multi-Status response.
+type Multistatus struct {
+	XMLName   xml.Name   `xml:"DAV: multistatus"`
+	Responses []Response `xml:"response"`
+	SyncToken string     `xml:"sync-token,omitempty"`
+}
+
+// Response describes one resource in a Multistatus. It has either property stats or,
+// for resources that can't be described, only a status.
+type Response struct {
+	Href      string     `xml:"href"`
+	Propstats []Propstat `xml:"propstat,omitempty"`
+	Status    string     `xml:"status,omitempty"`
+}
+
+// Propstat groups properties that share a status.
+type Propstat struct {
+	Prop   Prop   `xml:"prop"`
+	Status string `xml:"status"`
+}
+
+// Prop holds the properties of a Propstat.
+type Prop struct {
+	Properties []Property
+}
+
+// Status formats an HTTP status code as a status line, as used in Multistatus.
+func Status(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+// StatusResponse builds a response that only reports a status, such as 404 for a
+// resource that has been deleted.
+func StatusResponse(href string, code int) Response {
+	return Response{Href: href, Status: Status(code)}
+}
+
+// PropResponse builds a response with the requested properties of a resource. Requested
+// properties the resource doesn't have are reported as not found; a nil request returns
+// all of the available ones.
+func PropResponse(href string, available []Property, requested []xml.Name) Response {
+	response := Response{Href: href}
+	if requested == nil {
+		response.Propstats = append(response.Propstats, Propstat{Prop: Prop{available}, Status: Status(http.StatusOK)})
+		return response
+	}
+
+	var found, missing []Property
+	for _, name := range requested {
+		property, ok := findProperty(available, name)
+		if ok {
+			found = append(found, property)
+		} else {
+			missing = append(missing, Property{XMLName: name})
+		}
+	}
+	if len(found) > 0 {
+		response.Propstats = append(response.Propstats, Propstat{Prop: Prop{found}, Status: Status(http.StatusOK)})
+	}
+	if len(missing) > 0 {
+		response.Propstats = append(response.Propstats, Propstat{Prop: Prop{missing}, Status: Status(http.StatusNotFound)})
+	}
+	return response
+}
+
+func findProperty(properties []Property, name xml.Name) (Property, bool) {
+	for _, property := range properties {
+		if property.XMLName == name {
+			return property, true
+		}
+	}
+	return Property{}, false
+}
+
+// Error is the body of a response to a request that failed a precondition.
+type Error struct {
+	XMLName   xml.Name `xml:"DAV: error"`
+	Condition Property
+}
+
+// NewError builds an error body naming the precondition that failed, such as
+// DAV:valid-sync-token.
+func NewError(condition xml.Name) Error {
+	return Error{Condition: Property{XMLName: condition}}
+}
+
+// Marshal encodes a Multistatus or Error as an XML document.
+func Marshal(v interface{}) ([]byte, error) {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// Propfind is a parsed PROPFIND request. Props is nil when all properties are wanted,
+// which includes an empty request body.
+type Propfind struct {
+	Props []xml.Name
+}
+
+// Wants reports whether the request asks for the named property, explicitly or as part
+// of all properties.
+func (p Propfind) Wants(name xml.Name) bool {
+	if p.Props == nil {
+		return true
+	}
+	for _, prop := range p.Props {
+		if prop == name {
+			return true
+		}
+	}
+	return false
+}
+
+type propNames struct {
+	Names []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+func (p *propNames) list() []xml.Name {
+	names := make([]xml.Name, 0, len(p.Names))
+	for _, name := range p.Names {
+		names = append(names, name.XMLName)
+	}
+	return names
+}
+
+type propfindBody struct {
+	XMLName xml.Name   `xml:"DAV: propfind"`
+	AllProp *struct{}  `xml:"DAV: allprop"`
+	Prop    *propNames `xml:"DAV: prop"`
+}
+
+// ParsePropfind parses the body of a PROPFIND request.
+func ParsePropfind(r io.Reader) (Propfind, error) {
+	var body propfindBody
+	if err := xml.NewDecoder(r).Decode(&body); err != nil {
+		if err == io.EOF {
+			return Propfind{}, nil
+		}
+		return Propfind{}, fmt.Errorf("dav: invalid PROPFIND body: %w", err)
+	}
+	if body.AllProp == nil && body.Prop != nil {
+		return Propfind{Props: body.Prop.list()}, nil
+	}
+	return Propfind{}, nil
+}
+
+// Report types.
+var (
+	CalendarQuery    = Name(CalDAVNS, "calendar-query")
+	CalendarMultiget = Name(CalDAVNS, "calendar-multiget")
+	SyncCollection   = Name(NS, "sync-collection")
+)
+
+// Report is a parsed REPORT request.
+type Report struct {
+	Type      xml.Name // CalendarQuery, CalendarMultiget, SyncCollection or another, unsupported one
+	Propfind           // The properties to report on each resource
+	Hrefs     []string // The resources a calendar-multiget asks for
+	SyncToken string   // The token a sync-collection starts from; empty for an initial sync
+	// The component names a calendar-query's filter descends through, such as
+	// VCALENDAR and VTODO. Property and time-range filters are not represented.
+	Components []string
+}
+
+type compFilter struct {
+	Name  string       `xml:"name,attr"`
+	Comps []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type reportBody struct {
+	XMLName   xml.Name
+	AllProp   *struct{}  `xml:"DAV: allprop"`
+	Prop      *propNames `xml:"DAV: prop"`
+	Hrefs     []string   `xml:"DAV: href"`
+	SyncToken string     `xml:"DAV: sync-token"`
+	Filter    *struct {
+		Comp compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+// ParseReport parses the body of a REPORT request.
+func ParseReport(r io.Reader) (Report, error) {
+	var body reportBody
+	if err := xml.NewDecoder(r).Decode(&body); err != nil {
+		return Report{}, fmt.Errorf("dav: invalid REPORT body: %w", err)
+	}
+
+	report := Report{Type: body.XMLName, Hrefs: body.Hrefs, SyncToken: body.SyncToken}
+	if body.AllProp == nil && body.Prop != nil {
+		report.Props = body.Prop.list()
+	}
+	if body.Filter != nil {
+		for comp := &body.Filter.Comp; comp != nil; {
+			report.Components = append(report.Components, comp.Name)
+			if len(comp.Comps) == 0 {
+				break
+			}
+			comp = &comp.Comps[0]
+		}
+	}
+	return report, nil
+}
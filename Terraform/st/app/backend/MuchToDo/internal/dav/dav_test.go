@@ -0,0 +1,98 @@
+package dav
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDAV provides unit tests for reading and writing WebDAV XML.
+func TestDAV(t *testing.T) {
+	etag := Name(NS, "getetag")
+	ctag := Name(CalendarServerNS, "getctag")
+	resourceType := Name(NS, "resourcetype")
+
+	t.Run("ParsePropfind", func(t *testing.T) {
+		p, err := ParsePropfind(strings.NewReader(`<?xml version="1.0"?>
+			<d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/">
+				<d:prop><d:getetag/><cs:getctag/></d:prop>
+			</d:propfind>`))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"getetag", "getctag"}, []string{p.Props[0].Local, p.Props[1].Local})
+		assert.True(t, p.Wants(ctag))
+		assert.False(t, p.Wants(resourceType))
+
+		for _, body := range []string{"", `<propfind xmlns="DAV:"><allprop/></propfind>`} {
+			p, err = ParsePropfind(strings.NewReader(body))
+			assert.NoError(t, err)
+			assert.Nil(t, p.Props)
+			assert.True(t, p.Wants(resourceType))
+		}
+
+		_, err = ParsePropfind(strings.NewReader(`<propfind xmlns="DAV:"><prop>`))
+		assert.Error(t, err)
+	})
+
+	t.Run("ParseReport", func(t *testing.T) {
+		r, err := ParseReport(strings.NewReader(`<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+			<d:prop><d:getetag/><c:calendar-data/></d:prop>
+			<d:href>/dav/calendars/1/tasks/a.ics</d:href>
+			<d:href>/dav/calendars/1/tasks/b.ics</d:href>
+		</c:calendar-multiget>`))
+		assert.NoError(t, err)
+		assert.Equal(t, CalendarMultiget, r.Type)
+		assert.Len(t, r.Props, 2)
+		assert.Equal(t, []string{"/dav/calendars/1/tasks/a.ics", "/dav/calendars/1/tasks/b.ics"}, r.Hrefs)
+
+		r, err = ParseReport(strings.NewReader(`<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+			<d:prop><d:getetag/></d:prop>
+			<c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VTODO"/></c:comp-filter></c:filter>
+		</c:calendar-query>`))
+		assert.NoError(t, err)
+		assert.Equal(t, CalendarQuery, r.Type)
+		assert.Equal(t, []string{"VCALENDAR", "VTODO"}, r.Components)
+
+		r, err = ParseReport(strings.NewReader(`<d:sync-collection xmlns:d="DAV:">
+			<d:sync-token>urn:test:1</d:sync-token><d:sync-level>1</d:sync-level><d:prop><d:getetag/></d:prop>
+		</d:sync-collection>`))
+		assert.NoError(t, err)
+		assert.Equal(t, SyncCollection, r.Type)
+		assert.Equal(t, "urn:test:1", r.SyncToken)
+	})
+
+	t.Run("Multistatus", func(t *testing.T) {
+		available := []Property{
+			Element(resourceType, Element(Name(NS, "collection")), Element(Name(CalDAVNS, "calendar"))),
+			Text(ctag, "42"),
+		}
+		ms := Multistatus{
+			Responses: []Response{
+				PropResponse("/dav/tasks/", available, []xml.Name{ctag, etag}),
+				StatusResponse("/dav/tasks/gone.ics", http.StatusNotFound),
+			},
+			SyncToken: "urn:test:2",
+		}
+		body, err := Marshal(ms)
+		assert.NoError(t, err)
+		out := string(body)
+		assert.Contains(t, out, `<multistatus xmlns="DAV:">`)
+		assert.Contains(t, out, `<getctag xmlns="http://calendarserver.org/ns/">42</getctag>`)
+		assert.Contains(t, out, `<status>HTTP/1.1 200 OK</status>`)
+		assert.Contains(t, out, `<getetag xmlns="DAV:"></getetag></prop><status>HTTP/1.1 404 Not Found</status>`)
+		assert.Contains(t, out, `<href>/dav/tasks/gone.ics</href><status>HTTP/1.1 404 Not Found</status>`)
+		assert.Contains(t, out, `<sync-token>urn:test:2</sync-token>`)
+
+		all := PropResponse("/dav/tasks/", available, nil)
+		assert.Len(t, all.Propstats, 1)
+		assert.Len(t, all.Propstats[0].Prop.Properties, 2)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		body, err := Marshal(NewError(Name(NS, "valid-sync-token")))
+		assert.NoError(t, err)
+		assert.Contains(t, string(body), `<error xmlns="DAV:"><valid-sync-token xmlns="DAV:"></valid-sync-token></error>`)
+	})
+}
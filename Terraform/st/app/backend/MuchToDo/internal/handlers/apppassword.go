@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+const (
+	appPasswordLength     = 20            // Base32 characters, 100 bits
+	appPasswordGroup      = 4             // Characters between dashes, for readability
+	appPasswordsPerUser   = 20            // Most app passwords a user can have at once
+	appPasswordUsedUpdate = 1 * time.Hour // How often lastUsedAt is updated while a password is in use
+)
+
+// AppPasswordHandler manages app passwords, which apps that can't log in with a token,
+// such as CalDAV clients, use with HTTP Basic authentication.
+type AppPasswordHandler struct {
+	collection *mongo.Collection
+	users      *mongo.Collection
+}
+
+// NewAppPasswordHandler creates a new handler for app passwords.
+func NewAppPasswordHandler(collection *mongo.Collection, users *mongo.Collection) *AppPasswordHandler {
+	return &AppPasswordHandler{
+		collection: collection,
+		users:      users,
+	}
+}
+
+// CreateAppPassword godoc
+// @Summary      Create an app password
+// @Description  Creates a password for a single app, such as a CalDAV client, to sign in with along with the username.
+// @Description  The password is only returned in this response.
+// @Tags         app-passwords
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        appPassword body models.CreateAppPasswordDTO true "App name"
+// @Success      201  {object}  models.NewAppPassword
+// @Failure      400  {object}  map[string]string "Invalid input"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      409  {object}  map[string]string "Too many app passwords"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /users/me/app-passwords [post]
+func (h *AppPasswordHandler) CreateAppPassword(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var dto models.CreateAppPasswordDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	name := strings.TrimSpace(dto.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	count, err := h.collection.CountDocuments(ctx, bson.M{"userId": userID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create app password"})
+		return
+	}
+	if count >= appPasswordsPerUser {
+		c.JSON(http.StatusConflict, gin.H{"error": "Too many app passwords; revoke one first"})
+		return
+	}
+
+	password, err := newAppPassword()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate app password"})
+		return
+	}
+	appPassword := models.AppPassword{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Name:      name,
+		Hash:      hashAppPassword(password),
+		CreatedAt: time.Now(),
+	}
+	if _, err := h.collection.InsertOne(ctx, appPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create app password"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.NewAppPassword{AppPassword: appPassword, Password: password})
+}
+
+// GetAppPasswords godoc
+// @Summary      List app passwords
+// @Description  Lists the user's app passwords, oldest first. The passwords themselves can't be shown again.
+// @Tags         app-passwords
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {array}   models.AppPassword
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /users/me/app-passwords [get]
+func (h *AppPasswordHandler) GetAppPasswords(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var appPasswords []models.AppPassword
+	cursor, err := h.collection.Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err == nil {
+		err = cursor.All(ctx, &appPasswords)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch app passwords"})
+		return
+	}
+
+	if appPasswords == nil {
+		appPasswords = []models.AppPassword{}
+	}
+	c.JSON(http.StatusOK, appPasswords)
+}
+
+// DeleteAppPassword godoc
+// @Summary      Revoke an app password
+// @Description  Revokes an app password. Apps using it can no longer sign in.
+// @Tags         app-passwords
+// @Security     ApiKeyAuth
+// @Param        id path string true "App password ID"
+// @Success      204  "App password revoked"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "App password not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /users/me/app-passwords/{id} [delete]
+func (h *AppPasswordHandler) DeleteAppPassword(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	result, err := h.collection.DeleteOne(c.Request.Context(), bson.M{"_id": id, "userId": userID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke app password"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "App password not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// BasicAuthMiddleware authenticates requests with a username and app password, using
+// HTTP Basic authentication, and sets the user ID in the context like AuthMiddleware.
+func (h *AppPasswordHandler) BasicAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username, password, ok := c.Request.BasicAuth()
+		if !ok {
+			c.Header("WWW-Authenticate", `Basic realm="MuchToDo", charset="UTF-8"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Sign in with your username and an app password"})
+			return
+		}
+
+		userID, err := h.authenticate(c.Request.Context(), username, password)
+		if err != nil {
+			if err != mongo.ErrNoDocuments {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+				return
+			}
+			c.Header("WWW-Authenticate", `Basic realm="MuchToDo", charset="UTF-8"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or app password"})
+			return
+		}
+
+		c.Set("userID", userID.Hex())
+		c.Next()
+	}
+}
+
+// authenticate resolves a username and app password to the user they belong to, or
+// returns mongo.ErrNoDocuments if they don't match.
+func (h *AppPasswordHandler) authenticate(ctx context.Context, username, password string) (primitive.ObjectID, error) {
+	var appPassword models.AppPassword
+	if err := h.collection.FindOne(ctx, bson.M{"hash": hashAppPassword(password)}).Decode(&appPassword); err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	// Accounts scheduled for deletion are disabled.
+	filter := bson.M{"_id": appPassword.UserID, "username": strings.ToLower(strings.TrimSpace(username)), "deleteAt": nil}
+	count, err := h.users.CountDocuments(ctx, filter)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	if count == 0 {
+		return primitive.NilObjectID, mongo.ErrNoDocuments
+	}
+
+	now := time.Now()
+	if appPassword.LastUsedAt == nil || now.Sub(*appPassword.LastUsedAt) > appPasswordUsedUpdate {
+		if _, err := h.collection.UpdateOne(ctx, bson.M{"_id": appPassword.ID}, bson.M{"$set": bson.M{"lastUsedAt": now}}); err != nil {
+			slog.Warn("Failed to record app password use", slog.String("id", appPassword.ID.Hex()), slog.Any("error", err))
+		}
+	}
+	return appPassword.UserID, nil
+}
+
+// newAppPassword generates a random app password, in dash-separated groups so it is easy
+// to type on a phone.
+func newAppPassword() (string, error) {
+	b := make([]byte, 13)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:appPasswordLength]
+
+	groups := make([]string, 0, appPasswordLength/appPasswordGroup)
+	for i := 0; i < len(raw); i += appPasswordGroup {
+		groups = append(groups, raw[i:i+appPasswordGroup])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// hashAppPassword returns the hash under which an app password is stored. Case, dashes
+// and spaces are ignored, as people retype these passwords by hand.
+func hashAppPassword(password string) string {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(password))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// archiveBatch is how many todos are archived in one transaction, so that archiving
+// many todos doesn't outgrow a transaction's time and size limits.
+const archiveBatch = 500
+
+// getArchivedTodos writes a page of the user's archived todos, most recently archived first.
+func (h *TodoHandler) getArchivedTodos(c *gin.Context, userID primitive.ObjectID) {
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+	filter := bson.M{"userId": userID, "deletedAt": nil, "archivedAt": bson.M{"$ne": nil}}
+
+	total, err := h.collection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count archived todos"})
+		return
+	}
+
+	var todos []models.Todo
+	opts := options.Find().
+		SetSort(bson.D{{Key: "archivedAt", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(p.Skip()).
+		SetLimit(p.Limit)
+
+	cursor, err := h.collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch archived todos"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	if err = cursor.All(context.Background(), &todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode todos"})
+		return
+	}
+
+	if todos == nil {
+		todos = []models.Todo{}
+	}
+
+	c.JSON(http.StatusOK, paginatedResponse(todos, p, total))
+}
+
+// ArchiveCompleted godoc
+// @Summary      Archive completed todos
+// @Description  Archives the user's todos that were completed more than olderThanDays days ago, up to 500 at a time;
+// @Description  "more" is true if there are others left to archive with another request
+// @Tags         todos
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        archive body models.ArchiveCompletedDTO true "Age threshold in days"
+// @Success      200  {object}  map[string]interface{} "Returns the number of archived todos"
+// @Failure      400  {object}  map[string]string "Invalid input"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/archive [post]
+func (h *TodoHandler) ArchiveCompleted(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var dto models.ArchiveCompletedDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -dto.OlderThanDays)
+	archived, more, undoToken, err := h.archiveCompleted(context.Background(), userID, bson.M{"userId": userID}, cutoff)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive todos"})
+		return
+	}
+
+	setUndoToken(c, undoToken)
+	c.JSON(http.StatusOK, gin.H{"message": "Completed todos archived", "archived": archived, "more": more, "undoToken": undoToken})
+}
+
+// UnarchiveTodo godoc
+// @Summary      Unarchive a todo
+// @Description  Moves an archived todo back into the main list
+// @Tags         todos
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Todo ID"
+// @Success      200  {object}  map[string]string "{'message': 'Todo unarchived successfully', 'undoToken': '...'}"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Archived todo not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/unarchive [post]
+func (h *TodoHandler) UnarchiveTodo(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	filter := bson.M{"_id": id, "userId": userID, "deletedAt": nil, "archivedAt": bson.M{"$ne": nil}}
+	update := bson.M{
+		"$unset": bson.M{"archivedAt": ""},
+		"$set":   bson.M{"updatedAt": primitive.NewDateTimeFromTime(time.Now())},
+	}
+	_, undoToken, err := h.mutateTodo(context.Background(), userID, filter, update, models.TodoActionUnarchived)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Archived todo not found or you don't have permission"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unarchive todo"})
+		return
+	}
+
+	setUndoToken(c, undoToken)
+	c.JSON(http.StatusOK, gin.H{"message": "Todo unarchived successfully", "undoToken": undoToken})
+}
+
+// ArchiveCompletedBefore archives every user's todos that were completed before cutoff,
+// a batch at a time. It is run periodically by the background auto-archive job.
+func (h *TodoHandler) ArchiveCompletedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	var total int64
+	for {
+		archived, more, _, err := h.archiveCompleted(ctx, primitive.NilObjectID, bson.M{}, cutoff)
+		total += archived
+		if err != nil || !more {
+			return total, err
+		}
+	}
+}
+
+// archivableFilter matches the completed, non-archived todos matching scope that were
+// completed before cutoff. Todos completed before completedAt was tracked fall back to
+// updatedAt.
+func archivableFilter(scope bson.M, cutoff time.Time) bson.M {
+	filter := bson.M{
+		"completed":  true,
+		"archivedAt": nil,
+		"deletedAt":  nil,
+		"$or": bson.A{
+			bson.M{"completedAt": bson.M{"$lte": cutoff}},
+			bson.M{"completedAt": nil, "updatedAt": bson.M{"$lte": cutoff}},
+		},
+	}
+	for key, value := range scope {
+		filter[key] = value
+	}
+	return filter
+}
+
+// archiveCompleted archives up to archiveBatch of the completed, non-archived todos
+// matching scope that were completed before cutoff, in one transaction, recording each
+// one in its history on behalf of actor. It returns the number of archived todos, whether
+// there are more to archive, and an undo token.
+func (h *TodoHandler) archiveCompleted(ctx context.Context, actor primitive.ObjectID, scope bson.M, cutoff time.Time) (int64, bool, string, error) {
+	filter := archivableFilter(scope, cutoff)
+	var archived int64
+	var more bool
+	var undoToken string
+	err := h.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		archived = 0
+		var items []models.UndoItem
+
+		// One more than the batch is read to tell whether there are more.
+		var todos []models.Todo
+		opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(archiveBatch + 1)
+		cursor, err := h.collection.Find(sessCtx, filter, opts)
+		if err != nil {
+			return err
+		}
+		if err := cursor.All(sessCtx, &todos); err != nil {
+			return err
+		}
+		more = len(todos) > archiveBatch
+		if more {
+			todos = todos[:archiveBatch]
+		}
+
+		now := time.Now()
+		for i := range todos {
+			before := todos[i]
+			after := before
+			after.ArchivedAt = &now
+			after.UpdatedAt = now
+
+			update := bson.M{"$set": bson.M{"archivedAt": now, "updatedAt": now}}
+			if _, err := h.collection.UpdateOne(sessCtx, bson.M{"_id": before.ID}, update); err != nil {
+				return err
+			}
+			version, err := h.recordVersion(sessCtx, actor, models.TodoActionArchived, &before, after)
+			if err != nil {
+				return err
+			}
+			items = append(items, models.UndoItem{Before: before, Version: version})
+			archived++
+		}
+
+		undoToken, err = h.saveUndo(sessCtx, actor, models.TodoActionArchived, items)
+		return err
+	})
+	if err != nil {
+		return 0, false, "", err
+	}
+
+	return archived, more, undoToken, nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestArchive provides unit tests for which todos are archived, and for the requests
+// that are refused before the database is touched.
+func TestArchive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := primitive.NewObjectID()
+	cutoff := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Filter", func(t *testing.T) {
+		filter := archivableFilter(bson.M{"userId": userID}, cutoff)
+		assert.Equal(t, userID, filter["userId"])
+		assert.Equal(t, true, filter["completed"])
+		// Archived and trashed todos are left alone.
+		assert.Contains(t, filter, "archivedAt")
+		assert.Nil(t, filter["archivedAt"])
+		assert.Contains(t, filter, "deletedAt")
+		assert.Nil(t, filter["deletedAt"])
+		// Todos completed before completedAt was tracked go by updatedAt.
+		assert.Equal(t, bson.A{
+			bson.M{"completedAt": bson.M{"$lte": cutoff}},
+			bson.M{"completedAt": nil, "updatedAt": bson.M{"$lte": cutoff}},
+		}, filter["$or"])
+
+		// The auto-archiver's scope is every user.
+		assert.NotContains(t, archivableFilter(bson.M{}, cutoff), "userId")
+	})
+
+	t.Run("Pagination", func(t *testing.T) {
+		for query, want := range map[string]pagination{
+			"":                   {Page: 1, Limit: defaultPageSize},
+			"page=0&limit=-5":    {Page: 1, Limit: defaultPageSize},
+			"page=3&limit=1000":  {Page: 3, Limit: maxPageSize},
+			"page=10000&limit=1": {Page: maxPage, Limit: 1},
+		} {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/todos?"+query, nil)
+			p, ok := parsePagination(c)
+			assert.True(t, ok, query)
+			assert.Equal(t, want, p, query)
+		}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/todos?page=10001", nil)
+		_, ok := parsePagination(c)
+		assert.False(t, ok)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Requests", func(t *testing.T) {
+		h := &TodoHandler{}
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Set("userID", userID.Hex())
+		c.Request = httptest.NewRequest(http.MethodPost, "/tasks/archive", bytes.NewBufferString(`{"olderThanDays": -1}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		h.ArchiveCompleted(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		// A page deep enough to overflow the skip is refused rather than sent to MongoDB.
+		w = httptest.NewRecorder()
+		c, _ = gin.CreateTestContext(w)
+		c.Set("userID", userID.Hex())
+		c.Request = httptest.NewRequest(http.MethodGet, "/todos?archived=true&page=9223372036854775807", nil)
+		h.getArchivedTodos(c, userID)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = httptest.NewRecorder()
+		c, _ = gin.CreateTestContext(w)
+		c.Set("userID", userID.Hex())
+		c.Request = httptest.NewRequest(http.MethodPost, "/tasks/nope/unarchive", nil)
+		c.Params = gin.Params{{Key: "id", Value: "nope"}}
+		h.UnarchiveTodo(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/storage"
+)
+
+// maxFilenameLength caps the stored name of an uploaded file.
+const maxFilenameLength = 255
+
+// AttachmentHandler holds dependencies for attachment handlers.
+type AttachmentHandler struct {
+	collection     *mongo.Collection
+	todoCollection *mongo.Collection
+	store          storage.BlobStore
+	maxSize        int64         // Largest accepted upload in bytes
+	allowedTypes   []string      // Accepted content types; "type/*" matches a family
+	urlTTL         time.Duration // Lifetime of signed download URLs
+}
+
+// NewAttachmentHandler creates a new handler for todo attachments.
+func NewAttachmentHandler(collection *mongo.Collection, todoCollection *mongo.Collection, store storage.BlobStore, maxSize int64, allowedTypes []string, urlTTL time.Duration) *AttachmentHandler {
+	return &AttachmentHandler{
+		collection:     collection,
+		todoCollection: todoCollection,
+		store:          store,
+		maxSize:        maxSize,
+		allowedTypes:   allowedTypes,
+		urlTTL:         urlTTL,
+	}
+}
+
+// UploadAttachment godoc
+// @Summary      Attach a file to a todo
+// @Description  Uploads a file as multipart/form-data (field "file"). The content type is detected from the file itself and must be in the allowed list.
+// @Tags         attachments
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path     string true "Todo ID"
+// @Param        file formData file   true "File to attach"
+// @Success      201  {object}  models.Attachment
+// @Failure      400  {object}  map[string]string "Invalid ID format or missing file"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Todo not found"
+// @Failure      413  {object}  map[string]string "File too large"
+// @Failure      415  {object}  map[string]string "File type not allowed"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/attachments [post]
+func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
+	userID, todoID, ok := ownedTodo(c, h.todoCollection)
+	if !ok {
+		return
+	}
+
+	// Leave some room for the multipart envelope around the file itself.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxSize+1<<20)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large", "maxBytes": h.maxSize})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A file is required in the \"file\" field"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > h.maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large", "maxBytes": h.maxSize})
+		return
+	}
+
+	// Trust the file's content rather than the type claimed by the client.
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+	head = head[:n]
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !h.typeAllowed(contentType) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File type " + contentType + " is not allowed", "allowedTypes": h.allowedTypes})
+		return
+	}
+
+	attachment := models.Attachment{
+		ID:          primitive.NewObjectID(),
+		TodoID:      todoID,
+		UserID:      userID,
+		Filename:    cleanFilename(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		CreatedAt:   time.Now(),
+	}
+	attachment.StorageKey = userID.Hex() + "/" + todoID.Hex() + "/" + attachment.ID.Hex()
+
+	ctx := c.Request.Context()
+	body := io.MultiReader(bytes.NewReader(head), file)
+	if err := h.store.Put(ctx, attachment.StorageKey, body, header.Size, contentType); err != nil {
+		slog.Error("Failed to store attachment", "key", attachment.StorageKey, slog.Any("error", err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file"})
+		return
+	}
+
+	if _, err := h.collection.InsertOne(ctx, attachment); err != nil {
+		if delErr := h.store.Delete(context.Background(), attachment.StorageKey); delErr != nil {
+			slog.Error("Failed to remove orphaned attachment", "key", attachment.StorageKey, slog.Any("error", delErr))
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// GetAttachments godoc
+// @Summary      List a todo's attachments
+// @Description  Retrieves the files attached to a todo, oldest first
+// @Tags         attachments
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Todo ID"
+// @Success      200  {array}  models.Attachment
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Todo not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/attachments [get]
+func (h *AttachmentHandler) GetAttachments(c *gin.Context) {
+	userID, todoID, ok := ownedTodo(c, h.todoCollection)
+	if !ok {
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := h.collection.Find(context.Background(), bson.M{"todoId": todoID, "userId": userID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachments"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var attachments []models.Attachment
+	if err = cursor.All(context.Background(), &attachments); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode attachments"})
+		return
+	}
+
+	if attachments == nil {
+		attachments = []models.Attachment{}
+	}
+
+	c.JSON(http.StatusOK, attachments)
+}
+
+// GetAttachmentURL godoc
+// @Summary      Get a download link for an attachment
+// @Description  Returns a signed URL that allows downloading the file without authentication until it expires
+// @Tags         attachments
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id           path string true "Todo ID"
+// @Param        attachmentId path string true "Attachment ID"
+// @Success      200  {object}  map[string]interface{} "The attachment, its download url and when the url expires"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Attachment not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/attachments/{attachmentId} [get]
+func (h *AttachmentHandler) GetAttachmentURL(c *gin.Context) {
+	attachment, ok := h.findAttachment(c)
+	if !ok {
+		return
+	}
+
+	signed, err := h.store.SignedURL(attachment.StorageKey, storage.DownloadOptions{
+		Filename:    attachment.Filename,
+		ContentType: attachment.ContentType,
+		Expires:     h.urlTTL,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create download link"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attachment": attachment, "url": absoluteURL(c, signed), "expiresAt": time.Now().Add(h.urlTTL)})
+}
+
+// DeleteAttachment godoc
+// @Summary      Delete an attachment
+// @Description  Removes a file from a todo and from storage
+// @Tags         attachments
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id           path string true "Todo ID"
+// @Param        attachmentId path string true "Attachment ID"
+// @Success      200  {object}  map[string]string "{'message': 'Attachment deleted successfully'}"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Attachment not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/attachments/{attachmentId} [delete]
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	attachment, ok := h.findAttachment(c)
+	if !ok {
+		return
+	}
+
+	if err := h.DeleteAttachments(context.Background(), bson.M{"_id": attachment.ID}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete attachment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+// ServeBlob serves downloads for signed URLs issued by local storage. Other stores serve
+// their own URLs, so this responds with 404 for them.
+func (h *AttachmentHandler) ServeBlob(c *gin.Context) {
+	handler, ok := h.store.(http.Handler)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Route not found"})
+		return
+	}
+	handler.ServeHTTP(c.Writer, c.Request)
+}
+
+// DeleteAttachments removes the attachments matching filter, files first. Files that
+// can't be removed are logged and their records kept so that the deletion can be retried.
+func (h *AttachmentHandler) DeleteAttachments(ctx context.Context, filter bson.M) error {
+	opts := options.Find().SetProjection(bson.M{"storageKey": 1})
+	cursor, err := h.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	var attachments []models.Attachment
+	if err := cursor.All(ctx, &attachments); err != nil {
+		return err
+	}
+
+	var removed []primitive.ObjectID
+	var firstErr error
+	for _, attachment := range attachments {
+		if err := h.store.Delete(ctx, attachment.StorageKey); err != nil {
+			slog.Error("Failed to delete attachment file", "key", attachment.StorageKey, slog.Any("error", err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		removed = append(removed, attachment.ID)
+	}
+
+	if len(removed) > 0 {
+		if _, err := h.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": removed}}); err != nil {
+			return err
+		}
+	}
+	return firstErr
+}
+
+// deleteRecords removes the records of the attachments matching filter, returning the
+// storage keys of their files for deleteFiles to remove once ctx's transaction commits.
+func (h *AttachmentHandler) deleteRecords(ctx context.Context, filter bson.M) ([]string, error) {
+	opts := options.Find().SetProjection(bson.M{"storageKey": 1})
+	var attachments []models.Attachment
+	cursor, err := h.collection.Find(ctx, filter, opts)
+	if err == nil {
+		err = cursor.All(ctx, &attachments)
+	}
+	if err != nil || len(attachments) == 0 {
+		return nil, err
+	}
+
+	keys := make([]string, len(attachments))
+	for i, attachment := range attachments {
+		keys[i] = attachment.StorageKey
+	}
+	if _, err := h.collection.DeleteMany(ctx, filter); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// deleteFiles removes attachment files whose records are gone. A file that can't be
+// removed is only logged, as nothing refers to it any more.
+func (h *AttachmentHandler) deleteFiles(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := h.store.Delete(ctx, key); err != nil {
+			slog.Error("Failed to delete attachment file", "key", key, slog.Any("error", err))
+		}
+	}
+}
+
+// typeAllowed reports whether a content type is in the allowed list.
+func (h *AttachmentHandler) typeAllowed(contentType string) bool {
+	for _, allowed := range h.allowedTypes {
+		if allowed == contentType {
+			return true
+		}
+		if family, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(contentType, family+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// findAttachment loads the attachment named by the :id and :attachmentId parameters,
+// writing an error response and returning false if it can't.
+func (h *AttachmentHandler) findAttachment(c *gin.Context) (models.Attachment, bool) {
+	var attachment models.Attachment
+
+	userID, todoID, ok := ownedTodo(c, h.todoCollection)
+	if !ok {
+		return attachment, false
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("attachmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid attachment ID format"})
+		return attachment, false
+	}
+
+	err = h.collection.FindOne(context.Background(), bson.M{"_id": id, "todoId": todoID, "userId": userID}).Decode(&attachment)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
+			return attachment, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch attachment"})
+		return attachment, false
+	}
+
+	return attachment, true
+}
+
+// absoluteURL resolves a signed download URL against the request's host. Local storage
+// returns links relative to this API; other stores' links are returned unchanged.
+func absoluteURL(c *gin.Context, signed string) string {
+	if !strings.HasPrefix(signed, "/") {
+		return signed
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || strings.HasPrefix(c.Request.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + signed
+}
+
+// cleanFilename strips any path from an uploaded file's name and bounds its length.
+func cleanFilename(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		name = "file"
+	}
+	if len(name) > maxFilenameLength {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:maxFilenameLength-len(ext)], "") + ext
+	}
+	return name
+}
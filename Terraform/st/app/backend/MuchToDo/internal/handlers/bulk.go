@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// bulkRun tracks the in-memory state of every todo touched by a bulk request, so that
+// later operations see the effect of earlier ones before anything is written.
+type bulkRun struct {
+	userID  primitive.ObjectID
+	now     time.Time // The user's current time, for relative days in filters
+	state   map[primitive.ObjectID]*models.Todo
+	before  map[primitive.ObjectID]models.Todo // State before the request, for undo
+	version map[primitive.ObjectID]int         // Last history version written, for undo
+	order   []primitive.ObjectID               // Touched todos in first-touched order
+}
+
+// BulkTodos godoc
+// @Summary      Run several todo operations at once
+// @Description  Applies a list of operations (complete, uncomplete, delete, move, addLabel, removeLabel) to todos selected by ID or by filter.
+// @Description  All writes are executed with a single BulkWrite inside a transaction; either every change is applied or none is.
+// @Description  Filters are evaluated against the todos as stored before the request. Each affected todo is reported individually.
+// @Description  A filter matching more than 1000 todos, or operations touching more than 1000 different todos in all, are rejected with 400, and nothing is applied.
+// @Tags         todos
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        operations body models.BulkRequestDTO true "Operations to apply"
+// @Success      200  {object}  map[string]interface{} "Per-item results and an undo token"
+// @Header       200  {string}  X-Undo-Token "Token for POST /undo/{token}"
+// @Failure      400  {object}  map[string]interface{} "Invalid input, or operations touching too many todos"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/bulk [post]
+func (h *TodoHandler) BulkTodos(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var dto models.BulkRequestDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	now := userNow(c)
+	named := make(map[string]bool)
+	for _, op := range dto.Operations {
+		for _, id := range op.IDs {
+			named[id] = true
+		}
+		if (len(op.IDs) > 0) == (op.Filter != nil) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Each operation needs either ids or a filter"})
+			return
+		}
+		if op.Filter != nil {
+			if _, err := todoFilterQuery(userID, *op.Filter, now); err != nil {
+				respondFilterError(c, err)
+				return
+			}
+		}
+		if (op.Action == models.BulkActionAddLabel || op.Action == models.BulkActionRemoveLabel) && strings.TrimSpace(op.Label) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A label is required for " + op.Action})
+			return
+		}
+	}
+
+	// Filters can only be counted once the transaction reads them, but IDs can be now.
+	if len(named) > maxBulkTodos {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + errBulkTooManyTodos.Error()})
+		return
+	}
+
+	var results []models.BulkItemResult
+	var undoToken string
+	err = h.withTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		// The callback may be retried, so start from a clean slate each time.
+		results = []models.BulkItemResult{}
+		run := &bulkRun{
+			userID:  userID,
+			now:     now,
+			state:   make(map[primitive.ObjectID]*models.Todo),
+			before:  make(map[primitive.ObjectID]models.Todo),
+			version: make(map[primitive.ObjectID]int),
+		}
+
+		// Every operation's todos are loaded before anything is written, so that a
+		// request touching too many todos in all is refused without doing any work.
+		targets := make([][]*models.Todo, len(dto.Operations))
+		missing := make([][]models.BulkItemResult, len(dto.Operations))
+		for i, op := range dto.Operations {
+			var err error
+			targets[i], missing[i], err = h.resolveBulkTargets(sessCtx, run, op)
+			if errors.Is(err, errBulkFilterTooBroad) {
+				return bulkOperationError{operation: i, err: err}
+			}
+			if err != nil {
+				return err
+			}
+			if len(run.order) > maxBulkTodos {
+				return errBulkTooManyTodos
+			}
+		}
+
+		for _, op := range dto.Operations {
+			if op.Action == models.BulkActionComplete || op.Action == models.BulkActionUncomplete {
+				if err := h.lockGraph(sessCtx, userID); err != nil {
+					return err
+				}
+				break
+			}
+		}
+
+		var writes []mongo.WriteModel
+		for i, op := range dto.Operations {
+			for _, result := range missing[i] {
+				result.Operation = i
+				results = append(results, result)
+			}
+
+			for _, todo := range targets[i] {
+				if todo.DeletedAt != nil {
+					// Deleted by an earlier operation; named todos are reported as gone.
+					if op.Filter == nil {
+						results = append(results, models.BulkItemResult{Operation: i, ID: todo.ID.Hex(), Status: models.BulkStatusNotFound})
+					}
+					continue
+				}
+				status, update, err := h.applyBulkAction(sessCtx, run, todo, op)
+				if err != nil {
+					return err
+				}
+				results = append(results, models.BulkItemResult{Operation: i, ID: todo.ID.Hex(), Status: status})
+				if update == nil {
+					continue
+				}
+
+				writes = append(writes, mongo.NewUpdateOneModel().
+					SetFilter(bson.M{"_id": todo.ID, "userId": userID}).
+					SetUpdate(update))
+			}
+		}
+
+		if len(writes) == 0 {
+			return nil
+		}
+		if _, err := h.collection.BulkWrite(sessCtx, writes, options.BulkWrite().SetOrdered(true)); err != nil {
+			return err
+		}
+
+		items := make([]models.UndoItem, 0, len(run.order))
+		for _, id := range run.order {
+			if version, ok := run.version[id]; ok {
+				items = append(items, models.UndoItem{Before: run.before[id], Version: version})
+			}
+		}
+		token, err := h.saveUndo(sessCtx, userID, models.BulkUndoAction, items)
+		undoToken = token
+		return err
+	})
+	var opErr bulkOperationError
+	if errors.As(err, &opErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": opErr.Error(), "operation": opErr.operation})
+		return
+	}
+	if errors.Is(err, errBulkTooManyTodos) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply bulk operations"})
+		return
+	}
+
+	setUndoToken(c, undoToken)
+	c.JSON(http.StatusOK, gin.H{"results": results, "undoToken": undoToken})
+}
+
+// resolveBulkTargets loads the todos an operation applies to into the run's state.
+// IDs that are malformed or don't name one of the user's live todos are reported back.
+// Targets deleted by an earlier operation are left for the caller to skip.
+func (h *TodoHandler) resolveBulkTargets(ctx context.Context, run *bulkRun, op models.BulkOperationDTO) ([]*models.Todo, []models.BulkItemResult, error) {
+	var targets []*models.Todo
+	var missing []models.BulkItemResult
+
+	if op.Filter != nil {
+		filter, err := todoFilterQuery(run.userID, *op.Filter, run.now)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// One more than the cap is read to tell whether the filter matches too many.
+		var todos []models.Todo
+		cursor, err := h.collection.Find(ctx, filter, options.Find().SetLimit(maxBulkFilterMatches+1))
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := cursor.All(ctx, &todos); err != nil {
+			return nil, nil, err
+		}
+		if len(todos) > maxBulkFilterMatches {
+			return nil, nil, errBulkFilterTooBroad
+		}
+		for i := range todos {
+			targets = append(targets, run.track(todos[i]))
+		}
+		return targets, missing, nil
+	}
+
+	var ids, unknown []primitive.ObjectID
+	for _, raw := range op.IDs {
+		id, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			missing = append(missing, models.BulkItemResult{ID: raw, Status: models.BulkStatusInvalidID})
+			continue
+		}
+		ids = append(ids, id)
+		if _, ok := run.state[id]; !ok {
+			unknown = append(unknown, id)
+		}
+	}
+
+	if len(unknown) > 0 {
+		var todos []models.Todo
+		filter := bson.M{"_id": bson.M{"$in": unknown}, "userId": run.userID, "deletedAt": nil}
+		cursor, err := h.collection.Find(ctx, filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := cursor.All(ctx, &todos); err != nil {
+			return nil, nil, err
+		}
+		for i := range todos {
+			run.track(todos[i])
+		}
+	}
+
+	for _, id := range ids {
+		todo, ok := run.state[id]
+		if !ok {
+			missing = append(missing, models.BulkItemResult{ID: id.Hex(), Status: models.BulkStatusNotFound})
+			continue
+		}
+		targets = append(targets, todo)
+	}
+	return targets, missing, nil
+}
+
+// maxBulkFilterMatches caps how many todos a single filter-based operation may touch.
+// A filter matching more is rejected rather than applied to some of them.
+const maxBulkFilterMatches = 1000
+
+// maxBulkTodos caps how many different todos one bulk request may touch across all of
+// its operations, keeping its transaction, and the history and events it writes, bounded.
+const maxBulkTodos = 1000
+
+// errBulkTooManyTodos is returned for a request touching more than maxBulkTodos todos.
+var errBulkTooManyTodos = fmt.Errorf("the operations touch more than %d todos in all; split the request", maxBulkTodos)
+
+// errBulkFilterTooBroad is returned for a filter matching more than maxBulkFilterMatches todos.
+var errBulkFilterTooBroad = fmt.Errorf("the filter matches more than %d todos; narrow it down", maxBulkFilterMatches)
+
+// bulkOperationError is an error in one of a bulk request's operations, which the client
+// can fix.
+type bulkOperationError struct {
+	operation int // Index into the request's operations
+	err       error
+}
+
+func (e bulkOperationError) Error() string {
+	return fmt.Sprintf("Operation %d: %v", e.operation, e.err)
+}
+
+func (e bulkOperationError) Unwrap() error { return e.err }
+
+// track adds a todo loaded from the database to the run, unless the run already holds a
+// newer in-memory copy of it, and returns the tracked copy.
+func (r *bulkRun) track(todo models.Todo) *models.Todo {
+	if existing, ok := r.state[todo.ID]; ok {
+		return existing
+	}
+	copied := todo
+	r.state[todo.ID] = &copied
+	r.before[todo.ID] = cloneTodo(todo)
+	r.order = append(r.order, todo.ID)
+	return &copied
+}
+
+// applyBulkAction applies op to the in-memory todo, records the change in its history and
+// returns the item status and the update to send to MongoDB (nil if nothing changed).
+func (h *TodoHandler) applyBulkAction(ctx context.Context, run *bulkRun, todo *models.Todo, op models.BulkOperationDTO) (string, bson.M, error) {
+	if op.Action == models.BulkActionComplete && !todo.Completed {
+		blocked, err := h.blockedInRun(ctx, run, todo)
+		if err != nil {
+			return "", nil, err
+		}
+		if blocked {
+			return models.BulkStatusBlocked, nil, nil
+		}
+	}
+
+	before := cloneTodo(*todo)
+	update, action := bulkChange(todo, op, time.Now())
+	if update == nil {
+		return models.BulkStatusUnchanged, nil, nil
+	}
+
+	version, err := h.recordVersion(ctx, run.userID, action, &before, *todo)
+	if err != nil {
+		return "", nil, err
+	}
+	run.version[todo.ID] = version
+
+	return models.BulkStatusOK, update, nil
+}
+
+// bulkChange applies op to the in-memory todo and returns the update to send to MongoDB
+// and the history action to record, or a nil update if op leaves the todo as it is.
+func bulkChange(todo *models.Todo, op models.BulkOperationDTO, now time.Time) (bson.M, string) {
+	set := bson.M{"updatedAt": now}
+	update := bson.M{"$set": set}
+	action := models.TodoActionUpdated
+
+	switch op.Action {
+	case models.BulkActionComplete:
+		if todo.Completed {
+			return nil, ""
+		}
+		todo.Completed = true
+		todo.CompletedAt = &now
+		set["completed"] = true
+		set["completedAt"] = now
+
+	case models.BulkActionUncomplete:
+		if !todo.Completed {
+			return nil, ""
+		}
+		todo.Completed = false
+		todo.CompletedAt = nil
+		todo.ArchivedAt = nil // A reopened todo leaves the archive
+		set["completed"] = false
+		update["$unset"] = bson.M{"completedAt": "", "archivedAt": ""}
+
+	case models.BulkActionDelete:
+		todo.DeletedAt = &now
+		set["deletedAt"] = now
+		action = models.TodoActionDeleted
+
+	case models.BulkActionMove:
+		project := strings.TrimSpace(op.Project)
+		if todo.Project == project {
+			return nil, ""
+		}
+		todo.Project = project
+		if project != "" {
+			set["project"] = project
+		} else {
+			update["$unset"] = bson.M{"project": ""}
+		}
+
+	case models.BulkActionAddLabel:
+		label := strings.ToLower(strings.TrimSpace(op.Label))
+		for _, existing := range todo.Labels {
+			if existing == label {
+				return nil, ""
+			}
+		}
+		todo.Labels = append(todo.Labels, label)
+		update["$addToSet"] = bson.M{"labels": label}
+
+	case models.BulkActionRemoveLabel:
+		label := strings.ToLower(strings.TrimSpace(op.Label))
+		var kept []string
+		for _, existing := range todo.Labels {
+			if existing != label {
+				kept = append(kept, existing)
+			}
+		}
+		if len(kept) == len(todo.Labels) {
+			return nil, ""
+		}
+		todo.Labels = kept
+		update["$pull"] = bson.M{"labels": label}
+	}
+
+	todo.UpdatedAt = now
+	return update, action
+}
+
+// blockedInRun reports whether a todo still has incomplete blockers, taking into account
+// blockers completed earlier in the same bulk request.
+func (h *TodoHandler) blockedInRun(ctx context.Context, run *bulkRun, todo *models.Todo) (bool, error) {
+	var unknown []primitive.ObjectID
+	for _, blockerID := range todo.BlockedBy {
+		if blocker, ok := run.state[blockerID]; ok {
+			if !blocker.Completed && blocker.DeletedAt == nil {
+				return true, nil
+			}
+			continue
+		}
+		unknown = append(unknown, blockerID)
+	}
+	if len(unknown) == 0 {
+		return false, nil
+	}
+
+	open, err := h.incompleteTodoIDs(ctx, run.userID, unknown)
+	if err != nil {
+		return false, err
+	}
+	return len(open) > 0, nil
+}
+
+// cloneTodo copies a todo deeply enough that later in-memory edits don't leak into the copy.
+func cloneTodo(todo models.Todo) models.Todo {
+	clone := todo
+	clone.Labels = append([]string(nil), todo.Labels...)
+	clone.BlockedBy = append([]primitive.ObjectID(nil), todo.BlockedBy...)
+	return clone
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestBulk provides unit tests for how each bulk action changes a todo, for the state a
+// bulk request carries between its operations, and for the requests that are refused
+// before the database is touched.
+func TestBulk(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := primitive.NewObjectID()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	newRun := func() *bulkRun {
+		return &bulkRun{
+			userID:  userID,
+			now:     now,
+			state:   make(map[primitive.ObjectID]*models.Todo),
+			before:  make(map[primitive.ObjectID]models.Todo),
+			version: make(map[primitive.ObjectID]int),
+		}
+	}
+
+	t.Run("Changes", func(t *testing.T) {
+		todo := models.Todo{ID: primitive.NewObjectID(), Title: "Ship it", Project: "Work", Labels: []string{"urgent"}}
+
+		update, action := bulkChange(&todo, models.BulkOperationDTO{Action: models.BulkActionComplete}, now)
+		assert.Equal(t, models.TodoActionUpdated, action)
+		assert.Equal(t, bson.M{"$set": bson.M{"updatedAt": now, "completed": true, "completedAt": now}}, update)
+		assert.True(t, todo.Completed)
+		assert.Equal(t, now, *todo.CompletedAt)
+		assert.Equal(t, now, todo.UpdatedAt)
+
+		// Completing a completed todo, or moving a todo to its project, changes nothing.
+		update, _ = bulkChange(&todo, models.BulkOperationDTO{Action: models.BulkActionComplete}, now)
+		assert.Nil(t, update)
+		update, _ = bulkChange(&todo, models.BulkOperationDTO{Action: models.BulkActionMove, Project: " Work "}, now)
+		assert.Nil(t, update)
+
+		// A reopened todo leaves the archive.
+		todo.ArchivedAt = &now
+		update, _ = bulkChange(&todo, models.BulkOperationDTO{Action: models.BulkActionUncomplete}, now)
+		assert.Equal(t, bson.M{"completedAt": "", "archivedAt": ""}, update["$unset"])
+		assert.False(t, todo.Completed)
+		assert.Nil(t, todo.CompletedAt)
+		assert.Nil(t, todo.ArchivedAt)
+		update, _ = bulkChange(&todo, models.BulkOperationDTO{Action: models.BulkActionUncomplete}, now)
+		assert.Nil(t, update)
+
+		update, _ = bulkChange(&todo, models.BulkOperationDTO{Action: models.BulkActionMove, Project: "Home"}, now)
+		assert.Equal(t, "Home", update["$set"].(bson.M)["project"])
+		assert.Equal(t, "Home", todo.Project)
+		update, _ = bulkChange(&todo, models.BulkOperationDTO{Action: models.BulkActionMove}, now)
+		assert.Equal(t, bson.M{"project": ""}, update["$unset"])
+		assert.Empty(t, todo.Project)
+
+		// Labels are normalised like everywhere else.
+		update, _ = bulkChange(&todo, models.BulkOperationDTO{Action: models.BulkActionAddLabel, Label: " Home "}, now)
+		assert.Equal(t, bson.M{"labels": "home"}, update["$addToSet"])
+		assert.Equal(t, []string{"urgent", "home"}, todo.Labels)
+		update, _ = bulkChange(&todo, models.BulkOperationDTO{Action: models.BulkActionAddLabel, Label: "URGENT"}, now)
+		assert.Nil(t, update)
+		update, _ = bulkChange(&todo, models.BulkOperationDTO{Action: models.BulkActionRemoveLabel, Label: "Urgent"}, now)
+		assert.Equal(t, bson.M{"labels": "urgent"}, update["$pull"])
+		assert.Equal(t, []string{"home"}, todo.Labels)
+		update, _ = bulkChange(&todo, models.BulkOperationDTO{Action: models.BulkActionRemoveLabel, Label: "urgent"}, now)
+		assert.Nil(t, update)
+
+		update, action = bulkChange(&todo, models.BulkOperationDTO{Action: models.BulkActionDelete}, now)
+		assert.Equal(t, models.TodoActionDeleted, action)
+		assert.Equal(t, now, update["$set"].(bson.M)["deletedAt"])
+		assert.Equal(t, now, *todo.DeletedAt)
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		run := newRun()
+		todo := models.Todo{ID: primitive.NewObjectID(), Title: "Ship it", Labels: []string{"work"}}
+		tracked := run.track(todo)
+		tracked.Title = "Ship it today"
+		tracked.Labels[0] = "home"
+
+		// Later operations keep seeing the in-memory copy; undo keeps the original.
+		assert.Same(t, tracked, run.track(todo))
+		assert.Equal(t, "Ship it today", run.state[todo.ID].Title)
+		assert.Equal(t, "Ship it", run.before[todo.ID].Title)
+		assert.Equal(t, []string{"work"}, run.before[todo.ID].Labels)
+		assert.Equal(t, []primitive.ObjectID{todo.ID}, run.order)
+	})
+
+	t.Run("Blocked", func(t *testing.T) {
+		h := &TodoHandler{}
+		run := newRun()
+		blocker := run.track(models.Todo{ID: primitive.NewObjectID(), Title: "Test it"})
+		todo := run.track(models.Todo{ID: primitive.NewObjectID(), Title: "Ship it", BlockedBy: []primitive.ObjectID{blocker.ID}})
+
+		blocked, err := h.blockedInRun(t.Context(), run, todo)
+		assert.NoError(t, err)
+		assert.True(t, blocked)
+		status, update, err := h.applyBulkAction(t.Context(), run, todo, models.BulkOperationDTO{Action: models.BulkActionComplete})
+		assert.NoError(t, err)
+		assert.Equal(t, models.BulkStatusBlocked, status)
+		assert.Nil(t, update)
+		assert.False(t, todo.Completed)
+
+		// Blockers completed or deleted earlier in the request no longer block.
+		blocker.Completed = true
+		blocked, err = h.blockedInRun(t.Context(), run, todo)
+		assert.NoError(t, err)
+		assert.False(t, blocked)
+		blocker.Completed = false
+		blocker.DeletedAt = &now
+		blocked, err = h.blockedInRun(t.Context(), run, todo)
+		assert.NoError(t, err)
+		assert.False(t, blocked)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		id := primitive.NewObjectID().Hex()
+		tooManyIDs := `"` + strings.TrimSuffix(strings.Repeat(id+`","`, 501), `","`) + `"`
+		tooManyOps := strings.TrimSuffix(strings.Repeat(fmt.Sprintf(`{"action": "complete", "ids": ["%s"]}, `, id), 101), ", ")
+		// Three operations of 500 different IDs each name more todos than a request may touch.
+		var tooManyTodos []string
+		for range 3 {
+			ids := make([]string, 500)
+			for i := range ids {
+				ids[i] = `"` + primitive.NewObjectID().Hex() + `"`
+			}
+			tooManyTodos = append(tooManyTodos, `{"action": "complete", "ids": [`+strings.Join(ids, ", ")+`]}`)
+		}
+
+		h := &TodoHandler{}
+		for _, body := range []string{
+			`{"operations": []}`,
+			`{"operations": [{"action": "archive", "ids": ["` + id + `"]}]}`,
+			`{"operations": [{"action": "complete"}]}`,
+			`{"operations": [{"action": "complete", "ids": ["` + id + `"], "filter": {}}]}`,
+			`{"operations": [{"action": "complete", "filter": {"query": "due:<"}}]}`,
+			`{"operations": [{"action": "addLabel", "ids": ["` + id + `"], "label": " "}]}`,
+			`{"operations": [{"action": "removeLabel", "ids": ["` + id + `"]}]}`,
+			`{"operations": [{"action": "complete", "ids": [` + tooManyIDs + `]}]}`,
+			`{"operations": [` + tooManyOps + `]}`,
+			`{"operations": [` + strings.Join(tooManyTodos, ", ") + `]}`,
+		} {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userID", userID.Hex())
+			c.Request = httptest.NewRequest(http.MethodPost, "/tasks/bulk", bytes.NewBufferString(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			h.BulkTodos(c)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+	})
+
+	t.Run("Caps", func(t *testing.T) {
+		err := error(bulkOperationError{operation: 2, err: errBulkFilterTooBroad})
+		assert.Equal(t, "Operation 2: the filter matches more than 1000 todos; narrow it down", err.Error())
+		assert.ErrorIs(t, err, errBulkFilterTooBroad)
+
+		assert.Equal(t, "the operations touch more than 1000 todos in all; split the request", errBulkTooManyTodos.Error())
+
+		var opErr bulkOperationError
+		assert.True(t, errors.As(fmt.Errorf("transaction: %w", err), &opErr))
+		assert.Equal(t, 2, opErr.operation)
+	})
+}
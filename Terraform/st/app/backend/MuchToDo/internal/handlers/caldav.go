@@ -0,0 +1,1160 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/dav"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/ical"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+const (
+	caldavPrefix          = "/dav"
+	caldavCollection      = "tasks" // The one calendar collection each user has
+	caldavMethods         = "OPTIONS, PROPFIND, REPORT, GET, HEAD, PUT, DELETE"
+	caldavContentType     = "text/calendar; charset=utf-8; component=VTODO"
+	caldavSyncTokenPrefix = "urn:muchtodo:sync:"
+	caldavMaxBody         = 1 << 20 // Largest request body accepted
+	caldavMaxDepth        = 50      // Deepest chain of subtasks followed when checking for cycles
+)
+
+// Kinds of resources in the CalDAV tree.
+const (
+	caldavRootPath       = iota // /dav/
+	caldavPrincipalPath         // /dav/principals/<user>/
+	caldavHomePath              // /dav/calendars/<user>/
+	caldavCollectionPath        // /dav/calendars/<user>/tasks/
+	caldavResourcePath          // /dav/calendars/<user>/tasks/<name>
+)
+
+// Properties served over CalDAV.
+var (
+	davResourceType        = dav.Name(dav.NS, "resourcetype")
+	davDisplayName         = dav.Name(dav.NS, "displayname")
+	davCurrentUserPrinc    = dav.Name(dav.NS, "current-user-principal")
+	davPrincipalURL        = dav.Name(dav.NS, "principal-URL")
+	davOwner               = dav.Name(dav.NS, "owner")
+	davPrivilegeSet        = dav.Name(dav.NS, "current-user-privilege-set")
+	davSupportedReportSet  = dav.Name(dav.NS, "supported-report-set")
+	davSyncToken           = dav.Name(dav.NS, "sync-token")
+	davGetETag             = dav.Name(dav.NS, "getetag")
+	davGetContentType      = dav.Name(dav.NS, "getcontenttype")
+	davGetLastModified     = dav.Name(dav.NS, "getlastmodified")
+	calCalendarHomeSet     = dav.Name(dav.CalDAVNS, "calendar-home-set")
+	calSupportedComponents = dav.Name(dav.CalDAVNS, "supported-calendar-component-set")
+	calCalendarData        = dav.Name(dav.CalDAVNS, "calendar-data")
+	csGetCTag              = dav.Name(dav.CalendarServerNS, "getctag")
+)
+
+// caldavError is a request that violates a precondition of CalDAV or WebDAV, named by
+// condition.
+type caldavError struct {
+	condition xml.Name
+	message   string
+}
+
+func (e *caldavError) Error() string {
+	return e.message
+}
+
+func newCalDAVError(condition, message string) *caldavError {
+	return &caldavError{condition: dav.Name(dav.CalDAVNS, condition), message: message}
+}
+
+// caldavPath is a parsed path below /dav.
+type caldavPath struct {
+	kind   int    // One of the caldav*Path constants
+	userID string // Hex ID of the user the path belongs to; empty for the root
+	name   string // Resource name, for caldavResourcePath
+}
+
+// caldavTodo holds the fields of a todo read from a VTODO.
+type caldavTodo struct {
+	UID         string
+	Title       string
+	Description string
+	Project     *string // Nil if the client didn't send one
+	Labels      []string
+	DueDate     *time.Time
+	Priority    int
+	Completed   bool
+	CompletedAt *time.Time
+	Recurrence  *models.Recurrence
+	ParentUID   string
+}
+
+// CalDAVHandler serves a user's todos to CalDAV clients as a calendar collection of
+// VTODOs, for two-way sync. Clients sign in with an app password.
+type CalDAVHandler struct {
+	users *mongo.Collection
+	todos *TodoHandler
+}
+
+// NewCalDAVHandler creates a new handler for CalDAV.
+func NewCalDAVHandler(users *mongo.Collection, todos *TodoHandler) *CalDAVHandler {
+	return &CalDAVHandler{
+		users: users,
+		todos: todos,
+	}
+}
+
+// WellKnown redirects clients discovering the service (RFC 6764) to the CalDAV root.
+func (h *CalDAVHandler) WellKnown(c *gin.Context) {
+	c.Redirect(http.StatusMovedPermanently, caldavPrefix+"/")
+}
+
+// Options advertises CalDAV support. It doesn't require authentication.
+func (h *CalDAVHandler) Options(c *gin.Context) {
+	c.Header("DAV", "1, 3, calendar-access")
+	c.Header("Allow", caldavMethods)
+	c.Status(http.StatusOK)
+}
+
+// Propfind serves the properties of the principal, the calendar collection and the todos
+// in it. Depth 1 includes the members of a collection.
+func (h *CalDAVHandler) Propfind(c *gin.Context) {
+	userID, path, ok := h.resolve(c)
+	if !ok {
+		return
+	}
+	req, err := dav.ParsePropfind(io.LimitReader(c.Request.Body, caldavMaxBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	depth := c.GetHeader("Depth") // "0", "1" or "infinity", which is served like "1"
+
+	ctx := c.Request.Context()
+	loc := userPreferences(c).Location()
+	var responses []dav.Response
+	switch path.kind {
+	case caldavRootPath:
+		props := []dav.Property{
+			dav.Element(davResourceType, dav.Element(dav.Name(dav.NS, "collection"))),
+			dav.Href(davCurrentUserPrinc, caldavPrincipalHref(userID)),
+		}
+		responses = append(responses, dav.PropResponse(caldavPrefix+"/", props, req.Props))
+
+	case caldavPrincipalPath:
+		var user models.User
+		if err := h.users.FindOne(ctx, bson.M{"_id": userID}, options.FindOne().SetProjection(bson.M{"username": 1})).Decode(&user); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		props := []dav.Property{
+			dav.Element(davResourceType, dav.Element(dav.Name(dav.NS, "principal"))),
+			dav.Text(davDisplayName, user.Username),
+			dav.Href(davCurrentUserPrinc, caldavPrincipalHref(userID)),
+			dav.Href(davPrincipalURL, caldavPrincipalHref(userID)),
+			dav.Href(calCalendarHomeSet, caldavHomeHref(userID)),
+		}
+		responses = append(responses, dav.PropResponse(caldavPrincipalHref(userID), props, req.Props))
+
+	case caldavHomePath:
+		props := []dav.Property{
+			dav.Element(davResourceType, dav.Element(dav.Name(dav.NS, "collection"))),
+			dav.Href(davCurrentUserPrinc, caldavPrincipalHref(userID)),
+			dav.Href(davOwner, caldavPrincipalHref(userID)),
+		}
+		responses = append(responses, dav.PropResponse(caldavHomeHref(userID), props, req.Props))
+		if depth != "0" {
+			collection, err := h.collectionResponse(ctx, userID, req)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tasks"})
+				return
+			}
+			responses = append(responses, collection)
+		}
+
+	case caldavCollectionPath:
+		collection, err := h.collectionResponse(ctx, userID, req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tasks"})
+			return
+		}
+		responses = append(responses, collection)
+		if depth != "0" {
+			todos, err := h.findTodos(ctx, caldavMemberFilter(userID))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tasks"})
+				return
+			}
+			members, err := h.resourceResponses(ctx, userID, todos, req, loc)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tasks"})
+				return
+			}
+			responses = append(responses, members...)
+		}
+
+	case caldavResourcePath:
+		todo, err := h.findResource(ctx, userID, path.name)
+		if err != nil {
+			respondResourceError(c, err)
+			return
+		}
+		members, err := h.resourceResponses(ctx, userID, []models.Todo{todo}, req, loc)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load task"})
+			return
+		}
+		responses = append(responses, members...)
+	}
+
+	writeMultistatus(c, dav.Multistatus{Responses: responses})
+}
+
+// Report serves the calendar-query, calendar-multiget and sync-collection reports on the
+// calendar collection.
+func (h *CalDAVHandler) Report(c *gin.Context) {
+	userID, path, ok := h.resolve(c)
+	if !ok {
+		return
+	}
+	report, err := dav.ParseReport(io.LimitReader(c.Request.Body, caldavMaxBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if path.kind != caldavCollectionPath {
+		writeDAVError(c, http.StatusForbidden, dav.Name(dav.NS, "supported-report"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	loc := userPreferences(c).Location()
+	var ms dav.Multistatus
+	switch report.Type {
+	case dav.CalendarQuery:
+		// Every member is a VTODO, so the query either matches all of them or none.
+		var todos []models.Todo
+		if caldavQueryMatches(report.Components) {
+			todos, err = h.findTodos(ctx, caldavMemberFilter(userID))
+		}
+		if err == nil {
+			ms.Responses, err = h.resourceResponses(ctx, userID, todos, report.Propfind, loc)
+		}
+
+	case dav.CalendarMultiget:
+		ms.Responses, err = h.multiget(ctx, userID, report, loc)
+
+	case dav.SyncCollection:
+		ms, err = h.syncCollection(ctx, userID, report, loc)
+
+	default:
+		writeDAVError(c, http.StatusForbidden, dav.Name(dav.NS, "supported-report"))
+		return
+	}
+	if err != nil {
+		var davErr *caldavError
+		if errors.As(err, &davErr) {
+			writeDAVError(c, http.StatusForbidden, davErr.condition)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tasks"})
+		return
+	}
+
+	writeMultistatus(c, ms)
+}
+
+// Get serves a todo as an iCalendar object with a single VTODO.
+func (h *CalDAVHandler) Get(c *gin.Context) {
+	userID, path, ok := h.resolve(c)
+	if !ok {
+		return
+	}
+	if path.kind != caldavResourcePath {
+		c.Header("Allow", "OPTIONS, PROPFIND, REPORT")
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Only tasks can be downloaded"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	todo, err := h.findResource(ctx, userID, path.name)
+	if err != nil {
+		respondResourceError(c, err)
+		return
+	}
+	parents, err := h.todos.parentUIDs(ctx, userID, []models.Todo{todo})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load task"})
+		return
+	}
+
+	loc := userPreferences(c).Location()
+	c.Header("ETag", caldavETag(todo, loc))
+	c.Header("Last-Modified", todo.UpdatedAt.UTC().Format(http.TimeFormat))
+	c.Data(http.StatusOK, caldavContentType, []byte(caldavData(todo, parents, loc)))
+}
+
+// Put creates or replaces a todo from an iCalendar object with a single VTODO. Fields
+// iCalendar can't express, such as blockers, are left alone.
+func (h *CalDAVHandler) Put(c *gin.Context) {
+	userID, path, ok := h.resolve(c)
+	if !ok {
+		return
+	}
+	if path.kind != caldavResourcePath {
+		c.Header("Allow", "OPTIONS, PROPFIND, REPORT")
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Only tasks can be uploaded"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	prefs := userPreferences(c)
+	loc := prefs.Location()
+	cal, err := ical.Decode(io.LimitReader(c.Request.Body, caldavMaxBody))
+	if err != nil {
+		writeDAVError(c, http.StatusForbidden, dav.Name(dav.CalDAVNS, "valid-calendar-data"))
+		return
+	}
+	fields, err := parseCalDAVTodo(cal, loc)
+	if err != nil {
+		var davErr *caldavError
+		if errors.As(err, &davErr) {
+			writeDAVError(c, http.StatusForbidden, davErr.condition)
+			return
+		}
+		writeDAVError(c, http.StatusForbidden, dav.Name(dav.CalDAVNS, "valid-calendar-data"))
+		return
+	}
+
+	existing, err := h.findResource(ctx, userID, path.name)
+	exists := err == nil
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load task"})
+		return
+	}
+	if !caldavPreconditions(c, exists, caldavETag(existing, loc)) {
+		c.Status(http.StatusPreconditionFailed)
+		return
+	}
+
+	// A UID identifies one todo, and a todo keeps its UID.
+	if exists && todoUID(existing) != fields.UID {
+		writeDAVError(c, http.StatusForbidden, dav.Name(dav.CalDAVNS, "no-uid-conflict"))
+		return
+	}
+	other, err := h.findByUID(ctx, userID, fields.UID)
+	if err == nil && (!exists || other.ID != existing.ID) {
+		writeDAVError(c, http.StatusForbidden, dav.Name(dav.CalDAVNS, "no-uid-conflict"))
+		return
+	}
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load task"})
+		return
+	}
+
+	// Subtasks may arrive before their parent, in which case the link is made when the
+	// subtask is next uploaded.
+	var parentID *primitive.ObjectID
+	if fields.ParentUID != "" && fields.ParentUID != fields.UID {
+		parent, err := h.findByUID(ctx, userID, fields.ParentUID)
+		if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load task"})
+			return
+		}
+		if err == nil {
+			parentID = &parent.ID
+		}
+	}
+
+	if !exists {
+		h.createResource(c, userID, path.name, fields, parentID, prefs)
+		return
+	}
+	h.updateResource(c, userID, existing, fields, parentID)
+}
+
+// createResource stores a todo a client uploaded under a new name.
+func (h *CalDAVHandler) createResource(c *gin.Context, userID primitive.ObjectID, name string, fields caldavTodo, parentID *primitive.ObjectID, prefs models.Preferences) {
+	now := time.Now()
+	todo := models.Todo{
+		ID:          primitive.NewObjectID(),
+		UserID:      userID,
+		Title:       fields.Title,
+		Description: fields.Description,
+		Project:     prefs.DefaultProject,
+		Labels:      normalizeLabels(fields.Labels),
+		DueDate:     fields.DueDate,
+		Priority:    fields.Priority,
+		Recurrence:  fields.Recurrence,
+		ParentID:    parentID,
+		Completed:   fields.Completed,
+		ICalUID:     fields.UID,
+		DAVName:     name,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if fields.Project != nil {
+		todo.Project = strings.TrimSpace(*fields.Project)
+	}
+	if fields.Completed {
+		todo.CompletedAt = fields.CompletedAt
+		if todo.CompletedAt == nil {
+			todo.CompletedAt = &now
+		}
+	}
+
+	if err := h.todos.insertTodo(c.Request.Context(), &todo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
+		return
+	}
+	c.Header("ETag", caldavETag(todo, userPreferences(c).Location()))
+	c.Status(http.StatusCreated)
+}
+
+// updateResource applies an uploaded VTODO to an existing todo.
+func (h *CalDAVHandler) updateResource(c *gin.Context, userID primitive.ObjectID, existing models.Todo, fields caldavTodo, parentID *primitive.ObjectID) {
+	ctx := c.Request.Context()
+	set := bson.D{
+		{Key: "title", Value: fields.Title},
+		{Key: "description", Value: fields.Description},
+	}
+	unset := bson.D{}
+	setOrUnset := func(key string, value interface{}, present bool) {
+		if present {
+			set = append(set, bson.E{Key: key, Value: value})
+		} else {
+			unset = append(unset, bson.E{Key: key, Value: ""})
+		}
+	}
+
+	if fields.Project != nil {
+		project := strings.TrimSpace(*fields.Project)
+		setOrUnset("project", project, project != "")
+	}
+	labels := normalizeLabels(fields.Labels)
+	setOrUnset("labels", labels, len(labels) > 0)
+	setOrUnset("dueDate", fields.DueDate, fields.DueDate != nil)
+	setOrUnset("priority", fields.Priority, fields.Priority > 0)
+	setOrUnset("recurrence", fields.Recurrence, fields.Recurrence != nil)
+
+	switch {
+	case parentID != nil:
+		cycle, err := h.createsParentCycle(ctx, userID, existing.ID, *parentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load task"})
+			return
+		}
+		if !cycle {
+			set = append(set, bson.E{Key: "parentId", Value: *parentID})
+		}
+	case fields.ParentUID == "":
+		unset = append(unset, bson.E{Key: "parentId", Value: ""})
+	}
+
+	completing := fields.Completed && !existing.Completed
+	switch {
+	case completing:
+		completedAt := time.Now()
+		if fields.CompletedAt != nil {
+			completedAt = *fields.CompletedAt
+		}
+		set = append(set, bson.E{Key: "completed", Value: true}, bson.E{Key: "completedAt", Value: completedAt})
+	case !fields.Completed && existing.Completed:
+		// A reopened todo leaves the archive, which only holds completed todos.
+		set = append(set, bson.E{Key: "completed", Value: false})
+		unset = append(unset, bson.E{Key: "completedAt", Value: ""}, bson.E{Key: "archivedAt", Value: ""})
+	}
+	set = append(set, bson.E{Key: "updatedAt", Value: primitive.NewDateTimeFromTime(time.Now())})
+
+	changes := bson.D{{Key: "$set", Value: set}}
+	if len(unset) > 0 {
+		changes = append(changes, bson.E{Key: "$unset", Value: unset})
+	}
+	filter := bson.M{"_id": existing.ID, "userId": userID, "deletedAt": nil}
+	var after models.Todo
+	err := h.todos.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		if fields.Completed != existing.Completed {
+			if err := h.todos.lockGraph(sessCtx, userID); err != nil {
+				return err
+			}
+		}
+		if completing {
+			// A todo can't be completed while any of its blockers are still open.
+			var current models.Todo
+			if err := h.todos.collection.FindOne(sessCtx, filter).Decode(&current); err != nil {
+				return err
+			}
+			if err := h.todos.checkUnblocked(sessCtx, userID, current); err != nil {
+				return err
+			}
+		}
+		var err error
+		after, _, err = h.todos.applyMutation(sessCtx, userID, filter, changes, models.TodoActionUpdated)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+		if respondBlocked(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
+		return
+	}
+	c.Header("ETag", caldavETag(after, userPreferences(c).Location()))
+	c.Status(http.StatusNoContent)
+}
+
+// Delete moves a todo to the trash, like deleting it in the app.
+func (h *CalDAVHandler) Delete(c *gin.Context) {
+	userID, path, ok := h.resolve(c)
+	if !ok {
+		return
+	}
+	if path.kind != caldavResourcePath {
+		c.Header("Allow", "OPTIONS, PROPFIND, REPORT")
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Only tasks can be deleted"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	todo, err := h.findResource(ctx, userID, path.name)
+	if err != nil {
+		respondResourceError(c, err)
+		return
+	}
+	if !caldavPreconditions(c, true, caldavETag(todo, userPreferences(c).Location())) {
+		c.Status(http.StatusPreconditionFailed)
+		return
+	}
+
+	now := primitive.NewDateTimeFromTime(time.Now())
+	filter := bson.M{"_id": todo.ID, "userId": userID, "deletedAt": nil}
+	update := bson.M{"$set": bson.M{"deletedAt": now, "updatedAt": now}}
+	if _, _, err := h.todos.mutateTodo(ctx, userID, filter, update, models.TodoActionDeleted); err != nil {
+		respondResourceError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// resolve reads the authenticated user and the requested path, writing an error
+// response and returning false if the path doesn't exist or belongs to another user.
+func (h *CalDAVHandler) resolve(c *gin.Context) (primitive.ObjectID, caldavPath, bool) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return userID, caldavPath{}, false
+	}
+	path, ok := parseCalDAVPath(c.Param("path"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return userID, path, false
+	}
+	if path.kind != caldavRootPath && path.userID != userID.Hex() {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only access your own tasks"})
+		return userID, path, false
+	}
+	return userID, path, true
+}
+
+// collectionResponse describes the calendar collection itself.
+func (h *CalDAVHandler) collectionResponse(ctx context.Context, userID primitive.ObjectID, req dav.Propfind) (dav.Response, error) {
+	lastChange, err := h.todos.lastChange(ctx, userID)
+	if err != nil {
+		return dav.Response{}, err
+	}
+	token := formatSyncToken(lastChange)
+
+	report := func(name xml.Name) dav.Property {
+		return dav.Element(dav.Name(dav.NS, "supported-report"), dav.Element(dav.Name(dav.NS, "report"), dav.Element(name)))
+	}
+	privilege := func(name string) dav.Property {
+		return dav.Element(dav.Name(dav.NS, "privilege"), dav.Element(dav.Name(dav.NS, name)))
+	}
+	props := []dav.Property{
+		dav.Element(davResourceType, dav.Element(dav.Name(dav.NS, "collection")), dav.Element(dav.Name(dav.CalDAVNS, "calendar"))),
+		dav.Text(davDisplayName, "MuchToDo"),
+		dav.Element(calSupportedComponents, dav.Property{
+			XMLName: dav.Name(dav.CalDAVNS, "comp"),
+			Attrs:   []xml.Attr{{Name: xml.Name{Local: "name"}, Value: ical.Todo}},
+		}),
+		dav.Element(davSupportedReportSet, report(dav.CalendarQuery), report(dav.CalendarMultiget), report(dav.SyncCollection)),
+		dav.Element(davPrivilegeSet, privilege("read"), privilege("write")),
+		dav.Href(davOwner, caldavPrincipalHref(userID)),
+		dav.Href(davCurrentUserPrinc, caldavPrincipalHref(userID)),
+		dav.Text(davSyncToken, token),
+		dav.Text(csGetCTag, token),
+	}
+	return dav.PropResponse(caldavCollectionHref(userID), props, req.Props), nil
+}
+
+// resourceResponses describes todos as members of the calendar collection. Their data
+// is only included when asked for by name.
+func (h *CalDAVHandler) resourceResponses(ctx context.Context, userID primitive.ObjectID, todos []models.Todo, req dav.Propfind, loc *time.Location) ([]dav.Response, error) {
+	withData := req.Props != nil && req.Wants(calCalendarData)
+	var parents map[primitive.ObjectID]string
+	if withData {
+		var err error
+		if parents, err = h.todos.parentUIDs(ctx, userID, todos); err != nil {
+			return nil, err
+		}
+	}
+
+	responses := make([]dav.Response, 0, len(todos))
+	for _, todo := range todos {
+		props := []dav.Property{
+			dav.Element(davResourceType),
+			dav.Text(davGetETag, caldavETag(todo, loc)),
+			dav.Text(davGetContentType, caldavContentType),
+			dav.Text(davGetLastModified, todo.UpdatedAt.UTC().Format(http.TimeFormat)),
+		}
+		if withData {
+			props = append(props, dav.Text(calCalendarData, caldavData(todo, parents, loc)))
+		}
+		responses = append(responses, dav.PropResponse(caldavResourceHref(userID, caldavName(todo)), props, req.Props))
+	}
+	return responses, nil
+}
+
+// multiget describes the todos a calendar-multiget report asks for by href.
+func (h *CalDAVHandler) multiget(ctx context.Context, userID primitive.ObjectID, report dav.Report, loc *time.Location) ([]dav.Response, error) {
+	var names []string
+	for _, href := range report.Hrefs {
+		if name, ok := caldavHrefName(userID, href); ok {
+			names = append(names, name)
+		}
+	}
+	todos, err := h.findResources(ctx, userID, names)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]models.Todo, len(todos))
+	for _, todo := range todos {
+		byName[caldavName(todo)] = todo
+	}
+
+	var found []models.Todo
+	var missing []dav.Response
+	for _, href := range report.Hrefs {
+		name, ok := caldavHrefName(userID, href)
+		todo, exists := byName[name]
+		if !ok || !exists {
+			missing = append(missing, dav.StatusResponse(href, http.StatusNotFound))
+			continue
+		}
+		found = append(found, todo)
+	}
+
+	responses, err := h.resourceResponses(ctx, userID, found, report.Propfind, loc)
+	if err != nil {
+		return nil, err
+	}
+	return append(responses, missing...), nil
+}
+
+// syncCollection reports the todos that changed since the sync token of a
+// sync-collection report (RFC 6578): changed todos with their properties, and deleted,
+// archived and purged ones as not found. Without a token, all todos are reported.
+func (h *CalDAVHandler) syncCollection(ctx context.Context, userID primitive.ObjectID, report dav.Report, loc *time.Location) (dav.Multistatus, error) {
+	// The token is read first, so that changes made while the report is being built
+	// are reported again next time rather than lost. Every change numbered up to it
+	// has committed, as changes commit in the order of their numbers.
+	lastChange, err := h.todos.lastChange(ctx, userID)
+	if err != nil {
+		return dav.Multistatus{}, err
+	}
+	ms := dav.Multistatus{SyncToken: formatSyncToken(lastChange)}
+	if report.SyncToken == ms.SyncToken {
+		return ms, nil
+	}
+
+	since, ok := parseSyncToken(report.SyncToken)
+	if !ok || since.Seq > lastChange.Seq {
+		return ms, &caldavError{condition: dav.Name(dav.NS, "valid-sync-token"), message: "Unknown sync token"}
+	}
+	// Tombstones expire, so changes since older tokens may be incomplete.
+	if since.Seq > 0 && since.ChangedAt.Before(time.Now().Add(-models.TombstoneRetention)) {
+		return ms, &caldavError{condition: dav.Name(dav.NS, "valid-sync-token"), message: "Sync token has expired"}
+	}
+
+	if since.Seq == 0 {
+		todos, err := h.findTodos(ctx, caldavMemberFilter(userID))
+		if err == nil {
+			ms.Responses, err = h.resourceResponses(ctx, userID, todos, report.Propfind, loc)
+		}
+		return ms, err
+	}
+
+	changedSince := bson.M{"userId": userID, "changeSeq": bson.M{"$gt": since.Seq}}
+	changed, err := h.findTodos(ctx, changedSince)
+	if err != nil {
+		return ms, err
+	}
+	var live []models.Todo
+	for _, todo := range changed {
+		if todo.DeletedAt != nil || todo.ArchivedAt != nil {
+			ms.Responses = append(ms.Responses, dav.StatusResponse(caldavResourceHref(userID, caldavName(todo)), http.StatusNotFound))
+		} else {
+			live = append(live, todo)
+		}
+	}
+	responses, err := h.resourceResponses(ctx, userID, live, report.Propfind, loc)
+	if err != nil {
+		return ms, err
+	}
+	ms.Responses = append(ms.Responses, responses...)
+
+	var tombstones []models.TodoTombstone
+	cursor, err := h.todos.tombstones.Find(ctx, changedSince)
+	if err == nil {
+		err = cursor.All(ctx, &tombstones)
+	}
+	if err != nil {
+		return ms, err
+	}
+	for _, tombstone := range tombstones {
+		todo := models.Todo{ID: tombstone.TodoID, DAVName: tombstone.DAVName}
+		ms.Responses = append(ms.Responses, dav.StatusResponse(caldavResourceHref(userID, caldavName(todo)), http.StatusNotFound))
+	}
+	return ms, nil
+}
+
+// findTodos returns the todos matching filter, oldest first.
+func (h *CalDAVHandler) findTodos(ctx context.Context, filter bson.M) ([]models.Todo, error) {
+	var todos []models.Todo
+	cursor, err := h.todos.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	err = cursor.All(ctx, &todos)
+	return todos, err
+}
+
+// findResources returns the members of the collection with the given resource names.
+func (h *CalDAVHandler) findResources(ctx context.Context, userID primitive.ObjectID, names []string) ([]models.Todo, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var ids []primitive.ObjectID
+	for _, name := range names {
+		if hexID, ok := strings.CutSuffix(name, ".ics"); ok {
+			if id, err := primitive.ObjectIDFromHex(hexID); err == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	filter := caldavMemberFilter(userID)
+	or := bson.A{bson.M{"davName": bson.M{"$in": names}}}
+	if len(ids) > 0 {
+		or = append(or, bson.M{"_id": bson.M{"$in": ids}, "davName": nil})
+	}
+	filter["$or"] = or
+	return h.findTodos(ctx, filter)
+}
+
+// findResource returns the member of the collection with the given resource name, or
+// mongo.ErrNoDocuments.
+func (h *CalDAVHandler) findResource(ctx context.Context, userID primitive.ObjectID, name string) (models.Todo, error) {
+	todos, err := h.findResources(ctx, userID, []string{name})
+	if err != nil {
+		return models.Todo{}, err
+	}
+	for _, todo := range todos {
+		if caldavName(todo) == name {
+			return todo, nil
+		}
+	}
+	return models.Todo{}, mongo.ErrNoDocuments
+}
+
+// findByUID returns the member of the collection with the given UID, or
+// mongo.ErrNoDocuments.
+func (h *CalDAVHandler) findByUID(ctx context.Context, userID primitive.ObjectID, uid string) (models.Todo, error) {
+	filter := caldavMemberFilter(userID)
+	or := bson.A{bson.M{"icalUid": uid}}
+	if hexID, ok := strings.CutSuffix(uid, "@muchtodo"); ok {
+		if id, err := primitive.ObjectIDFromHex(hexID); err == nil {
+			or = append(or, bson.M{"_id": id, "icalUid": nil})
+		}
+	}
+	filter["$or"] = or
+
+	var todo models.Todo
+	err := h.todos.collection.FindOne(ctx, filter).Decode(&todo)
+	return todo, err
+}
+
+// createsParentCycle reports whether making parentID the parent of todoID would make
+// the todo its own ancestor.
+func (h *CalDAVHandler) createsParentCycle(ctx context.Context, userID, todoID, parentID primitive.ObjectID) (bool, error) {
+	id := parentID
+	for depth := 0; depth < caldavMaxDepth; depth++ {
+		if id == todoID {
+			return true, nil
+		}
+		var parent models.Todo
+		opts := options.FindOne().SetProjection(bson.M{"parentId": 1})
+		err := h.todos.collection.FindOne(ctx, bson.M{"_id": id, "userId": userID}, opts).Decode(&parent)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if parent.ParentID == nil {
+			return false, nil
+		}
+		id = *parent.ParentID
+	}
+	return true, nil
+}
+
+// caldavMemberFilter matches the todos in a user's calendar collection: those that are
+// neither trashed nor archived.
+func caldavMemberFilter(userID primitive.ObjectID) bson.M {
+	return bson.M{"userId": userID, "deletedAt": nil, "archivedAt": nil}
+}
+
+// caldavQueryMatches reports whether a calendar-query filter, given as the component
+// names it descends through, matches VTODOs.
+func caldavQueryMatches(components []string) bool {
+	if len(components) > 0 && !strings.EqualFold(components[0], ical.Calendar) {
+		return false
+	}
+	return len(components) < 2 || strings.EqualFold(components[1], ical.Todo)
+}
+
+// parseCalDAVTodo reads the fields of a todo from an iCalendar object. The object must
+// hold a single VTODO; overrides of single occurrences of a recurring one are ignored.
+func parseCalDAVTodo(cal *ical.Component, loc *time.Location) (caldavTodo, error) {
+	var fields caldavTodo
+	if cal.Name != ical.Calendar {
+		return fields, newCalDAVError("valid-calendar-data", "Not a VCALENDAR")
+	}
+	for _, child := range cal.Components {
+		if child.Name != ical.Todo && child.Name != "VTIMEZONE" {
+			return fields, newCalDAVError("supported-calendar-component", "Only VTODO components are supported")
+		}
+	}
+
+	var vtodo *ical.Component
+	for _, child := range cal.Children(ical.Todo) {
+		if child.Prop("RECURRENCE-ID") == nil {
+			if vtodo != nil {
+				return fields, newCalDAVError("valid-calendar-object-resource", "Only one VTODO is allowed")
+			}
+			vtodo = child
+		}
+	}
+	if vtodo == nil {
+		return fields, newCalDAVError("valid-calendar-object-resource", "A VTODO is required")
+	}
+
+	if uid := vtodo.Prop("UID"); uid != nil {
+		fields.UID = strings.TrimSpace(uid.Value)
+	}
+	if fields.UID == "" {
+		return fields, newCalDAVError("valid-calendar-object-resource", "UID is required")
+	}
+	if summary := vtodo.Prop("SUMMARY"); summary != nil {
+		fields.Title = strings.TrimSpace(summary.Text())
+	}
+	if fields.Title == "" {
+		return fields, newCalDAVError("valid-calendar-object-resource", "SUMMARY is required")
+	}
+	if description := vtodo.Prop("DESCRIPTION"); description != nil {
+		fields.Description = description.Text()
+	}
+	if project := vtodo.Prop("X-MUCHTODO-PROJECT"); project != nil {
+		text := project.Text()
+		fields.Project = &text
+	}
+
+	due := vtodo.Prop("DUE")
+	if due == nil {
+		due = vtodo.Prop("DTSTART")
+	}
+	if due != nil {
+		t, _, err := due.Time(loc)
+		if err != nil {
+			return fields, err
+		}
+		fields.DueDate = &t
+	}
+
+	if priority := vtodo.Prop("PRIORITY"); priority != nil {
+		p, err := strconv.Atoi(strings.TrimSpace(priority.Value))
+		if err != nil {
+			return fields, err
+		}
+		fields.Priority = priorityFromICal(p)
+	}
+
+	completed := vtodo.Prop("COMPLETED")
+	if completed != nil {
+		t, _, err := completed.Time(loc)
+		if err != nil {
+			return fields, err
+		}
+		fields.CompletedAt = &t
+	}
+	status := vtodo.Prop("STATUS")
+	percent := vtodo.Prop("PERCENT-COMPLETE")
+	fields.Completed = completed != nil ||
+		(status != nil && strings.EqualFold(status.Value, "COMPLETED")) ||
+		(percent != nil && strings.TrimSpace(percent.Value) == "100")
+
+	for _, prop := range vtodo.Props {
+		switch prop.Name {
+		case "CATEGORIES":
+			fields.Labels = append(fields.Labels, prop.TextList()...)
+		case "RRULE":
+			if rule, err := ical.ParseRecur(prop.Value); err == nil {
+				fields.Recurrence = recurrenceFromICal(rule)
+			}
+		case "RELATED-TO":
+			if reltype := prop.Param("RELTYPE"); reltype == "" || strings.EqualFold(reltype, "PARENT") {
+				fields.ParentUID = strings.TrimSpace(prop.Value)
+			}
+		}
+	}
+	return fields, nil
+}
+
+// priorityFromICal maps an iCalendar priority, where 1 is the highest, 9 the lowest and
+// 0 undefined, to a todo priority. It reverses calendarPriorities.
+func priorityFromICal(p int) int {
+	switch {
+	case p < 1 || p > 9:
+		return 0
+	case p <= 2:
+		return 1
+	case p <= 4:
+		return 2
+	case p <= 6:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// recurrenceFromICal converts a recurrence rule into a todo's recurrence, or nil if the
+// rule repeats more often than daily. Parts a todo can't express, such as a second
+// weekday, are dropped.
+func recurrenceFromICal(rule ical.Recur) *models.Recurrence {
+	r := &models.Recurrence{Interval: min(rule.Interval, 365)}
+	switch rule.Freq {
+	case ical.FreqDaily:
+		r.Frequency = models.RecurrenceDaily
+	case ical.FreqWeekly:
+		r.Frequency = models.RecurrenceWeekly
+		if len(rule.ByDay) > 0 {
+			day := rule.ByDay[0]
+			for i, name := range ical.Weekdays {
+				if strings.HasSuffix(day, name) {
+					weekday := i
+					r.Weekday = &weekday
+				}
+			}
+		}
+	case ical.FreqMonthly:
+		r.Frequency = models.RecurrenceMonthly
+		if rule.ByMonthDay >= 1 && rule.ByMonthDay <= 31 {
+			r.MonthDay = rule.ByMonthDay
+		}
+	case ical.FreqYearly:
+		r.Frequency = models.RecurrenceYearly
+	default:
+		return nil
+	}
+	return normalizeRecurrence(r)
+}
+
+// caldavData renders a todo as an iCalendar object with a single VTODO. Its timestamp is
+// the time of its last change, so the data only changes when the todo does.
+func caldavData(todo models.Todo, parents map[primitive.ObjectID]string, loc *time.Location) string {
+	cal := ical.NewCalendar(calendarProdID)
+	cal.Append(todoVTodo(todo, parents, loc, todo.UpdatedAt))
+	var buf bytes.Buffer
+	cal.Encode(&buf)
+	return buf.String()
+}
+
+// caldavETag returns the entity tag of a todo's iCalendar object. The time zone is part
+// of it because all-day due dates are rendered in it.
+func caldavETag(todo models.Todo, loc *time.Location) string {
+	sum := sha1.Sum([]byte(todo.ID.Hex() + "|" + strconv.FormatInt(todo.UpdatedAt.UnixMilli(), 10) + "|" + loc.String()))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// caldavPreconditions evaluates the If-Match and If-None-Match headers against the
+// current entity tag of a resource, which is ignored if the resource doesn't exist.
+func caldavPreconditions(c *gin.Context, exists bool, etag string) bool {
+	if match := c.GetHeader("If-Match"); match != "" {
+		if !exists || !etagListMatches(match, etag) {
+			return false
+		}
+	}
+	if noneMatch := c.GetHeader("If-None-Match"); noneMatch != "" && exists {
+		if etagListMatches(noneMatch, etag) {
+			return false
+		}
+	}
+	return true
+}
+
+// etagListMatches reports whether an If-Match or If-None-Match header value, a list of
+// entity tags or "*", matches etag. Weak tags match their strong counterparts.
+func etagListMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// formatSyncToken encodes the user's change sequence as a sync token, which also serves
+// as the collection's CTag. The time of the last change only tells when the token
+// expires; changes are found by their number.
+func formatSyncToken(changes models.TodoChanges) string {
+	var millis int64
+	if !changes.ChangedAt.IsZero() {
+		millis = changes.ChangedAt.UnixMilli()
+	}
+	return caldavSyncTokenPrefix + strconv.FormatInt(changes.Seq, 10) + "-" + strconv.FormatInt(millis, 10)
+}
+
+// parseSyncToken decodes a sync token. An empty token, or one issued before the user's
+// todos ever changed, returns a zero sequence number.
+func parseSyncToken(token string) (models.TodoChanges, bool) {
+	var changes models.TodoChanges
+	if token == "" {
+		return changes, true
+	}
+	value, ok := strings.CutPrefix(token, caldavSyncTokenPrefix)
+	if !ok {
+		return changes, false
+	}
+	seqValue, millisValue, ok := strings.Cut(value, "-")
+	if !ok {
+		return changes, false
+	}
+	seq, err := strconv.ParseInt(seqValue, 10, 64)
+	if err != nil || seq < 0 {
+		return changes, false
+	}
+	millis, err := strconv.ParseInt(millisValue, 10, 64)
+	if err != nil || millis < 0 {
+		return changes, false
+	}
+	changes.Seq = seq
+	if millis > 0 {
+		changes.ChangedAt = time.UnixMilli(millis)
+	}
+	return changes, true
+}
+
+// parseCalDAVPath parses a path below /dav.
+func parseCalDAVPath(p string) (caldavPath, bool) {
+	trimmed := strings.Trim(p, "/")
+	if trimmed == "" {
+		return caldavPath{kind: caldavRootPath}, true
+	}
+	parts := strings.Split(trimmed, "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "principals":
+		return caldavPath{kind: caldavPrincipalPath, userID: parts[1]}, true
+	case len(parts) == 2 && parts[0] == "calendars":
+		return caldavPath{kind: caldavHomePath, userID: parts[1]}, true
+	case len(parts) == 3 && parts[0] == "calendars" && parts[2] == caldavCollection:
+		return caldavPath{kind: caldavCollectionPath, userID: parts[1]}, true
+	case len(parts) == 4 && parts[0] == "calendars" && parts[2] == caldavCollection && !strings.HasSuffix(p, "/"):
+		return caldavPath{kind: caldavResourcePath, userID: parts[1], name: parts[3]}, true
+	}
+	return caldavPath{}, false
+}
+
+// caldavHrefName returns the resource name an href in a report refers to, if it is a
+// member of the user's collection. Hrefs may be paths or full URLs.
+func caldavHrefName(userID primitive.ObjectID, href string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	path, ok := parseCalDAVPath(strings.TrimPrefix(u.Path, caldavPrefix))
+	if !ok || !strings.HasPrefix(u.Path, caldavPrefix+"/") || path.kind != caldavResourcePath || path.userID != userID.Hex() {
+		return "", false
+	}
+	return path.name, true
+}
+
+// caldavName returns the resource name of a todo: the one a client stored it under, or
+// one derived from its ID.
+func caldavName(todo models.Todo) string {
+	if todo.DAVName != "" {
+		return todo.DAVName
+	}
+	return todo.ID.Hex() + ".ics"
+}
+
+func caldavPrincipalHref(userID primitive.ObjectID) string {
+	return caldavPrefix + "/principals/" + userID.Hex() + "/"
+}
+
+func caldavHomeHref(userID primitive.ObjectID) string {
+	return caldavPrefix + "/calendars/" + userID.Hex() + "/"
+}
+
+func caldavCollectionHref(userID primitive.ObjectID) string {
+	return caldavHomeHref(userID) + caldavCollection + "/"
+}
+
+func caldavResourceHref(userID primitive.ObjectID, name string) string {
+	return caldavCollectionHref(userID) + url.PathEscape(name)
+}
+
+// respondResourceError writes the response for a todo that couldn't be loaded.
+func respondResourceError(c *gin.Context, err error) {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load task"})
+}
+
+// writeMultistatus writes a 207 Multi-Status response.
+func writeMultistatus(c *gin.Context, ms dav.Multistatus) {
+	body, err := dav.Marshal(ms)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode response"})
+		return
+	}
+	c.Data(http.StatusMultiStatus, "application/xml; charset=utf-8", body)
+}
+
+// writeDAVError writes a response for a request that failed a precondition.
+func writeDAVError(c *gin.Context, status int, condition xml.Name) {
+	body, err := dav.Marshal(dav.NewError(condition))
+	if err != nil {
+		c.Status(status)
+		return
+	}
+	c.Data(status, "application/xml; charset=utf-8", body)
+}
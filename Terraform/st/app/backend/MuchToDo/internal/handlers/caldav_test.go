@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/ical"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestCalDAV provides unit tests for mapping todos to and from CalDAV resources.
+func TestCalDAV(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	userID := primitive.NewObjectID()
+
+	decode := func(t *testing.T, lines ...string) *ical.Component {
+		cal, err := ical.Decode(strings.NewReader(strings.Join(lines, "\r\n") + "\r\n"))
+		assert.NoError(t, err)
+		return cal
+	}
+
+	t.Run("ParseTodo", func(t *testing.T) {
+		cal := decode(t,
+			"BEGIN:VCALENDAR",
+			"BEGIN:VTODO",
+			"UID:abc-123",
+			`SUMMARY:Pay rent\, on time`,
+			`DESCRIPTION:Line one\nLine two`,
+			"DUE;VALUE=DATE:20250314",
+			"PRIORITY:5",
+			"STATUS:COMPLETED",
+			"COMPLETED:20250313T080000Z",
+			"CATEGORIES:Bills,home",
+			"CATEGORIES:urgent",
+			"X-MUCHTODO-PROJECT:Household",
+			"RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO",
+			"RELATED-TO;RELTYPE=PARENT:parent-1",
+			"END:VTODO",
+			"END:VCALENDAR",
+		)
+		fields, err := parseCalDAVTodo(cal, loc)
+		assert.NoError(t, err)
+		assert.Equal(t, "abc-123", fields.UID)
+		assert.Equal(t, "Pay rent, on time", fields.Title)
+		assert.Equal(t, "Line one\nLine two", fields.Description)
+		assert.Equal(t, time.Date(2025, 3, 14, 0, 0, 0, 0, loc), *fields.DueDate)
+		assert.Equal(t, 3, fields.Priority)
+		assert.True(t, fields.Completed)
+		assert.Equal(t, time.Date(2025, 3, 13, 8, 0, 0, 0, time.UTC), *fields.CompletedAt)
+		assert.Equal(t, []string{"Bills", "home", "urgent"}, fields.Labels)
+		assert.Equal(t, "Household", *fields.Project)
+		monday := int(time.Monday)
+		assert.Equal(t, &models.Recurrence{Frequency: models.RecurrenceWeekly, Interval: 2, Weekday: &monday}, fields.Recurrence)
+		assert.Equal(t, "parent-1", fields.ParentUID)
+	})
+
+	t.Run("ParseTodoDefaults", func(t *testing.T) {
+		cal := decode(t,
+			"BEGIN:VCALENDAR",
+			"BEGIN:VTIMEZONE",
+			"TZID:Europe/Berlin",
+			"END:VTIMEZONE",
+			"BEGIN:VTODO",
+			"UID:abc-123",
+			"SUMMARY:Call Amy",
+			"DTSTART;TZID=Europe/Berlin:20250314T173000",
+			"PERCENT-COMPLETE:40",
+			"RRULE:FREQ=HOURLY",
+			"END:VTODO",
+			"BEGIN:VTODO",
+			"UID:abc-123",
+			"RECURRENCE-ID:20250321T173000Z",
+			"SUMMARY:Call Amy (moved)",
+			"END:VTODO",
+			"END:VCALENDAR",
+		)
+		fields, err := parseCalDAVTodo(cal, loc)
+		assert.NoError(t, err)
+		assert.Equal(t, "Call Amy", fields.Title)
+		assert.Equal(t, time.Date(2025, 3, 14, 16, 30, 0, 0, time.UTC), fields.DueDate.UTC())
+		assert.False(t, fields.Completed)
+		assert.Nil(t, fields.Project)
+		assert.Nil(t, fields.Recurrence)
+		assert.Zero(t, fields.Priority)
+	})
+
+	t.Run("ParseTodoErrors", func(t *testing.T) {
+		cases := map[string][]string{
+			"supported-calendar-component":   {"BEGIN:VCALENDAR", "BEGIN:VEVENT", "UID:1", "SUMMARY:Meeting", "END:VEVENT", "END:VCALENDAR"},
+			"valid-calendar-object-resource": {"BEGIN:VCALENDAR", "BEGIN:VTODO", "SUMMARY:No UID", "END:VTODO", "END:VCALENDAR"},
+			"valid-calendar-data":            {"BEGIN:VTODO", "UID:1", "SUMMARY:Bare", "END:VTODO"},
+		}
+		for condition, lines := range cases {
+			_, err := parseCalDAVTodo(decode(t, lines...), loc)
+			if assert.IsType(t, &caldavError{}, err, condition) {
+				assert.Equal(t, condition, err.(*caldavError).condition.Local)
+			}
+		}
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		due := time.Date(2025, 3, 14, 0, 0, 0, 0, loc)
+		weekday := int(time.Friday)
+		parentID := primitive.NewObjectID()
+		todo := models.Todo{
+			ID: primitive.NewObjectID(), UserID: userID, Title: "Water plants", Description: "All of them; twice",
+			Project: "home", Labels: []string{"garden", "weekly"}, DueDate: &due, Priority: 2, ParentID: &parentID,
+			Recurrence: &models.Recurrence{Frequency: models.RecurrenceWeekly, Interval: 1, Weekday: &weekday},
+			ICalUID:    "client-uid", UpdatedAt: time.Date(2025, 3, 12, 9, 0, 0, 0, time.UTC),
+		}
+		data := caldavData(todo, map[primitive.ObjectID]string{parentID: "parent-uid"}, loc)
+		assert.Contains(t, data, "DTSTAMP:20250312T090000Z\r\n")
+
+		cal, err := ical.Decode(strings.NewReader(data))
+		assert.NoError(t, err)
+		fields, err := parseCalDAVTodo(cal, loc)
+		assert.NoError(t, err)
+		assert.Equal(t, "client-uid", fields.UID)
+		assert.Equal(t, todo.Title, fields.Title)
+		assert.Equal(t, todo.Description, fields.Description)
+		assert.Equal(t, "home", *fields.Project)
+		assert.Equal(t, todo.Labels, fields.Labels)
+		assert.True(t, due.Equal(*fields.DueDate))
+		assert.Equal(t, todo.Priority, fields.Priority)
+		assert.Equal(t, todo.Recurrence, fields.Recurrence)
+		assert.Equal(t, "parent-uid", fields.ParentUID)
+	})
+
+	t.Run("Priority", func(t *testing.T) {
+		for priority, icalPriority := range calendarPriorities {
+			assert.Equal(t, priority, priorityFromICal(icalPriority))
+		}
+		assert.Zero(t, priorityFromICal(0))
+		assert.Equal(t, 4, priorityFromICal(7))
+	})
+
+	t.Run("Paths", func(t *testing.T) {
+		id := userID.Hex()
+		cases := map[string]caldavPath{
+			"/":                                   {kind: caldavRootPath},
+			"/principals/" + id + "/":             {kind: caldavPrincipalPath, userID: id},
+			"/calendars/" + id + "/":              {kind: caldavHomePath, userID: id},
+			"/calendars/" + id + "/tasks":         {kind: caldavCollectionPath, userID: id},
+			"/calendars/" + id + "/tasks/a b.ics": {kind: caldavResourcePath, userID: id, name: "a b.ics"},
+		}
+		for p, want := range cases {
+			got, ok := parseCalDAVPath(p)
+			assert.True(t, ok, p)
+			assert.Equal(t, want, got, p)
+		}
+		for _, p := range []string{"/calendars/" + id + "/other/", "/calendars/" + id + "/tasks/a.ics/", "/elsewhere/"} {
+			_, ok := parseCalDAVPath(p)
+			assert.False(t, ok, p)
+		}
+
+		href := caldavResourceHref(userID, "a b.ics")
+		assert.Equal(t, "/dav/calendars/"+id+"/tasks/a%20b.ics", href)
+		name, ok := caldavHrefName(userID, "https://example.com"+href)
+		assert.True(t, ok)
+		assert.Equal(t, "a b.ics", name)
+		_, ok = caldavHrefName(primitive.NewObjectID(), href)
+		assert.False(t, ok)
+	})
+
+	t.Run("SyncToken", func(t *testing.T) {
+		changes := models.TodoChanges{Seq: 42, ChangedAt: time.Date(2025, 3, 12, 9, 0, 0, 123e6, time.UTC)}
+		since, ok := parseSyncToken(formatSyncToken(changes))
+		assert.True(t, ok)
+		assert.Equal(t, int64(42), since.Seq)
+		assert.True(t, changes.ChangedAt.Equal(since.ChangedAt))
+
+		since, ok = parseSyncToken(formatSyncToken(models.TodoChanges{}))
+		assert.True(t, ok)
+		assert.Zero(t, since.Seq)
+		assert.True(t, since.ChangedAt.IsZero())
+
+		for _, token := range []string{"urn:other:1", "urn:muchtodo:sync:x", "urn:muchtodo:sync:5", "urn:muchtodo:sync:-5-0", "urn:muchtodo:sync:5-x"} {
+			_, ok := parseSyncToken(token)
+			assert.False(t, ok, token)
+		}
+	})
+
+	t.Run("AppPassword", func(t *testing.T) {
+		password, err := newAppPassword()
+		assert.NoError(t, err)
+		assert.Regexp(t, `^[a-z2-7]{4}(-[a-z2-7]{4}){4}$`, password)
+		assert.Equal(t, hashAppPassword(password), hashAppPassword(strings.ToUpper(strings.ReplaceAll(password, "-", " "))))
+		assert.NotEqual(t, hashAppPassword(password), hashAppPassword(password+"a"))
+	})
+
+	t.Run("Preconditions", func(t *testing.T) {
+		todo := models.Todo{ID: primitive.NewObjectID(), UpdatedAt: time.Now()}
+		etag := caldavETag(todo, loc)
+		assert.NotEqual(t, etag, caldavETag(todo, time.UTC))
+
+		check := func(exists bool, header, value string) bool {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPut, "/", nil)
+			if header != "" {
+				c.Request.Header.Set(header, value)
+			}
+			return caldavPreconditions(c, exists, etag)
+		}
+		assert.True(t, check(true, "", ""))
+		assert.True(t, check(true, "If-Match", `"other", `+etag))
+		assert.False(t, check(true, "If-Match", `"other"`))
+		assert.False(t, check(false, "If-Match", "*"))
+		assert.False(t, check(true, "If-None-Match", "*"))
+		assert.True(t, check(false, "If-None-Match", "*"))
+		assert.True(t, check(true, "If-None-Match", `"other"`))
+	})
+}
@@ -0,0 +1,429 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/dates"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/ical"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+const (
+	calendarProdID   = "-//MuchToDo//Todos//EN"
+	calendarHistory  = 90 * 24 * time.Hour // How long completed todos stay in the feed after they were due
+	calendarEventLen = 30 * time.Minute    // Length of the events shown for todos due at a specific time
+	calendarCacheAge = 5 * time.Minute     // How long clients may cache the feed
+)
+
+// calendarPriorities maps todo priorities to iCalendar ones, where 1 is the highest and 9 the lowest.
+var calendarPriorities = map[int]int{1: 1, 2: 3, 3: 5, 4: 9}
+
+// CalendarHandler serves the iCalendar feed of a user's todos and manages the secret
+// token in its URL.
+type CalendarHandler struct {
+	users *mongo.Collection
+	todos *TodoHandler
+}
+
+// NewCalendarHandler creates a new handler for calendar feeds.
+func NewCalendarHandler(users *mongo.Collection, todos *TodoHandler) *CalendarHandler {
+	return &CalendarHandler{
+		users: users,
+		todos: todos,
+	}
+}
+
+// GetCalendarFeed godoc
+// @Summary      Get the calendar feed status
+// @Description  Reports whether the user's calendar feed is enabled. The feed URL itself is only shown when the token is generated.
+// @Tags         calendar
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  models.CalendarFeed
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "User not found"
+// @Router       /users/me/calendar [get]
+func (h *CalendarHandler) GetCalendarFeed(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var user models.User
+	opts := options.FindOne().SetProjection(bson.M{"calendarToken": 1})
+	if err := h.users.FindOne(context.Background(), bson.M{"_id": userID}, opts).Decode(&user); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	feed := models.CalendarFeed{Enabled: user.Calendar != nil}
+	if user.Calendar != nil {
+		feed.CreatedAt = &user.Calendar.CreatedAt
+	}
+	c.JSON(http.StatusOK, feed)
+}
+
+// RegenerateCalendarToken godoc
+// @Summary      Generate a new calendar feed URL
+// @Description  Enables the calendar feed with a new secret URL. Any previous URL stops working, so use this to revoke a leaked one.
+// @Tags         calendar
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      201  {object}  models.CalendarFeed
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "User not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /users/me/calendar [post]
+func (h *CalendarHandler) RegenerateCalendarToken(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	token, err := newCalendarToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"calendarToken": models.CalendarToken{Hash: hashCalendarToken(token), CreatedAt: now}}}
+	result, err := h.users.UpdateOne(context.Background(), bson.M{"_id": userID}, update)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save token"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.CalendarFeed{
+		Enabled:   true,
+		URL:       requestBaseURL(c) + "/calendar/" + token + ".ics",
+		CreatedAt: &now,
+	})
+}
+
+// DisableCalendarFeed godoc
+// @Summary      Disable the calendar feed
+// @Description  Revokes the calendar feed URL without issuing a new one.
+// @Tags         calendar
+// @Security     ApiKeyAuth
+// @Success      204  "Feed disabled"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /users/me/calendar [delete]
+func (h *CalendarHandler) DisableCalendarFeed(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if _, err := h.users.UpdateOne(context.Background(), bson.M{"_id": userID}, bson.M{"$unset": bson.M{"calendarToken": ""}}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable calendar feed"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ServeCalendar godoc
+// @Summary      Calendar feed
+// @Description  Serves the user's todos with due dates as an iCalendar (RFC 5545) feed for calendar apps to subscribe to.
+// @Description  The secret token in the URL authorizes the request. Todos due on a day become all-day entries, in the user's time zone.
+// @Description  Completed todos are included for 90 days after they were due. Recurring todos carry their recurrence rule.
+// @Tags         calendar
+// @Produce      text/calendar
+// @Param        token path  string true  "Feed token, followed by .ics"
+// @Param        type  query string false "event (default), todo or both"
+// @Success      200  {file}    file "iCalendar data"
+// @Failure      400  {object}  map[string]string "Invalid type"
+// @Failure      404  {object}  map[string]string "Unknown or revoked token"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /calendar/{token}.ics [get]
+func (h *CalendarHandler) ServeCalendar(c *gin.Context) {
+	token, ok := strings.CutSuffix(c.Param("token"), ".ics")
+	if !ok || token == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+		return
+	}
+	kind := c.DefaultQuery("type", models.CalendarTypeEvent)
+	if kind != models.CalendarTypeEvent && kind != models.CalendarTypeTodo && kind != models.CalendarTypeBoth {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be one of event, todo or both"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var user models.User
+	opts := options.FindOne().SetProjection(bson.M{"preferences": 1, "username": 1})
+	err := h.users.FindOne(ctx, bson.M{"calendarToken.hash": hashCalendarToken(token), "deleteAt": nil}, opts).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar"})
+		return
+	}
+
+	now := time.Now()
+	filter := bson.M{
+		"userId":     user.ID,
+		"deletedAt":  nil,
+		"archivedAt": nil,
+		"dueDate":    bson.M{"$ne": nil},
+		"$or": bson.A{
+			bson.M{"completed": false},
+			bson.M{"dueDate": bson.M{"$gte": now.Add(-calendarHistory)}},
+		},
+	}
+	var todos []models.Todo
+	cursor, err := h.todos.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "dueDate", Value: 1}}))
+	if err == nil {
+		err = cursor.All(ctx, &todos)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar"})
+		return
+	}
+
+	parents, err := h.todos.parentUIDs(ctx, user.ID, todos)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar"})
+		return
+	}
+
+	cal := todoCalendar(todos, parents, kind, user.Preferences.Location(), now)
+	cal.AddText("X-WR-CALNAME", "MuchToDo – "+user.Username)
+	cal.AddText("X-WR-TIMEZONE", user.Preferences.WithDefaults().Timezone)
+
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Header("Content-Disposition", `inline; filename="muchtodo.ics"`)
+	c.Header("Cache-Control", "private, max-age="+strconv.Itoa(int(calendarCacheAge.Seconds())))
+	c.Status(http.StatusOK)
+	cal.Encode(c.Writer)
+}
+
+// todoCalendar renders todos as a calendar of the given CalendarType*. Due dates at
+// midnight in loc are days rather than points in time. parents maps the parents of
+// subtasks to their UIDs.
+func todoCalendar(todos []models.Todo, parents map[primitive.ObjectID]string, kind string, loc *time.Location, now time.Time) *ical.Component {
+	cal := ical.NewCalendar(calendarProdID)
+	for _, todo := range todos {
+		if todo.DueDate == nil {
+			continue
+		}
+		if kind != models.CalendarTypeTodo {
+			cal.Append(todoEvent(todo, loc, now))
+		}
+		if kind != models.CalendarTypeEvent {
+			cal.Append(todoVTodo(todo, parents, loc, now))
+		}
+	}
+	return cal
+}
+
+// todoEvent renders a todo as a VEVENT on its due date. Completed todos are marked
+// with a check mark, as events have no completion status of their own.
+func todoEvent(todo models.Todo, loc *time.Location, now time.Time) *ical.Component {
+	event := &ical.Component{Name: ical.Event}
+	event.Add("UID", todo.ID.Hex()+"-event@muchtodo")
+	event.AddDateTime("DTSTAMP", now)
+
+	summary := todo.Title
+	if todo.Completed {
+		summary = "✓ " + summary
+	}
+	event.AddText("SUMMARY", summary)
+
+	due := todo.DueDate.In(loc)
+	if allDay(due) {
+		event.AddDate("DTSTART", due)
+		event.AddDate("DTEND", due.AddDate(0, 0, 1))
+	} else {
+		event.AddDateTime("DTSTART", due)
+		event.AddDateTime("DTEND", due.Add(calendarEventLen))
+	}
+	event.Add("TRANSP", "TRANSPARENT") // Todos don't make the user busy
+	addTodoDetails(event, todo, todoCategories(todo))
+	return event
+}
+
+// todoVTodo renders a todo as a VTODO, with its completion status. The project goes in
+// a property of its own so that CalDAV clients can hand it back unchanged.
+func todoVTodo(todo models.Todo, parents map[primitive.ObjectID]string, loc *time.Location, now time.Time) *ical.Component {
+	vtodo := &ical.Component{Name: ical.Todo}
+	vtodo.Add("UID", todoUID(todo))
+	vtodo.AddDateTime("DTSTAMP", now)
+	vtodo.AddText("SUMMARY", todo.Title)
+
+	if todo.DueDate != nil {
+		due := todo.DueDate.In(loc)
+		if allDay(due) {
+			vtodo.AddDate("DUE", due)
+		} else {
+			vtodo.AddDateTime("DUE", due)
+		}
+	}
+	if priority, ok := calendarPriorities[todo.Priority]; ok {
+		vtodo.Add("PRIORITY", strconv.Itoa(priority))
+	}
+	if todo.Completed {
+		vtodo.Add("STATUS", "COMPLETED")
+		vtodo.Add("PERCENT-COMPLETE", "100")
+		if todo.CompletedAt != nil {
+			vtodo.AddDateTime("COMPLETED", *todo.CompletedAt)
+		}
+	} else {
+		vtodo.Add("STATUS", "NEEDS-ACTION")
+	}
+	if todo.ParentID != nil {
+		if uid, ok := parents[*todo.ParentID]; ok {
+			vtodo.Add("RELATED-TO", uid)
+		}
+	}
+	if todo.Project != "" {
+		vtodo.AddText("X-MUCHTODO-PROJECT", todo.Project)
+	}
+	addTodoDetails(vtodo, todo, todo.Labels)
+	return vtodo
+}
+
+// addTodoDetails adds the properties events and tasks share.
+func addTodoDetails(component *ical.Component, todo models.Todo, categories []string) {
+	if todo.Description != "" {
+		component.AddText("DESCRIPTION", todo.Description)
+	}
+	if len(categories) > 0 {
+		escaped := make([]string, len(categories))
+		for i, category := range categories {
+			escaped[i] = ical.EscapeText(category)
+		}
+		component.Add("CATEGORIES", strings.Join(escaped, ","))
+	}
+	// Completing a recurring todo completes it for good, so only open todos repeat.
+	if rule, ok := todoRecurrence(todo); ok && !todo.Completed {
+		component.Add("RRULE", rule.String())
+	}
+	component.AddDateTime("CREATED", todo.CreatedAt)
+	component.AddDateTime("LAST-MODIFIED", todo.UpdatedAt)
+}
+
+// todoUID returns the UID of a todo: the one a CalDAV client gave it, or one derived
+// from its ID.
+func todoUID(todo models.Todo) string {
+	if todo.ICalUID != "" {
+		return todo.ICalUID
+	}
+	return todo.ID.Hex() + "@muchtodo"
+}
+
+// parentUIDs maps the parents of the subtasks among todos to their UIDs. Parents that
+// no longer exist are left out.
+func (h *TodoHandler) parentUIDs(ctx context.Context, userID primitive.ObjectID, todos []models.Todo) (map[primitive.ObjectID]string, error) {
+	var ids []primitive.ObjectID
+	for _, todo := range todos {
+		if todo.ParentID != nil {
+			ids = append(ids, *todo.ParentID)
+		}
+	}
+	uids := make(map[primitive.ObjectID]string)
+	if len(ids) == 0 {
+		return uids, nil
+	}
+
+	var parents []models.Todo
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "icalUid": 1})
+	cursor, err := h.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}, "userId": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cursor.All(ctx, &parents); err != nil {
+		return nil, err
+	}
+	for _, parent := range parents {
+		uids[parent.ID] = todoUID(parent)
+	}
+	return uids, nil
+}
+
+// todoCategories lists the project and labels of a todo.
+func todoCategories(todo models.Todo) []string {
+	var categories []string
+	if todo.Project != "" {
+		categories = append(categories, todo.Project)
+	}
+	return append(categories, todo.Labels...)
+}
+
+// todoRecurrence converts a todo's recurrence into a recurrence rule.
+func todoRecurrence(todo models.Todo) (ical.Recur, bool) {
+	r := normalizeRecurrence(todo.Recurrence)
+	if r == nil {
+		return ical.Recur{}, false
+	}
+
+	rule := ical.Recur{Interval: r.Interval}
+	switch r.Frequency {
+	case models.RecurrenceDaily:
+		rule.Freq = ical.FreqDaily
+	case models.RecurrenceWeekly:
+		rule.Freq = ical.FreqWeekly
+		if r.Weekday != nil && *r.Weekday >= 0 && *r.Weekday < len(ical.Weekdays) {
+			rule.ByDay = []string{ical.Weekdays[*r.Weekday]}
+		}
+	case models.RecurrenceMonthly:
+		rule.Freq = ical.FreqMonthly
+		rule.ByMonthDay = r.MonthDay
+	case models.RecurrenceYearly:
+		rule.Freq = ical.FreqYearly
+	default:
+		return ical.Recur{}, false
+	}
+	return rule, true
+}
+
+// allDay reports whether a due date names a day rather than a time: midnight in its location.
+func allDay(t time.Time) bool {
+	return t.Equal(dates.StartOfDay(t))
+}
+
+// newCalendarToken generates a random, URL-safe calendar feed token.
+func newCalendarToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashCalendarToken returns the hash under which a calendar token is stored.
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// requestBaseURL returns the scheme and host the client used to reach the server.
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || strings.HasPrefix(c.Request.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
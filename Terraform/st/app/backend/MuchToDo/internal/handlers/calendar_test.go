@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestCalendar provides unit tests for rendering todos as iCalendar data.
+func TestCalendar(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	now := time.Date(2025, 3, 12, 9, 0, 0, 0, time.UTC)
+	day := time.Date(2025, 3, 14, 0, 0, 0, 0, loc)
+	timed := time.Date(2025, 3, 14, 17, 30, 0, 0, loc)
+	completedAt := time.Date(2025, 3, 13, 8, 0, 0, 0, time.UTC)
+	monday := int(time.Monday)
+
+	rent := models.Todo{
+		ID: primitive.NewObjectID(), Title: "Pay rent", Project: "home", Labels: []string{"bills"}, DueDate: &day, Priority: 1,
+		Recurrence: &models.Recurrence{Frequency: models.RecurrenceWeekly, Interval: 2, Weekday: &monday},
+		CreatedAt:  now, UpdatedAt: now,
+	}
+	call := models.Todo{
+		ID: primitive.NewObjectID(), Title: "Call Amy", DueDate: &timed, Completed: true, CompletedAt: &completedAt,
+		Recurrence: &models.Recurrence{Frequency: models.RecurrenceDaily}, CreatedAt: now, UpdatedAt: now,
+	}
+	render := func(kind string) string {
+		var buf bytes.Buffer
+		assert.NoError(t, todoCalendar([]models.Todo{rent, call}, nil, kind, loc, now).Encode(&buf))
+		return buf.String()
+	}
+
+	t.Run("Events", func(t *testing.T) {
+		out := render(models.CalendarTypeEvent)
+		assert.Equal(t, 2, strings.Count(out, "BEGIN:VEVENT"))
+		assert.NotContains(t, out, "BEGIN:VTODO")
+		assert.Contains(t, out, "DTSTART;VALUE=DATE:20250314\r\nDTEND;VALUE=DATE:20250315\r\n")
+		assert.Contains(t, out, "DTSTART:20250314T163000Z\r\nDTEND:20250314T170000Z\r\n")
+		assert.Contains(t, out, "SUMMARY:✓ Call Amy\r\n")
+		assert.Contains(t, out, "CATEGORIES:home,bills\r\n")
+		assert.Contains(t, out, "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO\r\n")
+		assert.Equal(t, 1, strings.Count(out, "RRULE:")) // Not on the completed todo
+	})
+
+	t.Run("Todos", func(t *testing.T) {
+		out := render(models.CalendarTypeTodo)
+		assert.Equal(t, 2, strings.Count(out, "BEGIN:VTODO"))
+		assert.NotContains(t, out, "BEGIN:VEVENT")
+		assert.Contains(t, out, "UID:"+rent.ID.Hex()+"@muchtodo\r\n")
+		assert.Contains(t, out, "DUE;VALUE=DATE:20250314\r\n")
+		assert.Contains(t, out, "PRIORITY:1\r\n")
+		assert.Contains(t, out, "STATUS:NEEDS-ACTION\r\n")
+		assert.Contains(t, out, "STATUS:COMPLETED\r\nPERCENT-COMPLETE:100\r\nCOMPLETED:20250313T080000Z\r\n")
+		assert.Contains(t, out, "X-MUCHTODO-PROJECT:home\r\nCATEGORIES:bills\r\n") // Labels only; the project has its own property
+	})
+
+	t.Run("Both", func(t *testing.T) {
+		out := render(models.CalendarTypeBoth)
+		assert.Equal(t, 2, strings.Count(out, "BEGIN:VEVENT"))
+		assert.Equal(t, 2, strings.Count(out, "BEGIN:VTODO"))
+	})
+
+	t.Run("Token", func(t *testing.T) {
+		token, err := newCalendarToken()
+		assert.NoError(t, err)
+		assert.Len(t, token, 32)
+		assert.NotEqual(t, token, hashCalendarToken(token))
+		assert.Equal(t, hashCalendarToken(token), hashCalendarToken(token))
+	})
+}
@@ -0,0 +1,495 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/markdown"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// maxMentions caps how many distinct users a single comment can mention.
+const maxMentions = 20
+
+// mentionPattern matches @username. The @ must not follow a word character, so that
+// email addresses aren't taken for mentions.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_][A-Za-z0-9_.-]*)`)
+
+// CommentHandler holds dependencies for comment and activity feed handlers.
+type CommentHandler struct {
+	collection *mongo.Collection
+	todos      *TodoHandler // Used to check todo ownership and read todo history
+	users      *UserHandler // Used to resolve @mentions
+}
+
+// NewCommentHandler creates a new handler for todo comments.
+func NewCommentHandler(collection *mongo.Collection, todos *TodoHandler, users *UserHandler) *CommentHandler {
+	return &CommentHandler{
+		collection: collection,
+		todos:      todos,
+		users:      users,
+	}
+}
+
+// CreateComment godoc
+// @Summary      Comment on a todo
+// @Description  Posts a Markdown comment on a todo, optionally as a reply to another comment. @username mentions of existing users are recorded and show up in their activity feed.
+// @Tags         comments
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id      path string                  true "Todo ID"
+// @Param        comment body models.CreateCommentDTO true "Comment"
+// @Success      201  {object}  models.Comment
+// @Failure      400  {object}  map[string]string "Invalid input"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Todo or parent comment not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/comments [post]
+func (h *CommentHandler) CreateComment(c *gin.Context) {
+	userID, todoID, ok := ownedTodo(c, h.todos.collection)
+	if !ok {
+		return
+	}
+
+	var dto models.CreateCommentDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	body := strings.TrimSpace(dto.Body)
+	if body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment body cannot be empty"})
+		return
+	}
+
+	comment := models.Comment{
+		ID:        primitive.NewObjectID(),
+		TodoID:    todoID,
+		UserID:    userID,
+		AuthorID:  userID,
+		Body:      body,
+		CreatedAt: time.Now(),
+	}
+
+	if dto.ParentID != "" {
+		parentID, err := primitive.ObjectIDFromHex(dto.ParentID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent comment ID format"})
+			return
+		}
+		count, err := h.collection.CountDocuments(context.Background(), bson.M{"_id": parentID, "todoId": todoID, "deletedAt": nil})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch parent comment"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent comment not found"})
+			return
+		}
+		comment.ParentID = &parentID
+	}
+
+	mentions, err := h.resolveMentions(context.Background(), body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve mentions"})
+		return
+	}
+	comment.Mentions = mentions
+
+	if _, err := h.collection.InsertOne(context.Background(), comment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create comment"})
+		return
+	}
+
+	comment.BodyHTML = markdown.Render(comment.Body)
+	c.JSON(http.StatusCreated, comment)
+}
+
+// GetComments godoc
+// @Summary      List a todo's comments
+// @Description  Retrieves a todo's comments as threads, oldest first. Replies are nested under the comment they answer.
+// @Description  Deleted comments that still have replies are kept as placeholders with an empty body.
+// @Tags         comments
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path string true "Todo ID"
+// @Success      200  {array}   models.Comment
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Todo not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/comments [get]
+func (h *CommentHandler) GetComments(c *gin.Context) {
+	_, todoID, ok := ownedTodo(c, h.todos.collection)
+	if !ok {
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := h.collection.Find(context.Background(), bson.M{"todoId": todoID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comments"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var comments []models.Comment
+	if err = cursor.All(context.Background(), &comments); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode comments"})
+		return
+	}
+
+	for i := range comments {
+		comments[i].BodyHTML = markdown.Render(comments[i].Body)
+	}
+
+	c.JSON(http.StatusOK, commentThreads(comments))
+}
+
+// UpdateComment godoc
+// @Summary      Edit a comment
+// @Description  Replaces the body of a comment. Only the comment's author may edit it.
+// @Tags         comments
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id        path string                  true "Todo ID"
+// @Param        commentId path string                  true "Comment ID"
+// @Param        comment   body models.UpdateCommentDTO true "New body"
+// @Success      200  {object}  models.Comment
+// @Failure      400  {object}  map[string]string "Invalid input"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      403  {object}  map[string]string "Not the author"
+// @Failure      404  {object}  map[string]string "Comment not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/comments/{commentId} [put]
+func (h *CommentHandler) UpdateComment(c *gin.Context) {
+	comment, ok := h.findAuthoredComment(c)
+	if !ok {
+		return
+	}
+
+	var dto models.UpdateCommentDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	body := strings.TrimSpace(dto.Body)
+	if body == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Comment body cannot be empty"})
+		return
+	}
+
+	mentions, err := h.resolveMentions(context.Background(), body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve mentions"})
+		return
+	}
+
+	now := time.Now()
+	set := bson.M{"body": body, "editedAt": now}
+	update := bson.M{"$set": set}
+	if len(mentions) > 0 {
+		set["mentions"] = mentions
+	} else {
+		update["$unset"] = bson.M{"mentions": ""}
+	}
+
+	if _, err := h.collection.UpdateOne(context.Background(), bson.M{"_id": comment.ID}, update); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update comment"})
+		return
+	}
+
+	comment.Body = body
+	comment.Mentions = mentions
+	comment.EditedAt = &now
+	comment.BodyHTML = markdown.Render(body)
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteComment godoc
+// @Summary      Delete a comment
+// @Description  Deletes a comment. Only the comment's author may delete it. A comment with replies is blanked out instead, so the thread stays intact.
+// @Tags         comments
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id        path string true "Todo ID"
+// @Param        commentId path string true "Comment ID"
+// @Success      200  {object}  map[string]string "{'message': 'Comment deleted successfully'}"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      403  {object}  map[string]string "Not the author"
+// @Failure      404  {object}  map[string]string "Comment not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/comments/{commentId} [delete]
+func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	comment, ok := h.findAuthoredComment(c)
+	if !ok {
+		return
+	}
+
+	replies, err := h.collection.CountDocuments(context.Background(), bson.M{"parentId": comment.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+
+	if replies > 0 {
+		update := bson.M{
+			"$set":   bson.M{"body": "", "deletedAt": time.Now()},
+			"$unset": bson.M{"mentions": ""},
+		}
+		_, err = h.collection.UpdateOne(context.Background(), bson.M{"_id": comment.ID}, update)
+	} else {
+		_, err = h.collection.DeleteOne(context.Background(), bson.M{"_id": comment.ID})
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// GetActivity godoc
+// @Summary      Activity feed
+// @Description  Lists recent activity for the current user, newest first: changes to their todos, comments on their todos, comments elsewhere that mention them and finished data exports.
+// @Tags         comments
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        page  query int false "Page number (default 1, max 10000)"
+// @Param        limit query int false "Page size (default 20, max 100)"
+// @Success      200  {object}  map[string]interface{} "Paginated models.ActivityItem items"
+// @Failure      400  {object}  map[string]string "Invalid page"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /activity [get]
+func (h *CommentHandler) GetActivity(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"userId": userID}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":       0,
+			"type":      bson.M{"$literal": models.ActivityTodoChange},
+			"at":        "$changedAt",
+			"todoId":    "$todoId",
+			"todoTitle": "$snapshot.title",
+			"actorId":   "$changedBy",
+			"action":    "$action",
+			"version":   "$version",
+			"changes":   "$changes",
+		}}},
+		{{Key: "$unionWith", Value: bson.M{
+			"coll": h.collection.Name(),
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{
+					"deletedAt": nil,
+					"$or":       bson.A{bson.M{"userId": userID}, bson.M{"mentions.userId": userID}},
+				}},
+				bson.M{"$lookup": bson.M{
+					"from":         h.todos.collection.Name(),
+					"localField":   "todoId",
+					"foreignField": "_id",
+					"as":           "todo",
+				}},
+				bson.M{"$project": bson.M{
+					"_id": 0,
+					"type": bson.M{"$cond": bson.A{
+						bson.M{"$eq": bson.A{"$userId", userID}},
+						models.ActivityComment,
+						models.ActivityMention,
+					}},
+					"at":        "$createdAt",
+					"todoId":    "$todoId",
+					"todoTitle": bson.M{"$first": "$todo.title"},
+					"actorId":   "$authorId",
+					"commentId": "$_id",
+					"body":      "$body",
+				}},
+			},
+		}}},
+	}
+	if h.users != nil && h.users.exports != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$unionWith", Value: bson.M{
+			"coll": h.users.exports.collection.Name(),
+			"pipeline": bson.A{
+				bson.M{"$match": bson.M{"userId": userID, "completedAt": bson.M{"$exists": true}}},
+				bson.M{"$project": bson.M{
+					"_id":      0,
+					"type":     bson.M{"$literal": models.ActivityDataExport},
+					"at":       "$completedAt",
+					"actorId":  "$userId",
+					"action":   "$status",
+					"exportId": "$_id",
+				}},
+			},
+		}}})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "at", Value: -1}}}},
+		bson.D{{Key: "$facet", Value: bson.M{
+			"items": bson.A{bson.M{"$skip": p.Skip()}, bson.M{"$limit": p.Limit}},
+			"total": bson.A{bson.M{"$count": "count"}},
+		}}},
+	)
+
+	cursor, err := h.todos.historyCollection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activity"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var pages []struct {
+		Items []models.ActivityItem `bson:"items"`
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+	}
+	if err := cursor.All(context.Background(), &pages); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode activity"})
+		return
+	}
+
+	items := []models.ActivityItem{}
+	var total int64
+	if len(pages) > 0 {
+		if pages[0].Items != nil {
+			items = pages[0].Items
+		}
+		if len(pages[0].Total) > 0 {
+			total = pages[0].Total[0].Count
+		}
+	}
+	for i := range items {
+		if items[i].CommentID != nil {
+			items[i].BodyHTML = markdown.Render(items[i].Body)
+		}
+	}
+
+	c.JSON(http.StatusOK, paginatedResponse(items, p, total))
+}
+
+// findAuthoredComment loads the live comment named by the :id and :commentId parameters
+// and checks that the current user wrote it, writing an error response and returning
+// false if it can't.
+func (h *CommentHandler) findAuthoredComment(c *gin.Context) (models.Comment, bool) {
+	var comment models.Comment
+
+	userID, todoID, ok := ownedTodo(c, h.todos.collection)
+	if !ok {
+		return comment, false
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("commentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID format"})
+		return comment, false
+	}
+
+	err = h.collection.FindOne(context.Background(), bson.M{"_id": id, "todoId": todoID, "deletedAt": nil}).Decode(&comment)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Comment not found"})
+			return comment, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch comment"})
+		return comment, false
+	}
+
+	if comment.AuthorID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the author can change this comment"})
+		return comment, false
+	}
+
+	return comment, true
+}
+
+// resolveMentions looks up the users mentioned in body. Mentions of unknown usernames
+// are ignored.
+func (h *CommentHandler) resolveMentions(ctx context.Context, body string) ([]models.Mention, error) {
+	usernames := parseMentions(body)
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	found, err := h.users.LookupUsernames(ctx, usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	var mentions []models.Mention
+	for _, username := range usernames {
+		if id, ok := found[username]; ok {
+			mentions = append(mentions, models.Mention{UserID: id, Username: username})
+		}
+	}
+	return mentions, nil
+}
+
+// parseMentions returns the distinct lowercased usernames mentioned as @username in body,
+// in order of first appearance. Mentions inside inline or fenced code are ignored.
+func parseMentions(body string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+
+	for i, part := range strings.Split(body, "`") {
+		if i%2 == 1 {
+			continue // Inside a code span or fence
+		}
+		for _, match := range mentionPattern.FindAllStringSubmatch(part, -1) {
+			username := strings.ToLower(strings.TrimRight(match[1], ".-"))
+			if len(username) < 3 || seen[username] {
+				continue
+			}
+			seen[username] = true
+			usernames = append(usernames, username)
+			if len(usernames) == maxMentions {
+				return usernames
+			}
+		}
+	}
+	return usernames
+}
+
+// commentThreads arranges comments, given oldest first, into threads. Replies whose
+// parent is missing are shown as threads of their own.
+func commentThreads(comments []models.Comment) []*models.Comment {
+	byID := make(map[primitive.ObjectID]*models.Comment, len(comments))
+	for i := range comments {
+		byID[comments[i].ID] = &comments[i]
+	}
+
+	threads := []*models.Comment{}
+	for i := range comments {
+		comment := &comments[i]
+		if comment.ParentID != nil {
+			if parent, ok := byID[*comment.ParentID]; ok {
+				parent.Replies = append(parent.Replies, comment)
+				continue
+			}
+		}
+		threads = append(threads, comment)
+	}
+	return threads
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestComments provides unit tests for mention parsing and comment threading.
+func TestComments(t *testing.T) {
+	t.Run("Parses mentions", func(t *testing.T) {
+		body := "@Alice can you check this with @bob.smith? cc @alice, mail carol@example.com. Not `@dave` or @ab"
+		assert.Equal(t, []string{"alice", "bob.smith"}, parseMentions(body))
+	})
+
+	t.Run("Ignores mentions in fenced code", func(t *testing.T) {
+		body := "```\n@erin\n```\nthanks @frank."
+		assert.Equal(t, []string{"frank"}, parseMentions(body))
+	})
+
+	t.Run("Builds threads", func(t *testing.T) {
+		first, reply, nested, second := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+		missing := primitive.NewObjectID()
+		comments := []models.Comment{
+			{ID: first, Body: "first"},
+			{ID: reply, ParentID: &first, Body: "reply"},
+			{ID: second, Body: "second"},
+			{ID: nested, ParentID: &reply, Body: "nested"},
+			{ID: primitive.NewObjectID(), ParentID: &missing, Body: "orphan"},
+		}
+
+		threads := commentThreads(comments)
+		assert.Len(t, threads, 3)
+		assert.Equal(t, "first", threads[0].Body)
+		assert.Equal(t, "second", threads[1].Body)
+		assert.Equal(t, "orphan", threads[2].Body)
+		if assert.Len(t, threads[0].Replies, 1) {
+			assert.Equal(t, "reply", threads[0].Replies[0].Body)
+			assert.Len(t, threads[0].Replies[0].Replies, 1)
+		}
+	})
+}
@@ -0,0 +1,621 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/storage"
+)
+
+const (
+	dataExportMaxAttempts = 3             // Builds of one export before it is given up on
+	dataExportStaleAfter  = 1 * time.Hour // A build running this long is assumed to have died with its server
+)
+
+// dataExportFile is a JSON file of a data export archive, holding the records of one
+// collection that belong to the user.
+type dataExportFile struct {
+	name       string
+	collection string
+	filter     func(userID primitive.ObjectID) bson.M
+	write      func(ctx context.Context, w io.Writer, cursor *mongo.Cursor) (int, error)
+}
+
+func ownedBy(userID primitive.ObjectID) bson.M {
+	return bson.M{"userId": userID}
+}
+
+// dataExportFiles lists the archive's record files besides profile.json and todos.json.
+// Every collection holding a user's records should be listed here.
+var dataExportFiles = []dataExportFile{
+	{"history.json", "todo_history", ownedBy, writeRecords[models.TodoVersion]},
+	{"comments.json", "comments", func(userID primitive.ObjectID) bson.M {
+		// Comments on the user's todos, and the user's comments elsewhere
+		return bson.M{"$or": bson.A{bson.M{"userId": userID}, bson.M{"authorId": userID}}}
+	}, writeRecords[models.Comment]},
+	{"time_entries.json", "time_entries", ownedBy, writeRecords[models.TimeEntry]},
+	{"saved_filters.json", "saved_filters", ownedBy, writeRecords[models.SavedFilter]},
+	{"templates.json", "templates", ownedBy, writeRecords[models.Template]},
+	{"attachments.json", "attachments", ownedBy, writeRecords[models.Attachment]},
+	{"app_passwords.json", "app_passwords", ownedBy, writeRecords[models.AppPassword]},
+	{"webhooks.json", "webhooks", ownedBy, writeRecords[models.Webhook]},
+	{"webhook_deliveries.json", "webhook_deliveries", ownedBy, writeRecords[models.WebhookDelivery]},
+	{"data_exports.json", "data_exports", ownedBy, writeRecords[models.DataExport]},
+}
+
+// DataExportHandler builds archives of everything stored about a user, so that they can
+// take their data elsewhere or see what is kept about them.
+type DataExportHandler struct {
+	collection *mongo.Collection
+	db         *mongo.Database   // Holds the collections the archive copies from
+	store      storage.BlobStore // Holds the archives, and the attachment files copied into them
+	retention  time.Duration     // How long a finished archive can be downloaded
+	urlTTL     time.Duration     // Lifetime of signed download URLs
+}
+
+// NewDataExportHandler creates a new handler for data exports.
+func NewDataExportHandler(collection *mongo.Collection, db *mongo.Database, store storage.BlobStore, retention time.Duration, urlTTL time.Duration) *DataExportHandler {
+	return &DataExportHandler{
+		collection: collection,
+		db:         db,
+		store:      store,
+		retention:  retention,
+		urlTTL:     urlTTL,
+	}
+}
+
+// RequestExport godoc
+// @Summary      Request a copy of your data
+// @Description  Starts building a zip archive of JSON files holding the user's profile, todos and every related record, along with their attachments.
+// @Description  The archive is built in the background; an "export" entry appears in the activity feed when it is ready, and it can then be downloaded until it expires.
+// @Description  Only one export can be in progress at a time. The request is recorded and kept after the archive expires.
+// @Tags         users
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      202  {object}  models.DataExport
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      409  {object}  map[string]interface{} "An export is already in progress"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /users/me/export [post]
+func (h *DataExportHandler) RequestExport(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	export := models.DataExport{
+		ID:          primitive.NewObjectID(),
+		UserID:      userID,
+		Status:      models.DataExportPending,
+		Active:      true,
+		RequestedAt: time.Now(),
+	}
+	if _, err := h.collection.InsertOne(ctx, export); err != nil {
+		if !mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request export"})
+			return
+		}
+		var active models.DataExport
+		if err := h.collection.FindOne(ctx, bson.M{"userId": userID, "active": true}).Decode(&active); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request export"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "An export is already in progress", "export": active})
+		return
+	}
+
+	// Start right away; if this server stops first, the background job picks it up.
+	go h.run(export.ID)
+
+	c.Header("Location", "/users/me/exports/"+export.ID.Hex())
+	c.JSON(http.StatusAccepted, export)
+}
+
+// GetExports godoc
+// @Summary      List your data exports
+// @Description  Lists the user's data export requests, newest first, including those whose archives have expired.
+// @Tags         users
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {array}   models.DataExport
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /users/me/exports [get]
+func (h *DataExportHandler) GetExports(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var exports []models.DataExport
+	cursor, err := h.collection.Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.D{{Key: "requestedAt", Value: -1}}))
+	if err == nil {
+		err = cursor.All(ctx, &exports)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exports"})
+		return
+	}
+
+	if exports == nil {
+		exports = []models.DataExport{}
+	}
+	c.JSON(http.StatusOK, exports)
+}
+
+// GetExport godoc
+// @Summary      Get a data export
+// @Description  Retrieves the state of a data export request.
+// @Tags         users
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Export ID"
+// @Success      200  {object}  models.DataExport
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Export not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /users/me/exports/{id} [get]
+func (h *DataExportHandler) GetExport(c *gin.Context) {
+	export, ok := h.findExport(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, export)
+}
+
+// DownloadExport godoc
+// @Summary      Get a download link for a data export
+// @Description  Returns a signed URL that allows downloading the archive without authentication until it expires. Each link issued is recorded.
+// @Tags         users
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Export ID"
+// @Success      200  {object}  map[string]interface{} "The export, its download url and when the url expires"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Export not found"
+// @Failure      409  {object}  map[string]string "The archive isn't ready"
+// @Failure      410  {object}  map[string]string "The archive has expired"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /users/me/exports/{id}/download [get]
+func (h *DataExportHandler) DownloadExport(c *gin.Context) {
+	export, ok := h.findExport(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	switch {
+	case export.Status == models.DataExportExpired || (export.ExpiresAt != nil && !now.Before(*export.ExpiresAt)):
+		c.JSON(http.StatusGone, gin.H{"error": "The archive has expired; request a new export"})
+		return
+	case export.Status != models.DataExportReady:
+		c.JSON(http.StatusConflict, gin.H{"error": "The archive is not ready", "status": export.Status})
+		return
+	}
+
+	ttl := dataExportURLTTL(h.urlTTL, *export.ExpiresAt, now)
+	signed, err := h.store.SignedURL(export.StorageKey, storage.DownloadOptions{
+		Filename:    "muchtodo-data-" + export.CompletedAt.Format("2006-01-02") + ".zip",
+		ContentType: "application/zip",
+		Expires:     ttl,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create download link"})
+		return
+	}
+
+	update := bson.M{"$inc": bson.M{"downloads": 1}, "$set": bson.M{"lastDownloadAt": now}}
+	if err := h.collection.FindOneAndUpdate(c.Request.Context(), bson.M{"_id": export.ID}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&export); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record download"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"export": export, "url": absoluteURL(c, signed), "expiresAt": now.Add(ttl)})
+}
+
+// BuildPending builds the exports waiting for a build, including those whose build was
+// cut short, and returns how many became ready.
+func (h *DataExportHandler) BuildPending(ctx context.Context) (int, error) {
+	now := time.Now()
+	stale := bson.M{"status": models.DataExportRunning, "startedAt": bson.M{"$lt": now.Add(-dataExportStaleAfter)}}
+
+	// Give up on exports whose builds keep dying.
+	_, err := h.collection.UpdateMany(ctx,
+		bson.M{"active": true, "attempts": bson.M{"$gte": dataExportMaxAttempts}, "$or": bson.A{bson.M{"status": models.DataExportPending}, stale}},
+		bson.M{
+			"$set":   bson.M{"status": models.DataExportFailed, "completedAt": now, "error": "The archive could not be built"},
+			"$unset": bson.M{"active": ""},
+		})
+	if err != nil {
+		return 0, err
+	}
+
+	built := 0
+	for {
+		export, err := h.claim(ctx, bson.M{"active": true, "$or": bson.A{bson.M{"status": models.DataExportPending}, stale}})
+		if err == mongo.ErrNoDocuments {
+			return built, nil
+		}
+		if err != nil {
+			return built, err
+		}
+		if h.build(ctx, export) == nil {
+			built++
+		}
+	}
+}
+
+// ExpireArchives deletes the archives that expired before now and returns how many
+// were deleted. The export records are kept.
+func (h *DataExportHandler) ExpireArchives(ctx context.Context, now time.Time) (int, error) {
+	return h.expire(ctx, bson.M{"status": models.DataExportReady, "expiresAt": bson.M{"$lte": now}})
+}
+
+// DeleteArchives deletes the archives of a user's exports, as when their account is
+// deleted. The export records are kept.
+func (h *DataExportHandler) DeleteArchives(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := h.expire(ctx, bson.M{"userId": userID, "status": models.DataExportReady})
+	return err
+}
+
+// expire deletes the archives of the exports matching filter and marks them expired.
+// Archives that can't be deleted are logged and left ready so that expiry is retried.
+func (h *DataExportHandler) expire(ctx context.Context, filter bson.M) (int, error) {
+	var exports []models.DataExport
+	cursor, err := h.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"storageKey": 1}))
+	if err == nil {
+		err = cursor.All(ctx, &exports)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	var firstErr error
+	for _, export := range exports {
+		if err := h.store.Delete(ctx, export.StorageKey); err != nil {
+			slog.Error("Failed to delete data export archive", "key", export.StorageKey, slog.Any("error", err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		update := bson.M{
+			"$set":   bson.M{"status": models.DataExportExpired, "expiredAt": time.Now()},
+			"$unset": bson.M{"storageKey": ""},
+		}
+		if _, err := h.collection.UpdateOne(ctx, bson.M{"_id": export.ID}, update); err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, firstErr
+}
+
+// run builds a newly requested export, unless the background job has claimed it already.
+func (h *DataExportHandler) run(id primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), dataExportStaleAfter)
+	defer cancel()
+
+	export, err := h.claim(ctx, bson.M{"_id": id, "status": models.DataExportPending})
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			slog.Error("Failed to start data export", "export", id.Hex(), slog.Any("error", err))
+		}
+		return
+	}
+	h.build(ctx, export)
+}
+
+// claim marks the oldest export matching filter as running and returns it, or returns
+// mongo.ErrNoDocuments if there is none. Claiming is atomic, so each build runs once.
+func (h *DataExportHandler) claim(ctx context.Context, filter bson.M) (models.DataExport, error) {
+	var export models.DataExport
+	update := bson.M{
+		"$set": bson.M{"status": models.DataExportRunning, "startedAt": time.Now()},
+		"$inc": bson.M{"attempts": 1},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "requestedAt", Value: 1}}).
+		SetReturnDocument(options.After)
+	err := h.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&export)
+	return export, err
+}
+
+// build writes a claimed export's archive to the blob store and marks it ready. A failed
+// build is returned to the queue until it has been attempted dataExportMaxAttempts times.
+func (h *DataExportHandler) build(ctx context.Context, export models.DataExport) error {
+	key := "exports/" + export.UserID.Hex() + "/" + export.ID.Hex() + ".zip"
+	size, err := h.storeArchive(ctx, export, key)
+	if err != nil {
+		slog.Error("Failed to build data export", "export", export.ID.Hex(), "attempt", export.Attempts, slog.Any("error", err))
+		update := bson.M{"$set": bson.M{"status": models.DataExportPending}}
+		if export.Attempts >= dataExportMaxAttempts {
+			update = bson.M{
+				"$set":   bson.M{"status": models.DataExportFailed, "completedAt": time.Now(), "error": "The archive could not be built"},
+				"$unset": bson.M{"active": ""},
+			}
+		}
+		if _, updateErr := h.collection.UpdateOne(context.Background(), bson.M{"_id": export.ID}, update); updateErr != nil {
+			slog.Error("Failed to record data export failure", "export", export.ID.Hex(), slog.Any("error", updateErr))
+		}
+		return err
+	}
+
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"status":      models.DataExportReady,
+			"completedAt": now,
+			"expiresAt":   now.Add(h.retention),
+			"size":        size,
+			"storageKey":  key,
+		},
+		"$unset": bson.M{"active": ""},
+	}
+	if _, err := h.collection.UpdateOne(context.Background(), bson.M{"_id": export.ID}, update); err != nil {
+		if delErr := h.store.Delete(context.Background(), key); delErr != nil {
+			slog.Error("Failed to remove orphaned data export archive", "key", key, slog.Any("error", delErr))
+		}
+		return err
+	}
+	slog.Info("Data export ready", "export", export.ID.Hex(), "user", export.UserID.Hex(), "bytes", size)
+	return nil
+}
+
+// storeArchive writes an export's archive to a temporary file, whose size the blob store
+// needs up front, and uploads it under key.
+func (h *DataExportHandler) storeArchive(ctx context.Context, export models.DataExport, key string) (int64, error) {
+	tmp, err := os.CreateTemp("", "data-export-*.zip")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := h.writeArchive(ctx, export, tmp); err != nil {
+		return 0, err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return size, h.store.Put(ctx, key, tmp, size, "application/zip")
+}
+
+// writeArchive writes the zip archive of an export: a manifest, the user's profile, their
+// todos in the format of the JSON export (so they can be imported again), one file per
+// entry of dataExportFiles, and their attachment files under attachments/.
+func (h *DataExportHandler) writeArchive(ctx context.Context, export models.DataExport, w io.Writer) error {
+	exportedAt := time.Now()
+	manifest := models.DataExportManifest{
+		Version:    models.DataExportVersion,
+		ExportID:   export.ID.Hex(),
+		UserID:     export.UserID.Hex(),
+		ExportedAt: exportedAt,
+		Files:      map[string]int{},
+	}
+	zw := zip.NewWriter(w)
+
+	var user models.User
+	if err := h.db.Collection("users").FindOne(ctx, bson.M{"_id": export.UserID}).Decode(&user); err != nil {
+		return err
+	}
+	if err := writeZipJSON(zw, "profile.json", exportedAt, user); err != nil {
+		return err
+	}
+	manifest.Files["profile.json"] = 1
+
+	count, err := h.writeTodos(ctx, zw, export.UserID, exportedAt)
+	if err != nil {
+		return err
+	}
+	manifest.Files["todos.json"] = count
+
+	for _, file := range dataExportFiles {
+		cursor, err := h.db.Collection(file.collection).Find(ctx, file.filter(export.UserID), options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+		if err != nil {
+			return err
+		}
+		out, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: exportedAt})
+		if err == nil {
+			count, err = file.write(ctx, out, cursor)
+		}
+		cursor.Close(ctx)
+		if err != nil {
+			return err
+		}
+		manifest.Files[file.name] = count
+	}
+
+	if err := h.writeAttachments(ctx, zw, export.UserID); err != nil {
+		return err
+	}
+
+	if err := writeZipJSON(zw, "manifest.json", exportedAt, manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeTodos writes all of a user's todos, including archived and trashed ones, to
+// todos.json and returns how many there were.
+func (h *DataExportHandler) writeTodos(ctx context.Context, zw *zip.Writer, userID primitive.ObjectID, exportedAt time.Time) (int, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).
+		SetBatchSize(exportBatchSize)
+	cursor, err := h.db.Collection("todos").Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	out, err := zw.CreateHeader(&zip.FileHeader{Name: "todos.json", Method: zip.Deflate, Modified: exportedAt})
+	if err != nil {
+		return 0, err
+	}
+	todos := newTodoWriter(models.ExportFormatJSON, out, exportedAt)
+	count := 0
+	for cursor.Next(ctx) {
+		var todo models.Todo
+		if err := cursor.Decode(&todo); err != nil {
+			return count, err
+		}
+		if err := todos.Write(todo); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+	return count, todos.Close()
+}
+
+// writeAttachments copies a user's attachment files into the archive, named after the
+// attachment's ID and file name. Files missing from the blob store are skipped.
+func (h *DataExportHandler) writeAttachments(ctx context.Context, zw *zip.Writer, userID primitive.ObjectID) error {
+	cursor, err := h.db.Collection("attachments").Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var attachment models.Attachment
+		if err := cursor.Decode(&attachment); err != nil {
+			return err
+		}
+		blob, err := h.store.Get(ctx, attachment.StorageKey)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				slog.Warn("Attachment file missing from data export", "key", attachment.StorageKey)
+				continue
+			}
+			return err
+		}
+		// Most attachments are images and PDFs, which are compressed already.
+		out, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     "attachments/" + attachment.ID.Hex() + "-" + attachment.Filename,
+			Method:   zip.Store,
+			Modified: attachment.CreatedAt,
+		})
+		if err == nil {
+			_, err = io.Copy(out, blob)
+		}
+		blob.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// findExport loads the current user's export named by the :id parameter, writing an
+// error response and returning false if it can't.
+func (h *DataExportHandler) findExport(c *gin.Context) (models.DataExport, bool) {
+	var export models.DataExport
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return export, false
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return export, false
+	}
+
+	err = h.collection.FindOne(c.Request.Context(), bson.M{"_id": id, "userId": userID}).Decode(&export)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+			return export, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch export"})
+		return export, false
+	}
+
+	return export, true
+}
+
+// dataExportURLTTL returns how long a download link for an archive expiring at expiresAt
+// stays valid: urlTTL, but never past the archive's expiry.
+func dataExportURLTTL(urlTTL time.Duration, expiresAt time.Time, now time.Time) time.Duration {
+	if remaining := expiresAt.Sub(now); remaining < urlTTL {
+		return remaining
+	}
+	return urlTTL
+}
+
+// writeZipJSON adds a file holding v, encoded as indented JSON, to an archive.
+func writeZipJSON(zw *zip.Writer, name string, modified time.Time, v interface{}) error {
+	out, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeRecords writes the documents of cursor, decoded as T, as a JSON array with one
+// record per line, and returns how many there were.
+func writeRecords[T any](ctx context.Context, w io.Writer, cursor *mongo.Cursor) (int, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return 0, err
+	}
+	count := 0
+	for cursor.Next(ctx) {
+		var record T
+		if err := cursor.Decode(&record); err != nil {
+			return count, err
+		}
+		encoded, err := json.Marshal(record)
+		if err != nil {
+			return count, err
+		}
+		separator := ",\n"
+		if count == 0 {
+			separator = "\n"
+		}
+		if _, err := io.WriteString(w, separator); err != nil {
+			return count, err
+		}
+		if _, err := w.Write(encoded); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return count, err
+	}
+	_, err := io.WriteString(w, "\n]\n")
+	return count, err
+}
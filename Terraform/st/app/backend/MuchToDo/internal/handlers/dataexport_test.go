@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestDataExport provides unit tests for writing data export archives.
+func TestDataExport(t *testing.T) {
+	ctx := context.Background()
+	userID := primitive.NewObjectID()
+
+	t.Run("Records", func(t *testing.T) {
+		docs := []interface{}{
+			bson.M{"_id": primitive.NewObjectID(), "userId": userID, "name": "Today", "query": "due:today"},
+			bson.M{"_id": primitive.NewObjectID(), "userId": userID, "name": "Work", "query": "project:work"},
+		}
+		cursor, err := mongo.NewCursorFromDocuments(docs, nil, nil)
+		assert.NoError(t, err)
+
+		var buf bytes.Buffer
+		count, err := writeRecords[models.SavedFilter](ctx, &buf, cursor)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, count)
+
+		var filters []models.SavedFilter
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &filters))
+		assert.Len(t, filters, 2)
+		assert.Equal(t, "Work", filters[1].Name)
+		assert.Equal(t, userID, filters[1].UserID)
+
+		// Secrets stay out of the archive.
+		cursor, err = mongo.NewCursorFromDocuments([]interface{}{
+			bson.M{"_id": primitive.NewObjectID(), "userId": userID, "name": "Phone", "hash": "secret"},
+		}, nil, nil)
+		assert.NoError(t, err)
+		buf.Reset()
+		_, err = writeRecords[models.AppPassword](ctx, &buf, cursor)
+		assert.NoError(t, err)
+		assert.NotContains(t, buf.String(), "secret")
+
+		cursor, err = mongo.NewCursorFromDocuments(nil, nil, nil)
+		assert.NoError(t, err)
+		buf.Reset()
+		count, err = writeRecords[models.Template](ctx, &buf, cursor)
+		assert.NoError(t, err)
+		assert.Zero(t, count)
+		assert.Equal(t, "[\n]\n", buf.String())
+	})
+
+	t.Run("ZipJSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		user := models.User{ID: userID, Username: "amy", Password: "hashed"}
+		assert.NoError(t, writeZipJSON(zw, "profile.json", time.Now(), user))
+		assert.NoError(t, zw.Close())
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		assert.NoError(t, err)
+		assert.Len(t, zr.File, 1)
+		assert.Equal(t, "profile.json", zr.File[0].Name)
+		f, err := zr.File[0].Open()
+		assert.NoError(t, err)
+		data, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"username": "amy"`)
+		assert.NotContains(t, string(data), "hashed")
+	})
+
+	t.Run("URLTTL", func(t *testing.T) {
+		now := time.Now()
+		assert.Equal(t, 15*time.Minute, dataExportURLTTL(15*time.Minute, now.Add(time.Hour), now))
+		assert.Equal(t, 5*time.Minute, dataExportURLTTL(15*time.Minute, now.Add(5*time.Minute), now))
+	})
+
+	t.Run("Activity", func(t *testing.T) {
+		exportID := primitive.NewObjectID()
+		encoded, err := json.Marshal(models.ActivityItem{Type: models.ActivityDataExport, ActorID: userID, Action: models.DataExportReady, ExportID: &exportID})
+		assert.NoError(t, err)
+		assert.NotContains(t, string(encoded), "todoId")
+		assert.NotContains(t, string(encoded), "todoTitle")
+		assert.Contains(t, string(encoded), `"exportId":"`+exportID.Hex()+`"`)
+	})
+}
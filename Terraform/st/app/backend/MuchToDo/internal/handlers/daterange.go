@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/dates"
+)
+
+// maxReportRange is the widest date range a single report may cover.
+const maxReportRange = 366 * 24 * time.Hour
+
+// dayRange is an inclusive range of calendar days in the requester's time zone.
+type dayRange struct {
+	From time.Time // Midnight at the start of the first day
+	To   time.Time // Midnight at the start of the last day
+	Now  time.Time // The current time in Loc
+	Loc  *time.Location
+}
+
+// End returns midnight after the last day, the exclusive upper bound of the range.
+func (r dayRange) End() time.Time {
+	return r.To.AddDate(0, 0, 1)
+}
+
+// parseDayRange reads ?from=, ?to= and ?tz= from the request, with the time zone
+// defaulting to the user's preference. Days may be given as YYYY-MM-DD or as relative
+// references such as "today"; the range defaults to the defaultDays days up to and
+// including today. It writes an error response and returns false if the parameters
+// are invalid.
+func parseDayRange(c *gin.Context, defaultDays int) (dayRange, bool) {
+	var r dayRange
+
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", userPreferences(c).WithDefaults().Timezone))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown time zone"})
+		return r, false
+	}
+	r.Loc = loc
+	r.Now = time.Now().In(loc)
+
+	r.To = dates.StartOfDay(r.Now)
+	r.From = r.To.AddDate(0, 0, 1-defaultDays)
+	if ref := c.Query("from"); ref != "" {
+		if r.From, err = dates.ResolveDay(ref, r.Now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from: " + err.Error()})
+			return r, false
+		}
+	}
+	if ref := c.Query("to"); ref != "" {
+		if r.To, err = dates.ResolveDay(ref, r.Now); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to: " + err.Error()})
+			return r, false
+		}
+	}
+
+	if r.To.Before(r.From) || r.End().Sub(r.From) > maxReportRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to, and the range must not exceed a year"})
+		return r, false
+	}
+	return r, true
+}
@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// accountStateCacheTTL is how long whether an account is disabled is cached.
+const accountStateCacheTTL = 5 * time.Minute
+
+// accountState is what ActiveAccountMiddleware caches about an account.
+type accountState struct {
+	Exists   bool       `json:"exists"`
+	DeleteAt *time.Time `json:"deleteAt,omitempty"`
+}
+
+// ActiveAccountMiddleware rejects requests from accounts that are scheduled for deletion,
+// or no longer exist. It must run after the authentication middleware.
+func (h *UserHandler) ActiveAccountMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := getUserIDFromContext(c)
+		if err != nil {
+			c.Next()
+			return
+		}
+
+		state, err := h.loadAccountState(c.Request.Context(), userID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check account"})
+			return
+		}
+		if !state.Exists {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Account not found"})
+			return
+		}
+		if state.DeleteAt != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":    "This account is scheduled for deletion; restore it to use it again",
+				"deleteAt": state.DeleteAt,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// loadAccountState reads whether an account exists and is disabled, from the cache or
+// the database.
+func (h *UserHandler) loadAccountState(ctx context.Context, userID primitive.ObjectID) (accountState, error) {
+	cacheKey := accountStateCacheKey(userID)
+
+	var state accountState
+	if err := h.cache.Get(ctx, cacheKey, &state); err == nil {
+		return state, nil
+	}
+
+	var user models.User
+	opts := options.FindOne().SetProjection(bson.M{"deleteAt": 1})
+	err := h.collection.FindOne(ctx, bson.M{"_id": userID}, opts).Decode(&user)
+	switch err {
+	case nil:
+		state = accountState{Exists: true, DeleteAt: user.DeleteAt}
+	case mongo.ErrNoDocuments:
+		state = accountState{}
+	default:
+		return state, err
+	}
+
+	h.cache.Set(ctx, cacheKey, state, accountStateCacheTTL)
+	return state, nil
+}
+
+// RestoreUser godoc
+// @Summary      Restore an account scheduled for deletion
+// @Description  Cancels the scheduled deletion of the authenticated user's account and enables it again.
+// @Tags         users
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  map[string]string "{'message': 'Account restored successfully'}"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "User not found"
+// @Failure      409  {object}  map[string]string "The account is not scheduled for deletion"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /users/me/restore [post]
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	update := bson.M{"$unset": bson.M{"deletionRequestedAt": "", "deleteAt": ""}}
+	err = h.saveWithEvent(ctx, newUserEvent(models.UserEventRestored, userID, time.Now()), func(ctx context.Context) error {
+		result, err := h.collection.UpdateOne(ctx, bson.M{"_id": userID, "deleteAt": bson.M{"$ne": nil}}, update)
+		if err == nil && result.MatchedCount == 0 {
+			err = mongo.ErrNoDocuments
+		}
+		return err
+	})
+	if err != nil && err != mongo.ErrNoDocuments {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore account"})
+		return
+	}
+	if err == mongo.ErrNoDocuments {
+		count, err := h.collection.CountDocuments(ctx, bson.M{"_id": userID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore account"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "The account is not scheduled for deletion"})
+		return
+	}
+	h.cache.Delete(ctx, accountStateCacheKey(userID))
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account restored successfully"})
+}
+
+// AdminDeleteUser godoc
+// @Summary      Delete an account immediately
+// @Description  Permanently deletes a user's account and all their associated data straight away, whether or not it is scheduled for deletion.
+// @Description  Requires the admin API key in the X-Admin-Key header.
+// @Tags         admin
+// @Produce      json
+// @Param        X-Admin-Key header string true "Admin API key"
+// @Param        id path string true "User ID"
+// @Success      200  {object}  map[string]string "{'message': 'Account deleted successfully'}"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Invalid admin key"
+// @Failure      404  {object}  map[string]string "User not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /admin/users/{id} [delete]
+func (h *UserHandler) AdminDeleteUser(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	if err := h.purgeUser(c.Request.Context(), userID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		slog.Error("Failed to delete account", "user", userID.Hex(), slog.Any("error", err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+	slog.Info("Account deleted by an administrator", "user", userID.Hex())
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
+}
+
+// PurgeScheduledDeletions deletes the accounts whose grace period ended before now and
+// returns how many were deleted. Accounts that fail are retried on the next run.
+func (h *UserHandler) PurgeScheduledDeletions(ctx context.Context, now time.Time) (int, error) {
+	var users []models.User
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+	cursor, err := h.collection.Find(ctx, bson.M{"deleteAt": bson.M{"$lte": now}}, opts)
+	if err == nil {
+		err = cursor.All(ctx, &users)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	var firstErr error
+	for _, user := range users {
+		if err := h.purgeUser(ctx, user.ID); err != nil && err != mongo.ErrNoDocuments {
+			slog.Error("Failed to delete account", "user", user.ID.Hex(), slog.Any("error", err))
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		purged++
+	}
+	return purged, firstErr
+}
+
+// accountPurgeBatch is how many documents purgeUser deletes at a time.
+const accountPurgeBatch = 500
+
+// accountDataCollections are the collections holding a user's data other than their
+// todos, by userId: the change history, pending undo operations, comments, time entries
+// and tombstones of their todos, their saved filters, templates, app passwords, webhooks
+// and webhook delivery log, and their events that haven't expired from the outbox yet.
+var accountDataCollections = []string{"todo_history", "undo_operations", "comments", "time_entries", "todo_tombstones", "saved_filters", "templates", "app_passwords", "webhooks", "webhook_deliveries", "outbox"}
+
+// purgeUser permanently deletes a user and all their data, or returns
+// mongo.ErrNoDocuments if there is no such user.
+//
+// The data is deleted in batches, so that a large account doesn't need one huge
+// transaction, and the user document goes last: a purge that fails part way leaves the
+// account in place, scheduled for deletion, and the next attempt finishes the job.
+func (h *UserHandler) purgeUser(ctx context.Context, userID primitive.ObjectID) error {
+	count, err := h.collection.CountDocuments(ctx, bson.M{"_id": userID})
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	session, err := h.dbClient.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	// Each batch of todos goes with the outbox sequence counters of those todos.
+	err = deleteBatches(ctx, h.todoCollection, bson.M{"userId": userID}, func(ids []primitive.ObjectID) error {
+		_, err := session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+			if h.outbox != nil {
+				if err := h.outbox.Forget(sessCtx, models.AggregateTodo, ids); err != nil {
+					return nil, err
+				}
+			}
+			return h.todoCollection.DeleteMany(sessCtx, bson.M{"_id": bson.M{"$in": ids}})
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	db := h.dbClient.Database(h.config.DBName)
+	for _, name := range accountDataCollections {
+		collection := db.Collection(name)
+		err := deleteBatches(ctx, collection, bson.M{"userId": userID}, func(ids []primitive.ObjectID) error {
+			_, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
+		// The lock that serializes changes to the user's todo dependencies, and the counter
+		// of changes to their todos, are keyed by their ID
+		for _, name := range []string{"todo_graph_locks", todoChangesCollection} {
+			_, err := db.Collection(name).DeleteOne(sessCtx, bson.M{"_id": userID})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Delete the user
+		result, err := h.collection.DeleteOne(sessCtx, bson.M{"_id": userID})
+		if err != nil {
+			return nil, err
+		}
+
+		if result.DeletedCount == 0 {
+			return nil, mongo.ErrNoDocuments // Use a standard error to indicate user not found
+		}
+
+		// Only the user.deleted event, which holds no more than the user's ID, is kept
+		// for the sinks to receive. It is the account's last, so its counter goes too.
+		if h.outbox != nil {
+			if err := h.appendUserEvent(sessCtx, newUserEvent(models.UserEventDeleted, userID, time.Now())); err != nil {
+				return nil, err
+			}
+			if err := h.outbox.Forget(sessCtx, models.AggregateUser, []primitive.ObjectID{userID}); err != nil {
+				return nil, err
+			}
+		}
+
+		return result, nil
+	}
+
+	if _, err := session.WithTransaction(ctx, callback); err != nil {
+		return err
+	}
+	if h.outbox != nil {
+		h.outbox.Notify()
+	}
+	h.cache.Delete(context.Background(), accountStateCacheKey(userID))
+
+	// Files live outside the database, so they are removed once the account is gone.
+	// Failures are logged and leave the attachment records behind for a later retry.
+	if h.attachments != nil {
+		if err := h.attachments.DeleteAttachments(context.Background(), bson.M{"userId": userID}); err != nil {
+			slog.Error("Failed to delete attachments of account", "user", userID.Hex(), slog.Any("error", err))
+		}
+	}
+	// Data export records are kept as evidence that requests were fulfilled, but their
+	// archives go with the account.
+	if h.exports != nil {
+		if err := h.exports.DeleteArchives(context.Background(), userID); err != nil {
+			slog.Error("Failed to delete data export archives of account", "user", userID.Hex(), slog.Any("error", err))
+		}
+	}
+	return nil
+}
+
+// deleteBatches finds the documents of collection matching filter, accountPurgeBatch at
+// a time, and calls remove with their IDs until none are left. remove must delete them.
+func deleteBatches(ctx context.Context, collection *mongo.Collection, filter bson.M, remove func(ids []primitive.ObjectID) error) error {
+	opts := options.Find().SetProjection(bson.M{"_id": 1}).SetLimit(accountPurgeBatch)
+	for {
+		var docs []struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		cursor, err := collection.Find(ctx, filter, opts)
+		if err == nil {
+			err = cursor.All(ctx, &docs)
+		}
+		if err != nil || len(docs) == 0 {
+			return err
+		}
+
+		ids := make([]primitive.ObjectID, len(docs))
+		for i, doc := range docs {
+			ids[i] = doc.ID
+		}
+		if err := remove(ids); err != nil {
+			return err
+		}
+	}
+}
+
+// accountStateCacheKey is the cache key under which an account's state is cached.
+func accountStateCacheKey(userID primitive.ObjectID) string {
+	return fmt.Sprintf("account-state:%s", userID.Hex())
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/cache"
+)
+
+// mapCache is an in-memory cache.Cache for tests that must not reach the database.
+type mapCache map[string][]byte
+
+var _ cache.Cache = mapCache{}
+
+func (m mapCache) Get(ctx context.Context, key string, dest interface{}) error {
+	data, ok := m[key]
+	if !ok {
+		return errors.New("cache miss")
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func (m mapCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err == nil {
+		m[key] = data
+	}
+	return err
+}
+
+func (m mapCache) SetMany(ctx context.Context, data map[string]interface{}, expiration time.Duration) error {
+	for key, value := range data {
+		if err := m.Set(ctx, key, value, expiration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m mapCache) Delete(ctx context.Context, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m mapCache) Ping(ctx context.Context) error {
+	return nil
+}
+
+// TestAccountDeletion provides unit tests for disabling accounts scheduled for deletion.
+func TestAccountDeletion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	states := mapCache{}
+	h := &UserHandler{cache: states}
+
+	request := func(userID primitive.ObjectID) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(func(c *gin.Context) { c.Set("userID", userID.Hex()) }, h.ActiveAccountMiddleware())
+		router.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w
+	}
+
+	active := primitive.NewObjectID()
+	states.Set(ctx, accountStateCacheKey(active), accountState{Exists: true}, accountStateCacheTTL)
+	assert.Equal(t, http.StatusNoContent, request(active).Code)
+
+	disabled := primitive.NewObjectID()
+	deleteAt := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	states.Set(ctx, accountStateCacheKey(disabled), accountState{Exists: true, DeleteAt: &deleteAt}, accountStateCacheTTL)
+	w := request(disabled)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	var body struct {
+		DeleteAt time.Time `json:"deleteAt"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.True(t, deleteAt.Equal(body.DeleteAt))
+
+	gone := primitive.NewObjectID()
+	states.Set(ctx, accountStateCacheKey(gone), accountState{}, accountStateCacheTTL)
+	assert.Equal(t, http.StatusUnauthorized, request(gone).Code)
+}
@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// dependencyGraph maps a todo ID to the IDs of the todos blocking it.
+type dependencyGraph map[primitive.ObjectID][]primitive.ObjectID
+
+// newDependencyGraph builds the blockedBy graph for a set of todos.
+func newDependencyGraph(todos []models.Todo) dependencyGraph {
+	graph := make(dependencyGraph, len(todos))
+	for _, todo := range todos {
+		graph[todo.ID] = todo.BlockedBy
+	}
+	return graph
+}
+
+// createsCycle reports whether making todoID blocked by blockerID would
+// introduce a cycle, i.e. whether blockerID already (transitively) waits on todoID.
+func (g dependencyGraph) createsCycle(todoID, blockerID primitive.ObjectID) bool {
+	if todoID == blockerID {
+		return true
+	}
+
+	visited := make(map[primitive.ObjectID]bool)
+	stack := []primitive.ObjectID{blockerID}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if current == todoID {
+			return true
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		stack = append(stack, g[current]...)
+	}
+	return false
+}
+
+// topologicalOrder sorts todos so that every todo comes after the todos blocking it.
+// Ties are broken by creation time, oldest first. Blockers outside the given set are
+// ignored, and any todos left over by a (corrupt) cycle are appended at the end.
+func topologicalOrder(todos []models.Todo) []models.Todo {
+	sorted := make([]models.Todo, len(todos))
+	copy(sorted, todos)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	index := make(map[primitive.ObjectID]int, len(sorted))
+	for i, todo := range sorted {
+		index[todo.ID] = i
+	}
+
+	// Kahn's algorithm, always taking the oldest ready todo.
+	inDegree := make([]int, len(sorted))
+	dependents := make([][]int, len(sorted))
+	for i, todo := range sorted {
+		for _, blocker := range todo.BlockedBy {
+			if j, ok := index[blocker]; ok {
+				inDegree[i]++
+				dependents[j] = append(dependents[j], i)
+			}
+		}
+	}
+	ready := &readyQueue{}
+	for i := range sorted {
+		if inDegree[i] == 0 {
+			heap.Push(ready, i)
+		}
+	}
+
+	order := make([]models.Todo, 0, len(sorted))
+	placed := make([]bool, len(sorted))
+	for ready.Len() > 0 {
+		i := heap.Pop(ready).(int)
+		order = append(order, sorted[i])
+		placed[i] = true
+		for _, dependent := range dependents[i] {
+			if inDegree[dependent]--; inDegree[dependent] == 0 {
+				heap.Push(ready, dependent)
+			}
+		}
+	}
+	for i, todo := range sorted {
+		if !placed[i] {
+			order = append(order, todo)
+		}
+	}
+
+	return order
+}
+
+// readyQueue is a min-heap of indexes into todos sorted by creation time.
+type readyQueue []int
+
+func (q readyQueue) Len() int            { return len(q) }
+func (q readyQueue) Less(i, j int) bool  { return q[i] < q[j] }
+func (q readyQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *readyQueue) Push(x interface{}) { *q = append(*q, x.(int)) }
+func (q *readyQueue) Pop() interface{} {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
+
+// annotateBlocked sets IsBlocked on each todo that has at least one incomplete blocker.
+func (h *TodoHandler) annotateBlocked(ctx context.Context, userID primitive.ObjectID, todos []models.Todo) error {
+	var blockerIDs []primitive.ObjectID
+	for _, todo := range todos {
+		blockerIDs = append(blockerIDs, todo.BlockedBy...)
+	}
+	if len(blockerIDs) == 0 {
+		return nil
+	}
+
+	open, err := h.incompleteTodoIDs(ctx, userID, blockerIDs)
+	if err != nil {
+		return err
+	}
+
+	for i := range todos {
+		todos[i].IsBlocked = false
+		for _, blocker := range todos[i].BlockedBy {
+			if open[blocker] {
+				todos[i].IsBlocked = true
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// incompleteTodoIDs returns the subset of ids that belong to the user and are not completed.
+func (h *TodoHandler) incompleteTodoIDs(ctx context.Context, userID primitive.ObjectID, ids []primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	filter := bson.M{"_id": bson.M{"$in": ids}, "userId": userID, "completed": false, "deletedAt": nil}
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+
+	cursor, err := h.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	open := make(map[primitive.ObjectID]bool)
+	for cursor.Next(ctx) {
+		var result struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			return nil, err
+		}
+		open[result.ID] = true
+	}
+	return open, cursor.Err()
+}
+
+// openBlockers returns the IDs of the todo's blockers that are still incomplete.
+func (h *TodoHandler) openBlockers(ctx context.Context, userID primitive.ObjectID, todo models.Todo) ([]string, error) {
+	if len(todo.BlockedBy) == 0 {
+		return nil, nil
+	}
+
+	open, err := h.incompleteTodoIDs(ctx, userID, todo.BlockedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	blockers := make([]string, 0, len(open))
+	for _, blocker := range todo.BlockedBy {
+		if open[blocker] {
+			blockers = append(blockers, blocker.Hex())
+		}
+	}
+	return blockers, nil
+}
+
+// todoBlockedError is returned when a todo can't be completed while some of its
+// blockers are still open.
+type todoBlockedError struct {
+	blockers []string // IDs of the open blockers
+}
+
+func (e todoBlockedError) Error() string { return "todo is blocked by incomplete todos" }
+
+// checkUnblocked returns a todoBlockedError if any of the todo's blockers are still open.
+// Inside a transaction, the caller takes the user's graph lock first.
+func (h *TodoHandler) checkUnblocked(ctx context.Context, userID primitive.ObjectID, todo models.Todo) error {
+	blockers, err := h.openBlockers(ctx, userID, todo)
+	if err != nil {
+		return err
+	}
+	if len(blockers) > 0 {
+		return todoBlockedError{blockers: blockers}
+	}
+	return nil
+}
+
+// respondBlocked writes the conflict response for a todoBlockedError and reports
+// whether err was one.
+func respondBlocked(c *gin.Context, err error) bool {
+	var blocked todoBlockedError
+	if !errors.As(err, &blocked) {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "Todo is blocked by incomplete todos", "blockedBy": blocked.blockers})
+	return true
+}
+
+// lockGraph writes the user's graph lock. Every transaction that changes the user's
+// dependency graph, or which of their todos are completed, takes it first, so that of
+// two transactions that each check what the other changes, one is retried and sees
+// the other's change.
+func (h *TodoHandler) lockGraph(sessCtx mongo.SessionContext, userID primitive.ObjectID) error {
+	locks := h.collection.Database().Collection("todo_graph_locks")
+	_, err := locks.UpdateOne(sessCtx, bson.M{"_id": userID}, bson.M{"$inc": bson.M{"version": 1}}, options.Update().SetUpsert(true))
+	return err
+}
+
+// AddBlocker godoc
+// @Summary      Mark a todo as blocked by another
+// @Description  Adds a blockedBy relation between two todos owned by the current user. Relations that would create a cycle are rejected.
+// @Tags         todos
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Todo ID"
+// @Param        blocker body models.AddBlockerDTO true "Blocking todo"
+// @Success      200  {object}  models.Todo
+// @Header       200  {string}  X-Undo-Token "Token for POST /undo/{token}"
+// @Failure      400  {object}  map[string]string "Invalid input or ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Todo not found"
+// @Failure      409  {object}  map[string]string "Dependency would create a cycle"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/blockers [post]
+func (h *TodoHandler) AddBlocker(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	var dto models.AddBlockerDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	blockerID, err := primitive.ObjectIDFromHex(dto.BlockerID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid blocker ID format"})
+		return
+	}
+
+	var todo models.Todo
+	var undoToken string
+	err = h.withTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		var err error
+		todo, undoToken, err = h.addBlocker(sessCtx, userID, id, blockerID)
+		return err
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, mongo.ErrNoDocuments):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found or you don't have permission"})
+		case errors.Is(err, errBlockerNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Blocking todo not found or you don't have permission"})
+		case errors.Is(err, errDependencyCycle):
+			c.JSON(http.StatusConflict, gin.H{"error": "Dependency would create a cycle"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update todo"})
+		}
+		return
+	}
+
+	todos := []models.Todo{todo}
+	if err := h.annotateBlocked(context.Background(), userID, todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve dependencies"})
+		return
+	}
+
+	setUndoToken(c, undoToken)
+	c.JSON(http.StatusOK, todos[0])
+}
+
+var (
+	errBlockerNotFound = errors.New("blocking todo not found")
+	errDependencyCycle = errors.New("dependency would create a cycle")
+)
+
+// addBlocker makes a todo blocked by another, inside a transaction, unless that would
+// create a cycle. It returns the todo and an undo token, mongo.ErrNoDocuments if the
+// todo isn't one of the user's live todos, errBlockerNotFound if the blocker isn't, or
+// errDependencyCycle.
+func (h *TodoHandler) addBlocker(sessCtx mongo.SessionContext, userID, id, blockerID primitive.ObjectID) (models.Todo, string, error) {
+	// Of two transactions that each add half of a cycle, the lock makes one retry and
+	// see the other's edge.
+	if err := h.lockGraph(sessCtx, userID); err != nil {
+		return models.Todo{}, "", err
+	}
+
+	// Load the user's whole graph, trashed todos included, so the cycle check sees
+	// every edge that could come back when a todo is restored.
+	var todos []models.Todo
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "blockedBy": 1, "deletedAt": 1})
+	cursor, err := h.collection.Find(sessCtx, bson.M{"userId": userID}, opts)
+	if err == nil {
+		err = cursor.All(sessCtx, &todos)
+	}
+	if err != nil {
+		return models.Todo{}, "", err
+	}
+
+	active := make(map[primitive.ObjectID]bool, len(todos))
+	for _, todo := range todos {
+		active[todo.ID] = todo.DeletedAt == nil
+	}
+	if !active[id] {
+		return models.Todo{}, "", mongo.ErrNoDocuments
+	}
+	if !active[blockerID] {
+		return models.Todo{}, "", errBlockerNotFound
+	}
+	if newDependencyGraph(todos).createsCycle(id, blockerID) {
+		return models.Todo{}, "", errDependencyCycle
+	}
+
+	filter := bson.M{"_id": id, "userId": userID, "deletedAt": nil}
+	update := bson.M{
+		"$addToSet": bson.M{"blockedBy": blockerID},
+		"$set":      bson.M{"updatedAt": primitive.NewDateTimeFromTime(time.Now())},
+	}
+	return h.applyMutation(sessCtx, userID, filter, update, models.TodoActionUpdated)
+}
+
+// RemoveBlocker godoc
+// @Summary      Remove a blockedBy relation
+// @Description  Removes a blocking todo from a todo's blockedBy list
+// @Tags         todos
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Todo ID"
+// @Param        blockerId path string true "Blocking todo ID"
+// @Success      200  {object}  map[string]string "{'message': 'Dependency removed successfully', 'undoToken': '...'}"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Todo not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/blockers/{blockerId} [delete]
+func (h *TodoHandler) RemoveBlocker(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	blockerID, err := primitive.ObjectIDFromHex(c.Param("blockerId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid blocker ID format"})
+		return
+	}
+
+	filter := bson.M{"_id": id, "userId": userID, "deletedAt": nil, "blockedBy": blockerID}
+	update := bson.M{
+		"$pull": bson.M{"blockedBy": blockerID},
+		"$set":  bson.M{"updatedAt": primitive.NewDateTimeFromTime(time.Now())},
+	}
+	_, undoToken, err := h.mutateTodo(context.Background(), userID, filter, update, models.TodoActionUpdated)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dependency not found or you don't have permission"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update todo"})
+		return
+	}
+
+	setUndoToken(c, undoToken)
+	c.JSON(http.StatusOK, gin.H{"message": "Dependency removed successfully", "undoToken": undoToken})
+}
+
+// GetNextTodos godoc
+// @Summary      Get incomplete todos in the order they can be done
+// @Description  Returns the user's incomplete todos sorted topologically by their dependencies. Todos with isBlocked=false can be started now.
+// @Tags         todos
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {array}  models.Todo
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/next [get]
+func (h *TodoHandler) GetNextTodos(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var todos []models.Todo
+	cursor, err := h.collection.Find(context.Background(), bson.M{"userId": userID, "completed": false, "deletedAt": nil})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch todos"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	if err = cursor.All(context.Background(), &todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode todos"})
+		return
+	}
+
+	if err := h.annotateBlocked(context.Background(), userID, todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve dependencies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, topologicalOrder(todos))
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestDependencyGraph provides unit tests for blockedBy cycle detection and ordering.
+func TestDependencyGraph(t *testing.T) {
+	a, b, c, d := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// c is blocked by b, which is blocked by a. d is independent.
+	todos := []models.Todo{
+		{ID: c, BlockedBy: []primitive.ObjectID{b}, CreatedAt: base},
+		{ID: d, CreatedAt: base.Add(time.Minute)},
+		{ID: b, BlockedBy: []primitive.ObjectID{a}, CreatedAt: base.Add(2 * time.Minute)},
+		{ID: a, CreatedAt: base.Add(3 * time.Minute)},
+	}
+	graph := newDependencyGraph(todos)
+
+	t.Run("Self dependency is a cycle", func(t *testing.T) {
+		assert.True(t, graph.createsCycle(a, a))
+	})
+
+	t.Run("Direct and transitive back edges are cycles", func(t *testing.T) {
+		assert.True(t, graph.createsCycle(a, b), "a blocked by b closes a->b loop")
+		assert.True(t, graph.createsCycle(a, c), "a blocked by c closes a->b->c loop")
+	})
+
+	t.Run("Forward and unrelated edges are allowed", func(t *testing.T) {
+		assert.False(t, graph.createsCycle(c, a))
+		assert.False(t, graph.createsCycle(d, c))
+		assert.False(t, graph.createsCycle(a, d))
+	})
+
+	t.Run("Topological order puts blockers first", func(t *testing.T) {
+		order := topologicalOrder(todos)
+		var ids []primitive.ObjectID
+		for _, todo := range order {
+			ids = append(ids, todo.ID)
+		}
+		assert.Equal(t, []primitive.ObjectID{d, a, b, c}, ids)
+	})
+
+	t.Run("Topological order takes the oldest ready todo first", func(t *testing.T) {
+		// e and f are both blocked only by a; f is older, so it comes first once a is placed.
+		e, f := primitive.NewObjectID(), primitive.NewObjectID()
+		more := append([]models.Todo{
+			{ID: e, BlockedBy: []primitive.ObjectID{a}, CreatedAt: base.Add(5 * time.Minute)},
+			{ID: f, BlockedBy: []primitive.ObjectID{a}, CreatedAt: base.Add(4 * time.Minute)},
+		}, todos...)
+		var ids []primitive.ObjectID
+		for _, todo := range topologicalOrder(more) {
+			ids = append(ids, todo.ID)
+		}
+		assert.Equal(t, []primitive.ObjectID{d, a, b, c, f, e}, ids)
+	})
+
+	t.Run("Topological order appends todos left in a cycle", func(t *testing.T) {
+		cyclic := []models.Todo{
+			{ID: a, BlockedBy: []primitive.ObjectID{b}, CreatedAt: base},
+			{ID: b, BlockedBy: []primitive.ObjectID{a}, CreatedAt: base.Add(time.Minute)},
+			{ID: d, CreatedAt: base.Add(2 * time.Minute)},
+		}
+		var ids []primitive.ObjectID
+		for _, todo := range topologicalOrder(cyclic) {
+			ids = append(ids, todo.ID)
+		}
+		assert.Equal(t, []primitive.ObjectID{d, a, b}, ids)
+	})
+
+	t.Run("Topological order ignores blockers outside the set", func(t *testing.T) {
+		order := topologicalOrder(todos[:2])
+		assert.Len(t, order, 2)
+		assert.Equal(t, c, order[0].ID)
+	})
+}
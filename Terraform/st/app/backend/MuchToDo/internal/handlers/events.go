@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/realtime"
+)
+
+const (
+	eventHeartbeat   = 15 * time.Second // Keeps idle streams open through proxies that time them out
+	eventRetry       = 3 * time.Second  // How long clients wait before reconnecting
+	eventReplayLimit = 500              // Most missed events replayed on reconnecting; beyond that the client starts over
+	eventReset       = "reset"          // Tells the client to reload its todos, as the events it missed can't be replayed
+	// eventReplayWindow is how far before the last event received replaying starts. An
+	// event's time is taken before its transaction commits, so an event committed after
+	// the last one received can have an earlier time, by up to a transaction's lifetime.
+	eventReplayWindow = time.Minute
+)
+
+// EventHandler streams the user's todo events as Server-Sent Events, so that clients
+// see changes made elsewhere without polling.
+type EventHandler struct {
+	records *mongo.Collection // The outbox, which keeps recent events to replay
+	hub     *realtime.Hub
+}
+
+// NewEventHandler creates a new handler for event streams.
+func NewEventHandler(records *mongo.Collection, hub *realtime.Hub) *EventHandler {
+	return &EventHandler{records: records, hub: hub}
+}
+
+// StreamEvents godoc
+// @Summary      Stream todo events
+// @Description  Streams the user's todo events as Server-Sent Events: todo.created, todo.updated, todo.completed and todo.deleted,
+// @Description  each with the event's ID and the same JSON event webhooks receive. A comment is sent every 15 seconds to keep the
+// @Description  connection open. A client reconnecting with the Last-Event-ID header, or the lastEventId parameter, receives the
+// @Description  events it missed; if they can't be replayed, it receives a "reset" event and should reload its todos.
+// @Description  Replaying starts a minute before the last event received, so events it already has may be repeated.
+// @Tags         events
+// @Produce      text/event-stream
+// @Security     ApiKeyAuth
+// @Param        Last-Event-ID header string false "ID of the last event received"
+// @Param        lastEventId   query  string false "ID of the last event received, for clients that can't set headers"
+// @Success      200  {string}  string "Event stream"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /events [get]
+func (h *EventHandler) StreamEvents(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("lastEventId")
+	}
+
+	// Subscribe before looking up the missed events, so that none are lost in between;
+	// those that arrive both ways are only sent once.
+	subscription := h.hub.Subscribe(userID)
+	defer subscription.Close()
+
+	ctx := c.Request.Context()
+	var missed []models.OutboxRecord
+	replayable := true
+	if lastEventID != "" {
+		missed, replayable, err = h.missedEvents(ctx, userID, lastEventID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load missed events"})
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Stops nginx buffering the stream
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", eventRetry.Milliseconds()); err != nil {
+		return
+	}
+	if !replayable {
+		// An empty ID makes the client reconnect without one, rather than asking again.
+		if err := writeEvent(w, "", eventReset, "{}"); err != nil {
+			return
+		}
+	}
+	sent := map[primitive.ObjectID]bool{}
+	for _, record := range missed {
+		if err := writeEvent(w, record.ID.Hex(), record.EventType, record.Payload); err != nil {
+			return
+		}
+		sent[record.ID] = true
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-subscription.Done():
+			// The client fell behind or the server is shutting down; it reconnects.
+			return
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case record := <-subscription.Events():
+			if sent[record.ID] {
+				delete(sent, record.ID)
+				continue
+			}
+			if err := writeEvent(w, record.ID.Hex(), record.EventType, record.Payload); err != nil {
+				return
+			}
+		}
+		w.Flush()
+	}
+}
+
+// missedEvents returns the user's todo events that may have been recorded after the one
+// with the ID lastEventID, in the order they were recorded. Those recorded within
+// eventReplayWindow before it are included, so some may have been received already;
+// clients can tell from the todo's version. It reports false if they can't be replayed:
+// the ID is unknown, the event has expired, or too many have been missed.
+func (h *EventHandler) missedEvents(ctx context.Context, userID primitive.ObjectID, lastEventID string) ([]models.OutboxRecord, bool, error) {
+	lastID, err := primitive.ObjectIDFromHex(lastEventID)
+	if err != nil {
+		return nil, false, nil
+	}
+	var last models.OutboxRecord
+	err = h.records.FindOne(ctx, bson.M{"_id": lastID, "userId": userID}).Decode(&last)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	filter := bson.M{
+		"userId":        userID,
+		"aggregateType": models.AggregateTodo,
+		"createdAt":     bson.M{"$gte": last.CreatedAt.Add(-eventReplayWindow)},
+		// Events of the same todo up to the last one were received in sequence.
+		"$nor": bson.A{bson.M{"aggregateId": last.AggregateID, "sequence": bson.M{"$lte": last.Sequence}}},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(eventReplayLimit + 1)
+	var records []models.OutboxRecord
+	cursor, err := h.records.Find(ctx, filter, opts)
+	if err == nil {
+		err = cursor.All(ctx, &records)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if len(records) > eventReplayLimit {
+		slog.InfoContext(ctx, "Too many missed events to replay", "userId", userID.Hex())
+		return nil, false, nil
+	}
+	return records, true, nil
+}
+
+// writeEvent writes one Server-Sent Event.
+func writeEvent(w io.Writer, id string, event string, data string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "id: %s\nevent: %s\n", id, event)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
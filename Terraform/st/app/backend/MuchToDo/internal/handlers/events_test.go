@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/realtime"
+)
+
+// TestEvents provides unit tests for event streams, which don't need the database until
+// a client resumes one.
+func TestEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := primitive.NewObjectID()
+	hub := realtime.NewHub(nil)
+	h := NewEventHandler(nil, hub)
+
+	router := gin.New()
+	router.GET("/events", func(c *gin.Context) { c.Set("userID", userID.Hex()) }, h.StreamEvents)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// open starts a stream, returning a function reading its next event or comment.
+	open := func(t *testing.T, lastEventID string) func() string {
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/events", nil)
+		assert.NoError(t, err)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		reader := bufio.NewReader(resp.Body)
+		return func() string {
+			var block strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil || line == "\n" {
+					return block.String()
+				}
+				block.WriteString(line)
+			}
+		}
+	}
+
+	t.Run("Stream", func(t *testing.T) {
+		next := open(t, "")
+		assert.Equal(t, "retry: 3000\n", next())
+
+		record := models.OutboxRecord{
+			ID:            primitive.NewObjectID(),
+			AggregateType: models.AggregateTodo,
+			EventType:     models.TodoEventCompleted,
+			UserID:        userID,
+			Payload:       `{"type":"todo.completed"}`,
+		}
+		// Other users' events aren't sent.
+		other := record
+		other.UserID = primitive.NewObjectID()
+		assert.NoError(t, hub.Publish(t.Context(), other))
+		assert.NoError(t, hub.Publish(t.Context(), record))
+		assert.Equal(t, "id: "+record.ID.Hex()+"\nevent: todo.completed\ndata: {\"type\":\"todo.completed\"}\n", next())
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		next := open(t, "not-an-event")
+		assert.Equal(t, "retry: 3000\n", next())
+		assert.Equal(t, "id: \nevent: reset\ndata: {}\n", next())
+	})
+
+	t.Run("Format", func(t *testing.T) {
+		var b strings.Builder
+		assert.NoError(t, writeEvent(&b, "1", "note", "first\nsecond"))
+		assert.Equal(t, "id: 1\nevent: note\ndata: first\ndata: second\n\n", b.String())
+	})
+}
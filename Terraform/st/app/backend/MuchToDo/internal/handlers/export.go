@@ -0,0 +1,393 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// exportBatchSize is the number of todos fetched from MongoDB per round trip while exporting.
+const exportBatchSize = 500
+
+// todoWriter writes todos one at a time in an export format.
+type todoWriter interface {
+	Write(todo models.Todo) error
+	Close() error // Finishes the document; it doesn't close the underlying writer
+}
+
+// ExportTodos godoc
+// @Summary      Export todos
+// @Description  Streams the user's todos, oldest first, as CSV (RFC 4180, fixed column order), a JSON document or newline-delimited JSON.
+// @Description  format=markdown renders a GitHub task list instead, grouped by project with subtasks nested under their parents in the same project.
+// @Description  Accepts the same filters as the todo list. Archived todos are left out unless includeArchived is true.
+// @Tags         todos
+// @Produce      text/csv
+// @Produce      json
+// @Produce      application/x-ndjson
+// @Produce      text/markdown
+// @Security     ApiKeyAuth
+// @Param        format          query string false "csv (default), json, ndjson or markdown"
+// @Param        completed       query bool   false "Only completed (true) or open (false) todos"
+// @Param        project         query string false "Only todos in this project"
+// @Param        label           query string false "Only todos with this label"
+// @Param        filter          query string false "Filter expression, e.g. due:before:friday & !completed"
+// @Param        includeArchived query bool   false "Also export archived todos"
+// @Success      200  {file}    file "The export, as an attachment"
+// @Failure      400  {object}  map[string]string "Invalid format or filters"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/export [get]
+func (h *TodoHandler) ExportTodos(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	format := c.DefaultQuery("format", models.ExportFormatCSV)
+	contentType, ok := map[string]string{
+		models.ExportFormatCSV:      "text/csv; charset=utf-8",
+		models.ExportFormatJSON:     "application/json; charset=utf-8",
+		models.ExportFormatNDJSON:   "application/x-ndjson; charset=utf-8",
+		models.ExportFormatMarkdown: "text/markdown; charset=utf-8",
+	}[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of csv, json, ndjson or markdown"})
+		return
+	}
+
+	var f models.TodoFilterDTO
+	if err := c.ShouldBindQuery(&f); err != nil {
+		respondFilterError(c, err)
+		return
+	}
+	now := userNow(c)
+	filter, err := todoFilterQuery(userID, f, now)
+	if err != nil {
+		respondFilterError(c, err)
+		return
+	}
+	if includeArchived, _ := strconv.ParseBool(c.Query("includeArchived")); includeArchived {
+		delete(filter, "archivedAt")
+	}
+
+	ctx := c.Request.Context()
+	order := bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}
+	if format == models.ExportFormatMarkdown {
+		// Markdown is written one project section at a time.
+		order = append(bson.D{{Key: "project", Value: 1}}, order...)
+	}
+	opts := options.Find().SetSort(order).SetBatchSize(exportBatchSize).SetAllowDiskUse(true)
+	cursor, err := h.collection.Find(ctx, filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export todos"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="todos-`+now.Format("2006-01-02")+"."+exportExtension(format)+`"`)
+	c.Status(http.StatusOK)
+
+	// The status has been sent, so from here on errors can only cut the export short.
+	buffered := bufio.NewWriter(c.Writer)
+	out := newTodoWriter(format, buffered, now)
+	count := 0
+	for cursor.Next(ctx) {
+		var todo models.Todo
+		if err := cursor.Decode(&todo); err != nil {
+			slog.Error("Failed to decode todo during export", "user", userID.Hex(), slog.Any("error", err))
+			return
+		}
+		if err := out.Write(todo); err != nil {
+			return // The client went away
+		}
+		count++
+		if count%exportBatchSize == 0 {
+			buffered.Flush()
+			c.Writer.Flush()
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		slog.Error("Export cursor failed", "user", userID.Hex(), slog.Any("error", err))
+		return
+	}
+
+	if err := out.Close(); err == nil {
+		buffered.Flush()
+	}
+}
+
+// exportExtension returns the file name extension of an export format.
+func exportExtension(format string) string {
+	if format == models.ExportFormatMarkdown {
+		return "md"
+	}
+	return format
+}
+
+// newTodoWriter returns a todoWriter for one of the ExportFormat* formats. Markdown
+// dates are written in exportedAt's location.
+func newTodoWriter(format string, w io.Writer, exportedAt time.Time) todoWriter {
+	switch format {
+	case models.ExportFormatMarkdown:
+		return &markdownTodoWriter{w: w, loc: exportedAt.Location()}
+	case models.ExportFormatJSON:
+		return &jsonTodoWriter{w: w, exportedAt: exportedAt}
+	case models.ExportFormatNDJSON:
+		return &ndjsonTodoWriter{enc: json.NewEncoder(w)}
+	default:
+		cw := csv.NewWriter(w)
+		cw.UseCRLF = true // RFC 4180 line endings
+		return &csvTodoWriter{w: cw}
+	}
+}
+
+// csvTodoWriter writes todos as CSV rows under a header of models.TodoCSVColumns.
+// Values are written verbatim so that exports can be imported again unchanged.
+type csvTodoWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (t *csvTodoWriter) Write(todo models.Todo) error {
+	if !t.headerWritten {
+		if err := t.w.Write(models.TodoCSVColumns); err != nil {
+			return err
+		}
+		t.headerWritten = true
+	}
+	return t.w.Write(todoCSVRecord(todo))
+}
+
+func (t *csvTodoWriter) Close() error {
+	if !t.headerWritten {
+		if err := t.w.Write(models.TodoCSVColumns); err != nil {
+			return err
+		}
+	}
+	t.w.Flush()
+	return t.w.Error()
+}
+
+// todoCSVRecord formats a todo as a CSV row matching models.TodoCSVColumns.
+func todoCSVRecord(todo models.Todo) []string {
+	recurrence := ""
+	if todo.Recurrence != nil {
+		if encoded, err := json.Marshal(todo.Recurrence); err == nil {
+			recurrence = string(encoded)
+		}
+	}
+	parentID := ""
+	if todo.ParentID != nil {
+		parentID = todo.ParentID.Hex()
+	}
+	blockedBy := make([]string, len(todo.BlockedBy))
+	for i, id := range todo.BlockedBy {
+		blockedBy[i] = id.Hex()
+	}
+	priority := ""
+	if todo.Priority != 0 {
+		priority = strconv.Itoa(todo.Priority)
+	}
+
+	return []string{
+		todo.ID.Hex(),
+		todo.Title,
+		todo.Description,
+		strconv.FormatBool(todo.Completed),
+		todo.Project,
+		strings.Join(todo.Labels, ","),
+		csvTime(todo.DueDate),
+		priority,
+		recurrence,
+		parentID,
+		strings.Join(blockedBy, ","),
+		csvTime(todo.CompletedAt),
+		csvTime(todo.ArchivedAt),
+		csvTime(&todo.CreatedAt),
+		csvTime(&todo.UpdatedAt),
+	}
+}
+
+// csvTime formats an optional timestamp for CSV, in UTC.
+func csvTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// jsonTodoWriter writes todos as a models.TodoExport document, one array element at a time.
+type jsonTodoWriter struct {
+	w          io.Writer
+	exportedAt time.Time
+	started    bool
+}
+
+func (t *jsonTodoWriter) start() error {
+	t.started = true
+	_, err := io.WriteString(t.w, `{"version":`+strconv.Itoa(models.ExportVersion)+
+		`,"exportedAt":"`+t.exportedAt.UTC().Format(time.RFC3339)+`","todos":[`)
+	return err
+}
+
+func (t *jsonTodoWriter) Write(todo models.Todo) error {
+	separator := ",\n"
+	if !t.started {
+		if err := t.start(); err != nil {
+			return err
+		}
+		separator = "\n"
+	}
+	encoded, err := json.Marshal(todo)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(t.w, separator); err != nil {
+		return err
+	}
+	_, err = t.w.Write(encoded)
+	return err
+}
+
+func (t *jsonTodoWriter) Close() error {
+	if !t.started {
+		if err := t.start(); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(t.w, "\n]}\n")
+	return err
+}
+
+// ndjsonTodoWriter writes one JSON-encoded todo per line.
+type ndjsonTodoWriter struct {
+	enc *json.Encoder
+}
+
+func (t *ndjsonTodoWriter) Write(todo models.Todo) error {
+	return t.enc.Encode(todo)
+}
+
+func (t *ndjsonTodoWriter) Close() error {
+	return nil
+}
+
+// markdownTodoWriter writes todos as a GitHub task list that the Markdown importer can
+// read back. The todos must be written sorted by project: each project's section is
+// written once the next project's todos begin, as nesting subtasks needs the whole
+// section, so only one section is held at a time.
+//
+// Todos without a project come first, then one "## project" section per project. Each
+// todo is a "- [ ]" or "- [x]" item followed by its due date, priority and labels as
+// quick-add style tokens, its description as indented lines, and its subtasks in the
+// same project indented beneath it.
+type markdownTodoWriter struct {
+	w       io.Writer
+	loc     *time.Location
+	project string
+	section []models.Todo
+	written bool
+}
+
+func (t *markdownTodoWriter) Write(todo models.Todo) error {
+	if len(t.section) > 0 && todo.Project != t.project {
+		if err := t.writeSection(); err != nil {
+			return err
+		}
+	}
+	t.project = todo.Project
+	t.section = append(t.section, todo)
+	return nil
+}
+
+func (t *markdownTodoWriter) Close() error {
+	return t.writeSection()
+}
+
+// writeSection writes the todos held for the current project under its heading.
+func (t *markdownTodoWriter) writeSection() error {
+	if len(t.section) == 0 {
+		return nil
+	}
+	exported := make(map[primitive.ObjectID]bool, len(t.section))
+	for _, todo := range t.section {
+		exported[todo.ID] = true
+	}
+
+	// Subtasks whose parent isn't part of the section are listed at the top level.
+	children := make(map[primitive.ObjectID][]models.Todo)
+	var top []models.Todo
+	for _, todo := range t.section {
+		if todo.ParentID != nil && exported[*todo.ParentID] {
+			children[*todo.ParentID] = append(children[*todo.ParentID], todo)
+		} else {
+			top = append(top, todo)
+		}
+	}
+
+	var b strings.Builder
+	if t.written {
+		b.WriteString("\n")
+	}
+	if t.project != "" {
+		b.WriteString("## " + t.project + "\n\n")
+	}
+	for _, todo := range top {
+		t.writeItem(&b, todo, children, 0)
+	}
+	t.section = t.section[:0]
+	t.written = true
+
+	_, err := io.WriteString(t.w, b.String())
+	return err
+}
+
+// writeItem writes a todo and, beneath it, its subtasks.
+func (t *markdownTodoWriter) writeItem(b *strings.Builder, todo models.Todo, children map[primitive.ObjectID][]models.Todo, depth int) {
+	indent := strings.Repeat("  ", depth)
+	box := "[ ]"
+	if todo.Completed {
+		box = "[x]"
+	}
+	b.WriteString(indent + "- " + box + " " + strings.Join(strings.Fields(todo.Title), " "))
+
+	if todo.DueDate != nil {
+		due := todo.DueDate.In(t.loc)
+		if allDay(due) {
+			b.WriteString(" due:" + due.Format("2006-01-02"))
+		} else {
+			b.WriteString(" due:" + due.Format("2006-01-02T15:04"))
+		}
+	}
+	if todo.Priority != 0 {
+		b.WriteString(" p" + strconv.Itoa(todo.Priority))
+	}
+	for _, label := range todo.Labels {
+		b.WriteString(" #" + strings.Join(strings.Fields(label), "-"))
+	}
+	b.WriteString("\n")
+
+	if todo.Description != "" {
+		for _, line := range strings.Split(todo.Description, "\n") {
+			b.WriteString(strings.TrimRight(indent+"  "+line, " \t\r") + "\n")
+		}
+	}
+	for _, child := range children[todo.ID] {
+		t.writeItem(b, child, children, depth+1)
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/importer"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestExport provides unit tests for the export writers.
+func TestExport(t *testing.T) {
+	created := time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)
+	due := time.Date(2025, 3, 14, 17, 0, 0, 0, time.FixedZone("CET", 3600))
+	blocker := primitive.NewObjectID()
+	todos := []models.Todo{
+		{
+			ID:          primitive.NewObjectID(),
+			Title:       `Say "hi", then leave`,
+			Description: "Line one\nline two",
+			Project:     "home",
+			Labels:      []string{"errand", "quick"},
+			BlockedBy:   []primitive.ObjectID{blocker},
+			DueDate:     &due,
+			Priority:    2,
+			Recurrence:  &models.Recurrence{Frequency: "weekly", Interval: 1},
+			CreatedAt:   created,
+			UpdatedAt:   created,
+		},
+		{ID: primitive.NewObjectID(), Title: "Plain", Completed: true, CreatedAt: created, UpdatedAt: created},
+	}
+
+	export := func(format string, todos []models.Todo) string {
+		var buf bytes.Buffer
+		w := newTodoWriter(format, &buf, created)
+		for _, todo := range todos {
+			assert.NoError(t, w.Write(todo))
+		}
+		assert.NoError(t, w.Close())
+		return buf.String()
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		out := export(models.ExportFormatCSV, todos)
+		assert.True(t, strings.HasPrefix(out, strings.Join(models.TodoCSVColumns, ",")+"\r\n"))
+		assert.Contains(t, out, `"Say ""hi"", then leave"`)
+
+		records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+		assert.NoError(t, err)
+		assert.Len(t, records, 3)
+		first := records[1]
+		assert.Len(t, first, len(models.TodoCSVColumns))
+		assert.Equal(t, todos[0].ID.Hex(), first[0])
+		assert.Equal(t, "Line one\nline two", first[2])
+		assert.Equal(t, "false", first[3])
+		assert.Equal(t, "errand,quick", first[5])
+		assert.Equal(t, "2025-03-14T16:00:00Z", first[6]) // Converted to UTC
+		assert.Equal(t, "2", first[7])
+		assert.Equal(t, blocker.Hex(), first[10])
+		assert.Equal(t, "", first[11])
+		assert.Equal(t, "2025-03-01T09:30:00Z", first[13])
+
+		var recurrence models.Recurrence
+		assert.NoError(t, json.Unmarshal([]byte(first[8]), &recurrence))
+		assert.Equal(t, "weekly", recurrence.Frequency)
+
+		assert.Equal(t, "true", records[2][3])
+		assert.Equal(t, "", records[2][7])
+	})
+
+	t.Run("CSVEmpty", func(t *testing.T) {
+		assert.Equal(t, strings.Join(models.TodoCSVColumns, ",")+"\r\n", export(models.ExportFormatCSV, nil))
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var doc models.TodoExport
+		assert.NoError(t, json.Unmarshal([]byte(export(models.ExportFormatJSON, todos)), &doc))
+		assert.Equal(t, models.ExportVersion, doc.Version)
+		assert.True(t, created.Equal(doc.ExportedAt))
+		assert.Len(t, doc.Todos, 2)
+		assert.Equal(t, todos[0].Title, doc.Todos[0].Title)
+		assert.Equal(t, []string{"errand", "quick"}, doc.Todos[0].Labels)
+
+		doc = models.TodoExport{}
+		assert.NoError(t, json.Unmarshal([]byte(export(models.ExportFormatJSON, nil)), &doc))
+		assert.Equal(t, models.ExportVersion, doc.Version)
+		assert.Empty(t, doc.Todos)
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSuffix(export(models.ExportFormatNDJSON, todos), "\n"), "\n")
+		assert.Len(t, lines, 2)
+		var todo models.Todo
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &todo))
+		assert.Equal(t, "Plain", todo.Title)
+		assert.True(t, todo.Completed)
+
+		assert.Equal(t, "", export(models.ExportFormatNDJSON, nil))
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		loc := time.FixedZone("CET", 3600)
+		day := time.Date(2025, 3, 20, 0, 0, 0, 0, loc)
+		parent := todos[0]
+		child := models.Todo{ID: primitive.NewObjectID(), Title: "Buy card", ParentID: &parent.ID, Project: "home", DueDate: &day, Completed: true}
+		elsewhere := models.Todo{ID: primitive.NewObjectID(), Title: "File receipt", ParentID: &parent.ID, Project: "work"}
+		orphan := models.Todo{ID: primitive.NewObjectID(), Title: "Orphan", ParentID: &blocker, Project: "home", Labels: []string{"two words"}}
+
+		var buf bytes.Buffer
+		w := newTodoWriter(models.ExportFormatMarkdown, &buf, created.In(loc))
+		for _, todo := range []models.Todo{todos[1], parent, child, orphan, elsewhere} {
+			assert.NoError(t, w.Write(todo))
+		}
+		assert.NoError(t, w.Close())
+		assert.Equal(t, "- [x] Plain\n"+
+			"\n"+
+			"## home\n"+
+			"\n"+
+			`- [ ] Say "hi", then leave due:2025-03-14T17:00 p2 #errand #quick`+"\n"+
+			"  Line one\n"+
+			"  line two\n"+
+			"  - [x] Buy card due:2025-03-20\n"+
+			"- [ ] Orphan #two-words\n"+
+			"\n"+
+			"## work\n"+
+			"\n"+
+			"- [ ] File receipt\n", buf.String())
+
+		items, err := importer.Parse("", buf.Bytes(), loc)
+		assert.NoError(t, err)
+		assert.Len(t, items, 5)
+		assert.Equal(t, parent.Title, items[1].Title)
+		assert.Equal(t, parent.Description, items[1].Description)
+		assert.Equal(t, "home", items[1].Project)
+		assert.Equal(t, parent.Labels, items[1].Labels)
+		assert.True(t, due.Equal(*items[1].DueDate))
+		assert.Equal(t, 2, items[1].Priority)
+		assert.Equal(t, items[1].Ref, items[2].ParentRef)
+		assert.True(t, items[2].Completed)
+		assert.True(t, day.Equal(*items[2].DueDate))
+		assert.Equal(t, "", items[3].ParentRef)
+		assert.Equal(t, "work", items[4].Project)
+		assert.Equal(t, "", items[4].ParentRef)
+
+		assert.Equal(t, "", export(models.ExportFormatMarkdown, nil))
+	})
+}
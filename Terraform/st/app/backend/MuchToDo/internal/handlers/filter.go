@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/query"
+)
+
+// FilterHandler holds dependencies for saved filter handlers.
+type FilterHandler struct {
+	collection *mongo.Collection
+	todos      *TodoHandler // Used to run saved filters
+}
+
+// NewFilterHandler creates a new handler for saved filters.
+func NewFilterHandler(collection *mongo.Collection, todos *TodoHandler) *FilterHandler {
+	return &FilterHandler{
+		collection: collection,
+		todos:      todos,
+	}
+}
+
+// CreateFilter godoc
+// @Summary      Save a filter
+// @Description  Saves a named filter expression for the current user
+// @Tags         filters
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        filter body models.SavedFilterDTO true "Filter name and expression"
+// @Success      201  {object}  models.SavedFilter
+// @Failure      400  {object}  map[string]string "Invalid input or filter expression"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      409  {object}  map[string]string "A filter with this name already exists"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /filters [post]
+func (h *FilterHandler) CreateFilter(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	dto, ok := bindSavedFilter(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	filter := models.SavedFilter{
+		UserID:    userID,
+		Name:      dto.Name,
+		Query:     dto.Query,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	result, err := h.collection.InsertOne(context.Background(), filter)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A filter with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save filter"})
+		return
+	}
+	filter.ID = result.InsertedID.(primitive.ObjectID)
+
+	c.JSON(http.StatusCreated, filter)
+}
+
+// GetFilters godoc
+// @Summary      List saved filters
+// @Description  Retrieves the current user's saved filters, sorted by name
+// @Tags         filters
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {array}  models.SavedFilter
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /filters [get]
+func (h *FilterHandler) GetFilters(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := h.collection.Find(context.Background(), bson.M{"userId": userID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch filters"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var filters []models.SavedFilter
+	if err = cursor.All(context.Background(), &filters); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode filters"})
+		return
+	}
+
+	if filters == nil {
+		filters = []models.SavedFilter{}
+	}
+
+	c.JSON(http.StatusOK, filters)
+}
+
+// GetFilterByID godoc
+// @Summary      Get a saved filter
+// @Description  Retrieves a single saved filter by its ID
+// @Tags         filters
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Filter ID"
+// @Success      200  {object}  models.SavedFilter
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Filter not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /filters/{id} [get]
+func (h *FilterHandler) GetFilterByID(c *gin.Context) {
+	filter, ok := h.findFilter(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, filter)
+}
+
+// UpdateFilter godoc
+// @Summary      Update a saved filter
+// @Description  Renames a saved filter and/or replaces its expression
+// @Tags         filters
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Filter ID"
+// @Param        filter body models.SavedFilterDTO true "Filter name and expression"
+// @Success      200  {object}  models.SavedFilter
+// @Failure      400  {object}  map[string]string "Invalid input or filter expression"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Filter not found"
+// @Failure      409  {object}  map[string]string "A filter with this name already exists"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /filters/{id} [put]
+func (h *FilterHandler) UpdateFilter(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	dto, ok := bindSavedFilter(c)
+	if !ok {
+		return
+	}
+
+	var filter models.SavedFilter
+	update := bson.M{"$set": bson.M{"name": dto.Name, "query": dto.Query, "updatedAt": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = h.collection.FindOneAndUpdate(context.Background(), bson.M{"_id": id, "userId": userID}, update, opts).Decode(&filter)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Filter not found"})
+			return
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A filter with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update filter"})
+		return
+	}
+
+	c.JSON(http.StatusOK, filter)
+}
+
+// DeleteFilter godoc
+// @Summary      Delete a saved filter
+// @Description  Deletes a saved filter. The todos it matched are not affected.
+// @Tags         filters
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Filter ID"
+// @Success      200  {object}  map[string]string "{'message': 'Filter deleted successfully'}"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Filter not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /filters/{id} [delete]
+func (h *FilterHandler) DeleteFilter(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	result, err := h.collection.DeleteOne(context.Background(), bson.M{"_id": id, "userId": userID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete filter"})
+		return
+	}
+
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Filter not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Filter deleted successfully"})
+}
+
+// RunFilter godoc
+// @Summary      Run a saved filter
+// @Description  Lists the user's live todos matching a saved filter. Relative days such as "friday" are resolved at run time.
+// @Tags         filters
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Filter ID"
+// @Success      200  {array}  models.Todo
+// @Failure      400  {object}  map[string]string "Invalid ID format or stored expression"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Filter not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /filters/{id}/todos [get]
+func (h *FilterHandler) RunFilter(c *gin.Context) {
+	saved, ok := h.findFilter(c)
+	if !ok {
+		return
+	}
+
+	filter, err := todoFilterQuery(saved.UserID, models.TodoFilterDTO{Query: saved.Query}, userNow(c))
+	if err != nil {
+		respondFilterError(c, err)
+		return
+	}
+
+	h.todos.listTodos(c, saved.UserID, filter)
+}
+
+// findFilter loads the saved filter named by the :id parameter, writing an error response
+// and returning false if it can't.
+func (h *FilterHandler) findFilter(c *gin.Context) (models.SavedFilter, bool) {
+	var filter models.SavedFilter
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return filter, false
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return filter, false
+	}
+
+	err = h.collection.FindOne(context.Background(), bson.M{"_id": id, "userId": userID}).Decode(&filter)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Filter not found"})
+			return filter, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch filter"})
+		return filter, false
+	}
+
+	return filter, true
+}
+
+// bindSavedFilter binds and validates a saved filter from the request body, writing an
+// error response and returning false if it is invalid.
+func bindSavedFilter(c *gin.Context) (models.SavedFilterDTO, bool) {
+	var dto models.SavedFilterDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return dto, false
+	}
+
+	dto.Name = strings.TrimSpace(dto.Name)
+	dto.Query = strings.TrimSpace(dto.Query)
+	if dto.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Filter name is required"})
+		return dto, false
+	}
+	if _, err := query.Parse(dto.Query); err != nil {
+		respondFilterError(c, err)
+		return dto, false
+	}
+
+	return dto, true
+}
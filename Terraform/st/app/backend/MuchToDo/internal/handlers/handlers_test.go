@@ -94,7 +94,7 @@ func (s *UserHandlerIntegrationTestSuite) SetupSuite() {
 	userCollection := s.db.Database(s.cfg.DBName).Collection("users")
 	todoCollection := s.db.Database(s.cfg.DBName).Collection("todos")
 
-	userHandler := NewUserHandler(userCollection, todoCollection, tokenService, s.cacheService, s.db, s.cfg)
+	userHandler := NewUserHandler(userCollection, todoCollection, tokenService, s.cacheService, s.db, s.cfg, nil, nil, nil)
 
 	// Setup routes for testing
 	authRoutes := s.router.Group("/auth")
@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// withTransaction runs fn inside a MongoDB transaction. The todo events of the changes
+// it records are published once it commits.
+func (h *TodoHandler) withTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	session, err := h.collection.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	if err == nil && h.outbox != nil {
+		h.outbox.Notify()
+	}
+	return err
+}
+
+// mutateTodo applies update to the todo matching filter, records the change in the
+// todo's history and saves an undo operation for it, all in one transaction. It returns
+// the todo as it is after the update and the undo token, or mongo.ErrNoDocuments if
+// nothing matched.
+func (h *TodoHandler) mutateTodo(ctx context.Context, actor primitive.ObjectID, filter bson.M, update interface{}, action string) (models.Todo, string, error) {
+	var after models.Todo
+	var undoToken string
+	err := h.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		var err error
+		after, undoToken, err = h.applyMutation(sessCtx, actor, filter, update, action)
+		return err
+	})
+	return after, undoToken, err
+}
+
+// applyMutation does the work of mutateTodo inside a transaction the caller runs.
+func (h *TodoHandler) applyMutation(sessCtx mongo.SessionContext, actor primitive.ObjectID, filter bson.M, update interface{}, action string) (models.Todo, string, error) {
+	var before, after models.Todo
+	if err := h.collection.FindOne(sessCtx, filter).Decode(&before); err != nil {
+		return after, "", err
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if err := h.collection.FindOneAndUpdate(sessCtx, bson.M{"_id": before.ID}, update, opts).Decode(&after); err != nil {
+		return after, "", err
+	}
+
+	version, err := h.recordVersion(sessCtx, actor, action, &before, after)
+	if err != nil {
+		return after, "", err
+	}
+
+	undoToken, err := h.saveUndo(sessCtx, actor, action, []models.UndoItem{{Before: before, Version: version}})
+	return after, undoToken, err
+}
+
+// latestVersion returns the most recent history version of a todo, or 0 if it has none.
+func (h *TodoHandler) latestVersion(ctx context.Context, todoID primitive.ObjectID) (int, error) {
+	var last models.TodoVersion
+	opts := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}}).SetProjection(bson.M{"version": 1})
+	err := h.historyCollection.FindOne(ctx, bson.M{"todoId": todoID}, opts).Decode(&last)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return last.Version, nil
+}
+
+// recordVersion appends a new version to a todo's history, and its event to the outbox,
+// stamps the todo with the user's next change number and returns the version's number.
+// before is nil for newly created todos.
+func (h *TodoHandler) recordVersion(ctx context.Context, actor primitive.ObjectID, action string, before *models.Todo, after models.Todo) (int, error) {
+	last, err := h.latestVersion(ctx, after.ID)
+	if err != nil {
+		return 0, err
+	}
+	version := last + 1
+
+	entry := models.TodoVersion{
+		TodoID:    after.ID,
+		UserID:    after.UserID,
+		Version:   version,
+		Action:    action,
+		ChangedBy: actor,
+		ChangedAt: time.Now(),
+		Changes:   diffTodos(before, after),
+		Snapshot:  after,
+	}
+	if _, err := h.historyCollection.InsertOne(ctx, entry); err != nil {
+		return 0, err
+	}
+	seq, err := h.nextChange(ctx, after.UserID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := h.collection.UpdateOne(ctx, bson.M{"_id": after.ID}, bson.M{"$set": bson.M{"changeSeq": seq}}); err != nil {
+		return 0, err
+	}
+	if h.outbox != nil {
+		event := versionEvent(before, entry)
+		record := models.OutboxRecord{ID: event.ID, AggregateType: models.AggregateTodo, AggregateID: event.TodoID, EventType: event.Type, UserID: event.UserID}
+		if err := h.outbox.Append(ctx, record, event); err != nil {
+			return 0, err
+		}
+	}
+	return version, nil
+}
+
+// todoChangesCollection holds each user's models.TodoChanges.
+const todoChangesCollection = "todo_changes"
+
+// nextChange advances the user's change sequence, inside the caller's transaction, and
+// returns the new number.
+func (h *TodoHandler) nextChange(ctx context.Context, userID primitive.ObjectID) (int64, error) {
+	var changes models.TodoChanges
+	update := bson.M{"$inc": bson.M{"seq": 1}, "$set": bson.M{"changedAt": time.Now()}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := h.collection.Database().Collection(todoChangesCollection).FindOneAndUpdate(ctx, bson.M{"_id": userID}, update, opts).Decode(&changes)
+	return changes.Seq, err
+}
+
+// lastChange returns the user's change sequence as it stands, which is zero if their
+// todos never changed.
+func (h *TodoHandler) lastChange(ctx context.Context, userID primitive.ObjectID) (models.TodoChanges, error) {
+	changes := models.TodoChanges{UserID: userID}
+	err := h.collection.Database().Collection(todoChangesCollection).FindOne(ctx, bson.M{"_id": userID}).Decode(&changes)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		err = nil
+	}
+	return changes, err
+}
+
+// versionEvent describes the change recorded by a history entry as a todo event.
+func versionEvent(before *models.Todo, entry models.TodoVersion) models.TodoEvent {
+	eventType := models.TodoEventUpdated
+	switch {
+	case entry.Action == models.TodoActionCreated:
+		eventType = models.TodoEventCreated
+	case entry.Action == models.TodoActionDeleted:
+		eventType = models.TodoEventDeleted
+	case entry.Snapshot.Completed && (before == nil || !before.Completed):
+		eventType = models.TodoEventCompleted
+	}
+
+	return models.TodoEvent{
+		ID:         primitive.NewObjectID(),
+		Type:       eventType,
+		UserID:     entry.UserID,
+		TodoID:     entry.TodoID,
+		Action:     entry.Action,
+		Version:    entry.Version,
+		Changes:    entry.Changes,
+		OccurredAt: entry.ChangedAt,
+		Todo:       entry.Snapshot,
+	}
+}
+
+// diffTodos lists the user-visible fields that differ between two versions of a todo.
+func diffTodos(before *models.Todo, after models.Todo) []models.FieldChange {
+	if before == nil {
+		before = &models.Todo{}
+	}
+
+	changes := []models.FieldChange{}
+	add := func(field string, changed bool, old, new interface{}) {
+		if changed {
+			changes = append(changes, models.FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+
+	add("title", before.Title != after.Title, before.Title, after.Title)
+	add("description", before.Description != after.Description, before.Description, after.Description)
+	add("completed", before.Completed != after.Completed, before.Completed, after.Completed)
+	add("parentId", !sameObjectIDPtr(before.ParentID, after.ParentID), before.ParentID, after.ParentID)
+	add("project", before.Project != after.Project, before.Project, after.Project)
+	add("labels", !sameStrings(before.Labels, after.Labels), before.Labels, after.Labels)
+	add("dueDate", !sameTime(before.DueDate, after.DueDate), before.DueDate, after.DueDate)
+	add("priority", before.Priority != after.Priority, before.Priority, after.Priority)
+	add("recurrence", !reflect.DeepEqual(before.Recurrence, after.Recurrence), before.Recurrence, after.Recurrence)
+	add("blockedBy", !sameObjectIDs(before.BlockedBy, after.BlockedBy), before.BlockedBy, after.BlockedBy)
+	add("completedAt", !sameTime(before.CompletedAt, after.CompletedAt), before.CompletedAt, after.CompletedAt)
+	add("archivedAt", !sameTime(before.ArchivedAt, after.ArchivedAt), before.ArchivedAt, after.ArchivedAt)
+	add("deletedAt", !sameTime(before.DeletedAt, after.DeletedAt), before.DeletedAt, after.DeletedAt)
+
+	return changes
+}
+
+func sameObjectIDPtr(a, b *primitive.ObjectID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameObjectIDs(a, b []primitive.ObjectID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// GetTodoHistory godoc
+// @Summary      Get a todo's change history
+// @Description  Lists the recorded versions of a todo, newest first, with a field-level diff for each change
+// @Tags         history
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Todo ID"
+// @Param        page query int false "Page number (default 1, max 10000)"
+// @Param        limit query int false "Page size (default 20, max 100)"
+// @Success      200  {object}  map[string]interface{} "A page of models.TodoVersion"
+// @Failure      400  {object}  map[string]string "Invalid ID format or page"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/history [get]
+func (h *TodoHandler) GetTodoHistory(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+	filter := bson.M{"todoId": id, "userId": userID}
+
+	total, err := h.historyCollection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count history"})
+		return
+	}
+
+	var versions []models.TodoVersion
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}}).SetSkip(p.Skip()).SetLimit(p.Limit)
+	cursor, err := h.historyCollection.Find(context.Background(), filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch history"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	if err = cursor.All(context.Background(), &versions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode history"})
+		return
+	}
+
+	if versions == nil {
+		versions = []models.TodoVersion{}
+	}
+
+	c.JSON(http.StatusOK, paginatedResponse(versions, p, total))
+}
+
+// RevertTodo godoc
+// @Summary      Revert a todo to a previous version
+// @Description  Restores the title, description, project, labels, due date, priority, recurrence and completion state recorded in the given version.
+// @Description  Dependencies, archive and trash state are left as they are, except that a todo reverted to an incomplete version leaves the archive.
+// @Description  The revert is itself recorded as a new version.
+// @Tags         history
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Todo ID"
+// @Param        version path int true "Version to revert to"
+// @Success      200  {object}  models.Todo
+// @Header       200  {string}  X-Undo-Token "Token for POST /undo/{token}"
+// @Failure      400  {object}  map[string]string "Invalid ID or version"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Todo or version not found"
+// @Failure      409  {object}  map[string]interface{} "Todo is blocked by incomplete todos"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/history/{version}/revert [post]
+func (h *TodoHandler) RevertTodo(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	var target models.TodoVersion
+	err = h.historyCollection.FindOne(context.Background(), bson.M{"todoId": id, "userId": userID, "version": version}).Decode(&target)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch version"})
+		return
+	}
+
+	filter := bson.M{"_id": id, "userId": userID, "deletedAt": nil}
+	snapshot := target.Snapshot
+	update := revertUpdate(snapshot, time.Now())
+
+	var todo models.Todo
+	var undoToken string
+	err = h.withTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		var current models.Todo
+		if err := h.collection.FindOne(sessCtx, filter).Decode(&current); err != nil {
+			return err
+		}
+		if snapshot.Completed != current.Completed {
+			if err := h.lockGraph(sessCtx, userID); err != nil {
+				return err
+			}
+		}
+		if snapshot.Completed && !current.Completed {
+			if err := h.checkUnblocked(sessCtx, userID, current); err != nil {
+				return err
+			}
+		}
+		var err error
+		todo, undoToken, err = h.applyMutation(sessCtx, userID, filter, update, models.TodoActionReverted)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found or you don't have permission"})
+			return
+		}
+		if respondBlocked(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revert todo"})
+		return
+	}
+
+	setUndoToken(c, undoToken)
+	c.JSON(http.StatusOK, todo)
+}
+
+// revertUpdate returns the update that restores the fields a revert brings back from a
+// version's snapshot.
+func revertUpdate(snapshot models.Todo, now time.Time) bson.M {
+	set := bson.M{
+		"title":       snapshot.Title,
+		"description": snapshot.Description,
+		"completed":   snapshot.Completed,
+		"updatedAt":   primitive.NewDateTimeFromTime(now),
+	}
+	unset := bson.M{}
+	if snapshot.CompletedAt != nil {
+		set["completedAt"] = snapshot.CompletedAt
+	} else {
+		unset["completedAt"] = ""
+	}
+	if !snapshot.Completed {
+		// A reopened todo leaves the archive, which only holds completed todos.
+		unset["archivedAt"] = ""
+	}
+	if snapshot.Project != "" {
+		set["project"] = snapshot.Project
+	} else {
+		unset["project"] = ""
+	}
+	if len(snapshot.Labels) > 0 {
+		set["labels"] = snapshot.Labels
+	} else {
+		unset["labels"] = ""
+	}
+	if snapshot.DueDate != nil {
+		set["dueDate"] = snapshot.DueDate
+	} else {
+		unset["dueDate"] = ""
+	}
+	if snapshot.Priority > 0 {
+		set["priority"] = snapshot.Priority
+	} else {
+		unset["priority"] = ""
+	}
+	if snapshot.Recurrence != nil {
+		set["recurrence"] = snapshot.Recurrence
+	} else {
+		unset["recurrence"] = ""
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestHistory provides unit tests for the diffs and events recorded with each version of
+// a todo, and for the requests that are refused before the database is touched.
+func TestHistory(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := primitive.NewObjectID()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	fields := func(changes []models.FieldChange) []string {
+		names := []string{}
+		for _, change := range changes {
+			names = append(names, change.Field)
+		}
+		return names
+	}
+
+	t.Run("Diff", func(t *testing.T) {
+		blocker := primitive.NewObjectID()
+		todo := models.Todo{ID: primitive.NewObjectID(), UserID: userID, Title: "Ship it", BlockedBy: []primitive.ObjectID{blocker}}
+		assert.Empty(t, diffTodos(&todo, todo))
+
+		// Equal times in other locations, and copies of slices, are no change.
+		same := todo
+		same.BlockedBy = []primitive.ObjectID{blocker}
+		done, doneElsewhere := now, now.In(time.FixedZone("UTC+2", 2*60*60))
+		todo.CompletedAt, same.CompletedAt = &done, &doneElsewhere
+		assert.Empty(t, diffTodos(&todo, same))
+		todo.CompletedAt = nil
+
+		after := todo
+		after.Title = "Ship it today"
+		after.Completed = true
+		after.CompletedAt = &now
+		after.BlockedBy = nil
+		assert.Equal(t, []string{"title", "completed", "blockedBy", "completedAt"}, fields(diffTodos(&todo, after)))
+
+		changes := diffTodos(&todo, after)
+		assert.Equal(t, models.FieldChange{Field: "title", Old: "Ship it", New: "Ship it today"}, changes[0])
+		assert.Equal(t, models.FieldChange{Field: "completed", Old: false, New: true}, changes[1])
+
+		archived := todo
+		archived.ArchivedAt = &now
+		assert.Equal(t, []string{"archivedAt"}, fields(diffTodos(&todo, archived)))
+		deleted := todo
+		deleted.DeletedAt = &now
+		assert.Equal(t, []string{"deletedAt"}, fields(diffTodos(&todo, deleted)))
+
+		// A new todo's changes are its set fields.
+		assert.Equal(t, []string{"title", "blockedBy"}, fields(diffTodos(nil, todo)))
+	})
+
+	t.Run("Event", func(t *testing.T) {
+		todo := models.Todo{ID: primitive.NewObjectID(), UserID: userID, Title: "Ship it"}
+		after := todo
+		after.Title = "Ship it today"
+		entry := models.TodoVersion{
+			TodoID:    todo.ID,
+			UserID:    userID,
+			Version:   4,
+			Action:    models.TodoActionReverted,
+			ChangedAt: now,
+			Changes:   diffTodos(&todo, after),
+			Snapshot:  after,
+		}
+
+		event := versionEvent(&todo, entry)
+		assert.Equal(t, models.TodoEventUpdated, event.Type)
+		assert.Equal(t, userID, event.UserID)
+		assert.Equal(t, 4, event.Version)
+		assert.Equal(t, models.TodoActionReverted, event.Action)
+		assert.Equal(t, entry.Changes, event.Changes)
+		assert.Equal(t, now, event.OccurredAt)
+		assert.Equal(t, after, event.Todo)
+		// Each event gets its own ID, which is also its outbox record's.
+		assert.False(t, event.ID.IsZero())
+		assert.NotEqual(t, event.ID, versionEvent(&todo, entry).ID)
+
+		// Reverting to a completed version completes the todo.
+		after.Completed = true
+		entry.Snapshot = after
+		assert.Equal(t, models.TodoEventCompleted, versionEvent(&todo, entry).Type)
+	})
+
+	t.Run("Revert", func(t *testing.T) {
+		done := models.Todo{Title: "Ship it", Completed: true, CompletedAt: &now, Project: "Work", Labels: []string{"urgent"}, Priority: 2}
+		update := revertUpdate(done, now)
+		set := update["$set"].(bson.M)
+		assert.Equal(t, "Ship it", set["title"])
+		assert.Equal(t, true, set["completed"])
+		assert.Equal(t, "Work", set["project"])
+		assert.Equal(t, []string{"urgent"}, set["labels"])
+		// Archived todos stay archived when reverted to a completed version.
+		assert.Equal(t, bson.M{"dueDate": "", "recurrence": ""}, update["$unset"])
+
+		// Reverting to an incomplete version reopens the todo, taking it out of the archive.
+		update = revertUpdate(models.Todo{Title: "Ship it"}, now)
+		assert.Equal(t, false, update["$set"].(bson.M)["completed"])
+		assert.Contains(t, update["$unset"], "completedAt")
+		assert.Contains(t, update["$unset"], "archivedAt")
+	})
+
+	t.Run("Requests", func(t *testing.T) {
+		h := &TodoHandler{}
+		for _, params := range []gin.Params{
+			{{Key: "id", Value: "nope"}, {Key: "version", Value: "1"}},
+			{{Key: "id", Value: primitive.NewObjectID().Hex()}, {Key: "version", Value: "0"}},
+			{{Key: "id", Value: primitive.NewObjectID().Hex()}, {Key: "version", Value: "latest"}},
+		} {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userID", userID.Hex())
+			c.Request = httptest.NewRequest(http.MethodPost, "/tasks/id/history/version/revert", nil)
+			c.Params = params
+			h.RevertTodo(c)
+			assert.Equal(t, http.StatusBadRequest, w.Code, params)
+		}
+	})
+}
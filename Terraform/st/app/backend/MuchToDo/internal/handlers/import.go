@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/importer"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+const (
+	maxImportBytes = 10 << 20 // Largest import file accepted
+	maxImportItems = 2000     // Most todos a single import may create
+)
+
+// importRun holds the todos of an import file as they are checked and prepared.
+type importRun struct {
+	items   []importer.Item
+	results []models.ImportRowResult
+	todos   []*models.Todo // Todo to create per item; nil for invalid items and duplicates
+	ids     map[string]primitive.ObjectID
+	rows    map[string]int // Index of the item with a given ref
+}
+
+// ImportTodos godoc
+// @Summary      Import todos
+// @Description  Creates todos from a CSV file, one of our JSON or NDJSON exports, a Todoist or Trello JSON export, or a Markdown task list.
+// @Description  Every row is validated first and reported individually. If any row is invalid nothing is imported; with dryRun=true nothing is imported either way.
+// @Description  Todos whose title (ignoring case) and project match an existing todo or an earlier row are skipped unless duplicates=import.
+// @Description  Projects and labels can be renamed with projectMap[old]=new and labelMap[old]=new fields. Subtasks and blockers within the file are kept.
+// @Tags         todos
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        file       formData file   true  "File to import"
+// @Param        format     formData string false "csv, json, ndjson, todoist, trello or markdown; detected if omitted"
+// @Param        dryRun     formData bool   false "Only validate and report"
+// @Param        duplicates formData string false "skip (default) or import"
+// @Success      200  {object}  models.ImportResult "Dry run report"
+// @Success      201  {object}  models.ImportResult "Todos imported"
+// @Failure      400  {object}  map[string]string "Invalid options or unreadable file"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      413  {object}  map[string]string "File too large"
+// @Failure      422  {object}  models.ImportResult "Some rows are invalid; nothing was imported"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/import [post]
+func (h *TodoHandler) ImportTodos(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	// Leave some room for the multipart envelope around the file itself.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes+1<<20)
+	var opts models.ImportOptionsDTO
+	if err := c.ShouldBind(&opts); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large", "maxBytes": maxImportBytes})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A file is required in the \"file\" field"})
+		return
+	}
+	defer file.Close()
+	if header.Size > maxImportBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large", "maxBytes": maxImportBytes})
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read file"})
+		return
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = importer.Detect(data)
+	}
+	prefs := userPreferences(c)
+	items, err := importer.Parse(format, data, prefs.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import file: " + err.Error()})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The file contains no todos"})
+		return
+	}
+	if len(items) > maxImportItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("A single import can contain at most %d todos", maxImportItems)})
+		return
+	}
+
+	run := newImportRun(items)
+	run.mapNames(c.PostFormMap("projectMap"), c.PostFormMap("labelMap"), prefs.DefaultProject)
+	if opts.Duplicates != models.ImportDuplicatesImport {
+		if err := h.markDuplicates(c.Request.Context(), userID, run); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for duplicates"})
+			return
+		}
+	}
+	run.build(userID, time.Now())
+
+	result := run.result(format, opts.DryRun)
+	if opts.DryRun {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	if result.Invalid > 0 {
+		result.Imported = 0
+		c.JSON(http.StatusUnprocessableEntity, result)
+		return
+	}
+
+	err = h.withTransaction(c.Request.Context(), func(sessCtx mongo.SessionContext) error {
+		// IDs are assigned up front, so a retried transaction inserts the same todos again.
+		for _, todo := range run.todos {
+			if todo == nil {
+				continue
+			}
+			if err := h.insertTodoInTransaction(sessCtx, todo); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import todos"})
+		return
+	}
+
+	for i, todo := range run.todos {
+		if todo != nil {
+			result.Rows[i].ID = todo.ID.Hex()
+		}
+	}
+	c.JSON(http.StatusCreated, result)
+}
+
+// newImportRun prepares the parsed items of an import file, reporting items whose
+// ref appears more than once.
+func newImportRun(items []importer.Item) *importRun {
+	run := &importRun{
+		items:   items,
+		results: make([]models.ImportRowResult, len(items)),
+		todos:   make([]*models.Todo, len(items)),
+		ids:     make(map[string]primitive.ObjectID),
+		rows:    make(map[string]int),
+	}
+	for i := range items {
+		it := &items[i]
+		run.results[i] = models.ImportRowResult{Row: it.Row, Title: it.Title, Status: models.ImportStatusOK}
+		if it.Ref == "" {
+			continue
+		}
+		if first, seen := run.rows[it.Ref]; seen {
+			it.Errors = append(it.Errors, fmt.Sprintf("id %q is already used on row %d", it.Ref, items[first].Row))
+			continue
+		}
+		run.rows[it.Ref] = i
+	}
+	return run
+}
+
+// mapNames renames projects and labels, and puts todos without a project into the
+// default one. Labels are matched regardless of case; mapping one to "" drops it.
+func (r *importRun) mapNames(projects, labels map[string]string, defaultProject string) {
+	labelMap := make(map[string]string, len(labels))
+	for from, to := range labels {
+		labelMap[strings.ToLower(strings.TrimSpace(from))] = to
+	}
+
+	for i := range r.items {
+		it := &r.items[i]
+		if to, ok := projects[it.Project]; ok {
+			it.Project = strings.TrimSpace(to)
+		}
+		if it.Project == "" {
+			it.Project = defaultProject
+		}
+
+		mapped := it.Labels[:0]
+		for _, label := range it.Labels {
+			if to, ok := labelMap[strings.ToLower(strings.TrimSpace(label))]; ok {
+				label = to
+			}
+			if strings.TrimSpace(label) != "" {
+				mapped = append(mapped, label)
+			}
+		}
+		it.Labels = normalizeLabels(mapped)
+	}
+}
+
+// importKey identifies todos that count as duplicates of each other.
+func importKey(title, project string) string {
+	return strings.ToLower(strings.TrimSpace(title)) + "\x00" + project
+}
+
+// markDuplicates marks valid items matching one of the user's todos or an earlier item
+// of the file. A duplicate's ref resolves to the todo it matches, so that its subtasks
+// are attached there.
+func (h *TodoHandler) markDuplicates(ctx context.Context, userID primitive.ObjectID, r *importRun) error {
+	titles := make([]string, 0, len(r.items))
+	for _, it := range r.items {
+		titles = append(titles, it.Title)
+	}
+
+	// Compare titles case-insensitively, like importKey does.
+	findOpts := options.Find().
+		SetProjection(bson.M{"title": 1, "project": 1}).
+		SetCollation(&options.Collation{Locale: "en", Strength: 2})
+	cursor, err := h.collection.Find(ctx, bson.M{"userId": userID, "deletedAt": nil, "title": bson.M{"$in": titles}}, findOpts)
+	if err != nil {
+		return err
+	}
+	var existing []models.Todo
+	if err := cursor.All(ctx, &existing); err != nil {
+		return err
+	}
+
+	type match struct {
+		id  primitive.ObjectID // Set for existing todos
+		row int                // Index of the first item otherwise
+	}
+	seen := make(map[string]match, len(existing)+len(r.items))
+	for _, todo := range existing {
+		seen[importKey(todo.Title, todo.Project)] = match{id: todo.ID, row: -1}
+	}
+
+	for i := range r.items {
+		it := &r.items[i]
+		if len(it.Errors) > 0 {
+			continue
+		}
+		key := importKey(it.Title, it.Project)
+		m, ok := seen[key]
+		if !ok {
+			seen[key] = match{row: i}
+			continue
+		}
+
+		r.results[i].Status = models.ImportStatusDuplicate
+		if m.row < 0 {
+			r.results[i].DuplicateOf = m.id.Hex()
+			if it.Ref != "" {
+				r.ids[it.Ref] = m.id
+			}
+		} else if it.Ref != "" {
+			r.rows[it.Ref] = m.row
+		}
+	}
+	return nil
+}
+
+// build creates the todos for the items to import, resolving subtasks and blockers
+// between them. Items that turn out to be invalid are reported as such.
+func (r *importRun) build(userID primitive.ObjectID, now time.Time) {
+	// Every item to import gets its ID first, so that references can point forwards.
+	for i, it := range r.items {
+		if len(it.Errors) == 0 && r.results[i].Status == models.ImportStatusOK {
+			r.todos[i] = &models.Todo{ID: primitive.NewObjectID()}
+		}
+	}
+	resolve := func(ref string) (primitive.ObjectID, *importer.Item, bool) {
+		if id, ok := r.ids[ref]; ok {
+			return id, nil, true
+		}
+		row, ok := r.rows[ref]
+		if !ok {
+			return primitive.NilObjectID, nil, false
+		}
+		if todo := r.todos[row]; todo != nil {
+			return todo.ID, nil, true
+		}
+		return primitive.NilObjectID, &r.items[row], false
+	}
+
+	graph := make(dependencyGraph)
+	for i := range r.items {
+		it := &r.items[i]
+		todo := r.todos[i]
+		if todo == nil {
+			continue
+		}
+
+		// Parents outside the file are dropped: the todo is imported at the top level.
+		if it.ParentRef != "" {
+			parentID, parent, ok := resolve(it.ParentRef)
+			switch {
+			case ok && r.parentCycle(i):
+				it.Errors = append(it.Errors, "subtasks form a cycle")
+			case ok:
+				todo.ParentID = &parentID
+			case parent != nil && len(parent.Errors) > 0:
+				it.Errors = append(it.Errors, fmt.Sprintf("parent on row %d is invalid", parent.Row))
+			}
+		}
+
+		for _, ref := range it.BlockedByRefs {
+			blockerID, _, ok := resolve(ref)
+			if !ok {
+				continue
+			}
+			if graph.createsCycle(todo.ID, blockerID) {
+				it.Errors = append(it.Errors, "blockers form a cycle")
+				break
+			}
+			todo.BlockedBy = append(todo.BlockedBy, blockerID)
+			graph[todo.ID] = todo.BlockedBy
+		}
+
+		todo.UserID = userID
+		todo.Title = it.Title
+		todo.Description = it.Description
+		todo.Project = it.Project
+		todo.Labels = it.Labels
+		todo.DueDate = it.DueDate
+		todo.Priority = it.Priority
+		todo.Recurrence = normalizeRecurrence(it.Recurrence)
+		todo.CreatedAt = now
+		if it.CreatedAt != nil {
+			todo.CreatedAt = *it.CreatedAt
+		}
+		todo.UpdatedAt = now
+		if it.Completed {
+			todo.Completed = true
+			todo.CompletedAt = it.CompletedAt
+			if todo.CompletedAt == nil {
+				todo.CompletedAt = &now
+			}
+		}
+		if it.Archived {
+			todo.ArchivedAt = &now
+		}
+	}
+
+	// Drop the todos found to be invalid above, along with subtasks and blockers
+	// pointing at them.
+	invalid := make(map[primitive.ObjectID]int)
+	for changed := true; changed; {
+		changed = false
+		for i := range r.items {
+			it := &r.items[i]
+			todo := r.todos[i]
+			if todo == nil {
+				continue
+			}
+			if todo.ParentID != nil && len(it.Errors) == 0 {
+				if row, ok := invalid[*todo.ParentID]; ok {
+					it.Errors = append(it.Errors, fmt.Sprintf("parent on row %d is invalid", row))
+				}
+			}
+			if len(it.Errors) > 0 {
+				invalid[todo.ID] = it.Row
+				r.todos[i] = nil
+				changed = true
+			}
+		}
+	}
+	for _, todo := range r.todos {
+		if todo == nil || len(todo.BlockedBy) == 0 {
+			continue
+		}
+		blockers := todo.BlockedBy[:0]
+		for _, id := range todo.BlockedBy {
+			if _, ok := invalid[id]; !ok {
+				blockers = append(blockers, id)
+			}
+		}
+		todo.BlockedBy = blockers
+		if len(blockers) == 0 {
+			todo.BlockedBy = nil
+		}
+	}
+
+	for i, it := range r.items {
+		if len(it.Errors) > 0 {
+			r.results[i].Status = models.ImportStatusInvalid
+			r.results[i].Errors = it.Errors
+		}
+	}
+}
+
+// parentCycle reports whether following parents from the item at index i leads back to it.
+func (r *importRun) parentCycle(i int) bool {
+	visited := map[int]bool{i: true}
+	for ref := r.items[i].ParentRef; ref != ""; {
+		row, ok := r.rows[ref]
+		if !ok {
+			return false
+		}
+		if visited[row] {
+			return true
+		}
+		visited[row] = true
+		ref = r.items[row].ParentRef
+	}
+	return false
+}
+
+// result summarises the run.
+func (r *importRun) result(format string, dryRun bool) models.ImportResult {
+	result := models.ImportResult{Format: format, DryRun: dryRun, Rows: r.results}
+	for _, row := range r.results {
+		switch row.Status {
+		case models.ImportStatusOK:
+			result.Imported++
+		case models.ImportStatusDuplicate:
+			result.Duplicates++
+		case models.ImportStatusInvalid:
+			result.Invalid++
+		}
+	}
+	return result
+}
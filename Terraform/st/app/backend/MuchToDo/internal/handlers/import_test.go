@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/importer"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestImport provides unit tests for preparing imported todos.
+func TestImport(t *testing.T) {
+	userID := primitive.NewObjectID()
+	now := time.Date(2025, 3, 12, 9, 0, 0, 0, time.UTC)
+
+	t.Run("MapNames", func(t *testing.T) {
+		run := newImportRun([]importer.Item{
+			{Row: 1, Title: "a", Project: "Inbox", Labels: []string{"Phone", "someday", "Work"}},
+			{Row: 2, Title: "b", Project: "Side"},
+			{Row: 3, Title: "c"},
+		})
+		run.mapNames(map[string]string{"Inbox": "", "Side": "hobby"}, map[string]string{"phone": "calls", "SOMEDAY": ""}, "home")
+		assert.Equal(t, "home", run.items[0].Project)
+		assert.Equal(t, []string{"calls", "work"}, run.items[0].Labels)
+		assert.Equal(t, "hobby", run.items[1].Project)
+		assert.Equal(t, "home", run.items[2].Project)
+	})
+
+	t.Run("Build", func(t *testing.T) {
+		created := now.AddDate(0, -1, 0)
+		run := newImportRun([]importer.Item{
+			{Row: 1, Ref: "p", Title: "Parent", CreatedAt: &created},
+			{Row: 2, Ref: "c", ParentRef: "p", BlockedByRefs: []string{"d", "elsewhere"}, Title: "Child", Completed: true},
+			{Row: 3, Ref: "d", Title: "Blocker", Archived: true},
+			{Row: 4, Ref: "o", ParentRef: "outside", Title: "Orphan"},
+			{Row: 5, Ref: "p", Title: "Same ref"},
+		})
+		run.build(userID, now)
+
+		parent, child, blocker, orphan := run.todos[0], run.todos[1], run.todos[2], run.todos[3]
+		assert.Equal(t, userID, parent.UserID)
+		assert.Equal(t, created, parent.CreatedAt)
+		assert.Equal(t, now, child.CreatedAt)
+		assert.Equal(t, parent.ID, *child.ParentID)
+		assert.Equal(t, []primitive.ObjectID{blocker.ID}, child.BlockedBy)
+		assert.True(t, child.Completed)
+		assert.Equal(t, now, *child.CompletedAt)
+		assert.Equal(t, now, *blocker.ArchivedAt)
+		assert.Nil(t, orphan.ParentID) // Imported at the top level
+
+		assert.Nil(t, run.todos[4])
+		assert.Equal(t, models.ImportStatusInvalid, run.results[4].Status)
+		assert.Equal(t, []string{`id "p" is already used on row 1`}, run.results[4].Errors)
+
+		result := run.result(importer.FormatCSV, false)
+		assert.Equal(t, 4, result.Imported)
+		assert.Equal(t, 1, result.Invalid)
+	})
+
+	t.Run("Cycles", func(t *testing.T) {
+		run := newImportRun([]importer.Item{
+			{Row: 1, Ref: "a", BlockedByRefs: []string{"b"}, Title: "A"},
+			{Row: 2, Ref: "b", BlockedByRefs: []string{"a"}, Title: "B"},
+			{Row: 3, Ref: "x", ParentRef: "y", Title: "X"},
+			{Row: 4, Ref: "y", ParentRef: "x", Title: "Y"},
+		})
+		run.build(userID, now)
+
+		assert.NotNil(t, run.todos[0])
+		assert.Nil(t, run.todos[0].BlockedBy) // Its blocker was dropped with row 2
+		assert.Equal(t, []string{"blockers form a cycle"}, run.results[1].Errors)
+		assert.Equal(t, []string{"subtasks form a cycle"}, run.results[2].Errors)
+		assert.Equal(t, []string{"subtasks form a cycle"}, run.results[3].Errors)
+	})
+
+	t.Run("InvalidParent", func(t *testing.T) {
+		run := newImportRun([]importer.Item{
+			{Row: 1, Ref: "a", Title: "A", Errors: []string{"title is required"}},
+			{Row: 2, Ref: "b", ParentRef: "a", Title: "B"},
+			{Row: 3, Ref: "c", ParentRef: "b", Title: "C"},
+		})
+		run.build(userID, now)
+
+		assert.Equal(t, []string{"parent on row 1 is invalid"}, run.results[1].Errors)
+		assert.Equal(t, []string{"parent on row 2 is invalid"}, run.results[2].Errors)
+		assert.Equal(t, 3, run.result(importer.FormatCSV, true).Invalid)
+	})
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+	maxPage         = 10000 // Keeps the skip well within range; deeper pages should be filtered instead
+)
+
+// pagination holds the page and page size requested through the query string.
+type pagination struct {
+	Page  int64
+	Limit int64
+}
+
+// Skip returns the number of documents to skip for the requested page.
+func (p pagination) Skip() int64 {
+	return (p.Page - 1) * p.Limit
+}
+
+// parsePagination reads ?page= and ?limit= from the request, falling back to
+// sensible defaults for missing or invalid values. A page beyond maxPage is rejected
+// with a 400 response, and false is returned.
+func parsePagination(c *gin.Context) (pagination, bool) {
+	p := pagination{Page: 1, Limit: defaultPageSize}
+
+	if page, err := strconv.ParseInt(c.Query("page"), 10, 64); err == nil && page > 0 {
+		p.Page = page
+	}
+	if limit, err := strconv.ParseInt(c.Query("limit"), 10, 64); err == nil && limit > 0 {
+		p.Limit = limit
+	}
+	if p.Limit > maxPageSize {
+		p.Limit = maxPageSize
+	}
+	if p.Page > maxPage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page: must be at most 10000"})
+		return p, false
+	}
+
+	return p, true
+}
+
+// paginatedResponse builds the response envelope for a page of results.
+func paginatedResponse(items interface{}, p pagination, total int64) gin.H {
+	return gin.H{
+		"items": items,
+		"page":  p.Page,
+		"limit": p.Limit,
+		"total": total,
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// preferencesContextKey is the Gin context key under which the preferences of the
+// authenticated user can be loaded.
+const preferencesContextKey = "preferences"
+
+// preferencesCacheTTL is how long a user's preferences are cached.
+const preferencesCacheTTL = time.Hour
+
+// localePattern accepts BCP 47 style language tags such as "en", "de-AT" or "zh-Hant-TW".
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// preferencesLoader loads the authenticated user's preferences on first use, so that
+// requests which don't need them don't pay for the lookup.
+type preferencesLoader struct {
+	once  sync.Once
+	load  func() models.Preferences
+	prefs models.Preferences
+}
+
+func (l *preferencesLoader) get() models.Preferences {
+	l.once.Do(func() { l.prefs = l.load() })
+	return l.prefs
+}
+
+// PreferencesMiddleware makes the authenticated user's preferences available to handlers
+// through userPreferences. It must run after the authentication middleware.
+func (h *UserHandler) PreferencesMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if userID, err := getUserIDFromContext(c); err == nil {
+			ctx := c.Request.Context()
+			c.Set(preferencesContextKey, &preferencesLoader{
+				load: func() models.Preferences { return h.loadPreferences(ctx, userID) },
+			})
+		}
+		c.Next()
+	}
+}
+
+// userPreferences returns the preferences stored for the authenticated user. Fields the
+// user hasn't set are empty; without the preferences middleware all of them are.
+func userPreferences(c *gin.Context) models.Preferences {
+	if value, ok := c.Get(preferencesContextKey); ok {
+		if loader, ok := value.(*preferencesLoader); ok {
+			return loader.get()
+		}
+	}
+	return models.Preferences{}
+}
+
+// userNow returns the current time in the authenticated user's time zone, so that
+// "today" and other calendar days are the user's rather than the server's.
+func userNow(c *gin.Context) time.Time {
+	return time.Now().In(userPreferences(c).Location())
+}
+
+// loadPreferences reads a user's preferences from the cache or the database. Failures
+// are logged and treated as the user not having set any preferences.
+func (h *UserHandler) loadPreferences(ctx context.Context, userID primitive.ObjectID) models.Preferences {
+	cacheKey := preferencesCacheKey(userID)
+
+	var prefs models.Preferences
+	if err := h.cache.Get(ctx, cacheKey, &prefs); err == nil {
+		return prefs
+	}
+
+	var user models.User
+	opts := options.FindOne().SetProjection(bson.M{"preferences": 1})
+	if err := h.collection.FindOne(ctx, bson.M{"_id": userID}, opts).Decode(&user); err != nil {
+		if err != mongo.ErrNoDocuments {
+			slog.Error("Failed to load preferences", "user", userID.Hex(), slog.Any("error", err))
+		}
+		return models.Preferences{}
+	}
+
+	h.cache.Set(ctx, cacheKey, user.Preferences, preferencesCacheTTL)
+	return user.Preferences
+}
+
+// GetPreferences godoc
+// @Summary      Get current user's preferences
+// @Description  Retrieves the time zone, locale, week start, date format and default project of the authenticated user. Unset fields show their defaults.
+// @Tags         users
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  models.Preferences
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Router       /users/me/preferences [get]
+func (h *UserHandler) GetPreferences(c *gin.Context) {
+	if _, err := getUserIDFromContext(c); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.JSON(http.StatusOK, userPreferences(c).WithDefaults())
+}
+
+// UpdatePreferences godoc
+// @Summary      Update current user's preferences
+// @Description  Changes some or all preferences. The time zone decides which calendar day "today" is across the API, e.g. for due dates, filters and statistics.
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        preferences body models.UpdatePreferencesDTO true "Preferences to change"
+// @Success      200  {object}  models.Preferences
+// @Failure      400  {object}  map[string]string "Invalid input"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "User not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /users/me/preferences [put]
+func (h *UserHandler) UpdatePreferences(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var dto models.UpdatePreferencesDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	if dto.Timezone != nil && *dto.Timezone != "" {
+		if _, err := time.LoadLocation(*dto.Timezone); err != nil || *dto.Timezone == "Local" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown time zone: " + *dto.Timezone})
+			return
+		}
+	}
+	if dto.Locale != nil && *dto.Locale != "" && !localePattern.MatchString(*dto.Locale) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid locale: " + *dto.Locale})
+		return
+	}
+
+	set := bson.M{}
+	unset := bson.M{}
+	fields := map[string]*string{
+		"timezone":       dto.Timezone,
+		"locale":         dto.Locale,
+		"weekStart":      dto.WeekStart,
+		"dateFormat":     dto.DateFormat,
+		"defaultProject": dto.DefaultProject,
+	}
+	for field, value := range fields {
+		if value == nil {
+			continue
+		}
+		if v := strings.TrimSpace(*value); v != "" {
+			set["preferences."+field] = v
+		} else {
+			unset["preferences."+field] = ""
+		}
+	}
+	if len(set) == 0 && len(unset) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No preferences provided"})
+		return
+	}
+
+	set["updatedAt"] = time.Now()
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var user models.User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"preferences": 1})
+	err = h.collection.FindOneAndUpdate(context.Background(), bson.M{"_id": userID}, update, opts).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+		return
+	}
+
+	h.cache.Delete(context.Background(), preferencesCacheKey(userID))
+
+	c.JSON(http.StatusOK, user.Preferences.WithDefaults())
+}
+
+func preferencesCacheKey(userID primitive.ObjectID) string {
+	return fmt.Sprintf("user-preferences:%s", userID.Hex())
+}
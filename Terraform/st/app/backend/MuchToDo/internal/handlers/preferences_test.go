@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestPreferences provides unit tests for preference defaults and validation, which
+// rejects bad input before the database is touched.
+func TestPreferences(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Defaults", func(t *testing.T) {
+		assert.Equal(t, models.DefaultPreferences(), models.Preferences{}.WithDefaults())
+
+		prefs := models.Preferences{Timezone: "Europe/Berlin", WeekStart: models.WeekStartSunday, DefaultProject: "Home"}.WithDefaults()
+		assert.Equal(t, "Europe/Berlin", prefs.Timezone)
+		assert.Equal(t, models.WeekStartSunday, prefs.WeekStart)
+		assert.Equal(t, "en", prefs.Locale)
+		assert.Equal(t, "YYYY-MM-DD", prefs.DateFormat)
+		assert.Equal(t, "Home", prefs.DefaultProject)
+	})
+
+	t.Run("Location", func(t *testing.T) {
+		assert.Equal(t, time.UTC, models.Preferences{}.Location())
+		assert.Equal(t, time.UTC, models.Preferences{Timezone: "Mars/Olympus_Mons"}.Location())
+		assert.Equal(t, "Asia/Tokyo", models.Preferences{Timezone: "Asia/Tokyo"}.Location().String())
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		h := &UserHandler{}
+		for _, body := range []string{
+			`{"timezone": "Mars/Olympus_Mons"}`,
+			`{"timezone": "Local"}`,
+			`{"locale": "english please"}`,
+			`{"locale": "e"}`,
+			`{"locale": "en_GB"}`,
+			`{"weekStart": "wednesday"}`,
+			`{"dateFormat": "YY/M/D"}`,
+			`{}`,
+		} {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("userID", primitive.NewObjectID().Hex())
+			c.Request = httptest.NewRequest(http.MethodPut, "/users/me/preferences", bytes.NewBufferString(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			h.UpdatePreferences(c)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		}
+
+		for _, locale := range []string{"en", "de-AT", "zh-Hant-TW", "es-419"} {
+			assert.True(t, localePattern.MatchString(locale), locale)
+		}
+	})
+
+	t.Run("DayRangeTimezone", func(t *testing.T) {
+		rangeFor := func(url string) dayRange {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, url, nil)
+			c.Set(preferencesContextKey, &preferencesLoader{load: func() models.Preferences {
+				return models.Preferences{Timezone: "America/New_York"}
+			}})
+			r, ok := parseDayRange(c, 7)
+			assert.True(t, ok, url)
+			return r
+		}
+
+		// Without tz, days are the user's; an explicit tz still wins.
+		assert.Equal(t, "America/New_York", rangeFor("/stats").Loc.String())
+		assert.Equal(t, "Asia/Tokyo", rangeFor("/stats?tz=Asia/Tokyo").Loc.String())
+	})
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/quickadd"
+)
+
+// QuickAddTodo godoc
+// @Summary      Create a todo from free text
+// @Description  Parses text such as "Pay rent every month on the 1st #home p1" into a todo with due date, recurrence, labels, project and priority.
+// @Description  The language is taken from locale, then the Accept-Language header (en, de and fr are supported; others fall back to en).
+// @Description  With preview=true the parsed fields are returned without creating anything.
+// @Tags         todos
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        todo body models.QuickAddDTO true "Free text to parse"
+// @Success      200  {object}  map[string]interface{} "Preview: the parsed models.CreateTodoDTO and the language used"
+// @Success      201  {object}  map[string]interface{} "The created models.Todo, the parsed fields and the language used"
+// @Failure      400  {object}  map[string]string "Invalid input"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/quick [post]
+func (h *TodoHandler) QuickAddTodo(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var dto models.QuickAddDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	prefs := userPreferences(c)
+	tag := dto.Locale
+	if tag == "" {
+		tag = prefs.Locale
+	}
+	if tag == "" {
+		tag = c.GetHeader("Accept-Language")
+	}
+	lang := quickadd.Language(tag)
+	parsed := quickadd.Parse(dto.Text, lang, userNow(c))
+
+	if dto.Preview {
+		c.JSON(http.StatusOK, gin.H{"parsed": parsed, "locale": lang})
+		return
+	}
+
+	newTodo, err := newTodoFromDTO(userID, parsed, prefs)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	if err := h.insertTodo(context.Background(), &newTodo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create todo"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"todo": newTodo, "parsed": parsed, "locale": lang})
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// snippetLength is the approximate number of characters of context shown around a match
+// in long fields such as the description.
+const snippetLength = 160
+
+// SearchTodos godoc
+// @Summary      Search todos
+// @Description  Full-text search over the title and description of the user's live todos, best matches first.
+// @Description  Supports "quoted phrases" and -negated terms. Can be combined with the list filters.
+// @Tags         todos
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        q          query string true  "Search query"
+// @Param        completed  query bool   false "Only completed (true) or open (false) todos"
+// @Param        project    query string false "Only todos in this project"
+// @Param        label      query string false "Only todos with this label"
+// @Param        filter     query string false "Filter expression, e.g. due:before:friday & !completed"
+// @Param        page       query int    false "Page number (default 1, max 10000)"
+// @Param        limit      query int    false "Page size (default 20, max 100)"
+// @Success      200  {object}  map[string]interface{} "Paginated models.TodoSearchResult items"
+// @Failure      400  {object}  map[string]string "Missing query, invalid filters or page"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/search [get]
+func (h *TodoHandler) SearchTodos(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+
+	var f models.TodoFilterDTO
+	if err := c.ShouldBindQuery(&f); err != nil {
+		respondFilterError(c, err)
+		return
+	}
+
+	filter, err := todoFilterQuery(userID, f, userNow(c))
+	if err != nil {
+		respondFilterError(c, err)
+		return
+	}
+
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+	filter["$text"] = bson.M{"$search": q}
+
+	total, err := h.collection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search todos"})
+		return
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: -1}}).
+		SetSkip(p.Skip()).
+		SetLimit(p.Limit)
+
+	cursor, err := h.collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search todos"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var results []models.TodoSearchResult
+	if err = cursor.All(context.Background(), &results); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode todos"})
+		return
+	}
+
+	pattern := searchPattern(searchTerms(q))
+	for i := range results {
+		results[i].Highlights = make(map[string]string)
+		if snippet, ok := highlight(results[i].Title, pattern, 0); ok {
+			results[i].Highlights["title"] = snippet
+		}
+		if snippet, ok := highlight(results[i].Description, pattern, snippetLength); ok {
+			results[i].Highlights["description"] = snippet
+		}
+	}
+
+	if results == nil {
+		results = []models.TodoSearchResult{}
+	}
+
+	c.JSON(http.StatusOK, paginatedResponse(results, p, total))
+}
+
+// searchTerms extracts the words and phrases a text search matches on, using the same
+// syntax as MongoDB's $text operator: "quoted" text is a phrase and a leading - negates.
+// Negated words and phrases are dropped since they never appear in results.
+func searchTerms(q string) []string {
+	var terms []string
+	for len(q) > 0 {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		if q == "" {
+			break
+		}
+
+		negated := strings.HasPrefix(q, "-")
+		if negated {
+			q = q[1:]
+		}
+
+		var term string
+		if strings.HasPrefix(q, `"`) {
+			end := strings.Index(q[1:], `"`)
+			if end < 0 {
+				term, q = q[1:], ""
+			} else {
+				term, q = q[1:end+1], q[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(q, unicode.IsSpace)
+			if end < 0 {
+				end = len(q)
+			}
+			term, q = q[:end], q[end:]
+		}
+
+		if term = strings.TrimSpace(term); term != "" && !negated {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// searchPattern compiles the terms into a single case-insensitive pattern, longest first
+// so that phrases win over the words they contain. It returns nil if there are no terms.
+func searchPattern(terms []string) *regexp.Regexp {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	sorted := append([]string(nil), terms...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	quoted := make([]string, len(sorted))
+	for i, term := range sorted {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	return regexp.MustCompile(`(?i)(?:` + strings.Join(quoted, "|") + `)`)
+}
+
+// highlight returns text HTML-escaped, with every match of pattern that starts a word
+// wrapped in <mark>. If maxLen is positive, only a window of about maxLen characters
+// around the first match is kept. ok is false if nothing matched.
+func highlight(text string, pattern *regexp.Regexp, maxLen int) (snippet string, ok bool) {
+	if pattern == nil {
+		return "", false
+	}
+
+	var matches [][]int
+	for _, m := range pattern.FindAllStringIndex(text, -1) {
+		if m[0] > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(text[:m[0]])
+			if unicode.IsLetter(prev) || unicode.IsDigit(prev) {
+				continue
+			}
+		}
+		matches = append(matches, m)
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+
+	start, end := 0, len(text)
+	if maxLen > 0 && len(text) > maxLen {
+		start = wordBoundaryBefore(text, matches[0][0]-maxLen/3)
+		end = wordBoundaryAfter(text, start+maxLen)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m[0] < pos {
+			continue
+		}
+		if m[1] > end {
+			break
+		}
+		b.WriteString(html.EscapeString(text[pos:m[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m[0]:m[1]]))
+		b.WriteString("</mark>")
+		pos = m[1]
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+
+	return b.String(), true
+}
+
+// wordBoundaryBefore moves i back to the start of the word containing it.
+func wordBoundaryBefore(text string, i int) int {
+	if i <= 0 {
+		return 0
+	}
+	if space := strings.LastIndexFunc(text[:i], unicode.IsSpace); space >= 0 {
+		_, size := utf8.DecodeRuneInString(text[space:])
+		return space + size
+	}
+	return 0
+}
+
+// wordBoundaryAfter moves i forward to the end of the word containing it.
+func wordBoundaryAfter(text string, i int) int {
+	if i >= len(text) {
+		return len(text)
+	}
+	if space := strings.IndexFunc(text[i:], unicode.IsSpace); space >= 0 {
+		return i + space
+	}
+	return len(text)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSearchHighlighting provides unit tests for search query parsing and snippet highlighting.
+func TestSearchHighlighting(t *testing.T) {
+	t.Run("Terms, phrases and negation", func(t *testing.T) {
+		terms := searchTerms(`  milk "weekly shop" -eggs -"corner store" bread `)
+		assert.Equal(t, []string{"milk", "weekly shop", "bread"}, terms)
+	})
+
+	t.Run("Unterminated phrase runs to the end", func(t *testing.T) {
+		assert.Equal(t, []string{"call mum"}, searchTerms(`"call mum`))
+	})
+
+	t.Run("Only negated terms highlight nothing", func(t *testing.T) {
+		assert.Nil(t, searchPattern(searchTerms("-milk")))
+	})
+
+	t.Run("Matches are marked case-insensitively and escaped", func(t *testing.T) {
+		pattern := searchPattern(searchTerms(`milk "weekly shop"`))
+		snippet, ok := highlight("Buy MILK & bread for the Weekly Shop <today>", pattern, 0)
+		assert.True(t, ok)
+		assert.Equal(t, "Buy <mark>MILK</mark> &amp; bread for the <mark>Weekly Shop</mark> &lt;today&gt;", snippet)
+	})
+
+	t.Run("Matches inside words are ignored", func(t *testing.T) {
+		pattern := searchPattern(searchTerms("art"))
+		_, ok := highlight("Start the party", pattern, 0)
+		assert.False(t, ok)
+
+		snippet, ok := highlight("Start art class", pattern, 0)
+		assert.True(t, ok)
+		assert.Equal(t, "Start <mark>art</mark> class", snippet)
+	})
+
+	t.Run("Long text is cut around the first match", func(t *testing.T) {
+		text := strings.Repeat("lorem ipsum ", 30) + "needle " + strings.Repeat("dolor sit ", 30)
+		snippet, ok := highlight(text, searchPattern([]string{"needle"}), 80)
+		assert.True(t, ok)
+		assert.True(t, strings.HasPrefix(snippet, "…"))
+		assert.True(t, strings.HasSuffix(snippet, "…"))
+		assert.Contains(t, snippet, "<mark>needle</mark>")
+		assert.Less(t, len(snippet), 120)
+	})
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/dates"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// dayKeyLayout formats days the way MongoDB's "%Y-%m-%d" date format does.
+const dayKeyLayout = "2006-01-02"
+
+// GetStats godoc
+// @Summary      Productivity statistics
+// @Description  Summarises the current user's todos over a range of days: completions per day and per week, average time to complete,
+// @Description  completion streaks, open and overdue counts, and breakdowns by label and project. Days are calendar days in the given time zone,
+// @Description  and weeks start on the user's preferred week start.
+// @Tags         users
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        from query string false "First day (YYYY-MM-DD, today, yesterday...); defaults to 29 days ago"
+// @Param        to   query string false "Last day; defaults to today"
+// @Param        tz   query string false "IANA time zone, e.g. Europe/Berlin (default: the user's time zone preference)"
+// @Success      200  {object}  models.TodoStats
+// @Failure      400  {object}  map[string]string "Invalid parameters"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /users/me/stats [get]
+func (h *TodoHandler) GetStats(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	r, ok := parseDayRange(c, 30)
+	if !ok {
+		return
+	}
+
+	weekStart := userPreferences(c).WithDefaults().WeekStart
+	stats, err := h.todoStats(context.Background(), userID, r, weekStart)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute statistics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// todoStats computes a user's statistics for a range of days with a single aggregation.
+// Weeks start on weekStart, one of the WeekStart* constants.
+func (h *TodoHandler) todoStats(ctx context.Context, userID primitive.ObjectID, r dayRange, weekStart string) (models.TodoStats, error) {
+	tz := r.Loc.String()
+	today := dates.StartOfDay(r.Now)
+	// Streaks are counted as of the end of the range, or today if the range extends past it.
+	streakDay := today
+	if r.To.Before(today) {
+		streakDay = r.To
+	}
+
+	completedInRange := bson.M{"$and": bson.A{
+		bson.M{"$eq": bson.A{"$completed", true}},
+		bson.M{"$gte": bson.A{"$completedAt", r.From}},
+		bson.M{"$lt": bson.A{"$completedAt", r.End()}},
+	}}
+	open := bson.M{"$ne": bson.A{"$completed", true}}
+	overdue := bson.M{"$and": bson.A{
+		open,
+		bson.M{"$eq": bson.A{bson.M{"$type": "$dueDate"}, "date"}},
+		bson.M{"$lt": bson.A{"$dueDate", today}},
+	}}
+	countIf := func(cond bson.M) bson.M {
+		return bson.M{"$sum": bson.M{"$cond": bson.A{cond, 1, 0}}}
+	}
+	breakdown := func(key interface{}) bson.A {
+		return bson.A{
+			bson.M{"$group": bson.M{
+				"_id":       key,
+				"completed": countIf(completedInRange),
+				"open":      countIf(open),
+				"overdue":   countIf(overdue),
+			}},
+			bson.M{"$match": bson.M{"$or": bson.A{bson.M{"completed": bson.M{"$gt": 0}}, bson.M{"open": bson.M{"$gt": 0}}}}},
+			bson.M{"$sort": bson.D{{Key: "_id", Value: 1}}},
+		}
+	}
+	completedIn := func(end interface{}) bson.M {
+		return bson.M{"$match": bson.M{"completed": true, "completedAt": end}}
+	}
+	completionsBy := func(date interface{}) bson.A {
+		return bson.A{
+			completedIn(bson.M{"$gte": r.From, "$lt": r.End()}),
+			bson.M{"$group": bson.M{
+				"_id":   bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": date, "timezone": tz}},
+				"count": bson.M{"$sum": 1},
+			}},
+		}
+	}
+	weekOf := bson.M{"$dateTrunc": bson.M{"date": "$completedAt", "unit": "week", "timezone": tz, "startOfWeek": weekStart}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"userId": userID, "deletedAt": nil}}},
+		{{Key: "$facet", Value: bson.M{
+			"created": bson.A{
+				bson.M{"$match": bson.M{"createdAt": bson.M{"$gte": r.From, "$lt": r.End()}}},
+				bson.M{"$count": "count"},
+			},
+			"completed": bson.A{
+				completedIn(bson.M{"$gte": r.From, "$lt": r.End()}),
+				bson.M{"$group": bson.M{
+					"_id":   nil,
+					"count": bson.M{"$sum": 1},
+					"avgMs": bson.M{"$avg": bson.M{"$subtract": bson.A{"$completedAt", "$createdAt"}}},
+				}},
+			},
+			"open": bson.A{
+				bson.M{"$group": bson.M{"_id": nil, "open": countIf(open), "overdue": countIf(overdue)}},
+			},
+			"perDay":  completionsBy("$completedAt"),
+			"perWeek": completionsBy(weekOf),
+			// Every day with a completion up to the end of the range, for streaks.
+			"days": bson.A{
+				completedIn(bson.M{"$lt": r.End()}),
+				bson.M{"$group": bson.M{"_id": bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$completedAt", "timezone": tz}}}},
+				bson.M{"$sort": bson.D{{Key: "_id", Value: 1}}},
+			},
+			"byLabel":   append(bson.A{bson.M{"$unwind": "$labels"}}, breakdown("$labels")...),
+			"byProject": breakdown(bson.M{"$ifNull": bson.A{"$project", ""}}),
+		}}},
+	}
+
+	cursor, err := h.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return models.TodoStats{}, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Created []struct {
+			Count int64 `bson:"count"`
+		} `bson:"created"`
+		Completed []struct {
+			Count int64   `bson:"count"`
+			AvgMs float64 `bson:"avgMs"`
+		} `bson:"completed"`
+		Open []struct {
+			Open    int64 `bson:"open"`
+			Overdue int64 `bson:"overdue"`
+		} `bson:"open"`
+		PerDay  []models.StatsBucket `bson:"perDay"`
+		PerWeek []models.StatsBucket `bson:"perWeek"`
+		Days    []struct {
+			Day string `bson:"_id"`
+		} `bson:"days"`
+		ByLabel   []models.StatsBreakdown `bson:"byLabel"`
+		ByProject []models.StatsBreakdown `bson:"byProject"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return models.TodoStats{}, err
+	}
+
+	stats := models.TodoStats{
+		From:      r.From.Format(dayKeyLayout),
+		To:        r.To.Format(dayKeyLayout),
+		Timezone:  tz,
+		WeekStart: weekStart,
+		ByLabel:   []models.StatsBreakdown{},
+		ByProject: []models.StatsBreakdown{},
+	}
+	var perDay, perWeek []models.StatsBucket
+	var days []string
+	if len(results) > 0 {
+		res := results[0]
+		if len(res.Created) > 0 {
+			stats.Created = res.Created[0].Count
+		}
+		if len(res.Completed) > 0 {
+			stats.Completed = res.Completed[0].Count
+			avg := res.Completed[0].AvgMs / 1000
+			stats.AvgTimeToCompleteSeconds = &avg
+		}
+		if len(res.Open) > 0 {
+			stats.Open = res.Open[0].Open
+			stats.Overdue = res.Open[0].Overdue
+		}
+		if res.ByLabel != nil {
+			stats.ByLabel = res.ByLabel
+		}
+		if res.ByProject != nil {
+			stats.ByProject = res.ByProject
+		}
+		perDay, perWeek = res.PerDay, res.PerWeek
+		for _, d := range res.Days {
+			days = append(days, d.Day)
+		}
+	}
+
+	stats.PerDay, stats.PerWeek = fillCompletionBuckets(r, weekStart, perDay, perWeek)
+	stats.CurrentStreak, stats.LongestStreak = completionStreaks(days, streakDay)
+	return stats, nil
+}
+
+// fillCompletionBuckets lists every day and week of the range in order, taking the
+// counts from the aggregated buckets and using zero for the rest. Weeks are keyed by
+// their first day.
+func fillCompletionBuckets(r dayRange, weekStart string, perDay, perWeek []models.StatsBucket) ([]models.StatsBucket, []models.StatsBucket) {
+	firstWeekday := time.Monday
+	if weekStart == models.WeekStartSunday {
+		firstWeekday = time.Sunday
+	}
+
+	dayCounts := make(map[string]int64, len(perDay))
+	for _, b := range perDay {
+		dayCounts[b.Key] = b.Count
+	}
+	weekCounts := make(map[string]int64, len(perWeek))
+	for _, b := range perWeek {
+		weekCounts[b.Key] = b.Count
+	}
+
+	days := []models.StatsBucket{}
+	weeks := []models.StatsBucket{}
+	for d := r.From; !d.After(r.To); d = d.AddDate(0, 0, 1) {
+		key := d.Format(dayKeyLayout)
+		days = append(days, models.StatsBucket{Key: key, Count: dayCounts[key]})
+
+		offset := (int(d.Weekday()) - int(firstWeekday) + 7) % 7
+		weekKey := d.AddDate(0, 0, -offset).Format(dayKeyLayout)
+		if len(weeks) == 0 || weeks[len(weeks)-1].Key != weekKey {
+			weeks = append(weeks, models.StatsBucket{Key: weekKey, Count: weekCounts[weekKey]})
+		}
+	}
+	return days, weeks
+}
+
+// completionStreaks returns the current and longest runs of consecutive days in days,
+// which holds distinct YYYY-MM-DD days in ascending order. The current streak counts
+// only if it reaches today or yesterday, so that it isn't broken before today is over.
+func completionStreaks(days []string, today time.Time) (current, longest int) {
+	var run int
+	var prev time.Time
+	for i, key := range days {
+		day, err := time.Parse(dayKeyLayout, key)
+		if err != nil {
+			continue
+		}
+		if i > 0 && day.Equal(prev.AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+		prev = day
+	}
+
+	if len(days) == 0 {
+		return 0, longest
+	}
+	todayKey := today.Format(dayKeyLayout)
+	yesterdayKey := today.AddDate(0, 0, -1).Format(dayKeyLayout)
+	if last := days[len(days)-1]; last == todayKey || last == yesterdayKey {
+		current = run
+	}
+	return current, longest
+}
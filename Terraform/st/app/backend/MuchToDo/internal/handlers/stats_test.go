@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestStats provides unit tests for the statistics helpers.
+func TestStats(t *testing.T) {
+	today := time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC)
+
+	t.Run("Streaks", func(t *testing.T) {
+		days := []string{"2025-02-27", "2025-02-28", "2025-03-01", "2025-03-02", "2025-03-05", "2025-03-10", "2025-03-11"}
+		current, longest := completionStreaks(days, today)
+		assert.Equal(t, 2, current) // Still alive: yesterday counts until today is over
+		assert.Equal(t, 4, longest) // Across the end of February
+
+		current, longest = completionStreaks(days[:5], today)
+		assert.Equal(t, 0, current)
+		assert.Equal(t, 4, longest)
+
+		current, longest = completionStreaks(nil, today)
+		assert.Equal(t, 0, current)
+		assert.Equal(t, 0, longest)
+	})
+
+	t.Run("Fills empty days and weeks", func(t *testing.T) {
+		r := dayRange{From: time.Date(2025, 3, 8, 0, 0, 0, 0, time.UTC), To: time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC), Loc: time.UTC}
+		days, weeks := fillCompletionBuckets(r, models.WeekStartMonday,
+			[]models.StatsBucket{{Key: "2025-03-09", Count: 3}},
+			[]models.StatsBucket{{Key: "2025-03-03", Count: 3}},
+		)
+		assert.Equal(t, []models.StatsBucket{
+			{Key: "2025-03-08", Count: 0},
+			{Key: "2025-03-09", Count: 3},
+			{Key: "2025-03-10", Count: 0},
+			{Key: "2025-03-11", Count: 0},
+		}, days)
+		assert.Equal(t, []models.StatsBucket{{Key: "2025-03-03", Count: 3}, {Key: "2025-03-10", Count: 0}}, weeks)
+
+		// Saturday the 8th is in the week starting Sunday the 2nd, the rest in the next one.
+		_, weeks = fillCompletionBuckets(r, models.WeekStartSunday, nil, []models.StatsBucket{{Key: "2025-03-09", Count: 3}})
+		assert.Equal(t, []models.StatsBucket{{Key: "2025-03-02", Count: 0}, {Key: "2025-03-09", Count: 3}}, weeks)
+	})
+}
@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/dates"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+const (
+	maxTemplateDepth = 3   // Top-level items plus two levels of subtasks
+	maxTemplateTodos = 200 // Todos created by a single instantiation
+)
+
+// TemplateHandler holds dependencies for template handlers.
+type TemplateHandler struct {
+	collection *mongo.Collection
+	todos      *TodoHandler // Used to create todos from templates
+}
+
+// NewTemplateHandler creates a new handler for todo templates.
+func NewTemplateHandler(collection *mongo.Collection, todos *TodoHandler) *TemplateHandler {
+	return &TemplateHandler{
+		collection: collection,
+		todos:      todos,
+	}
+}
+
+// CreateTemplate godoc
+// @Summary      Create a template
+// @Description  Saves a reusable list of todos with relative due dates, labels and subtasks
+// @Tags         templates
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        template body models.TemplateDTO true "Template"
+// @Success      201  {object}  models.Template
+// @Failure      400  {object}  map[string]string "Invalid input"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      409  {object}  map[string]string "A template with this name already exists"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /templates [post]
+func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	dto, ok := bindTemplate(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	template := models.Template{
+		UserID:      userID,
+		Name:        dto.Name,
+		Description: dto.Description,
+		Project:     dto.Project,
+		Items:       dto.Items,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	result, err := h.collection.InsertOne(context.Background(), template)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A template with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
+		return
+	}
+	template.ID = result.InsertedID.(primitive.ObjectID)
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// GetTemplates godoc
+// @Summary      List templates
+// @Description  Retrieves the current user's templates, sorted by name
+// @Tags         templates
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {array}  models.Template
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /templates [get]
+func (h *TemplateHandler) GetTemplates(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	cursor, err := h.collection.Find(context.Background(), bson.M{"userId": userID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch templates"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var templates []models.Template
+	if err = cursor.All(context.Background(), &templates); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode templates"})
+		return
+	}
+
+	if templates == nil {
+		templates = []models.Template{}
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// GetTemplateByID godoc
+// @Summary      Get a template
+// @Description  Retrieves a single template by its ID
+// @Tags         templates
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Template ID"
+// @Success      200  {object}  models.Template
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Template not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /templates/{id} [get]
+func (h *TemplateHandler) GetTemplateByID(c *gin.Context) {
+	template, ok := h.findTemplate(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// UpdateTemplate godoc
+// @Summary      Update a template
+// @Description  Replaces a template's name, description, project and items. Todos created from it earlier are not affected.
+// @Tags         templates
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Template ID"
+// @Param        template body models.TemplateDTO true "Template"
+// @Success      200  {object}  models.Template
+// @Failure      400  {object}  map[string]string "Invalid input"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Template not found"
+// @Failure      409  {object}  map[string]string "A template with this name already exists"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /templates/{id} [put]
+func (h *TemplateHandler) UpdateTemplate(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	dto, ok := bindTemplate(c)
+	if !ok {
+		return
+	}
+
+	var template models.Template
+	update := bson.M{"$set": bson.M{
+		"name":        dto.Name,
+		"description": dto.Description,
+		"project":     dto.Project,
+		"items":       dto.Items,
+		"updatedAt":   time.Now(),
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = h.collection.FindOneAndUpdate(context.Background(), bson.M{"_id": id, "userId": userID}, update, opts).Decode(&template)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+			return
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			c.JSON(http.StatusConflict, gin.H{"error": "A template with this name already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate godoc
+// @Summary      Delete a template
+// @Description  Deletes a template. Todos created from it are not affected.
+// @Tags         templates
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Template ID"
+// @Success      200  {object}  map[string]string "{'message': 'Template deleted successfully'}"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Template not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /templates/{id} [delete]
+func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	result, err := h.collection.DeleteOne(context.Background(), bson.M{"_id": id, "userId": userID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+}
+
+// InstantiateTemplate godoc
+// @Summary      Create todos from a template
+// @Description  Creates every todo in the template, subtasks included, in a single transaction.
+// @Description  Due dates are the start date plus each item's dueOffsetDays; subtasks count from the start date too.
+// @Tags         templates
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Template ID"
+// @Param        options body models.InstantiateTemplateDTO true "Start date and optional project"
+// @Success      201  {object}  map[string]interface{} "The created todos, parents before their subtasks"
+// @Failure      400  {object}  map[string]string "Invalid input"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Template not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /templates/{id}/instantiate [post]
+func (h *TemplateHandler) InstantiateTemplate(c *gin.Context) {
+	var dto models.InstantiateTemplateDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	start, err := dates.ParseDate(dto.StartDate, userPreferences(c).Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	template, ok := h.findTemplate(c)
+	if !ok {
+		return
+	}
+
+	project := strings.TrimSpace(dto.Project)
+	if project == "" {
+		project = template.Project
+	}
+
+	var created []models.Todo
+	err = h.todos.withTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		// The callback may be retried, so start from a clean slate each time.
+		created = nil
+		var insert func(items []models.TemplateItem, parentID *primitive.ObjectID) error
+		insert = func(items []models.TemplateItem, parentID *primitive.ObjectID) error {
+			for _, item := range items {
+				todo := templateTodo(template.UserID, item, project, start, parentID)
+				if err := h.todos.insertTodoInTransaction(sessCtx, &todo); err != nil {
+					return err
+				}
+				created = append(created, todo)
+
+				id := todo.ID
+				if err := insert(item.Subtasks, &id); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return insert(template.Items, nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create todos from template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"todos": created})
+}
+
+// templateTodo builds the todo for a template item.
+func templateTodo(userID primitive.ObjectID, item models.TemplateItem, project string, start time.Time, parentID *primitive.ObjectID) models.Todo {
+	now := time.Now()
+	todo := models.Todo{
+		UserID:      userID,
+		Title:       item.Title,
+		Description: item.Description,
+		ParentID:    parentID,
+		Project:     project,
+		Labels:      normalizeLabels(item.Labels),
+		Priority:    item.Priority,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if item.DueOffsetDays != nil {
+		due := start.AddDate(0, 0, *item.DueOffsetDays)
+		todo.DueDate = &due
+	}
+	return todo
+}
+
+// findTemplate loads the template named by the :id parameter, writing an error response
+// and returning false if it can't.
+func (h *TemplateHandler) findTemplate(c *gin.Context) (models.Template, bool) {
+	var template models.Template
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return template, false
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return template, false
+	}
+
+	err = h.collection.FindOne(context.Background(), bson.M{"_id": id, "userId": userID}).Decode(&template)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+			return template, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch template"})
+		return template, false
+	}
+
+	return template, true
+}
+
+// bindTemplate binds and validates a template from the request body, writing an error
+// response and returning false if it is invalid.
+func bindTemplate(c *gin.Context) (models.TemplateDTO, bool) {
+	var dto models.TemplateDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return dto, false
+	}
+
+	dto.Name = strings.TrimSpace(dto.Name)
+	dto.Project = strings.TrimSpace(dto.Project)
+	if dto.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Template name is required"})
+		return dto, false
+	}
+
+	count, err := countTemplateItems(dto.Items, 1)
+	if err == nil && count > maxTemplateTodos {
+		err = fmt.Errorf("a template can create at most %d todos", maxTemplateTodos)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return dto, false
+	}
+
+	return dto, true
+}
+
+// countTemplateItems counts the todos a list of template items creates, failing if the
+// subtasks nest deeper than maxTemplateDepth.
+func countTemplateItems(items []models.TemplateItem, depth int) (int, error) {
+	if len(items) > 0 && depth > maxTemplateDepth {
+		return 0, fmt.Errorf("subtasks can only be nested %d levels deep", maxTemplateDepth-1)
+	}
+
+	count := len(items)
+	for _, item := range items {
+		n, err := countTemplateItems(item.Subtasks, depth+1)
+		if err != nil {
+			return 0, err
+		}
+		count += n
+	}
+	return count, nil
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestTemplateItems provides unit tests for template validation and todo construction.
+func TestTemplateItems(t *testing.T) {
+	t.Run("Counts nested subtasks", func(t *testing.T) {
+		items := []models.TemplateItem{
+			{Title: "Laptop", Subtasks: []models.TemplateItem{{Title: "Order"}, {Title: "Set up", Subtasks: []models.TemplateItem{{Title: "VPN"}}}}},
+			{Title: "Intro meeting"},
+		}
+		count, err := countTemplateItems(items, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, count)
+	})
+
+	t.Run("Rejects nesting beyond the limit", func(t *testing.T) {
+		deep := []models.TemplateItem{{Title: "1", Subtasks: []models.TemplateItem{{Title: "2", Subtasks: []models.TemplateItem{{Title: "3", Subtasks: []models.TemplateItem{{Title: "4"}}}}}}}}
+		_, err := countTemplateItems(deep, 1)
+		assert.Error(t, err)
+	})
+
+	t.Run("Due date is offset from the start date", func(t *testing.T) {
+		userID, parentID := primitive.NewObjectID(), primitive.NewObjectID()
+		start := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+		offset := 3
+
+		todo := templateTodo(userID, models.TemplateItem{Title: "Review", Labels: []string{"HR"}, DueOffsetDays: &offset}, "Onboarding", start, &parentID)
+		assert.Equal(t, "Review", todo.Title)
+		assert.Equal(t, "Onboarding", todo.Project)
+		assert.Equal(t, []string{"hr"}, todo.Labels)
+		assert.Equal(t, &parentID, todo.ParentID)
+		assert.Equal(t, time.Date(2025, 3, 13, 0, 0, 0, 0, time.UTC), *todo.DueDate)
+
+		todo = templateTodo(userID, models.TemplateItem{Title: "Whenever"}, "", start, nil)
+		assert.Nil(t, todo.DueDate)
+		assert.Nil(t, todo.ParentID)
+	})
+}
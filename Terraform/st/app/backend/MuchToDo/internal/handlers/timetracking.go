@@ -0,0 +1,545 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// maxTimeEntryLength is the longest manual time entry accepted.
+const maxTimeEntryLength = 24 * time.Hour
+
+// TimeHandler holds dependencies for time tracking handlers.
+type TimeHandler struct {
+	collection *mongo.Collection
+	todos      *TodoHandler // Used to check todo ownership and look up todos for reports
+}
+
+// NewTimeHandler creates a new handler for time tracking.
+func NewTimeHandler(collection *mongo.Collection, todos *TodoHandler) *TimeHandler {
+	return &TimeHandler{
+		collection: collection,
+		todos:      todos,
+	}
+}
+
+// StartTimer godoc
+// @Summary      Start a timer on a todo
+// @Description  Starts tracking time on a todo. A user can only have one running timer; starting a second one fails with the running timer in the response.
+// @Tags         time
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id    path string               true  "Todo ID"
+// @Param        timer body models.StartTimerDTO false "Optional note"
+// @Success      201  {object}  models.TimeEntry
+// @Failure      400  {object}  map[string]string "Invalid input"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Todo not found"
+// @Failure      409  {object}  map[string]interface{} "A timer is already running"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/timer/start [post]
+func (h *TimeHandler) StartTimer(c *gin.Context) {
+	userID, todoID, ok := ownedTodo(c, h.todos.collection)
+	if !ok {
+		return
+	}
+
+	var dto models.StartTimerDTO
+	if err := c.ShouldBindJSON(&dto); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	now := time.Now()
+	entry := models.TimeEntry{
+		ID:        primitive.NewObjectID(),
+		TodoID:    todoID,
+		UserID:    userID,
+		Start:     now,
+		Running:   true,
+		Note:      strings.TrimSpace(dto.Note),
+		CreatedAt: now,
+	}
+
+	// The unique index on running entries rejects a second timer, even under concurrent requests.
+	if _, err := h.collection.InsertOne(context.Background(), entry); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			var running models.TimeEntry
+			if err := h.collection.FindOne(context.Background(), bson.M{"userId": userID, "running": true}).Decode(&running); err == nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "A timer is already running", "running": running})
+				return
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "A timer is already running"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start timer"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// StopTimer godoc
+// @Summary      Stop a todo's timer
+// @Description  Stops the running timer on a todo and records the time spent
+// @Tags         time
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path string true "Todo ID"
+// @Success      200  {object}  models.TimeEntry
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "No timer is running on this todo"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/timer/stop [post]
+func (h *TimeHandler) StopTimer(c *gin.Context) {
+	userID, todoID, ok := ownedTodo(c, h.todos.collection)
+	if !ok {
+		return
+	}
+
+	var entry models.TimeEntry
+	err := h.collection.FindOne(context.Background(), bson.M{"userId": userID, "todoId": todoID, "running": true}).Decode(&entry)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No timer is running on this todo"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch timer"})
+		return
+	}
+
+	end := time.Now()
+	seconds := int64(end.Sub(entry.Start) / time.Second)
+	update := bson.M{
+		"$set":   bson.M{"end": end, "seconds": seconds},
+		"$unset": bson.M{"running": ""},
+	}
+	// Matching on running guards against a concurrent stop recording the entry twice.
+	result, err := h.collection.UpdateOne(context.Background(), bson.M{"_id": entry.ID, "running": true}, update)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stop timer"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No timer is running on this todo"})
+		return
+	}
+
+	entry.End = &end
+	entry.Seconds = seconds
+	entry.Running = false
+	c.JSON(http.StatusOK, entry)
+}
+
+// GetRunningTimer godoc
+// @Summary      Get the running timer
+// @Description  Retrieves the current user's running timer, with the time elapsed so far
+// @Tags         time
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  models.TimeEntry
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "No timer is running"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /timer [get]
+func (h *TimeHandler) GetRunningTimer(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var entry models.TimeEntry
+	err = h.collection.FindOne(context.Background(), bson.M{"userId": userID, "running": true}).Decode(&entry)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No timer is running"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch timer"})
+		return
+	}
+
+	entry.Seconds = entrySeconds(entry, time.Now())
+	c.JSON(http.StatusOK, entry)
+}
+
+// CreateTimeEntry godoc
+// @Summary      Add time to a todo
+// @Description  Records time spent on a todo by hand, given a start and either an end or a length in minutes (at most 24 hours)
+// @Tags         time
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id    path string                    true "Todo ID"
+// @Param        entry body models.CreateTimeEntryDTO true "Time entry"
+// @Success      201  {object}  models.TimeEntry
+// @Failure      400  {object}  map[string]string "Invalid input"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Todo not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/time [post]
+func (h *TimeHandler) CreateTimeEntry(c *gin.Context) {
+	userID, todoID, ok := ownedTodo(c, h.todos.collection)
+	if !ok {
+		return
+	}
+
+	var dto models.CreateTimeEntryDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	start, end, err := timeEntrySpan(dto)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry := models.TimeEntry{
+		ID:        primitive.NewObjectID(),
+		TodoID:    todoID,
+		UserID:    userID,
+		Start:     start,
+		End:       &end,
+		Seconds:   int64(end.Sub(start) / time.Second),
+		Manual:    true,
+		Note:      strings.TrimSpace(dto.Note),
+		CreatedAt: time.Now(),
+	}
+
+	if _, err := h.collection.InsertOne(context.Background(), entry); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save time entry"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
+
+// GetTimeEntries godoc
+// @Summary      List a todo's time entries
+// @Description  Retrieves the time entries of a todo, newest first, with the total time spent. A running timer counts up to now.
+// @Tags         time
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id   path string true "Todo ID"
+// @Success      200  {object}  map[string]interface{} "Entries and totalSeconds"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Todo not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/time [get]
+func (h *TimeHandler) GetTimeEntries(c *gin.Context) {
+	userID, todoID, ok := ownedTodo(c, h.todos.collection)
+	if !ok {
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "start", Value: -1}})
+	cursor, err := h.collection.Find(context.Background(), bson.M{"userId": userID, "todoId": todoID}, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch time entries"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	entries := []models.TimeEntry{}
+	if err = cursor.All(context.Background(), &entries); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode time entries"})
+		return
+	}
+
+	now := time.Now()
+	var total int64
+	for i := range entries {
+		entries[i].Seconds = entrySeconds(entries[i], now)
+		total += entries[i].Seconds
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries, "totalSeconds": total})
+}
+
+// DeleteTimeEntry godoc
+// @Summary      Delete a time entry
+// @Description  Deletes a time entry from a todo. Deleting a running timer discards it.
+// @Tags         time
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id      path string true "Todo ID"
+// @Param        entryId path string true "Time entry ID"
+// @Success      200  {object}  map[string]string "{'message': 'Time entry deleted successfully'}"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Time entry not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/time/{entryId} [delete]
+func (h *TimeHandler) DeleteTimeEntry(c *gin.Context) {
+	userID, todoID, ok := ownedTodo(c, h.todos.collection)
+	if !ok {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("entryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time entry ID format"})
+		return
+	}
+
+	result, err := h.collection.DeleteOne(context.Background(), bson.M{"_id": id, "todoId": todoID, "userId": userID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete time entry"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Time entry not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Time entry deleted successfully"})
+}
+
+// GetTimeReport godoc
+// @Summary      Time report
+// @Description  Totals the time tracked between two days (inclusive), grouped by todo, project, label or day. Entries count towards the day they started on.
+// @Description  An entry on a todo with several labels counts towards each label. Use format=csv for a spreadsheet-friendly download.
+// @Tags         time
+// @Produce      json
+// @Produce      text/csv
+// @Security     ApiKeyAuth
+// @Param        from    query string false "First day (YYYY-MM-DD, today, yesterday...); defaults to six days ago"
+// @Param        to      query string false "Last day; defaults to today"
+// @Param        groupBy query string false "todo (default), project, label or day"
+// @Param        tz      query string false "IANA time zone used for days, e.g. Europe/Berlin (default: the user's time zone preference)"
+// @Param        format  query string false "json (default) or csv"
+// @Success      200  {object}  map[string]interface{} "Report rows and totalSeconds"
+// @Failure      400  {object}  map[string]string "Invalid parameters"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /time/report [get]
+func (h *TimeHandler) GetTimeReport(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	r, ok := parseDayRange(c, 7)
+	if !ok {
+		return
+	}
+
+	groupBy := c.DefaultQuery("groupBy", models.TimeGroupTodo)
+	grouping, ok := h.timeReportGrouping(groupBy, r.Loc)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "groupBy must be one of todo, project, label or day"})
+		return
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"userId": userID, "start": bson.M{"$gte": r.From, "$lt": r.End()}}}},
+		// Running timers count up to now.
+		{{Key: "$set", Value: bson.M{"seconds": bson.M{"$cond": bson.A{
+			bson.M{"$eq": bson.A{"$running", true}},
+			bson.M{"$dateDiff": bson.M{"startDate": "$start", "endDate": r.Now, "unit": "second"}},
+			"$seconds",
+		}}}}},
+		{{Key: "$facet", Value: bson.M{
+			"rows":  grouping,
+			"total": bson.A{bson.M{"$group": bson.M{"_id": nil, "seconds": bson.M{"$sum": "$seconds"}}}},
+		}}},
+	}
+
+	cursor, err := h.collection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build time report"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	var results []struct {
+		Rows  []models.TimeReportRow `bson:"rows"`
+		Total []struct {
+			Seconds int64 `bson:"seconds"`
+		} `bson:"total"`
+	}
+	if err := cursor.All(context.Background(), &results); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode time report"})
+		return
+	}
+
+	rows := []models.TimeReportRow{}
+	var total int64
+	if len(results) > 0 {
+		if results[0].Rows != nil {
+			rows = results[0].Rows
+		}
+		if len(results[0].Total) > 0 {
+			total = results[0].Total[0].Seconds
+		}
+	}
+
+	if c.Query("format") == "csv" {
+		writeTimeReportCSV(c, groupBy, rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":         r.From.Format("2006-01-02"),
+		"to":           r.To.Format("2006-01-02"),
+		"groupBy":      groupBy,
+		"rows":         rows,
+		"totalSeconds": total,
+	})
+}
+
+// timeReportGrouping returns the aggregation stages that turn time entries into report
+// rows for groupBy, or false if groupBy isn't supported.
+func (h *TimeHandler) timeReportGrouping(groupBy string, loc *time.Location) (bson.A, bool) {
+	lookupTodo := bson.M{"$lookup": bson.M{
+		"from":         h.todos.collection.Name(),
+		"localField":   "todoId",
+		"foreignField": "_id",
+		"as":           "todo",
+	}}
+	sum := func(key interface{}) bson.M {
+		return bson.M{"$group": bson.M{
+			"_id":     key,
+			"seconds": bson.M{"$sum": "$seconds"},
+			"entries": bson.M{"$sum": 1},
+		}}
+	}
+
+	var stages bson.A
+	switch groupBy {
+	case models.TimeGroupTodo:
+		stages = bson.A{
+			sum("$todoId"),
+			bson.M{"$lookup": bson.M{"from": h.todos.collection.Name(), "localField": "_id", "foreignField": "_id", "as": "todo"}},
+			bson.M{"$project": bson.M{
+				"key":     bson.M{"$toString": "$_id"},
+				"name":    bson.M{"$first": "$todo.title"},
+				"seconds": 1,
+				"entries": 1,
+			}},
+			bson.M{"$sort": bson.D{{Key: "seconds", Value: -1}, {Key: "key", Value: 1}}},
+		}
+	case models.TimeGroupProject:
+		stages = bson.A{
+			lookupTodo,
+			sum(bson.M{"$ifNull": bson.A{bson.M{"$first": "$todo.project"}, ""}}),
+			bson.M{"$project": bson.M{"key": "$_id", "seconds": 1, "entries": 1}},
+			bson.M{"$sort": bson.D{{Key: "seconds", Value: -1}, {Key: "key", Value: 1}}},
+		}
+	case models.TimeGroupLabel:
+		stages = bson.A{
+			lookupTodo,
+			bson.M{"$unwind": bson.M{"path": "$todo"}},
+			bson.M{"$unwind": bson.M{"path": "$todo.labels", "preserveNullAndEmptyArrays": true}},
+			sum(bson.M{"$ifNull": bson.A{"$todo.labels", ""}}),
+			bson.M{"$project": bson.M{"key": "$_id", "seconds": 1, "entries": 1}},
+			bson.M{"$sort": bson.D{{Key: "seconds", Value: -1}, {Key: "key", Value: 1}}},
+		}
+	case models.TimeGroupDay:
+		stages = bson.A{
+			sum(bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$start", "timezone": loc.String()}}),
+			bson.M{"$project": bson.M{"key": "$_id", "seconds": 1, "entries": 1}},
+			bson.M{"$sort": bson.D{{Key: "key", Value: 1}}},
+		}
+	default:
+		return nil, false
+	}
+	return stages, true
+}
+
+// writeTimeReportCSV writes report rows as a CSV download.
+func writeTimeReportCSV(c *gin.Context, groupBy string, rows []models.TimeReportRow) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="time-report-`+groupBy+`.csv"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	header := []string{groupBy, "seconds", "hours", "entries"}
+	if groupBy == models.TimeGroupTodo {
+		header = []string{"todo", "title", "seconds", "hours", "entries"}
+	}
+	w.Write(header)
+
+	for _, row := range rows {
+		record := []string{csvSafe(row.Key)}
+		if groupBy == models.TimeGroupTodo {
+			record = append(record, csvSafe(row.Name))
+		}
+		record = append(record,
+			strconv.FormatInt(row.Seconds, 10),
+			strconv.FormatFloat(float64(row.Seconds)/3600, 'f', 2, 64),
+			strconv.FormatInt(row.Entries, 10),
+		)
+		w.Write(record)
+	}
+	w.Flush()
+}
+
+// csvSafe keeps user-entered text from being read as a formula by spreadsheet programs.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// timeEntrySpan validates a manual time entry and returns its start and end.
+func timeEntrySpan(dto models.CreateTimeEntryDTO) (time.Time, time.Time, error) {
+	start, err := time.Parse(time.RFC3339, dto.Start)
+	if err != nil {
+		return start, start, errors.New("start must be an RFC 3339 timestamp")
+	}
+
+	var end time.Time
+	switch {
+	case dto.End != "" && dto.Minutes > 0:
+		return start, start, errors.New("give either end or minutes, not both")
+	case dto.End != "":
+		if end, err = time.Parse(time.RFC3339, dto.End); err != nil {
+			return start, start, errors.New("end must be an RFC 3339 timestamp")
+		}
+	case dto.Minutes > int(maxTimeEntryLength/time.Minute):
+		// Checked before converting, which could overflow.
+		return start, start, errors.New("a time entry cannot be longer than 24 hours")
+	case dto.Minutes > 0:
+		end = start.Add(time.Duration(dto.Minutes) * time.Minute)
+	default:
+		return start, start, errors.New("either end or minutes is required")
+	}
+
+	if !end.After(start) {
+		return start, end, errors.New("end must be after start")
+	}
+	if end.Sub(start) > maxTimeEntryLength {
+		return start, end, errors.New("a time entry cannot be longer than 24 hours")
+	}
+	return start, end, nil
+}
+
+// entrySeconds returns the length of an entry in seconds, counting a running timer up to now.
+func entrySeconds(entry models.TimeEntry, now time.Time) int64 {
+	if entry.Running {
+		return int64(now.Sub(entry.Start) / time.Second)
+	}
+	return entry.Seconds
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestTimeTracking provides unit tests for time entry validation and reporting helpers.
+func TestTimeTracking(t *testing.T) {
+	t.Run("Manual entry with an end", func(t *testing.T) {
+		start, end, err := timeEntrySpan(models.CreateTimeEntryDTO{Start: "2025-03-10T09:00:00Z", End: "2025-03-10T10:30:00Z"})
+		assert.NoError(t, err)
+		assert.Equal(t, 90*time.Minute, end.Sub(start))
+	})
+
+	t.Run("Manual entry with minutes", func(t *testing.T) {
+		start, end, err := timeEntrySpan(models.CreateTimeEntryDTO{Start: "2025-03-10T09:00:00+01:00", Minutes: 45})
+		assert.NoError(t, err)
+		assert.Equal(t, 45*time.Minute, end.Sub(start))
+	})
+
+	t.Run("Rejects invalid entries", func(t *testing.T) {
+		invalid := []models.CreateTimeEntryDTO{
+			{Start: "2025-03-10"},
+			{Start: "2025-03-10T09:00:00Z"},
+			{Start: "2025-03-10T09:00:00Z", End: "2025-03-10T10:00:00Z", Minutes: 60},
+			{Start: "2025-03-10T09:00:00Z", End: "2025-03-10T08:00:00Z"},
+			{Start: "2025-03-10T09:00:00Z", Minutes: 25 * 60},
+		}
+		for _, dto := range invalid {
+			_, _, err := timeEntrySpan(dto)
+			assert.Error(t, err, dto)
+		}
+	})
+
+	t.Run("Manual entries are at most a day long", func(t *testing.T) {
+		start, end, err := timeEntrySpan(models.CreateTimeEntryDTO{Start: "2025-03-10T00:00:00Z", Minutes: 1440})
+		assert.NoError(t, err)
+		assert.Equal(t, 24*time.Hour, end.Sub(start))
+		assert.NoError(t, binding.Validator.ValidateStruct(models.CreateTimeEntryDTO{Start: "2025-03-10T00:00:00Z", Minutes: 1440}))
+
+		// Minutes that would overflow a duration are refused too, not wrapped around.
+		for _, minutes := range []int{1441, math.MaxInt64 / 60} {
+			dto := models.CreateTimeEntryDTO{Start: "2025-03-10T00:00:00Z", Minutes: minutes}
+			_, _, err := timeEntrySpan(dto)
+			assert.Error(t, err, minutes)
+			assert.Error(t, binding.Validator.ValidateStruct(dto), minutes)
+		}
+	})
+
+	t.Run("Running timers count up to now", func(t *testing.T) {
+		now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+		running := models.TimeEntry{Start: now.Add(-5 * time.Minute), Running: true}
+		assert.Equal(t, int64(300), entrySeconds(running, now))
+		assert.Equal(t, int64(60), entrySeconds(models.TimeEntry{Seconds: 60}, now))
+	})
+
+	t.Run("Escapes spreadsheet formulas", func(t *testing.T) {
+		assert.Equal(t, "'=SUM(A1)", csvSafe("=SUM(A1)"))
+		assert.Equal(t, "Client work", csvSafe("Client work"))
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,17 +13,37 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/dates"
 	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/outbox"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/query"
 )
 
-// TodoHandler holds the database collection for todos.
+// TodoHandler holds the database collections for todos, their history and pending undo operations.
 type TodoHandler struct {
-	collection *mongo.Collection
+	collection        *mongo.Collection
+	historyCollection *mongo.Collection
+	undoCollection    *mongo.Collection
+	tombstones        *mongo.Collection   // Records purged todos for CalDAV sync; may be nil
+	undoWindow        time.Duration       // How long an undo token stays valid
+	attachments       *AttachmentHandler  // Removes the files of purged todos; may be nil
+	outbox            *outbox.Outbox      // Receives the todo events of every change; may be nil
+	dependents        []*mongo.Collection // Other per-todo records (keyed by todoId) removed with purged todos
 }
 
-// NewTodoHandler creates a new handler for ToDo operations.
-func NewTodoHandler(collection *mongo.Collection) *TodoHandler {
-	return &TodoHandler{collection: collection}
+// NewTodoHandler creates a new handler for ToDo operations. Documents in the dependent
+// collections that belong to a todo are deleted when the todo is purged.
+func NewTodoHandler(collection *mongo.Collection, historyCollection *mongo.Collection, undoCollection *mongo.Collection, tombstones *mongo.Collection, undoWindow time.Duration, attachments *AttachmentHandler, events *outbox.Outbox, dependents ...*mongo.Collection) *TodoHandler {
+	return &TodoHandler{
+		collection:        collection,
+		historyCollection: historyCollection,
+		undoCollection:    undoCollection,
+		tombstones:        tombstones,
+		undoWindow:        undoWindow,
+		attachments:       attachments,
+		outbox:            events,
+		dependents:        dependents,
+	}
 }
 
 // getUserIDFromContext retrieves the user ID from the Gin context.
@@ -40,6 +61,34 @@ func getUserIDFromContext(c *gin.Context) (primitive.ObjectID, error) {
 	return primitive.ObjectIDFromHex(userIDHex)
 }
 
+// ownedTodo resolves the :id parameter to one of the user's live todos in collection,
+// writing an error response and returning false if it can't.
+func ownedTodo(c *gin.Context, collection *mongo.Collection) (userID, todoID primitive.ObjectID, ok bool) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return userID, todoID, false
+	}
+
+	todoID, err = primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return userID, todoID, false
+	}
+
+	count, err := collection.CountDocuments(context.Background(), bson.M{"_id": todoID, "userId": userID, "deletedAt": nil})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch todo"})
+		return userID, todoID, false
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found or you don't have permission"})
+		return userID, todoID, false
+	}
+
+	return userID, todoID, true
+}
+
 // CreateTodo godoc
 // @Summary      Create a new todo
 // @Description  Adds a new todo item to the current user's list
@@ -51,6 +100,7 @@ func getUserIDFromContext(c *gin.Context) (primitive.ObjectID, error) {
 // @Success      201  {object}  models.Todo
 // @Failure      400  {object}  map[string]string "Invalid input"
 // @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Parent todo not found"
 // @Failure      500  {object}  map[string]string "Server error"
 // @Router       /todos [post]
 func (h *TodoHandler) CreateTodo(c *gin.Context) {
@@ -66,34 +116,106 @@ func (h *TodoHandler) CreateTodo(c *gin.Context) {
 		return
 	}
 
+	newTodo, err := newTodoFromDTO(userID, dto, userPreferences(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	if dto.ParentID != "" {
+		parentID, err := primitive.ObjectIDFromHex(dto.ParentID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent ID format"})
+			return
+		}
+		count, err := h.collection.CountDocuments(context.Background(), bson.M{"_id": parentID, "userId": userID, "deletedAt": nil})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch parent todo"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent todo not found"})
+			return
+		}
+		newTodo.ParentID = &parentID
+	}
+
+	if err := h.insertTodo(context.Background(), &newTodo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create todo"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newTodo)
+}
+
+// newTodoFromDTO builds a new todo owned by userID from a create request. Due dates are
+// read in the user's time zone, and todos without a project go to the default project.
+func newTodoFromDTO(userID primitive.ObjectID, dto models.CreateTodoDTO, prefs models.Preferences) (models.Todo, error) {
 	// now := primitive.NewDateTimeFromTime(time.Now())
 	now := time.Now()
-	newTodo := models.Todo{
+	var dueDate *time.Time
+	if strings.TrimSpace(dto.DueDate) != "" {
+		due, err := dates.ParseDate(dto.DueDate, prefs.Location())
+		if err != nil {
+			return models.Todo{}, err
+		}
+		dueDate = &due
+	}
+
+	project := strings.TrimSpace(dto.Project)
+	if project == "" {
+		project = prefs.DefaultProject
+	}
+
+	return models.Todo{
 		UserID:      userID,
 		Title:       dto.Title,
 		Description: dto.Description,
+		Project:     project,
+		Labels:      normalizeLabels(dto.Labels),
+		DueDate:     dueDate,
+		Priority:    dto.Priority,
+		Recurrence:  normalizeRecurrence(dto.Recurrence),
 		Completed:   false,
 		CreatedAt:   now,
 		UpdatedAt:   now,
-	}
+	}, nil
+}
+
+// insertTodo stores a new todo and records its creation in the history, setting its ID.
+func (h *TodoHandler) insertTodo(ctx context.Context, todo *models.Todo) error {
+	return h.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		return h.insertTodoInTransaction(sessCtx, todo)
+	})
+}
 
-	result, err := h.collection.InsertOne(context.Background(), newTodo)
+// insertTodoInTransaction is insertTodo for callers already running a transaction.
+func (h *TodoHandler) insertTodoInTransaction(sessCtx mongo.SessionContext, todo *models.Todo) error {
+	result, err := h.collection.InsertOne(sessCtx, todo)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create todo"})
-		return
+		return err
 	}
-
-	newTodo.ID = result.InsertedID.(primitive.ObjectID)
-	c.JSON(http.StatusCreated, newTodo)
+	todo.ID = result.InsertedID.(primitive.ObjectID)
+	_, err = h.recordVersion(sessCtx, todo.UserID, models.TodoActionCreated, nil, *todo)
+	return err
 }
 
 // GetAllTodos godoc
 // @Summary      Get all todos for the current user
-// @Description  Retrieves a list of all todo items belonging to the user
+// @Description  Retrieves a list of all todo items belonging to the user. Archived todos are excluded unless archived=true,
+// @Description  in which case a paginated list of archived todos is returned instead.
 // @Tags         todos
 // @Produce      json
 // @Security     ApiKeyAuth
+// @Param        archived query bool false "List archived todos instead"
+// @Param        page query int false "Page number for archived todos (default 1, max 10000)"
+// @Param        limit query int false "Page size for archived todos (default 20, max 100)"
+// @Param        completed query bool false "Only completed (true) or open (false) todos"
+// @Param        project query string false "Only todos in this project"
+// @Param        label query string false "Only todos with this label"
+// @Param        filter query string false "Filter expression, e.g. due:before:friday & label:work & !completed"
 // @Success      200  {array}  models.Todo
+// @Failure      400  {object}  map[string]string "Invalid filters or page"
 // @Failure      401  {object}  map[string]string "Unauthorized"
 // @Failure      500  {object}  map[string]string "Server error"
 // @Router       /todos [get]
@@ -106,8 +228,29 @@ func (h *TodoHandler) GetAllTodos(c *gin.Context) {
 	}
 	println("User ID:", userID.Hex())
 
+	if c.Query("archived") == "true" {
+		h.getArchivedTodos(c, userID)
+		return
+	}
+
+	var f models.TodoFilterDTO
+	if err := c.ShouldBindQuery(&f); err != nil {
+		respondFilterError(c, err)
+		return
+	}
+
+	filter, err := todoFilterQuery(userID, f, userNow(c))
+	if err != nil {
+		respondFilterError(c, err)
+		return
+	}
+
+	h.listTodos(c, userID, filter)
+}
+
+// listTodos responds with the todos matching filter, newest first, flagged as blocked where needed.
+func (h *TodoHandler) listTodos(c *gin.Context, userID primitive.ObjectID, filter bson.M) {
 	var todos []models.Todo
-	filter := bson.M{"userId": userID}
 	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
 
 	cursor, err := h.collection.Find(context.Background(), filter, opts)
@@ -126,6 +269,11 @@ func (h *TodoHandler) GetAllTodos(c *gin.Context) {
 		todos = []models.Todo{}
 	}
 
+	if err := h.annotateBlocked(context.Background(), userID, todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve dependencies"})
+		return
+	}
+
 	c.JSON(http.StatusOK, todos)
 }
 
@@ -156,7 +304,7 @@ func (h *TodoHandler) GetTodoByID(c *gin.Context) {
 	}
 
 	var todo models.Todo
-	filter := bson.M{"_id": id, "userId": userID}
+	filter := bson.M{"_id": id, "userId": userID, "deletedAt": nil}
 	err = h.collection.FindOne(context.Background(), filter).Decode(&todo)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -167,7 +315,13 @@ func (h *TodoHandler) GetTodoByID(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, todo)
+	todos := []models.Todo{todo}
+	if err := h.annotateBlocked(context.Background(), userID, todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve dependencies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, todos[0])
 }
 
 // UpdateTodo godoc
@@ -179,10 +333,11 @@ func (h *TodoHandler) GetTodoByID(c *gin.Context) {
 // @Security     ApiKeyAuth
 // @Param        id path string true "Todo ID"
 // @Param        todo body models.UpdateTodoDTO true "Todo Update Object"
-// @Success      200  {object}  map[string]string "{'message': 'Todo updated successfully'}"
+// @Success      200  {object}  map[string]string "{'message': 'Todo updated successfully', 'undoToken': '...'}"
 // @Failure      400  {object}  map[string]string "Invalid input or ID format"
 // @Failure      401  {object}  map[string]string "Unauthorized"
 // @Failure      404  {object}  map[string]string "Todo not found"
+// @Failure      409  {object}  map[string]interface{} "Todo is blocked by incomplete todos"
 // @Failure      500  {object}  map[string]string "Server error"
 // @Router       /todos/{id} [put]
 func (h *TodoHandler) UpdateTodo(c *gin.Context) {
@@ -205,50 +360,127 @@ func (h *TodoHandler) UpdateTodo(c *gin.Context) {
 	}
 
 	update := bson.D{}
+	unset := bson.D{}
 	if dto.Title != nil {
 		update = append(update, bson.E{Key: "title", Value: *dto.Title})
 	}
 	if dto.Description != nil {
 		update = append(update, bson.E{Key: "description", Value: *dto.Description})
 	}
+	if dto.Project != nil {
+		if project := strings.TrimSpace(*dto.Project); project != "" {
+			update = append(update, bson.E{Key: "project", Value: project})
+		} else {
+			unset = append(unset, bson.E{Key: "project", Value: ""})
+		}
+	}
+	if dto.Labels != nil {
+		if labels := normalizeLabels(*dto.Labels); len(labels) > 0 {
+			update = append(update, bson.E{Key: "labels", Value: labels})
+		} else {
+			unset = append(unset, bson.E{Key: "labels", Value: ""})
+		}
+	}
+	if dto.DueDate != nil {
+		if strings.TrimSpace(*dto.DueDate) != "" {
+			due, err := dates.ParseDate(*dto.DueDate, userPreferences(c).Location())
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+				return
+			}
+			update = append(update, bson.E{Key: "dueDate", Value: due})
+		} else {
+			unset = append(unset, bson.E{Key: "dueDate", Value: ""})
+		}
+	}
+	if dto.Priority != nil {
+		if *dto.Priority > 0 {
+			update = append(update, bson.E{Key: "priority", Value: *dto.Priority})
+		} else {
+			unset = append(unset, bson.E{Key: "priority", Value: ""})
+		}
+	}
+	if dto.Recurrence != nil {
+		if recurrence := normalizeRecurrence(dto.Recurrence); recurrence != nil {
+			update = append(update, bson.E{Key: "recurrence", Value: recurrence})
+		} else {
+			unset = append(unset, bson.E{Key: "recurrence", Value: ""})
+		}
+	}
 	if dto.Completed != nil {
+		if !*dto.Completed {
+			// A reopened todo leaves the archive, which only holds completed todos.
+			unset = append(unset, bson.E{Key: "completedAt", Value: ""}, bson.E{Key: "archivedAt", Value: ""})
+		}
 		update = append(update, bson.E{Key: "completed", Value: *dto.Completed})
 	}
 
-	if len(update) == 0 {
+	if len(update) == 0 && len(unset) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No update fields provided"})
 		return
 	}
 	update = append(update, bson.E{Key: "updatedAt", Value: primitive.NewDateTimeFromTime(time.Now())})
 
-	filter := bson.M{"_id": id, "userId": userID}
-	result, err := h.collection.UpdateOne(context.Background(), filter, bson.D{{Key: "$set", Value: update}})
+	filter := bson.M{"_id": id, "userId": userID, "deletedAt": nil}
+	var undoToken string
+	err = h.withTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		set := append(bson.D{}, update...)
+		if dto.Completed != nil {
+			if err := h.lockGraph(sessCtx, userID); err != nil {
+				return err
+			}
+		}
+		if dto.Completed != nil && *dto.Completed {
+			// A todo can't be completed while any of its blockers are still open.
+			var current models.Todo
+			if err := h.collection.FindOne(sessCtx, filter).Decode(&current); err != nil {
+				return err
+			}
+			if err := h.checkUnblocked(sessCtx, userID, current); err != nil {
+				return err
+			}
+			if !current.Completed {
+				set = append(set, bson.E{Key: "completedAt", Value: primitive.NewDateTimeFromTime(time.Now())})
+			}
+		}
+
+		changes := bson.D{{Key: "$set", Value: set}}
+		if len(unset) > 0 {
+			changes = append(changes, bson.E{Key: "$unset", Value: unset})
+		}
+		var err error
+		_, undoToken, err = h.applyMutation(sessCtx, userID, filter, changes, models.TodoActionUpdated)
+		return err
+	})
 	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found or you don't have permission"})
+			return
+		}
+		if respondBlocked(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update todo"})
 		return
 	}
 
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found or you don't have permission"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "Todo updated successfully"})
+	setUndoToken(c, undoToken)
+	c.JSON(http.StatusOK, gin.H{"message": "Todo updated successfully", "undoToken": undoToken})
 }
 
 // DeleteTodo godoc
-// @Summary      Delete a todo
-// @Description  Deletes a specific todo item by its ID
+// @Summary      Move a todo to the trash
+// @Description  Soft-deletes a specific todo item. It can be restored from the trash until it is purged.
 // @Tags         todos
 // @Produce      json
 // @Security     ApiKeyAuth
 // @Param        id path string true "Todo ID"
-// @Success      200  {object}  map[string]string "{'message': 'Todo deleted successfully'}"
+// @Success      200  {object}  map[string]string "{'message': 'Todo moved to trash', 'undoToken': '...'}"
 // @Failure      400  {object}  map[string]string "Invalid ID format"
 // @Failure      401  {object}  map[string]string "Unauthorized"
 // @Failure      404  {object}  map[string]string "Todo not found"
 // @Failure      500  {object}  map[string]string "Server error"
-// @Router       /todos/{id} [delete]
+// @Router       /tasks/{id} [delete]
 func (h *TodoHandler) DeleteTodo(c *gin.Context) {
 	userID, err := getUserIDFromContext(c)
 	if err != nil {
@@ -262,17 +494,84 @@ func (h *TodoHandler) DeleteTodo(c *gin.Context) {
 		return
 	}
 
-	filter := bson.M{"_id": id, "userId": userID}
-	result, err := h.collection.DeleteOne(context.Background(), filter)
+	now := primitive.NewDateTimeFromTime(time.Now())
+	filter := bson.M{"_id": id, "userId": userID, "deletedAt": nil}
+	update := bson.M{"$set": bson.M{"deletedAt": now, "updatedAt": now}}
+	_, undoToken, err := h.mutateTodo(context.Background(), userID, filter, update, models.TodoActionDeleted)
 	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found or you don't have permission"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete todo"})
 		return
 	}
 
-	if result.DeletedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found or you don't have permission"})
-		return
+	setUndoToken(c, undoToken)
+	c.JSON(http.StatusOK, gin.H{"message": "Todo moved to trash", "undoToken": undoToken})
+}
+
+// normalizeLabels lower-cases and trims labels, dropping empty and duplicate entries.
+func normalizeLabels(labels []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		label = strings.ToLower(strings.TrimSpace(label))
+		if label == "" || seen[label] {
+			continue
+		}
+		seen[label] = true
+		normalized = append(normalized, label)
 	}
+	return normalized
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Todo deleted successfully"})
+// normalizeRecurrence returns nil for an empty recurrence and defaults the interval to 1.
+func normalizeRecurrence(r *models.Recurrence) *models.Recurrence {
+	if r == nil || r.Frequency == "" {
+		return nil
+	}
+	normalized := *r
+	if normalized.Interval < 1 {
+		normalized.Interval = 1
+	}
+	return &normalized
+}
+
+// todoFilterQuery builds the MongoDB filter for the user's live todos matching f.
+// Relative days in a filter expression are resolved against now. Errors from parsing
+// the expression are returned as *query.Error.
+func todoFilterQuery(userID primitive.ObjectID, f models.TodoFilterDTO, now time.Time) (bson.M, error) {
+	filter := bson.M{"userId": userID, "deletedAt": nil, "archivedAt": nil}
+	if f.Completed != nil {
+		filter["completed"] = *f.Completed
+	}
+	if f.Project != nil {
+		if project := strings.TrimSpace(*f.Project); project != "" {
+			filter["project"] = project
+		} else {
+			filter["project"] = nil
+		}
+	}
+	if f.Label != nil {
+		filter["labels"] = strings.ToLower(strings.TrimSpace(*f.Label))
+	}
+	if q := strings.TrimSpace(f.Query); q != "" {
+		expr, err := query.Parse(q)
+		if err != nil {
+			return nil, err
+		}
+		filter["$and"] = bson.A{expr.Mongo(now)}
+	}
+	return filter, nil
+}
+
+// respondFilterError reports an invalid filter, pointing at the problem in a filter expression.
+func respondFilterError(c *gin.Context, err error) {
+	var queryErr *query.Error
+	if errors.As(err, &queryErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filter: " + queryErr.Msg, "position": queryErr.Pos + 1})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid filters: " + err.Error()})
 }
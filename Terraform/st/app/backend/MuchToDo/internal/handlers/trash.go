@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// GetTrash godoc
+// @Summary      List trashed todos
+// @Description  Retrieves the current user's deleted todos, most recently deleted first
+// @Tags         trash
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {array}  models.Todo
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/trash [get]
+func (h *TodoHandler) GetTrash(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	var todos []models.Todo
+	filter := trashFilter(userID)
+	opts := options.Find().SetSort(bson.D{{Key: "deletedAt", Value: -1}})
+
+	cursor, err := h.collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trash"})
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	if err = cursor.All(context.Background(), &todos); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode todos"})
+		return
+	}
+
+	if todos == nil {
+		todos = []models.Todo{}
+	}
+
+	c.JSON(http.StatusOK, todos)
+}
+
+// RestoreTodo godoc
+// @Summary      Restore a todo from the trash
+// @Description  Moves a deleted todo back into the user's list
+// @Tags         trash
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Todo ID"
+// @Success      200  {object}  map[string]string "{'message': 'Todo restored successfully', 'undoToken': '...'}"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Todo not found in trash"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/{id}/restore [post]
+func (h *TodoHandler) RestoreTodo(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	filter := trashFilter(userID)
+	filter["_id"] = id
+	update := bson.M{
+		"$unset": bson.M{"deletedAt": ""},
+		"$set":   bson.M{"updatedAt": primitive.NewDateTimeFromTime(time.Now())},
+	}
+	_, undoToken, err := h.mutateTodo(context.Background(), userID, filter, update, models.TodoActionRestored)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found in trash"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore todo"})
+		return
+	}
+
+	setUndoToken(c, undoToken)
+	c.JSON(http.StatusOK, gin.H{"message": "Todo restored successfully", "undoToken": undoToken})
+}
+
+// PurgeTodo godoc
+// @Summary      Permanently delete a trashed todo
+// @Description  Removes a todo from the trash for good. This cannot be undone. Its subtasks become top-level todos.
+// @Tags         trash
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Todo ID"
+// @Success      200  {object}  map[string]string "{'message': 'Todo permanently deleted'}"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Todo not found in trash"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/trash/{id} [delete]
+func (h *TodoHandler) PurgeTodo(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return
+	}
+
+	filter := trashFilter(userID)
+	filter["_id"] = id
+	purged, err := h.purgeTodos(context.Background(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge todo"})
+		return
+	}
+
+	if purged == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found in trash"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Todo permanently deleted"})
+}
+
+// EmptyTrash godoc
+// @Summary      Empty the trash
+// @Description  Permanently deletes every todo in the current user's trash. Their subtasks become top-level todos.
+// @Tags         trash
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {object}  map[string]interface{} "Returns the number of purged todos"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /tasks/trash [delete]
+func (h *TodoHandler) EmptyTrash(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	purged, err := h.purgeTodos(context.Background(), trashFilter(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to empty trash"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Trash emptied", "purged": purged})
+}
+
+// PurgeExpiredTrash permanently deletes every todo that has been in the trash since before cutoff.
+// It is run periodically by the background trash purger.
+func (h *TodoHandler) PurgeExpiredTrash(ctx context.Context, cutoff time.Time) (int64, error) {
+	return h.purgeTodos(ctx, expiredTrashFilter(cutoff))
+}
+
+// trashFilter matches the todos in a user's trash.
+func trashFilter(userID primitive.ObjectID) bson.M {
+	return bson.M{"userId": userID, "deletedAt": bson.M{"$ne": nil}}
+}
+
+// expiredTrashFilter matches every user's todos that have been in the trash since before cutoff.
+func expiredTrashFilter(cutoff time.Time) bson.M {
+	return bson.M{"deletedAt": bson.M{"$lte": cutoff}}
+}
+
+// purgeBatch is how many todos purgeTodos deletes in one transaction.
+const purgeBatch = 200
+
+// purgeTodos hard-deletes the todos matching filter along with their history, dependent
+// records, outbox sequence counters and attachments, drops them from any blockedBy
+// lists, makes their subtasks top-level todos and leaves a tombstone for each. The todos
+// are deleted in batches, each in a transaction, so that a batch that fails leaves
+// nothing half-deleted to be retried; attachment files are removed once their records
+// are gone.
+func (h *TodoHandler) purgeTodos(ctx context.Context, filter bson.M) (int64, error) {
+	var purged int64
+	for {
+		var deleted int64
+		var found int
+		var files []string
+		err := h.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+			var err error
+			deleted, found, files, err = h.purgeBatch(sessCtx, filter)
+			return err
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged += deleted
+		if h.attachments != nil {
+			h.attachments.deleteFiles(ctx, files)
+		}
+		if found < purgeBatch {
+			return purged, nil
+		}
+	}
+}
+
+// purgeBatch purges up to purgeBatch of the todos matching filter, inside a transaction.
+// It returns how many were deleted and found, and the storage keys of their attachment
+// files, which are left for the caller to remove after the commit.
+func (h *TodoHandler) purgeBatch(sessCtx mongo.SessionContext, filter bson.M) (int64, int, []string, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "userId": 1, "davName": 1}).SetLimit(purgeBatch)
+	var todos []models.Todo
+	cursor, err := h.collection.Find(sessCtx, filter, opts)
+	if err == nil {
+		err = cursor.All(sessCtx, &todos)
+	}
+	if err != nil || len(todos) == 0 {
+		return 0, 0, nil, err
+	}
+
+	now := time.Now()
+	ids := make([]primitive.ObjectID, len(todos))
+	byUser := make(map[primitive.ObjectID][]primitive.ObjectID)
+	for i, todo := range todos {
+		ids[i] = todo.ID
+		byUser[todo.UserID] = append(byUser[todo.UserID], todo.ID)
+	}
+
+	_, err = h.collection.UpdateMany(sessCtx, bson.M{"blockedBy": bson.M{"$in": ids}}, bson.M{"$pull": bson.M{"blockedBy": bson.M{"$in": ids}}})
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	// Each user's purges in the batch are one change to their todos, for CalDAV sync.
+	seqs := make(map[primitive.ObjectID]int64, len(byUser))
+	for userID, purged := range byUser {
+		seq, err := h.nextChange(sessCtx, userID)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		seqs[userID] = seq
+
+		// Subtasks outlive their parent as top-level todos, rather than pointing at nothing.
+		orphans := bson.M{"parentId": bson.M{"$in": purged}, "_id": bson.M{"$nin": ids}}
+		update := bson.M{"$unset": bson.M{"parentId": ""}, "$set": bson.M{"updatedAt": now, "changeSeq": seq}}
+		if _, err := h.collection.UpdateMany(sessCtx, orphans, update); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+
+	tombstones := make([]interface{}, len(todos))
+	for i, todo := range todos {
+		tombstones[i] = models.TodoTombstone{TodoID: todo.ID, UserID: todo.UserID, DAVName: todo.DAVName, ChangeSeq: seqs[todo.UserID], PurgedAt: now}
+	}
+
+	// CalDAV clients learn about purged todos from their tombstones.
+	if h.tombstones != nil {
+		if _, err := h.tombstones.InsertMany(sessCtx, tombstones); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+
+	for _, collection := range append([]*mongo.Collection{h.historyCollection}, h.dependents...) {
+		if _, err := collection.DeleteMany(sessCtx, bson.M{"todoId": bson.M{"$in": ids}}); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+
+	// The todos will have no more events, so their outbox sequence counters can go.
+	if h.outbox != nil {
+		if err := h.outbox.Forget(sessCtx, models.AggregateTodo, ids); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+
+	var files []string
+	if h.attachments != nil {
+		files, err = h.attachments.deleteRecords(sessCtx, bson.M{"todoId": bson.M{"$in": ids}})
+		if err != nil {
+			return 0, 0, nil, err
+		}
+	}
+
+	// The todos are deleted last, and only while still trashed, so that one restored
+	// since the batch was read makes the transaction conflict and retry without it.
+	result, err := h.collection.DeleteMany(sessCtx, bson.M{"_id": bson.M{"$in": ids}, "deletedAt": bson.M{"$ne": nil}})
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return result.DeletedCount, len(todos), files, nil
+}
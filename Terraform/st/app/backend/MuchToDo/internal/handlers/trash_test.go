@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestTrash provides unit tests for which todos count as trashed, and for the requests
+// that are refused before the database is touched.
+func TestTrash(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := primitive.NewObjectID()
+
+	t.Run("Filters", func(t *testing.T) {
+		// The trash is the user's deleted todos; lists and filters only see live ones.
+		assert.Equal(t, bson.M{"userId": userID, "deletedAt": bson.M{"$ne": nil}}, trashFilter(userID))
+		live, err := todoFilterQuery(userID, models.TodoFilterDTO{}, time.Now())
+		assert.NoError(t, err)
+		assert.Nil(t, live["deletedAt"])
+		assert.Contains(t, live, "deletedAt")
+		assert.Contains(t, live, "archivedAt")
+
+		// Expiry applies to every user's trash, by when the todos were deleted.
+		cutoff := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		assert.Equal(t, bson.M{"deletedAt": bson.M{"$lte": cutoff}}, expiredTrashFilter(cutoff))
+	})
+
+	t.Run("Requests", func(t *testing.T) {
+		h := &TodoHandler{}
+		for name, handle := range map[string]gin.HandlerFunc{"restore": h.RestoreTodo, "purge": h.PurgeTodo} {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/tasks/trash/nope", nil)
+			c.Params = gin.Params{{Key: "id", Value: "nope"}}
+			handle(c)
+			assert.Equal(t, http.StatusUnauthorized, w.Code, name)
+
+			w = httptest.NewRecorder()
+			c, _ = gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/tasks/trash/nope", nil)
+			c.Params = gin.Params{{Key: "id", Value: "nope"}}
+			c.Set("userID", userID.Hex())
+			handle(c)
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+		}
+	})
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// errUndoConflict is returned when a todo changed after the operation being undone.
+var errUndoConflict = errors.New("todo changed since the operation")
+
+// undoTokenHeader carries the undo token on responses from undoable endpoints.
+const undoTokenHeader = "X-Undo-Token"
+
+// setUndoToken exposes an undo token to the client, if one was issued.
+func setUndoToken(c *gin.Context, token string) {
+	if token != "" {
+		c.Header(undoTokenHeader, token)
+	}
+}
+
+// newUndoToken generates a random, URL-safe undo token.
+func newUndoToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// saveUndo stores an undo operation for the given items and returns its token.
+// Operations performed by background jobs (a nil actor) and empty operations are not undoable.
+func (h *TodoHandler) saveUndo(ctx context.Context, actor primitive.ObjectID, action string, items []models.UndoItem) (string, error) {
+	if actor.IsZero() || len(items) == 0 {
+		return "", nil
+	}
+
+	token, err := newUndoToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	op := models.UndoOperation{
+		Token:     token,
+		UserID:    actor,
+		Action:    action,
+		Items:     items,
+		CreatedAt: now,
+		ExpiresAt: now.Add(h.undoWindow),
+	}
+	if _, err := h.undoCollection.InsertOne(ctx, op); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// checkUndo returns errUndoConflict if a todo's history has moved on from the version
+// the operation being undone wrote, so undoing it would discard later changes.
+func checkUndo(item models.UndoItem, latestVersion int) error {
+	if latestVersion != item.Version {
+		return errUndoConflict
+	}
+	return nil
+}
+
+// checkRestorable checks todos about to be restored against the user's graph as it
+// stands, given as their todos with at least the IDs, blockers, parents, completion and
+// trash state. It returns errUndoConflict if a blocker or parent the restored todos
+// bring back no longer exists or would close a cycle, and a todoBlockedError if a todo
+// it completes again has open blockers.
+func checkRestorable(graph []models.Todo, restored []models.Todo) error {
+	byID := make(map[primitive.ObjectID]models.Todo, len(graph))
+	for _, todo := range graph {
+		byID[todo.ID] = todo
+	}
+	previous := make(map[primitive.ObjectID]models.Todo, len(restored))
+	for _, todo := range restored {
+		previous[todo.ID] = byID[todo.ID]
+		byID[todo.ID] = todo
+	}
+	dependencies := make(dependencyGraph, len(byID))
+	for id, todo := range byID {
+		dependencies[id] = todo.BlockedBy
+	}
+
+	// Only what the undo changes is checked, so that it isn't refused over states the
+	// app allows to arise, like a completed todo whose blocker was reopened.
+	for _, todo := range restored {
+		prev := previous[todo.ID]
+		for _, blocker := range todo.BlockedBy {
+			if slices.Contains(prev.BlockedBy, blocker) {
+				continue
+			}
+			if _, ok := byID[blocker]; !ok || dependencies.createsCycle(todo.ID, blocker) {
+				return errUndoConflict
+			}
+		}
+
+		if todo.ParentID != nil && !sameObjectIDPtr(todo.ParentID, prev.ParentID) {
+			seen := map[primitive.ObjectID]bool{}
+			for id := *todo.ParentID; ; {
+				parent, ok := byID[id]
+				if !ok || id == todo.ID || seen[id] {
+					return errUndoConflict
+				}
+				seen[id] = true
+				if parent.ParentID == nil {
+					break
+				}
+				id = *parent.ParentID
+			}
+		}
+
+		if todo.Completed && !prev.Completed && todo.DeletedAt == nil {
+			var open []string
+			for _, blocker := range todo.BlockedBy {
+				if b, ok := byID[blocker]; ok && !b.Completed && b.DeletedAt == nil {
+					open = append(open, blocker.Hex())
+				}
+			}
+			if len(open) > 0 {
+				return todoBlockedError{blockers: open}
+			}
+		}
+	}
+	return nil
+}
+
+// Undo godoc
+// @Summary      Undo a recent operation
+// @Description  Reverses the operation that issued the token, restoring every affected todo to its previous state in one transaction.
+// @Description  Tokens are single-use and only valid for a short window. The undo is refused if any affected todo has changed since,
+// @Description  or if restoring it would bring back a dependency or parent that no longer exists or would close a cycle, or complete a blocked todo.
+// @Tags         undo
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        token path string true "Undo token"
+// @Success      200  {object}  map[string]interface{} "Returns the undone action and the number of restored todos"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Undo token not found or expired"
+// @Failure      409  {object}  map[string]interface{} "A todo changed since the operation, or would be completed while blocked"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /undo/{token} [post]
+func (h *TodoHandler) Undo(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	token := c.Param("token")
+
+	var op models.UndoOperation
+	err = h.withTransaction(context.Background(), func(sessCtx mongo.SessionContext) error {
+		filter := bson.M{"token": token, "userId": userID, "expiresAt": bson.M{"$gt": time.Now()}}
+		if err := h.undoCollection.FindOneAndDelete(sessCtx, filter).Decode(&op); err != nil {
+			return err
+		}
+
+		// Restoring can bring back blockers, parents and completion, so the rest of the
+		// user's graph must not change until the restored todos are checked against it.
+		if err := h.lockGraph(sessCtx, userID); err != nil {
+			return err
+		}
+
+		currents := make([]models.Todo, len(op.Items))
+		restored := make([]models.Todo, len(op.Items))
+		for i, item := range op.Items {
+			err := h.collection.FindOne(sessCtx, bson.M{"_id": item.Before.ID, "userId": userID}).Decode(&currents[i])
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return errUndoConflict
+			}
+			if err != nil {
+				return err
+			}
+
+			version, err := h.latestVersion(sessCtx, currents[i].ID)
+			if err != nil {
+				return err
+			}
+			if err := checkUndo(item, version); err != nil {
+				return err
+			}
+			restored[i] = item.Before
+			restored[i].UpdatedAt = time.Now()
+		}
+
+		var graph []models.Todo
+		opts := options.Find().SetProjection(bson.M{"_id": 1, "blockedBy": 1, "parentId": 1, "completed": 1, "deletedAt": 1})
+		cursor, err := h.collection.Find(sessCtx, bson.M{"userId": userID}, opts)
+		if err == nil {
+			err = cursor.All(sessCtx, &graph)
+		}
+		if err != nil {
+			return err
+		}
+		if err := checkRestorable(graph, restored); err != nil {
+			return err
+		}
+
+		for i := range restored {
+			if _, err := h.collection.ReplaceOne(sessCtx, bson.M{"_id": restored[i].ID}, restored[i]); err != nil {
+				return err
+			}
+			if _, err := h.recordVersion(sessCtx, userID, models.TodoActionUndone, &currents[i], restored[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Undo token not found or expired"})
+			return
+		}
+		if errors.Is(err, errUndoConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Cannot undo: a todo has changed since the operation"})
+			return
+		}
+		if respondBlocked(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to undo operation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Operation undone", "action": op.Action, "restored": len(op.Items)})
+}
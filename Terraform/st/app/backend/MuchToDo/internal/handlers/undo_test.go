@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// TestUndo provides unit tests for when an operation can be undone, and for the
+// requests that are refused before the database is touched.
+func TestUndo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := primitive.NewObjectID()
+
+	t.Run("VersionMismatch", func(t *testing.T) {
+		item := models.UndoItem{Before: models.Todo{ID: primitive.NewObjectID(), Title: "Ship it"}, Version: 3}
+		assert.NoError(t, checkUndo(item, 3))
+		// A later change, or history that is missing the operation's version, refuses the undo.
+		assert.ErrorIs(t, checkUndo(item, 4), errUndoConflict)
+		assert.ErrorIs(t, checkUndo(item, 2), errUndoConflict)
+		assert.ErrorIs(t, checkUndo(item, 0), errUndoConflict)
+	})
+
+	t.Run("Restorable", func(t *testing.T) {
+		a, b, c := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+		now := time.Now()
+
+		// A was blocked by B; the blocker was removed, then B was made blocked by A.
+		graph := []models.Todo{{ID: a}, {ID: b, BlockedBy: []primitive.ObjectID{a}}, {ID: c, Completed: true}}
+		undone := models.Todo{ID: a, BlockedBy: []primitive.ObjectID{b}}
+		assert.ErrorIs(t, checkRestorable(graph, []models.Todo{undone}), errUndoConflict)
+		// Undoing both changes together leaves no cycle.
+		assert.NoError(t, checkRestorable(graph, []models.Todo{undone, {ID: b}}))
+		// Blockers that have since been purged aren't brought back.
+		assert.ErrorIs(t, checkRestorable(graph, []models.Todo{{ID: a, BlockedBy: []primitive.ObjectID{primitive.NewObjectID()}}}), errUndoConflict)
+
+		// Parents must still exist, and not be the todo's own subtasks.
+		graph = []models.Todo{{ID: a}, {ID: b, ParentID: &a}, {ID: c}}
+		assert.ErrorIs(t, checkRestorable(graph, []models.Todo{{ID: a, ParentID: &b}}), errUndoConflict)
+		missing := primitive.NewObjectID()
+		assert.ErrorIs(t, checkRestorable(graph, []models.Todo{{ID: c, ParentID: &missing}}), errUndoConflict)
+		assert.NoError(t, checkRestorable(graph, []models.Todo{{ID: c, ParentID: &b}}))
+
+		// A todo completed again must have no open blockers; trashed and completed ones don't count.
+		graph = []models.Todo{{ID: a, BlockedBy: []primitive.ObjectID{b, c}}, {ID: b}, {ID: c, Completed: true}}
+		err := checkRestorable(graph, []models.Todo{{ID: a, Completed: true, BlockedBy: []primitive.ObjectID{b, c}}})
+		assert.Equal(t, todoBlockedError{blockers: []string{b.Hex()}}, err)
+		graph[1].DeletedAt = &now
+		assert.NoError(t, checkRestorable(graph, []models.Todo{{ID: a, Completed: true, BlockedBy: []primitive.ObjectID{b, c}}}))
+
+		// What the undo leaves as it is isn't checked, like a blocker reopened since.
+		graph = []models.Todo{{ID: a, Completed: true, BlockedBy: []primitive.ObjectID{b}}, {ID: b}}
+		assert.NoError(t, checkRestorable(graph, []models.Todo{{ID: a, Title: "Ship it", Completed: true, BlockedBy: []primitive.ObjectID{b}}}))
+	})
+
+	t.Run("NotUndoable", func(t *testing.T) {
+		h := &TodoHandler{}
+		items := []models.UndoItem{{Before: models.Todo{ID: primitive.NewObjectID()}, Version: 1}}
+
+		// Background jobs and operations that changed nothing issue no token.
+		token, err := h.saveUndo(t.Context(), primitive.NilObjectID, models.TodoActionArchived, items)
+		assert.NoError(t, err)
+		assert.Empty(t, token)
+		token, err = h.saveUndo(t.Context(), userID, models.TodoActionUpdated, nil)
+		assert.NoError(t, err)
+		assert.Empty(t, token)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		setUndoToken(c, "")
+		assert.Empty(t, w.Header().Get(undoTokenHeader))
+		setUndoToken(c, "abc")
+		assert.Equal(t, "abc", w.Header().Get(undoTokenHeader))
+	})
+
+	t.Run("Tokens", func(t *testing.T) {
+		a, err := newUndoToken()
+		assert.NoError(t, err)
+		b, err := newUndoToken()
+		assert.NoError(t, err)
+		assert.Len(t, a, 32)
+		assert.NotEqual(t, a, b)
+	})
+
+	t.Run("Requests", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/undo/abc", nil)
+		c.Params = gin.Params{{Key: "token", Value: "abc"}}
+		(&TodoHandler{}).Undo(c)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
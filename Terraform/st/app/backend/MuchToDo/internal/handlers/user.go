@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"math/rand"
 	"net/http"
 	"strings"
@@ -19,6 +20,7 @@ import (
 	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/cache"
 	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/config"
 	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/outbox"
 	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/utils"
 )
 
@@ -28,12 +30,15 @@ type UserHandler struct {
 	todoCollection *mongo.Collection
 	tokenSvc       *auth.TokenService
 	cache          cache.Cache
-	dbClient       *mongo.Client // Added for cache refreshing
-	config         config.Config // Added for cache refreshing
+	dbClient       *mongo.Client      // Added for cache refreshing
+	config         config.Config      // Added for cache refreshing
+	attachments    *AttachmentHandler // Removes the files of deleted accounts; may be nil
+	exports        *DataExportHandler // Removes the data export archives of deleted accounts; may be nil
+	outbox         *outbox.Outbox     // Receives the events of changes to accounts; may be nil
 }
 
 // NewUserHandler creates a new UserHandler.
-func NewUserHandler(collection *mongo.Collection, todoCollection *mongo.Collection, tokenSvc *auth.TokenService, cache cache.Cache, db *mongo.Client, cfg config.Config) *UserHandler {
+func NewUserHandler(collection *mongo.Collection, todoCollection *mongo.Collection, tokenSvc *auth.TokenService, cache cache.Cache, db *mongo.Client, cfg config.Config, attachments *AttachmentHandler, exports *DataExportHandler, events *outbox.Outbox) *UserHandler {
 	return &UserHandler{
 		collection:     collection,
 		todoCollection: todoCollection,
@@ -41,6 +46,9 @@ func NewUserHandler(collection *mongo.Collection, todoCollection *mongo.Collecti
 		cache:          cache,
 		dbClient:       db,
 		config:         cfg,
+		attachments:    attachments,
+		exports:        exports,
+		outbox:         events,
 	}
 }
 
@@ -77,6 +85,7 @@ func (h *UserHandler) Register(c *gin.Context) {
 	now := time.Now()
 
 	newUser := models.User{
+		ID:        primitive.NewObjectID(),
 		FirstName: dto.FirstName,
 		LastName:  dto.LastName,
 		Username:  strings.ToLower(dto.Username),
@@ -89,7 +98,11 @@ func (h *UserHandler) Register(c *gin.Context) {
 		return
 	}
 
-	_, err = h.collection.InsertOne(context.Background(), newUser)
+	event := newUserEvent(models.UserEventCreated, newUser.ID, now)
+	err = h.saveWithEvent(context.Background(), event, func(ctx context.Context) error {
+		_, err := h.collection.InsertOne(ctx, newUser)
+		return err
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
@@ -106,6 +119,7 @@ func (h *UserHandler) Register(c *gin.Context) {
 // @Summary      Log in a user
 // @Description  Logs in a user with username and password, returning a session token.
 // @Description  The token is returned in the response body and as an httpOnly cookie.
+// @Description  For an account scheduled for deletion, the response includes deleteAt and the token can only be used to restore the account.
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -144,7 +158,7 @@ func (h *UserHandler) Login(c *gin.Context) {
 	cookieDomain := utils.GetCookieDomain(c, h.config.CookieDomains)
 	c.SetCookie("token", token, h.tokenSvc.GetExpirationSeconds(), "/", cookieDomain, h.config.SecureCookie, true)
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"message": "Login successful",
 		"token":   token, // Also return token in body for API clients
 		"user": models.PublicUser{
@@ -153,7 +167,12 @@ func (h *UserHandler) Login(c *gin.Context) {
 			LastName:  user.LastName,
 			Username:  user.Username,
 		},
-	})
+	}
+	// A disabled account can sign in, but only to restore itself.
+	if user.DeleteAt != nil {
+		response["deleteAt"] = user.DeleteAt
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // Logout godoc
@@ -203,6 +222,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 	}
 
 	update := bson.D{}
+	var changes []string
 
 	// Handle username change
 	if dto.Username != nil {
@@ -226,13 +246,16 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 			return
 		}
 		update = append(update, bson.E{Key: "username", Value: newUsername})
+		changes = append(changes, "username")
 	}
 
 	if dto.FirstName != nil {
 		update = append(update, bson.E{Key: "firstName", Value: *dto.FirstName})
+		changes = append(changes, "firstName")
 	}
 	if dto.LastName != nil {
 		update = append(update, bson.E{Key: "lastName", Value: *dto.LastName})
+		changes = append(changes, "lastName")
 	}
 
 	if len(update) == 0 {
@@ -240,20 +263,28 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	update = append(update, bson.E{Key: "updatedAt", Value: primitive.NewDateTimeFromTime(time.Now())})
+	now := time.Now()
+	update = append(update, bson.E{Key: "updatedAt", Value: primitive.NewDateTimeFromTime(now)})
 
 	filter := bson.M{"_id": userID}
-	result, err := h.collection.UpdateOne(context.Background(), filter, bson.D{{Key: "$set", Value: update}})
+	event := newUserEvent(models.UserEventUpdated, userID, now)
+	event.Changes = changes
+	err = h.saveWithEvent(context.Background(), event, func(ctx context.Context) error {
+		result, err := h.collection.UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: update}})
+		if err == nil && result.MatchedCount == 0 {
+			err = mongo.ErrNoDocuments
+		}
+		return err
+	})
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
 		return
 	}
 
-	if result.MatchedCount == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully"})
 }
 
@@ -317,7 +348,12 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 
 	// Update the password in the database
 	update := bson.M{"$set": bson.M{"password": user.Password}}
-	_, err = h.collection.UpdateOne(context.Background(), filter, update)
+	event := newUserEvent(models.UserEventUpdated, userID, time.Now())
+	event.Changes = []string{"password"}
+	err = h.saveWithEvent(context.Background(), event, func(ctx context.Context) error {
+		_, err := h.collection.UpdateOne(ctx, filter, update)
+		return err
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update password"})
 		return
@@ -328,12 +364,15 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 
 // DeleteUser godoc
 // @Summary      Delete current user's account
-// @Description  Permanently deletes the authenticated user's account and all their associated data (e.g., todos)
+// @Description  Schedules the authenticated user's account and all their associated data (e.g., todos) for permanent deletion after a grace period.
+// @Description  The account is disabled straight away; until the deletion date it can be restored with POST /users/me/restore. Without a grace period the account is deleted immediately.
 // @Tags         users
 // @Produce      json
 // @Security     ApiKeyAuth
 // @Success      200  {object}  map[string]string "{'message': 'Account deleted successfully'}"
+// @Success      202  {object}  map[string]interface{} "The account is scheduled for deletion at deleteAt"
 // @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "User not found"
 // @Failure      500  {object}  map[string]string "Server error"
 // @Router       /users/me [delete]
 func (h *UserHandler) DeleteUser(c *gin.Context) {
@@ -349,49 +388,48 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	// Use a transaction to ensure both the user and their todos are deleted
-	session, err := h.dbClient.StartSession()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start database session"})
-		return
-	}
-	defer session.EndSession(context.Background())
-
-	callback := func(sessCtx mongo.SessionContext) (interface{}, error) {
-		// Delete all todos for this user
-		_, err := h.todoCollection.DeleteMany(sessCtx, bson.M{"userId": userID})
-		if err != nil {
-			return nil, err
-		}
-
-		// Delete the user
-		result, err := h.collection.DeleteOne(sessCtx, bson.M{"_id": userID})
-		if err != nil {
-			return nil, err
-		}
+	// Clear the session cookie
+	cookieDomain := utils.GetCookieDomain(c, h.config.CookieDomains)
 
-		if result.DeletedCount == 0 {
-			return nil, mongo.ErrNoDocuments // Use a standard error to indicate user not found
+	if h.config.AccountDeletionGraceDays <= 0 {
+		if err := h.purgeUser(context.Background(), userID); err != nil {
+			if err == mongo.ErrNoDocuments {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			slog.Error("Failed to delete account", "user", userID.Hex(), slog.Any("error", err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+			return
 		}
-
-		return result, nil
+		c.SetCookie("token", "", -1, "/", cookieDomain, false, true)
+		c.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
+		return
 	}
 
-	_, err = session.WithTransaction(context.Background(), callback)
+	now := time.Now()
+	deleteAt := now.AddDate(0, 0, h.config.AccountDeletionGraceDays)
+	update := bson.M{"$set": bson.M{"deletionRequestedAt": now, "deleteAt": deleteAt}}
+	event := newUserEvent(models.UserEventDeletionScheduled, userID, now)
+	event.DeleteAt = &deleteAt
+	err = h.saveWithEvent(context.Background(), event, func(ctx context.Context) error {
+		result, err := h.collection.UpdateOne(ctx, bson.M{"_id": userID, "deleteAt": nil}, update)
+		if err == nil && result.MatchedCount == 0 {
+			err = mongo.ErrNoDocuments
+		}
+		return err
+	})
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account: " + err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
 		return
 	}
+	h.cache.Delete(context.Background(), accountStateCacheKey(userID))
 
-	// Clear the session cookie
-	cookieDomain := utils.GetCookieDomain(c, h.config.CookieDomains)
 	c.SetCookie("token", "", -1, "/", cookieDomain, false, true)
-
-	c.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
+	c.JSON(http.StatusAccepted, gin.H{"message": "Account scheduled for deletion", "deleteAt": deleteAt})
 }
 
 // CheckUsernameAvailability godoc
@@ -442,6 +480,40 @@ func (h *UserHandler) CheckUsernameAvailability(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"available": true, "message": "Username is available"})
 }
 
+// LookupUsernames resolves usernames to user IDs, keyed by the lowercased username.
+// Usernames that don't belong to any user, or belong to an account scheduled for deletion,
+// are left out of the result.
+func (h *UserHandler) LookupUsernames(ctx context.Context, usernames []string) (map[string]primitive.ObjectID, error) {
+	found := make(map[string]primitive.ObjectID)
+	if len(usernames) == 0 {
+		return found, nil
+	}
+
+	lowered := make([]string, len(usernames))
+	for i, username := range usernames {
+		lowered[i] = strings.ToLower(username)
+	}
+
+	opts := options.Find().SetProjection(bson.M{"username": 1})
+	cursor, err := h.collection.Find(ctx, bson.M{"username": bson.M{"$in": lowered}, "deleteAt": nil}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var result struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Username string             `bson:"username"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			return nil, err
+		}
+		found[result.Username] = result.ID
+	}
+	return found, cursor.Err()
+}
+
 // GetCurrentUser godoc
 // @Summary      Get current user's profile
 // @Description  Retrieves the profile of the user corresponding to the provided JWT
@@ -524,3 +596,40 @@ func (h *UserHandler) triggerRandomCacheRefresh() {
 		}()
 	}
 }
+
+// saveWithEvent runs save, which changes an account, and appends event to the outbox in
+// the same transaction. Without an outbox, save runs on its own.
+func (h *UserHandler) saveWithEvent(ctx context.Context, event models.UserEvent, save func(ctx context.Context) error) error {
+	if h.outbox == nil {
+		return save(ctx)
+	}
+
+	session, err := h.dbClient.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if err := save(sessCtx); err != nil {
+			return nil, err
+		}
+		return nil, h.appendUserEvent(sessCtx, event)
+	})
+	if err == nil {
+		h.outbox.Notify()
+	}
+	return err
+}
+
+// appendUserEvent appends an account event to the outbox. ctx must be the session
+// context of the transaction saving the change.
+func (h *UserHandler) appendUserEvent(ctx context.Context, event models.UserEvent) error {
+	record := models.OutboxRecord{ID: event.ID, AggregateType: models.AggregateUser, AggregateID: event.UserID, EventType: event.Type, UserID: event.UserID}
+	return h.outbox.Append(ctx, record, event)
+}
+
+// newUserEvent creates an event of the given type for a user's account.
+func newUserEvent(eventType string, userID primitive.ObjectID, now time.Time) models.UserEvent {
+	return models.UserEvent{ID: primitive.NewObjectID(), Type: eventType, UserID: userID, OccurredAt: now}
+}
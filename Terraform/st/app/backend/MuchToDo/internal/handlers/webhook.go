@@ -0,0 +1,714 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/outbox"
+)
+
+const (
+	webhooksPerUser      = 20               // Most webhooks a user can have at once
+	webhookTimeout       = 10 * time.Second // For one delivery attempt
+	webhookMaxAttempts   = 8                // Attempts at a delivery before it is given up on
+	webhookRetryBase     = 30 * time.Second // Wait before the first retry; it doubles for each one after
+	webhookLease         = 2 * time.Minute  // A delivery being sent this long is assumed to have died with its server
+	webhookResponseLimit = 64 << 10         // Bytes of a response that are read before it is discarded
+)
+
+// WebhookHandler manages the user's webhooks and posts todo events to them. Each event
+// is stored as a delivery that is retried with exponential backoff until the endpoint
+// accepts it, so that the attempts can be inspected and replayed.
+type WebhookHandler struct {
+	collection   *mongo.Collection
+	deliveries   *mongo.Collection
+	client       *http.Client
+	allowPrivate bool // Whether webhooks may post to loopback and private addresses
+}
+
+// errWebhookDestination is the error of an attempt to post to an address webhooks may
+// not reach.
+var errWebhookDestination = errors.New("webhooks can't post to loopback, private or link-local addresses")
+
+// NewWebhookHandler creates a new handler for webhooks. Unless allowPrivate is set,
+// webhooks can't post to loopback, private or link-local addresses, so that users can't
+// use them to reach services inside the network.
+func NewWebhookHandler(collection *mongo.Collection, deliveries *mongo.Collection, allowPrivate bool) *WebhookHandler {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivate {
+		// The address is checked as it is dialled, after DNS resolution, so that a host
+		// name can't be pointed at a private address once the webhook is registered.
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if privateAddress(ip) {
+				return errWebhookDestination
+			}
+			return nil
+		}
+	}
+
+	return &WebhookHandler{
+		collection:   collection,
+		deliveries:   deliveries,
+		allowPrivate: allowPrivate,
+		client: &http.Client{
+			Timeout: webhookTimeout,
+			// No proxy, which would dial the endpoint in the webhook's place.
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: webhookTimeout,
+			},
+			// A redirect is reported as the response it is, rather than followed.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// CreateWebhook godoc
+// @Summary      Register a webhook
+// @Description  Registers an endpoint to post todo events to: todo.created, todo.updated, todo.completed and todo.deleted.
+// @Description  Each delivery is a JSON event signed with HMAC-SHA256: the X-MuchToDo-Signature header is "sha256=" followed by the hex
+// @Description  signature of the X-MuchToDo-Timestamp header, a period and the body. The signing secret is only returned in this response.
+// @Description  The URL can't point to a loopback, private or link-local address.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        webhook body models.WebhookDTO true "Endpoint and events"
+// @Success      201  {object}  models.NewWebhook
+// @Failure      400  {object}  map[string]string "Invalid input"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      409  {object}  map[string]string "Too many webhooks"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	dto, ok := h.bindWebhookDTO(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	count, err := h.collection.CountDocuments(ctx, bson.M{"userId": userID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+	if count >= webhooksPerUser {
+		c.JSON(http.StatusConflict, gin.H{"error": "Too many webhooks; delete one first"})
+		return
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate webhook secret"})
+		return
+	}
+	now := time.Now()
+	webhook := models.Webhook{
+		ID:          primitive.NewObjectID(),
+		UserID:      userID,
+		URL:         dto.URL,
+		Description: dto.Description,
+		Events:      webhookEvents(dto.Events),
+		Active:      dto.Active == nil || *dto.Active,
+		Secret:      secret,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := h.collection.InsertOne(ctx, webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.NewWebhook{Webhook: webhook, Secret: secret})
+}
+
+// GetWebhooks godoc
+// @Summary      List webhooks
+// @Description  Lists the user's webhooks, oldest first. Their signing secrets can't be shown again.
+// @Tags         webhooks
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Success      200  {array}   models.Webhook
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /webhooks [get]
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var webhooks []models.Webhook
+	cursor, err := h.collection.Find(ctx, bson.M{"userId": userID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err == nil {
+		err = cursor.All(ctx, &webhooks)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+
+	if webhooks == nil {
+		webhooks = []models.Webhook{}
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// GetWebhook godoc
+// @Summary      Get a webhook
+// @Description  Retrieves a webhook.
+// @Tags         webhooks
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Webhook ID"
+// @Success      200  {object}  models.Webhook
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Webhook not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	webhook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, webhook)
+}
+
+// UpdateWebhook godoc
+// @Summary      Update a webhook
+// @Description  Replaces a webhook's endpoint, description and events, and pauses or resumes it. Its secret stays the same.
+// @Description  Deliveries already queued are still sent.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Webhook ID"
+// @Param        webhook body models.WebhookDTO true "Endpoint and events"
+// @Success      200  {object}  models.Webhook
+// @Failure      400  {object}  map[string]string "Invalid input"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Webhook not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	webhook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	dto, ok := h.bindWebhookDTO(c)
+	if !ok {
+		return
+	}
+
+	update := bson.M{"$set": bson.M{
+		"url":         dto.URL,
+		"description": dto.Description,
+		"events":      webhookEvents(dto.Events),
+		"active":      dto.Active == nil || *dto.Active,
+		"updatedAt":   time.Now(),
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := h.collection.FindOneAndUpdate(c.Request.Context(), bson.M{"_id": webhook.ID, "userId": webhook.UserID}, update, opts).Decode(&webhook)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook godoc
+// @Summary      Delete a webhook
+// @Description  Deletes a webhook along with its delivery log. Queued deliveries are not sent.
+// @Tags         webhooks
+// @Security     ApiKeyAuth
+// @Param        id path string true "Webhook ID"
+// @Success      204  "Webhook deleted"
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Webhook not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	webhook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	result, err := h.collection.DeleteOne(ctx, bson.M{"_id": webhook.ID, "userId": webhook.UserID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+	if _, err := h.deliveries.DeleteMany(ctx, bson.M{"webhookId": webhook.ID}); err != nil {
+		slog.Warn("Failed to delete webhook deliveries", "webhook", webhook.ID.Hex(), slog.Any("error", err))
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetDeliveries godoc
+// @Summary      List a webhook's deliveries
+// @Description  Lists the events sent to a webhook, newest first, with the response code and error of every attempt.
+// @Tags         webhooks
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Webhook ID"
+// @Param        status query string false "Only deliveries in this state" Enums(pending, sending, succeeded, failed)
+// @Param        page query int false "Page number (default 1, max 10000)"
+// @Param        limit query int false "Page size (default 20, max 100)"
+// @Success      200  {object}  map[string]interface{} "Paginated list of models.WebhookDelivery"
+// @Failure      400  {object}  map[string]string "Invalid ID format or page"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Webhook not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	webhook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	filter := bson.M{"webhookId": webhook.ID}
+	if status := c.Query("status"); status != "" {
+		filter["status"] = status
+	}
+
+	ctx := c.Request.Context()
+	p, ok := parsePagination(c)
+	if !ok {
+		return
+	}
+	total, err := h.deliveries.CountDocuments(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+
+	var deliveries []models.WebhookDelivery
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).SetSkip(p.Skip()).SetLimit(p.Limit)
+	cursor, err := h.deliveries.Find(ctx, filter, opts)
+	if err == nil {
+		err = cursor.All(ctx, &deliveries)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+	c.JSON(http.StatusOK, paginatedResponse(deliveries, p, total))
+}
+
+// ReplayDelivery godoc
+// @Summary      Replay a webhook delivery
+// @Description  Sends the event of an earlier delivery to the webhook again, as a new delivery with the same event ID, even if the webhook is paused.
+// @Tags         webhooks
+// @Produce      json
+// @Security     ApiKeyAuth
+// @Param        id path string true "Webhook ID"
+// @Param        deliveryId path string true "Delivery ID"
+// @Success      202  {object}  models.WebhookDelivery
+// @Failure      400  {object}  map[string]string "Invalid ID format"
+// @Failure      401  {object}  map[string]string "Unauthorized"
+// @Failure      404  {object}  map[string]string "Webhook or delivery not found"
+// @Failure      500  {object}  map[string]string "Server error"
+// @Router       /webhooks/{id}/deliveries/{deliveryId}/replay [post]
+func (h *WebhookHandler) ReplayDelivery(c *gin.Context) {
+	webhook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := primitive.ObjectIDFromHex(c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID format"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var original models.WebhookDelivery
+	if err := h.deliveries.FindOne(ctx, bson.M{"_id": deliveryID, "webhookId": webhook.ID}).Decode(&original); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch delivery"})
+		return
+	}
+
+	replay := newWebhookDelivery(webhook, original.EventID, original.EventType, original.Payload, time.Now())
+	replay.ReplayOf = &original.ID
+	if _, err := h.deliveries.InsertOne(ctx, replay); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay delivery"})
+		return
+	}
+
+	// Send it right away; if this server stops first, the background job picks it up.
+	go h.deliverQueued([]primitive.ObjectID{replay.ID})
+
+	c.JSON(http.StatusAccepted, replay)
+}
+
+// Name implements outbox.Sink.
+func (h *WebhookHandler) Name() string { return "webhooks" }
+
+// Publish implements outbox.Sink: it queues deliveries of a todo event to the active
+// webhooks subscribed to it, and starts sending them.
+func (h *WebhookHandler) Publish(ctx context.Context, record models.OutboxRecord) error {
+	if record.AggregateType != models.AggregateTodo {
+		return nil
+	}
+
+	var webhooks []models.Webhook
+	cursor, err := h.collection.Find(ctx, bson.M{"userId": record.UserID, "active": true, "events": record.EventType})
+	if err == nil {
+		err = cursor.All(ctx, &webhooks)
+	}
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	// The relay publishes a record again if it stops before recording that it did. The
+	// deliveries then already exist, so inserting them again fails and nothing is sent twice.
+	now := time.Now()
+	deliveries := make([]interface{}, len(webhooks))
+	ids := make([]primitive.ObjectID, len(webhooks))
+	for i, webhook := range webhooks {
+		delivery := newWebhookDelivery(webhook, record.ID, record.EventType, record.Payload, now)
+		delivery.ID = webhookDeliveryID(webhook.ID, record.ID)
+		deliveries[i] = delivery
+		ids[i] = delivery.ID
+	}
+	if _, err := h.deliveries.InsertMany(ctx, deliveries, options.InsertMany().SetOrdered(false)); err != nil && !onlyDuplicateKeys(err) {
+		return err
+	}
+
+	go h.deliverQueued(ids)
+	return nil
+}
+
+// DeliverDue sends the deliveries whose next attempt is due, including those whose
+// sender died, and returns how many succeeded.
+func (h *WebhookHandler) DeliverDue(ctx context.Context) (int, error) {
+	delivered := 0
+	for {
+		delivery, err := h.claim(ctx, bson.M{
+			"status":        bson.M{"$in": bson.A{models.WebhookDeliveryPending, models.WebhookDeliverySending}},
+			"nextAttemptAt": bson.M{"$lte": time.Now()},
+		})
+		if err == mongo.ErrNoDocuments {
+			return delivered, nil
+		}
+		if err != nil {
+			return delivered, err
+		}
+		ok, err := h.deliver(ctx, delivery)
+		if err != nil {
+			return delivered, err
+		}
+		if ok {
+			delivered++
+		}
+	}
+}
+
+// deliverQueued makes the first attempt at newly queued deliveries, unless the
+// background job has claimed them already.
+func (h *WebhookHandler) deliverQueued(ids []primitive.ObjectID) {
+	ctx := context.Background()
+	for _, id := range ids {
+		delivery, err := h.claim(ctx, bson.M{"_id": id, "status": models.WebhookDeliveryPending})
+		if err != nil {
+			if err != mongo.ErrNoDocuments {
+				slog.Error("Failed to start webhook delivery", "delivery", id.Hex(), slog.Any("error", err))
+			}
+			continue
+		}
+		if _, err := h.deliver(ctx, delivery); err != nil {
+			slog.Error("Failed to record webhook delivery", "delivery", id.Hex(), slog.Any("error", err))
+		}
+	}
+}
+
+// claim marks the delivery matching filter that has waited longest as being sent and
+// returns it, or returns mongo.ErrNoDocuments if there is none. Claiming is atomic, so
+// each attempt is made once.
+func (h *WebhookHandler) claim(ctx context.Context, filter bson.M) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	update := bson.M{"$set": bson.M{"status": models.WebhookDeliverySending, "nextAttemptAt": time.Now().Add(webhookLease)}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+		SetReturnDocument(options.After)
+	err := h.deliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery)
+	return delivery, err
+}
+
+// deliver makes an attempt at a claimed delivery and records its outcome. A failed
+// delivery is retried with exponential backoff until it has been attempted
+// webhookMaxAttempts times. It reports whether the endpoint accepted the delivery.
+func (h *WebhookHandler) deliver(ctx context.Context, delivery models.WebhookDelivery) (bool, error) {
+	var attempt models.WebhookAttempt
+	var webhook models.Webhook
+	deleted := false
+	err := h.collection.FindOne(ctx, bson.M{"_id": delivery.WebhookID}).Decode(&webhook)
+	switch {
+	case err == nil:
+		attempt = h.send(ctx, webhook, delivery)
+	case errors.Is(err, mongo.ErrNoDocuments):
+		attempt = models.WebhookAttempt{At: time.Now(), Error: "The webhook was deleted"}
+		deleted = true
+	default:
+		return false, err
+	}
+
+	ok := attempt.ResponseCode >= 200 && attempt.ResponseCode < 300
+	set := bson.M{"responseCode": attempt.ResponseCode}
+	update := bson.M{"$set": set, "$push": bson.M{"attempts": attempt}}
+	switch {
+	case ok:
+		set["status"] = models.WebhookDeliverySucceeded
+		set["completedAt"] = time.Now()
+		update["$unset"] = bson.M{"nextAttemptAt": ""}
+	case deleted || len(delivery.Attempts)+1 >= webhookMaxAttempts:
+		set["status"] = models.WebhookDeliveryFailed
+		set["completedAt"] = time.Now()
+		update["$unset"] = bson.M{"nextAttemptAt": ""}
+	default:
+		set["status"] = models.WebhookDeliveryPending
+		set["nextAttemptAt"] = time.Now().Add(webhookBackoff(len(delivery.Attempts) + 1))
+	}
+
+	_, err = h.deliveries.UpdateOne(ctx, bson.M{"_id": delivery.ID}, update)
+	return ok, err
+}
+
+// send posts a delivery to its webhook and describes how that went.
+func (h *WebhookHandler) send(ctx context.Context, webhook models.Webhook, delivery models.WebhookDelivery) models.WebhookAttempt {
+	start := time.Now()
+	attempt := models.WebhookAttempt{At: start}
+	defer func() { attempt.DurationMs = time.Since(start).Milliseconds() }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "MuchToDo-Webhooks/1.0")
+	req.Header.Set(outbox.EventHeader, delivery.EventType)
+	req.Header.Set(outbox.DeliveryHeader, delivery.ID.Hex())
+	req.Header.Set(outbox.TimestampHeader, timestamp)
+	req.Header.Set(outbox.SignatureHeader, outbox.Signature(webhook.Secret, timestamp, []byte(delivery.Payload)))
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, webhookResponseLimit))
+
+	attempt.ResponseCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		attempt.Error = "Unexpected response: " + resp.Status
+	}
+	return attempt
+}
+
+// findWebhook loads the current user's webhook named by the :id parameter, writing an
+// error response and returning false if it can't.
+func (h *WebhookHandler) findWebhook(c *gin.Context) (models.Webhook, bool) {
+	var webhook models.Webhook
+
+	userID, err := getUserIDFromContext(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+		return webhook, false
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		return webhook, false
+	}
+
+	err = h.collection.FindOne(c.Request.Context(), bson.M{"_id": id, "userId": userID}).Decode(&webhook)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return webhook, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhook"})
+		return webhook, false
+	}
+
+	return webhook, true
+}
+
+// bindWebhookDTO reads and validates a webhook from the request body, writing an error
+// response and returning false if it is invalid.
+func (h *WebhookHandler) bindWebhookDTO(c *gin.Context) (models.WebhookDTO, bool) {
+	var dto models.WebhookDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return dto, false
+	}
+	u, err := url.Parse(dto.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The URL must be an absolute http or https URL"})
+		return dto, false
+	}
+	// Host names are checked again when they are dialled, as they can resolve to anything.
+	if !h.allowPrivate && privateHost(u.Hostname()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The URL can't point to a loopback, private or link-local address"})
+		return dto, false
+	}
+	return dto, true
+}
+
+// privateHost reports whether a URL's host is a local name or an address webhooks may
+// not post to.
+func privateHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && privateAddress(ip)
+}
+
+// privateAddress reports whether ip is a loopback, private, link-local, unspecified or
+// multicast address, or in the carrier-grade NAT range, none of which webhooks may post to.
+func privateAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip)
+}
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, which is private in practice.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// newWebhookDelivery creates a delivery of an event to a webhook, due now.
+func newWebhookDelivery(webhook models.Webhook, eventID primitive.ObjectID, eventType string, payload string, now time.Time) models.WebhookDelivery {
+	return models.WebhookDelivery{
+		ID:            primitive.NewObjectID(),
+		WebhookID:     webhook.ID,
+		UserID:        webhook.UserID,
+		EventID:       eventID,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        models.WebhookDeliveryPending,
+		Attempts:      []models.WebhookAttempt{},
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+	}
+}
+
+// webhookDeliveryID returns the ID of the delivery of an event to a webhook, which is
+// derived from the two. It begins with the event ID's timestamp, so that deliveries
+// still sort by when their events happened.
+func webhookDeliveryID(webhookID, eventID primitive.ObjectID) primitive.ObjectID {
+	sum := sha256.Sum256(append(webhookID[:], eventID[:]...))
+	var id primitive.ObjectID
+	copy(id[:4], eventID[:4])
+	copy(id[4:], sum[:])
+	return id
+}
+
+// onlyDuplicateKeys reports whether err is a bulk write error in which every failed
+// write was rejected for a duplicate key.
+func onlyDuplicateKeys(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if !mongo.IsDuplicateKeyError(writeErr) {
+			return false
+		}
+	}
+	return true
+}
+
+// webhookEvents returns the event types a webhook subscribes to without duplicates,
+// in a fixed order.
+func webhookEvents(requested []string) []string {
+	events := []string{}
+	for _, eventType := range models.TodoEventTypes {
+		if slices.Contains(requested, eventType) {
+			events = append(events, eventType)
+		}
+	}
+	return events
+}
+
+// webhookBackoff returns how long to wait after a delivery's nth failed attempt.
+func webhookBackoff(attempts int) time.Duration {
+	return webhookRetryBase << (attempts - 1)
+}
+
+// newWebhookSecret generates a random secret for signing a webhook's deliveries.
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/outbox"
+)
+
+// TestWebhooks provides unit tests for webhook deliveries, using a local receiver.
+func TestWebhooks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// The receivers are on localhost, which webhooks can only post to when allowed.
+	h := NewWebhookHandler(nil, nil, true)
+	webhook := models.Webhook{ID: primitive.NewObjectID(), UserID: primitive.NewObjectID(), Secret: "whsec_test"}
+	event := models.TodoEvent{ID: primitive.NewObjectID(), Type: models.TodoEventCompleted, Todo: models.Todo{Title: "Ship it", Completed: true}}
+	payload, err := json.Marshal(event)
+	assert.NoError(t, err)
+	delivery := newWebhookDelivery(webhook, event.ID, event.Type, string(payload), time.Now())
+
+	t.Run("Send", func(t *testing.T) {
+		var received *http.Request
+		var body []byte
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer receiver.Close()
+		webhook := webhook
+		webhook.URL = receiver.URL + "/hook"
+
+		attempt := h.send(t.Context(), webhook, delivery)
+		assert.Equal(t, http.StatusNoContent, attempt.ResponseCode)
+		assert.Empty(t, attempt.Error)
+
+		assert.Equal(t, http.MethodPost, received.Method)
+		assert.Equal(t, "/hook", received.URL.Path)
+		assert.Equal(t, "application/json", received.Header.Get("Content-Type"))
+		assert.Equal(t, models.TodoEventCompleted, received.Header.Get(outbox.EventHeader))
+		assert.Equal(t, delivery.ID.Hex(), received.Header.Get(outbox.DeliveryHeader))
+		assert.Equal(t, payload, body)
+
+		// The receiver can check the signature with the secret.
+		timestamp := received.Header.Get(outbox.TimestampHeader)
+		_, err := strconv.ParseInt(timestamp, 10, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, outbox.Signature("whsec_test", timestamp, body), received.Header.Get(outbox.SignatureHeader))
+		assert.NotEqual(t, outbox.Signature("other", timestamp, body), received.Header.Get(outbox.SignatureHeader))
+
+		var got models.TodoEvent
+		assert.NoError(t, json.Unmarshal(body, &got))
+		assert.Equal(t, event.ID, got.ID)
+		assert.Equal(t, "Ship it", got.Todo.Title)
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/moved" {
+				http.Redirect(w, r, "/hook", http.StatusFound)
+				return
+			}
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}))
+		webhook := webhook
+
+		webhook.URL = receiver.URL + "/hook"
+		attempt := h.send(t.Context(), webhook, delivery)
+		assert.Equal(t, http.StatusInternalServerError, attempt.ResponseCode)
+		assert.Contains(t, attempt.Error, "500")
+
+		// Redirects are not followed.
+		webhook.URL = receiver.URL + "/moved"
+		attempt = h.send(t.Context(), webhook, delivery)
+		assert.Equal(t, http.StatusFound, attempt.ResponseCode)
+
+		// Without a response there is only an error.
+		receiver.Close()
+		webhook.URL = receiver.URL + "/hook"
+		attempt = h.send(t.Context(), webhook, delivery)
+		assert.Zero(t, attempt.ResponseCode)
+		assert.NotEmpty(t, attempt.Error)
+	})
+
+	t.Run("PrivateAddresses", func(t *testing.T) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("a webhook posted to a private address")
+		}))
+		defer receiver.Close()
+		webhook := webhook
+		webhook.URL = receiver.URL + "/hook"
+
+		attempt := NewWebhookHandler(nil, nil, false).send(t.Context(), webhook, delivery)
+		assert.Zero(t, attempt.ResponseCode)
+		assert.Contains(t, attempt.Error, errWebhookDestination.Error())
+
+		for host, private := range map[string]bool{
+			"127.0.0.1":        true,
+			"::1":              true,
+			"localhost":        true,
+			"api.localhost":    true,
+			"10.1.2.3":         true,
+			"172.16.0.1":       true,
+			"192.168.1.1":      true,
+			"169.254.169.254":  true,
+			"100.64.0.1":       true,
+			"0.0.0.0":          true,
+			"::ffff:127.0.0.1": true,
+			"fd00::1":          true,
+			"93.184.216.34":    false,
+			"2606:4700::1111":  false,
+			"example.com":      false,
+			"mongo":            false, // Caught when it is dialled
+		} {
+			assert.Equal(t, private, privateHost(host), host)
+		}
+	})
+
+	t.Run("Backoff", func(t *testing.T) {
+		assert.Equal(t, 30*time.Second, webhookBackoff(1))
+		assert.Equal(t, time.Minute, webhookBackoff(2))
+		assert.Equal(t, 32*time.Minute, webhookBackoff(webhookMaxAttempts-1))
+	})
+
+	t.Run("DeliveryID", func(t *testing.T) {
+		id := webhookDeliveryID(webhook.ID, event.ID)
+		assert.Equal(t, id, webhookDeliveryID(webhook.ID, event.ID))
+		assert.NotEqual(t, id, webhookDeliveryID(primitive.NewObjectID(), event.ID))
+		assert.Equal(t, event.ID.Timestamp(), id.Timestamp())
+
+		duplicate := mongo.BulkWriteError{WriteError: mongo.WriteError{Code: 11000}}
+		other := mongo.BulkWriteError{WriteError: mongo.WriteError{Code: 121}}
+		assert.True(t, onlyDuplicateKeys(mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{duplicate, duplicate}}))
+		assert.False(t, onlyDuplicateKeys(mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{duplicate, other}}))
+		assert.False(t, onlyDuplicateKeys(mongo.ErrClientDisconnected))
+	})
+
+	t.Run("Events", func(t *testing.T) {
+		todo := models.Todo{ID: primitive.NewObjectID(), Title: "Ship it"}
+		done := todo
+		done.Completed = true
+
+		entry := models.TodoVersion{TodoID: todo.ID, Version: 1, Action: models.TodoActionCreated, Snapshot: done}
+		assert.Equal(t, models.TodoEventCreated, versionEvent(nil, entry).Type)
+		entry.Action = models.TodoActionUpdated
+		assert.Equal(t, models.TodoEventCompleted, versionEvent(&todo, entry).Type)
+		assert.Equal(t, models.TodoEventUpdated, versionEvent(&done, entry).Type)
+		entry.Action = models.TodoActionDeleted
+		assert.Equal(t, models.TodoEventDeleted, versionEvent(&done, entry).Type)
+
+		entry.Action = models.TodoActionRestored
+		event := versionEvent(&done, entry)
+		assert.Equal(t, models.TodoEventUpdated, event.Type)
+		assert.Equal(t, todo.ID, event.TodoID)
+		assert.Equal(t, models.TodoActionRestored, event.Action)
+
+		assert.Equal(t, []string{models.TodoEventCreated, models.TodoEventDeleted}, webhookEvents([]string{"todo.deleted", "todo.created", "todo.deleted"}))
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		for body, valid := range map[string]bool{
+			`{"url": "https://example.com/hook", "events": ["todo.completed"]}`:          true,
+			`{"url": "ftp://example.com/hook", "events": ["todo.completed"]}`:            false,
+			`{"url": "https://example.com/hook", "events": ["todo.moved"]}`:              false,
+			`{"url": "https://example.com/hook", "events": []}`:                          false,
+			`{"events": ["todo.created"]}`:                                               false,
+			`{"url": "http://169.254.169.254/latest", "events": ["todo.created"]}`:       false,
+			`{"url": "http://localhost:6379", "events": ["todo.created"]}`:               false,
+			`{"url": "http://[::1]:27017/hook", "events": ["todo.created"]}`:             false,
+			`{"url": "https://hooks.example.com:8443/hook", "events": ["todo.created"]}`: true,
+		} {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			_, ok := NewWebhookHandler(nil, nil, false).bindWebhookDTO(c)
+			assert.Equal(t, valid, ok, body)
+			if !valid {
+				assert.Equal(t, http.StatusBadRequest, w.Code, body)
+			}
+		}
+	})
+}
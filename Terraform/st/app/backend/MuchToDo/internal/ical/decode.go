@@ -0,0 +1,245 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDecodeLine is the longest unfolded content line Decode accepts.
+const maxDecodeLine = 1 << 20
+
+// Decode reads a single top-level component, usually a VCALENDAR, unfolding long lines.
+func Decode(r io.Reader) (*Component, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), maxDecodeLine)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			if len(lines) == 0 {
+				return nil, errors.New("ical: continuation line without a line to continue")
+			}
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ical: %w", err)
+	}
+
+	var root *Component
+	var stack []*Component
+	for n, line := range lines {
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("ical: line %d: %w", n+1, err)
+		}
+		switch strings.ToUpper(prop.Name) {
+		case "BEGIN":
+			c := &Component{Name: strings.ToUpper(prop.Value)}
+			if len(stack) > 0 {
+				stack[len(stack)-1].Append(c)
+			} else if root != nil {
+				return nil, errors.New("ical: more than one top-level component")
+			} else {
+				root = c
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(prop.Value) {
+				return nil, fmt.Errorf("ical: line %d: unexpected END:%s", n+1, prop.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("ical: line %d: property outside of a component", n+1)
+			}
+			current := stack[len(stack)-1]
+			current.Props = append(current.Props, prop)
+		}
+	}
+	if root == nil {
+		return nil, errors.New("ical: no component found")
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("ical: %s is not closed", stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+// parseLine splits a content line into its name, parameters and value.
+func parseLine(line string) (Property, error) {
+	var prop Property
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return prop, errors.New("missing property name")
+	}
+	prop.Name = strings.ToUpper(line[:i])
+
+	rest := line[i:]
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return prop, errors.New("malformed parameter")
+		}
+		param := Param{Name: strings.ToUpper(rest[:eq])}
+		rest = rest[eq+1:]
+
+		// Values may be quoted; a parameter may also list several values, which are kept together.
+		var value strings.Builder
+		for {
+			if strings.HasPrefix(rest, `"`) {
+				end := strings.IndexByte(rest[1:], '"')
+				if end < 0 {
+					return prop, errors.New("unterminated quoted parameter value")
+				}
+				value.WriteString(rest[1 : end+1])
+				rest = rest[end+2:]
+			} else {
+				end := strings.IndexAny(rest, ";:,")
+				if end < 0 {
+					return prop, errors.New("missing property value")
+				}
+				value.WriteString(rest[:end])
+				rest = rest[end:]
+			}
+			if !strings.HasPrefix(rest, ",") {
+				break
+			}
+			value.WriteByte(',')
+			rest = rest[1:]
+		}
+		param.Value = value.String()
+		prop.Params = append(prop.Params, param)
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return prop, errors.New("missing property value")
+	}
+	prop.Value = rest[1:]
+	return prop, nil
+}
+
+// Prop returns the first property with the given name, or nil.
+func (c *Component) Prop(name string) *Property {
+	for i := range c.Props {
+		if c.Props[i].Name == name {
+			return &c.Props[i]
+		}
+	}
+	return nil
+}
+
+// Children returns the nested components with the given name.
+func (c *Component) Children(name string) []*Component {
+	var children []*Component
+	for _, child := range c.Components {
+		if child.Name == name {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// Param returns the value of the named parameter, or "".
+func (p Property) Param(name string) string {
+	for _, param := range p.Params {
+		if param.Name == name {
+			return param.Value
+		}
+	}
+	return ""
+}
+
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// Text returns the value of a TEXT property, unescaped.
+func (p Property) Text() string {
+	return textUnescaper.Replace(p.Value)
+}
+
+// TextList returns the values of a property holding a comma-separated list of texts,
+// such as CATEGORIES, unescaped.
+func (p Property) TextList() []string {
+	var list []string
+	var current strings.Builder
+	for i := 0; i < len(p.Value); i++ {
+		switch ch := p.Value[i]; {
+		case ch == '\\' && i+1 < len(p.Value):
+			current.WriteByte(ch)
+			current.WriteByte(p.Value[i+1])
+			i++
+		case ch == ',':
+			list = append(list, textUnescaper.Replace(current.String()))
+			current.Reset()
+		default:
+			current.WriteByte(ch)
+		}
+	}
+	return append(list, textUnescaper.Replace(current.String()))
+}
+
+// Time parses a DATE or DATE-TIME property. Dates are midnight in loc and reported as
+// such through allDay; times without a zone ("floating") are read in loc, and times
+// with a TZID parameter in that zone, falling back to loc if it is unknown.
+func (p Property) Time(loc *time.Location) (t time.Time, allDay bool, err error) {
+	value := strings.TrimSpace(p.Value)
+	if strings.EqualFold(p.Param("VALUE"), "DATE") || len(value) == len(dateLayout) {
+		t, err = time.ParseInLocation(dateLayout, value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse(dateTimeLayout, value)
+		return t, false, err
+	}
+	if tzid := p.Param("TZID"); tzid != "" {
+		if zone, zoneErr := time.LoadLocation(tzid); zoneErr == nil {
+			loc = zone
+		}
+	}
+	t, err = time.ParseInLocation(strings.TrimSuffix(dateTimeLayout, "Z"), value, loc)
+	return t, false, err
+}
+
+// ParseRecur parses an RRULE value. Only the parts Recur holds are read; others, such as
+// COUNT or UNTIL, are ignored.
+func ParseRecur(value string) (Recur, error) {
+	var r Recur
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(val)
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return r, fmt.Errorf("ical: invalid INTERVAL %q", val)
+			}
+			r.Interval = n
+		case "BYDAY":
+			r.ByDay = strings.Split(strings.ToUpper(val), ",")
+		case "BYMONTHDAY":
+			n, err := strconv.Atoi(strings.Split(val, ",")[0])
+			if err != nil {
+				return r, fmt.Errorf("ical: invalid BYMONTHDAY %q", val)
+			}
+			r.ByMonthDay = n
+		}
+	}
+	if r.Freq == "" {
+		return r, errors.New("ical: RRULE without FREQ")
+	}
+	return r, nil
+}
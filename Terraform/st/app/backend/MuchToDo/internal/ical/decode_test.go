@@ -0,0 +1,115 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestDecode provides unit tests for reading iCalendar data.
+func TestDecode(t *testing.T) {
+	t.Run("Calendar", func(t *testing.T) {
+		data := strings.Join([]string{
+			"BEGIN:VCALENDAR",
+			"VERSION:2.0",
+			"BEGIN:VTODO",
+			"UID:1@test",
+			`SUMMARY:Pay rent\, on`,
+			"  time",
+			`CATEGORIES:bills,home\, garden`,
+			`X-TEST;LABEL="a:b";ROLE=x,y:value`,
+			"DUE;TZID=Europe/Berlin:20250314T173000",
+			"END:VTODO",
+			"END:VCALENDAR",
+			"",
+		}, "\r\n")
+
+		cal, err := Decode(strings.NewReader(data))
+		assert.NoError(t, err)
+		assert.Equal(t, Calendar, cal.Name)
+		assert.Equal(t, "2.0", cal.Prop("VERSION").Value)
+
+		todos := cal.Children(Todo)
+		assert.Len(t, todos, 1)
+		todo := todos[0]
+		assert.Equal(t, "Pay rent, on time", todo.Prop("SUMMARY").Text())
+		assert.Equal(t, []string{"bills", "home, garden"}, todo.Prop("CATEGORIES").TextList())
+		assert.Equal(t, "a:b", todo.Prop("X-TEST").Param("LABEL"))
+		assert.Equal(t, "x,y", todo.Prop("X-TEST").Param("ROLE"))
+		assert.Equal(t, "value", todo.Prop("X-TEST").Value)
+		assert.Nil(t, todo.Prop("DESCRIPTION"))
+
+		due, allDay, err := todo.Prop("DUE").Time(time.UTC)
+		assert.NoError(t, err)
+		assert.False(t, allDay)
+		assert.Equal(t, time.Date(2025, 3, 14, 16, 30, 0, 0, time.UTC), due.UTC())
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		cal := NewCalendar("-//Test//EN")
+		todo := &Component{Name: Todo}
+		todo.AddText("SUMMARY", strings.Repeat("Ünïcödé, text; ", 10))
+		cal.Append(todo)
+
+		var buf bytes.Buffer
+		assert.NoError(t, cal.Encode(&buf))
+		decoded, err := Decode(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, strings.Repeat("Ünïcödé, text; ", 10), decoded.Children(Todo)[0].Prop("SUMMARY").Text())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, data := range []string{
+			"",
+			"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VCALENDAR\r\n",
+			"BEGIN:VCALENDAR\r\n",
+			"SUMMARY:outside\r\n",
+			"BEGIN:VCALENDAR\r\nno colon\r\nEND:VCALENDAR\r\n",
+			"BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\nBEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n",
+		} {
+			_, err := Decode(strings.NewReader(data))
+			assert.Error(t, err, data)
+		}
+	})
+
+	t.Run("Time", func(t *testing.T) {
+		loc := time.FixedZone("CET", 3600)
+		cases := []struct {
+			prop   Property
+			want   time.Time
+			allDay bool
+		}{
+			{Property{Value: "20250314", Params: []Param{{Name: "VALUE", Value: "DATE"}}}, time.Date(2025, 3, 14, 0, 0, 0, 0, loc), true},
+			{Property{Value: "20250314T163000Z"}, time.Date(2025, 3, 14, 16, 30, 0, 0, time.UTC), false},
+			{Property{Value: "20250314T173000"}, time.Date(2025, 3, 14, 17, 30, 0, 0, loc), false},
+			{Property{Value: "20250314T173000", Params: []Param{{Name: "TZID", Value: "Not/AZone"}}}, time.Date(2025, 3, 14, 17, 30, 0, 0, loc), false},
+		}
+		for _, tc := range cases {
+			got, allDay, err := tc.prop.Time(loc)
+			assert.NoError(t, err, tc.prop.Value)
+			assert.True(t, tc.want.Equal(got), tc.prop.Value)
+			assert.Equal(t, tc.allDay, allDay, tc.prop.Value)
+		}
+
+		_, _, err := Property{Value: "tomorrow"}.Time(loc)
+		assert.Error(t, err)
+	})
+
+	t.Run("ParseRecur", func(t *testing.T) {
+		r, err := ParseRecur("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=10")
+		assert.NoError(t, err)
+		assert.Equal(t, Recur{Freq: FreqWeekly, Interval: 2, ByDay: []string{"MO", "WE"}}, r)
+
+		r, err = ParseRecur("FREQ=MONTHLY;BYMONTHDAY=15")
+		assert.NoError(t, err)
+		assert.Equal(t, "FREQ=MONTHLY;BYMONTHDAY=15", r.String())
+
+		_, err = ParseRecur("INTERVAL=2")
+		assert.Error(t, err)
+		_, err = ParseRecur("FREQ=DAILY;INTERVAL=0")
+		assert.Error(t, err)
+	})
+}
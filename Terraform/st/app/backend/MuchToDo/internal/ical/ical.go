@@ -0,0 +1,199 @@
+// Package ical writes iCalendar data as described by RFC 5545: components such as
+// VCALENDAR, VTODO and VEVENT made of properties, one per line, with long lines folded.
+//
+// Property values are stored in their encoded form. Use the typed helpers (AddText,
+// AddDateTime, AddDate) to have values escaped and formatted correctly.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Component types used by the application.
+const (
+	Calendar = "VCALENDAR"
+	Todo     = "VTODO"
+	Event    = "VEVENT"
+)
+
+// maxLineOctets is the longest a content line may be before it has to be folded.
+const maxLineOctets = 75
+
+const (
+	dateLayout     = "20060102"
+	dateTimeLayout = "20060102T150405Z"
+)
+
+// Param is a property parameter, such as VALUE=DATE.
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Property is a single content line of a component.
+type Property struct {
+	Name   string
+	Params []Param
+	Value  string // Encoded value, e.g. an escaped text or a formatted date
+}
+
+// Component is an iCalendar component with its properties and nested components.
+type Component struct {
+	Name       string
+	Props      []Property
+	Components []*Component
+}
+
+// NewCalendar returns an empty VCALENDAR identifying its producer by prodID, e.g.
+// "-//MuchToDo//Todos//EN".
+func NewCalendar(prodID string) *Component {
+	cal := &Component{Name: Calendar}
+	cal.Add("VERSION", "2.0")
+	cal.Add("PRODID", prodID)
+	cal.Add("CALSCALE", "GREGORIAN")
+	return cal
+}
+
+// Add appends a property with an already encoded value.
+func (c *Component) Add(name, value string, params ...Param) {
+	c.Props = append(c.Props, Property{Name: name, Params: params, Value: value})
+}
+
+// AddText appends a TEXT property, escaping the value.
+func (c *Component) AddText(name, text string, params ...Param) {
+	c.Add(name, EscapeText(text), params...)
+}
+
+// AddDateTime appends a DATE-TIME property in UTC.
+func (c *Component) AddDateTime(name string, t time.Time) {
+	c.Add(name, FormatDateTime(t))
+}
+
+// AddDate appends a DATE property for t's calendar day, in t's location.
+func (c *Component) AddDate(name string, t time.Time) {
+	c.Add(name, FormatDate(t), Param{Name: "VALUE", Value: "DATE"})
+}
+
+// Append adds a nested component.
+func (c *Component) Append(child *Component) {
+	c.Components = append(c.Components, child)
+}
+
+// Encode writes the component and everything nested in it.
+func (c *Component) Encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	c.encode(bw)
+	return bw.Flush()
+}
+
+func (c *Component) encode(w *bufio.Writer) {
+	writeLine(w, "BEGIN:"+c.Name)
+	for _, p := range c.Props {
+		writeLine(w, p.String())
+	}
+	for _, child := range c.Components {
+		child.encode(w)
+	}
+	writeLine(w, "END:"+c.Name)
+}
+
+// String returns the property as an unfolded content line.
+func (p Property) String() string {
+	var b strings.Builder
+	b.WriteString(p.Name)
+	for _, param := range p.Params {
+		b.WriteByte(';')
+		b.WriteString(param.Name)
+		b.WriteByte('=')
+		b.WriteString(quoteParam(param.Value))
+	}
+	b.WriteByte(':')
+	b.WriteString(p.Value)
+	return b.String()
+}
+
+// writeLine writes a content line, folding it after every maxLineOctets octets without
+// splitting a UTF-8 sequence. Continuation lines start with a space, which counts
+// towards their length.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLineOctets - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+// quoteParam quotes a parameter value if it contains characters with a meaning of their
+// own. Double quotes can't be represented and are dropped.
+func quoteParam(value string) string {
+	value = strings.ReplaceAll(value, `"`, "")
+	if strings.ContainsAny(value, ":;,") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// EscapeText escapes a TEXT value: backslashes, semicolons, commas and line breaks.
+func EscapeText(text string) string {
+	return textEscaper.Replace(text)
+}
+
+// FormatDateTime formats a DATE-TIME value in UTC.
+func FormatDateTime(t time.Time) string {
+	return t.UTC().Format(dateTimeLayout)
+}
+
+// FormatDate formats a DATE value for t's calendar day in t's location.
+func FormatDate(t time.Time) string {
+	return t.Format(dateLayout)
+}
+
+// Recurrence frequencies, as used in RRULE.
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// Weekdays holds the RRULE names of the days of the week, indexed by time.Weekday.
+var Weekdays = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Recur is a recurrence rule (RRULE).
+type Recur struct {
+	Freq       string   // One of the Freq* constants
+	Interval   int      // Every n periods; 0 or 1 for every period
+	ByDay      []string // Weekday names from Weekdays
+	ByMonthDay int      // Day of the month, 0 for none
+}
+
+// String encodes the rule as an RRULE value.
+func (r Recur) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		parts = append(parts, "BYDAY="+strings.Join(r.ByDay, ","))
+	}
+	if r.ByMonthDay != 0 {
+		parts = append(parts, fmt.Sprintf("BYMONTHDAY=%d", r.ByMonthDay))
+	}
+	return strings.Join(parts, ";")
+}
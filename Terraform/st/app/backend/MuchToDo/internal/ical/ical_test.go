@@ -0,0 +1,70 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestEncode provides unit tests for writing iCalendar data.
+func TestEncode(t *testing.T) {
+	t.Run("EscapeText", func(t *testing.T) {
+		assert.Equal(t, `Buy milk\, eggs\; bread`, EscapeText("Buy milk, eggs; bread"))
+		assert.Equal(t, `C:\\temp\nline two\nline three`, EscapeText("C:\\temp\r\nline two\nline three"))
+	})
+
+	t.Run("Calendar", func(t *testing.T) {
+		due := time.Date(2025, 3, 14, 17, 30, 0, 0, time.FixedZone("CET", 3600))
+		cal := NewCalendar("-//Test//EN")
+		todo := &Component{Name: Todo}
+		todo.Add("UID", "1@test")
+		todo.AddText("SUMMARY", "Pay rent, on time")
+		todo.AddDateTime("DUE", due)
+		todo.AddDate("DTSTART", due)
+		todo.Add("X-TEST", "value", Param{Name: "LABEL", Value: "a:b"})
+		cal.Append(todo)
+
+		var buf bytes.Buffer
+		assert.NoError(t, cal.Encode(&buf))
+		assert.Equal(t, strings.Join([]string{
+			"BEGIN:VCALENDAR",
+			"VERSION:2.0",
+			"PRODID:-//Test//EN",
+			"CALSCALE:GREGORIAN",
+			"BEGIN:VTODO",
+			"UID:1@test",
+			`SUMMARY:Pay rent\, on time`,
+			"DUE:20250314T163000Z",
+			"DTSTART;VALUE=DATE:20250314",
+			`X-TEST;LABEL="a:b":value`,
+			"END:VTODO",
+			"END:VCALENDAR",
+			"",
+		}, "\r\n"), buf.String())
+	})
+
+	t.Run("Folding", func(t *testing.T) {
+		c := &Component{Name: Todo}
+		c.AddText("DESCRIPTION", strings.Repeat("ä", 100)) // Two octets each
+		var buf bytes.Buffer
+		assert.NoError(t, c.Encode(&buf))
+
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+		unfolded := ""
+		for _, line := range lines[1 : len(lines)-1] {
+			assert.LessOrEqual(t, len(line), maxLineOctets)
+			unfolded += strings.TrimPrefix(line, " ")
+		}
+		assert.Equal(t, "DESCRIPTION:"+strings.Repeat("ä", 100), unfolded)
+		assert.True(t, strings.HasPrefix(lines[2], " ä"))
+	})
+
+	t.Run("Recur", func(t *testing.T) {
+		assert.Equal(t, "FREQ=DAILY", Recur{Freq: FreqDaily, Interval: 1}.String())
+		assert.Equal(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", Recur{Freq: FreqWeekly, Interval: 2, ByDay: []string{Weekdays[time.Monday]}}.String())
+		assert.Equal(t, "FREQ=MONTHLY;BYMONTHDAY=1", Recur{Freq: FreqMonthly, ByMonthDay: 1}.String())
+	})
+}
@@ -0,0 +1,165 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// csvAliases maps other header names, lower-cased and without spaces, dashes or
+// underscores, to the column of our CSV export they stand for.
+var csvAliases = map[string]string{
+	"content": "title", "name": "title", "task": "title", "subject": "title",
+	"desc": "description", "notes": "description", "note": "description",
+	"list":  "project",
+	"label": "labels", "tags": "labels", "tag": "labels",
+	"due":    "dueDate",
+	"done":   "completed",
+	"parent": "parentId",
+}
+
+// csvColumn returns the export column a header stands for, or "" if it isn't known.
+func csvColumn(header string) string {
+	key := strings.ToLower(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.TrimSpace(header)))
+	if column, ok := csvAliases[key]; ok {
+		return column
+	}
+	for _, column := range models.TodoCSVColumns {
+		if strings.ToLower(column) == key {
+			return column
+		}
+	}
+	return ""
+}
+
+// parseCSV reads a CSV file with a header row. Unknown columns are ignored.
+func parseCSV(data []byte, loc *time.Location) ([]Item, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff")) // Spreadsheet apps like to add a BOM
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		if column := csvColumn(name); column != "" {
+			if _, seen := columns[column]; !seen {
+				columns[column] = i
+			}
+		}
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("invalid CSV: no title column")
+	}
+
+	var items []Item
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		items = append(items, csvItem(record, columns, line, loc))
+	}
+	return items, nil
+}
+
+// csvItem builds the item for one CSV record.
+func csvItem(record []string, columns map[string]int, line int, loc *time.Location) Item {
+	field := func(column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	timeField := func(column string) *time.Time {
+		t, err := parseTime(field(column), loc)
+		if err != nil {
+			return nil
+		}
+		return t
+	}
+
+	it := Item{
+		Row:           line,
+		Ref:           field("id"),
+		ParentRef:     field("parentId"),
+		BlockedByRefs: splitList(field("blockedBy")),
+		Title:         field("title"),
+		Description:   field("description"),
+		Project:       field("project"),
+		Labels:        splitList(field("labels")),
+		CompletedAt:   timeField("completedAt"),
+		CreatedAt:     timeField("createdAt"),
+	}
+
+	if due := field("dueDate"); due != "" {
+		if t, err := parseTime(due, loc); err != nil {
+			it.errorf("dueDate: %v", err)
+		} else {
+			it.DueDate = t
+		}
+	}
+	if priority := strings.TrimPrefix(strings.ToLower(field("priority")), "p"); priority != "" {
+		if p, err := strconv.Atoi(priority); err != nil {
+			it.errorf("priority: %q is not a number", field("priority"))
+		} else {
+			it.Priority = p
+		}
+	}
+	if completed := field("completed"); completed != "" {
+		if done, ok := parseBool(completed); ok {
+			it.Completed = done
+		} else {
+			it.errorf("completed: %q is not true or false", completed)
+		}
+	}
+	if recurrence := field("recurrence"); recurrence != "" {
+		var r models.Recurrence
+		if err := json.Unmarshal([]byte(recurrence), &r); err != nil {
+			it.errorf("recurrence: not a JSON object")
+		} else {
+			it.Recurrence = &r
+		}
+	}
+	it.Archived = field("archivedAt") != ""
+	return it
+}
+
+// parseBool reads the usual spellings of yes and no.
+func parseBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "y", "1", "x", "done", "completed":
+		return true, true
+	case "false", "no", "n", "0", "open", "todo":
+		return false, true
+	}
+	return false, false
+}
+
+// splitList splits a comma-separated list, dropping blank entries.
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
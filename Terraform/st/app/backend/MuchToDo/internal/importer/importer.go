@@ -0,0 +1,176 @@
+// Package importer reads todos exported by MuchToDo or by other todo apps into a common
+// list of items, ready to be created for a user.
+//
+// Supported formats:
+//
+//	csv      a header row followed by one todo per row; the columns of our CSV export
+//	         are recognised, along with common aliases such as "content" or "tags"
+//	json     our JSON export document
+//	ndjson   our newline-delimited JSON export
+//	todoist  a Todoist JSON export with "projects" and "items"
+//	trello   a Trello board export with "lists", "cards" and "checklists"
+//	markdown a GitHub task list of "- [ ]" and "- [x]" items, such as our Markdown export
+//
+// Problems with individual todos don't stop parsing: they are recorded on the item, so
+// that a caller can report every problem at once. Only a malformed document fails as a
+// whole.
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/dates"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// Formats understood by Parse.
+const (
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatNDJSON   = "ndjson"
+	FormatTodoist  = "todoist"
+	FormatTrello   = "trello"
+	FormatMarkdown = "markdown"
+)
+
+// ErrUnknownFormat is returned when the format of a document can't be detected.
+var ErrUnknownFormat = errors.New("unrecognised file format")
+
+// Item is a todo read from an import file.
+type Item struct {
+	Row           int      // Position in the file: the CSV line, or the item's 1-based index
+	Ref           string   // The item's ID in the file, if it has one
+	ParentRef     string   // Ref of the item this one is a subtask of
+	BlockedByRefs []string // Refs of the items blocking this one
+	Title         string
+	Description   string
+	Project       string
+	Labels        []string
+	DueDate       *time.Time
+	Priority      int // 1 (highest) to 4, 0 for none
+	Recurrence    *models.Recurrence
+	Completed     bool
+	CompletedAt   *time.Time
+	Archived      bool
+	CreatedAt     *time.Time
+	Errors        []string // Problems with the item; an item with errors can't be imported
+}
+
+// errorf records a problem with the item.
+func (it *Item) errorf(format string, args ...interface{}) {
+	it.Errors = append(it.Errors, fmt.Sprintf(format, args...))
+}
+
+// Parse reads the items in data, which is in one of the Format* formats or, if format
+// is empty, in whichever of them it looks like. Dates without a time zone are read in loc.
+func Parse(format string, data []byte, loc *time.Location) ([]Item, error) {
+	if format == "" {
+		format = Detect(data)
+	}
+
+	var items []Item
+	var err error
+	switch format {
+	case FormatCSV:
+		items, err = parseCSV(data, loc)
+	case FormatJSON:
+		items, err = parseExport(data)
+	case FormatNDJSON:
+		items, err = parseNDJSON(data)
+	case FormatTodoist:
+		items, err = parseTodoist(data, loc)
+	case FormatTrello:
+		items, err = parseTrello(data, loc)
+	case FormatMarkdown:
+		items, err = parseMarkdown(data, loc)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		validate(&items[i])
+	}
+	return items, nil
+}
+
+// Detect guesses the format of data: JSON documents are told apart by their top-level
+// keys, several JSON objects in a row are NDJSON, task lists are Markdown and anything
+// else is taken to be CSV.
+func Detect(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("{")) {
+		if isMarkdown(trimmed) {
+			return FormatMarkdown
+		}
+		return FormatCSV
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	var keys map[string]json.RawMessage
+	if err := dec.Decode(&keys); err != nil {
+		return ""
+	}
+	if dec.More() {
+		return FormatNDJSON
+	}
+	switch {
+	case keys["todos"] != nil:
+		return FormatJSON
+	case keys["items"] != nil:
+		return FormatTodoist
+	case keys["cards"] != nil:
+		return FormatTrello
+	case keys["title"] != nil:
+		return FormatNDJSON // A single exported todo
+	}
+	return ""
+}
+
+// validate checks the fields every format shares and tidies them up.
+func validate(it *Item) {
+	it.Title = strings.TrimSpace(it.Title)
+	it.Project = strings.TrimSpace(it.Project)
+	if it.Title == "" {
+		it.errorf("title is required")
+	}
+	if it.Priority < 0 || it.Priority > 4 {
+		it.errorf("priority must be between 0 and 4")
+	}
+	if r := it.Recurrence; r != nil {
+		switch r.Frequency {
+		case "", models.RecurrenceDaily, models.RecurrenceWeekly, models.RecurrenceMonthly, models.RecurrenceYearly:
+		default:
+			it.errorf("unknown recurrence frequency %q", r.Frequency)
+		}
+		if r.Interval < 0 || r.Interval > 365 {
+			it.errorf("recurrence interval must be between 0 and 365")
+		}
+	}
+}
+
+// floatingLayout is a local date and time without a zone, as used by Todoist.
+const floatingLayout = "2006-01-02T15:04:05"
+
+// parseTime reads a date or timestamp in any of the forms found in exports. Empty values
+// give nil.
+func parseTime(value string, loc *time.Location) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := dates.ParseDate(value, loc); err == nil {
+		return &t, nil
+	}
+	if t, err := time.ParseInLocation(floatingLayout, value, loc); err == nil {
+		return &t, nil
+	}
+	return nil, fmt.Errorf("invalid date %q", value)
+}
@@ -0,0 +1,170 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParse provides unit tests for reading each supported import format.
+func TestParse(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+
+	t.Run("Detect", func(t *testing.T) {
+		assert.Equal(t, FormatCSV, Detect([]byte("title,project\nBuy milk,home\n")))
+		assert.Equal(t, FormatJSON, Detect([]byte(`{"version":1,"todos":[]}`)))
+		assert.Equal(t, FormatNDJSON, Detect([]byte("{\"title\":\"a\"}\n{\"title\":\"b\"}\n")))
+		assert.Equal(t, FormatNDJSON, Detect([]byte(`{"title":"a"}`)))
+		assert.Equal(t, FormatTodoist, Detect([]byte(`{"projects":[],"items":[]}`)))
+		assert.Equal(t, FormatTrello, Detect([]byte(`{"name":"Board","cards":[]}`)))
+		assert.Equal(t, "", Detect([]byte(`{"something":"else"}`)))
+		assert.Equal(t, FormatMarkdown, Detect([]byte("\n- [ ] Buy milk\n")))
+		assert.Equal(t, FormatMarkdown, Detect([]byte("## Home\n\n* [x] Buy milk\n")))
+		assert.Equal(t, FormatCSV, Detect([]byte("title\n- [ ] Buy milk\n")))
+
+		_, err := Parse("", []byte(`{"something":"else"}`), loc)
+		assert.ErrorIs(t, err, ErrUnknownFormat)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		data := "\ufeffTask,Notes,List,Tags,Due Date,Priority,Done,Extra\r\n" +
+			"Buy milk,\"2 litres, semi-skimmed\",home,\"errand, quick\",2025-03-14,p2,yes,ignored\r\n" +
+			",no title,,,,,,\r\n" +
+			"Bad row,,,,next week,urgent,maybe,\r\n"
+		items, err := Parse(FormatCSV, []byte(data), loc)
+		assert.NoError(t, err)
+		assert.Len(t, items, 3)
+
+		first := items[0]
+		assert.Equal(t, 2, first.Row)
+		assert.Equal(t, "Buy milk", first.Title)
+		assert.Equal(t, "2 litres, semi-skimmed", first.Description)
+		assert.Equal(t, "home", first.Project)
+		assert.Equal(t, []string{"errand", "quick"}, first.Labels)
+		assert.Equal(t, time.Date(2025, 3, 14, 0, 0, 0, 0, loc), *first.DueDate)
+		assert.Equal(t, 2, first.Priority)
+		assert.True(t, first.Completed)
+		assert.Empty(t, first.Errors)
+
+		assert.Equal(t, []string{"title is required"}, items[1].Errors)
+		assert.Len(t, items[2].Errors, 3) // Due date, priority and completed
+
+		_, err = Parse(FormatCSV, []byte("what,ever\n1,2\n"), loc)
+		assert.Error(t, err)
+	})
+
+	t.Run("OwnExport", func(t *testing.T) {
+		data := `{"version":1,"exportedAt":"2025-03-01T00:00:00Z","todos":[
+			{"id":"65f000000000000000000001","title":"Parent","project":"work","createdAt":"2025-02-01T10:00:00Z"},
+			{"id":"65f000000000000000000002","title":"Child","parentId":"65f000000000000000000001",
+			 "blockedBy":["65f000000000000000000001"],"completed":true,"archivedAt":"2025-02-10T00:00:00Z",
+			 "recurrence":{"frequency":"fortnightly","interval":1}}
+		]}`
+		items, err := Parse("", []byte(data), loc)
+		assert.NoError(t, err)
+		assert.Len(t, items, 2)
+		assert.Equal(t, "65f000000000000000000001", items[0].Ref)
+		assert.Equal(t, time.Date(2025, 2, 1, 10, 0, 0, 0, time.UTC), *items[0].CreatedAt)
+		assert.Equal(t, items[0].Ref, items[1].ParentRef)
+		assert.Equal(t, []string{items[0].Ref}, items[1].BlockedByRefs)
+		assert.True(t, items[1].Completed)
+		assert.True(t, items[1].Archived)
+		assert.Equal(t, []string{`unknown recurrence frequency "fortnightly"`}, items[1].Errors)
+
+		_, err = Parse(FormatJSON, []byte(`{"version":99,"todos":[]}`), loc)
+		assert.Error(t, err)
+	})
+
+	t.Run("Todoist", func(t *testing.T) {
+		data := `{
+			"projects":[{"id":"220474322","name":"Inbox"},{"id":2203306141,"name":"Work"}],
+			"items":[
+				{"id":"2995104339","content":"Call Amy","project_id":"220474322","priority":4,
+				 "labels":["phone"],"due":{"date":"2025-03-14T10:00:00"}},
+				{"id":"2995104340","content":"Prepare slides","project_id":2203306141,"parent_id":"2995104339",
+				 "priority":1,"checked":true,"completed_at":"2025-03-02T09:00:00Z"}
+			]}`
+		items, err := Parse("", []byte(data), loc)
+		assert.NoError(t, err)
+		assert.Len(t, items, 2)
+		assert.Equal(t, "Inbox", items[0].Project)
+		assert.Equal(t, 1, items[0].Priority)
+		assert.Equal(t, time.Date(2025, 3, 14, 10, 0, 0, 0, loc), *items[0].DueDate)
+		assert.Equal(t, "Work", items[1].Project)
+		assert.Equal(t, "2995104339", items[1].ParentRef)
+		assert.Equal(t, 0, items[1].Priority)
+		assert.True(t, items[1].Completed)
+		assert.NotNil(t, items[1].CompletedAt)
+	})
+
+	t.Run("Trello", func(t *testing.T) {
+		data := `{
+			"name":"Renovation",
+			"lists":[{"id":"l1","name":"Doing"},{"id":"l2","name":"Old","closed":true}],
+			"labels":[{"id":"b1","name":"","color":"red"},{"id":"b2","name":"Kitchen"}],
+			"cards":[
+				{"id":"c1","name":"Order tiles","desc":"Grey","idList":"l1","idLabels":["b1","b2"],
+				 "due":"2025-03-14T12:00:00.000Z","dueComplete":false},
+				{"id":"c2","name":"Old plan","idList":"l2","closed":false}
+			],
+			"checklists":[{"idCard":"c1","checkItems":[{"id":"i1","name":"Measure","state":"complete"}]}]
+		}`
+		items, err := Parse("", []byte(data), loc)
+		assert.NoError(t, err)
+		assert.Len(t, items, 3)
+		assert.Equal(t, "Renovation", items[0].Project)
+		assert.Equal(t, []string{"Doing", "red", "Kitchen"}, items[0].Labels)
+		assert.Equal(t, time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC), items[0].DueDate.UTC())
+		assert.False(t, items[0].Archived)
+		assert.True(t, items[1].Archived) // Its list is archived
+
+		subtask := items[2]
+		assert.Equal(t, "Measure", subtask.Title)
+		assert.Equal(t, "c1", subtask.ParentRef)
+		assert.True(t, subtask.Completed)
+	})
+
+	t.Run("Markdown", func(t *testing.T) {
+		data := "# Sprint 12\n" +
+			"\n" +
+			"Some notes about the sprint.\n" +
+			"\n" +
+			"- [ ] Fix login #123 due:2025-03-14 p1 #bug #backend\n" +
+			"  Users get logged out.\n" +
+			"\n" +
+			"  Happens on Safari.\n" +
+			"  - [X] Reproduce\n" +
+			"\t- [ ] Write test @qa\n" +
+			"      - [ ] Ask Sam due:2025-03-13T09:30\n" +
+			"* [ ] #later\n" +
+			"- [ ] Bad date due:someday\n" +
+			"- plain bullet\n"
+		items, err := Parse(FormatMarkdown, []byte(data), loc)
+		assert.NoError(t, err)
+		assert.Len(t, items, 6)
+
+		first := items[0]
+		assert.Equal(t, 5, first.Row)
+		assert.Equal(t, "Fix login #123", first.Title)
+		assert.Equal(t, "Users get logged out.\n\nHappens on Safari.", first.Description)
+		assert.Equal(t, "Sprint 12", first.Project)
+		assert.Equal(t, []string{"bug", "backend"}, first.Labels)
+		assert.Equal(t, time.Date(2025, 3, 14, 0, 0, 0, 0, loc), *first.DueDate)
+		assert.Equal(t, 1, first.Priority)
+		assert.False(t, first.Completed)
+
+		assert.Equal(t, first.Ref, items[1].ParentRef)
+		assert.True(t, items[1].Completed)
+		assert.Equal(t, items[1].Ref, items[2].ParentRef) // A tab is deeper than two spaces
+		assert.Equal(t, "qa", items[2].Project)
+		assert.Equal(t, items[2].Ref, items[3].ParentRef)
+		assert.Equal(t, "qa", items[3].Project)
+		assert.Equal(t, time.Date(2025, 3, 13, 9, 30, 0, 0, loc), *items[3].DueDate)
+
+		assert.Equal(t, "#later", items[4].Title)
+		assert.Empty(t, items[4].Labels)
+		assert.Equal(t, "", items[4].ParentRef)
+		assert.Len(t, items[5].Errors, 1)
+	})
+}
@@ -0,0 +1,75 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// parseExport reads our JSON export document.
+func parseExport(data []byte) ([]Item, error) {
+	var doc models.TodoExport
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON export: %w", err)
+	}
+	if doc.Version > models.ExportVersion {
+		return nil, fmt.Errorf("export version %d is newer than this server supports (%d)", doc.Version, models.ExportVersion)
+	}
+
+	items := make([]Item, len(doc.Todos))
+	for i, todo := range doc.Todos {
+		items[i] = exportedItem(i+1, todo)
+	}
+	return items, nil
+}
+
+// parseNDJSON reads our newline-delimited JSON export, one todo per line.
+func parseNDJSON(data []byte) ([]Item, error) {
+	var items []Item
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var todo models.Todo
+		err := dec.Decode(&todo)
+		if err == io.EOF {
+			return items, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid NDJSON on item %d: %w", len(items)+1, err)
+		}
+		items = append(items, exportedItem(len(items)+1, todo))
+	}
+}
+
+// exportedItem converts a todo from one of our exports.
+func exportedItem(row int, todo models.Todo) Item {
+	it := Item{
+		Row:         row,
+		Title:       todo.Title,
+		Description: todo.Description,
+		Project:     todo.Project,
+		Labels:      todo.Labels,
+		DueDate:     todo.DueDate,
+		Priority:    todo.Priority,
+		Recurrence:  todo.Recurrence,
+		Completed:   todo.Completed,
+		CompletedAt: todo.CompletedAt,
+		Archived:    todo.ArchivedAt != nil,
+	}
+	if !todo.ID.IsZero() {
+		it.Ref = todo.ID.Hex()
+	}
+	if todo.ParentID != nil {
+		it.ParentRef = todo.ParentID.Hex()
+	}
+	for _, id := range todo.BlockedBy {
+		it.BlockedByRefs = append(it.BlockedByRefs, id.Hex())
+	}
+	if !todo.CreatedAt.IsZero() {
+		created := todo.CreatedAt
+		it.CreatedAt = &created
+	}
+	return it
+}
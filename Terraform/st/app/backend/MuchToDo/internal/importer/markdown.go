@@ -0,0 +1,171 @@
+package importer
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// markdownTask matches a GitHub task-list item: its indentation, check box and text.
+var markdownTask = regexp.MustCompile(`^([ \t]*)[-*+][ \t]+\[([ xX])\](?:[ \t]+(.*))?$`)
+
+// markdownHeading matches an ATX heading and its text.
+var markdownHeading = regexp.MustCompile(`^#{1,6}[ \t]+(.*?)[ \t#]*$`)
+
+// markdownPriority matches a priority token, p1 (highest) to p4, as used by quick add.
+var markdownPriority = regexp.MustCompile(`^[pP]([1-4])$`)
+
+// markdownTimeLayout is a due date with a time of day, as written by the Markdown export.
+const markdownTimeLayout = "2006-01-02T15:04"
+
+// parseMarkdown reads a GitHub task list, such as our Markdown export. Each "- [ ]" or
+// "- [x]" item is a todo, and items indented under another are its subtasks. Headings
+// set the project of the items below them. Trailing tokens on an item's line set its
+// fields: "due:2025-03-14" (or "due:2025-03-14T17:30"), a priority from "p1" to "p4",
+// "#label" and "@project". Other lines indented under an item form its description,
+// and everything else is ignored.
+func parseMarkdown(data []byte, loc *time.Location) ([]Item, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	type open struct {
+		indent int
+		index  int
+	}
+	var items []Item
+	var stack []open // The items the next one could be a subtask of, outermost first
+	var description []string
+	project := ""
+
+	// flush ends the description of the last item.
+	flush := func() {
+		if len(items) > 0 && len(description) > 0 {
+			it := &items[len(items)-1]
+			it.Description = strings.TrimSpace(strings.Join(description, "\n"))
+		}
+		description = nil
+	}
+
+	for i, line := range strings.Split(string(data), "\n") {
+		row := i + 1
+		line = strings.TrimRight(line, " \t\r")
+
+		if m := markdownTask.FindStringSubmatch(line); m != nil {
+			flush()
+			indent := markdownIndent(m[1])
+			for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+				stack = stack[:len(stack)-1]
+			}
+
+			it := Item{Row: row, Ref: strconv.Itoa(row), Project: project, Completed: m[2] != " "}
+			if len(stack) > 0 {
+				parent := items[stack[len(stack)-1].index]
+				it.ParentRef = parent.Ref
+				it.Project = parent.Project
+			}
+			parseMarkdownText(&it, m[3], loc)
+			items = append(items, it)
+			stack = append(stack, open{indent: indent, index: len(items) - 1})
+			continue
+		}
+
+		if m := markdownHeading.FindStringSubmatch(line); m != nil {
+			flush()
+			stack = nil
+			project = strings.TrimSpace(m[1])
+			continue
+		}
+
+		// A line indented under the last item continues its description; blank lines
+		// are kept in case more of it follows.
+		if len(stack) > 0 {
+			last := stack[len(stack)-1]
+			if line == "" {
+				description = append(description, "")
+				continue
+			}
+			prefix := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+			if markdownIndent(prefix) > last.indent {
+				description = append(description, strings.TrimSpace(line))
+				continue
+			}
+		}
+		flush()
+		stack = nil
+	}
+	flush()
+	return items, nil
+}
+
+// parseMarkdownText reads an item's title and the field tokens that end it. A line of
+// nothing but tokens is all title, so that it isn't lost.
+func parseMarkdownText(it *Item, text string, loc *time.Location) {
+	words := strings.Fields(text)
+	end := len(words)
+	for end > 1 && isMarkdownToken(words[end-1]) {
+		end--
+	}
+	it.Title = strings.Join(words[:end], " ")
+
+	for _, word := range words[end:] {
+		switch {
+		case markdownPriority.MatchString(word):
+			it.Priority = int(word[1] - '0')
+		case strings.HasPrefix(word, "due:"):
+			value := strings.TrimPrefix(word, "due:")
+			if t, err := time.ParseInLocation(markdownTimeLayout, value, loc); err == nil {
+				it.DueDate = &t
+			} else if t, err := parseTime(value, loc); err == nil {
+				it.DueDate = t
+			} else {
+				it.errorf("%v", err)
+			}
+		case word[0] == '#':
+			it.Labels = append(it.Labels, word[1:])
+		case word[0] == '@':
+			it.Project = word[1:]
+		}
+	}
+}
+
+// isMarkdownToken reports whether word sets a field rather than being part of the title.
+// Tags starting with a digit are left in the title, so that references such as "#123"
+// survive.
+func isMarkdownToken(word string) bool {
+	switch {
+	case markdownPriority.MatchString(word):
+		return true
+	case strings.HasPrefix(word, "due:"):
+		return len(word) > len("due:")
+	case word[0] == '#' || word[0] == '@':
+		return len(word) > 1 && (word[1] < '0' || word[1] > '9')
+	}
+	return false
+}
+
+// markdownIndent measures indentation in columns, counting a tab as four.
+func markdownIndent(prefix string) int {
+	n := 0
+	for _, r := range prefix {
+		if r == '\t' {
+			n += 4
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// isMarkdown reports whether data looks like a Markdown task list: its first non-blank
+// line is a task-list item or a heading.
+func isMarkdown(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		return markdownTask.MatchString(line) || markdownHeading.MatchString(line)
+	}
+	return false
+}
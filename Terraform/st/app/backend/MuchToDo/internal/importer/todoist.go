@@ -0,0 +1,89 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// todoistID is a Todoist ID, which older exports give as a number and newer ones as a string.
+type todoistID string
+
+func (id *todoistID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = todoistID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("invalid ID %s", data)
+	}
+	*id = todoistID(n)
+	return nil
+}
+
+// todoistExport is the part of a Todoist JSON export that we read.
+type todoistExport struct {
+	Projects []struct {
+		ID   todoistID `json:"id"`
+		Name string    `json:"name"`
+	} `json:"projects"`
+	Items []struct {
+		ID          todoistID `json:"id"`
+		Content     string    `json:"content"`
+		Description string    `json:"description"`
+		ProjectID   todoistID `json:"project_id"`
+		ParentID    todoistID `json:"parent_id"`
+		Labels      []string  `json:"labels"`
+		Priority    int       `json:"priority"` // 4 is the most urgent, 1 is none
+		Checked     bool      `json:"checked"`
+		CompletedAt string    `json:"completed_at"`
+		AddedAt     string    `json:"added_at"`
+		Due         *struct {
+			Date string `json:"date"`
+		} `json:"due"`
+	} `json:"items"`
+}
+
+// parseTodoist reads a Todoist JSON export. Projects are matched by name, and
+// Todoist's priorities are turned around: its 4 is our 1.
+func parseTodoist(data []byte, loc *time.Location) ([]Item, error) {
+	var doc todoistExport
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid Todoist export: %w", err)
+	}
+
+	projects := make(map[todoistID]string, len(doc.Projects))
+	for _, project := range doc.Projects {
+		projects[project.ID] = project.Name
+	}
+
+	items := make([]Item, len(doc.Items))
+	for i, source := range doc.Items {
+		it := Item{
+			Row:         i + 1,
+			Ref:         string(source.ID),
+			ParentRef:   string(source.ParentID),
+			Title:       source.Content,
+			Description: source.Description,
+			Project:     projects[source.ProjectID],
+			Labels:      source.Labels,
+			Completed:   source.Checked,
+		}
+		if source.Priority > 1 && source.Priority <= 4 {
+			it.Priority = 5 - source.Priority
+		}
+		if source.Due != nil {
+			if due, err := parseTime(source.Due.Date, loc); err != nil {
+				it.errorf("due: %v", err)
+			} else {
+				it.DueDate = due
+			}
+		}
+		it.CompletedAt, _ = parseTime(source.CompletedAt, loc)
+		it.CreatedAt, _ = parseTime(source.AddedAt, loc)
+		items[i] = it
+	}
+	return items, nil
+}
@@ -0,0 +1,109 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// trelloExport is the part of a Trello board export that we read.
+type trelloExport struct {
+	Name  string `json:"name"`
+	Lists []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Closed bool   `json:"closed"`
+	} `json:"lists"`
+	Labels []struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Color string `json:"color"`
+	} `json:"labels"`
+	Cards []struct {
+		ID          string   `json:"id"`
+		Name        string   `json:"name"`
+		Desc        string   `json:"desc"`
+		IDList      string   `json:"idList"`
+		IDLabels    []string `json:"idLabels"`
+		Due         string   `json:"due"`
+		DueComplete bool     `json:"dueComplete"`
+		Closed      bool     `json:"closed"`
+	} `json:"cards"`
+	Checklists []struct {
+		IDCard     string `json:"idCard"`
+		CheckItems []struct {
+			ID    string `json:"id"`
+			Name  string `json:"name"`
+			State string `json:"state"` // "complete" or "incomplete"
+		} `json:"checkItems"`
+	} `json:"checklists"`
+}
+
+// parseTrello reads a Trello board export. The board becomes the project, each card's
+// list becomes a label alongside its own labels, and checklist items become subtasks.
+// Cards that are archived, or sit in an archived list, are imported as archived.
+func parseTrello(data []byte, loc *time.Location) ([]Item, error) {
+	var doc trelloExport
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid Trello export: %w", err)
+	}
+
+	lists := make(map[string]string, len(doc.Lists))
+	closedLists := make(map[string]bool)
+	for _, list := range doc.Lists {
+		lists[list.ID] = list.Name
+		closedLists[list.ID] = list.Closed
+	}
+	labels := make(map[string]string, len(doc.Labels))
+	for _, label := range doc.Labels {
+		name := label.Name
+		if name == "" {
+			name = label.Color // Trello labels may be just a colour
+		}
+		labels[label.ID] = name
+	}
+
+	var items []Item
+	archived := make(map[string]bool)
+	for _, card := range doc.Cards {
+		it := Item{
+			Row:         len(items) + 1,
+			Ref:         card.ID,
+			Title:       card.Name,
+			Description: card.Desc,
+			Project:     doc.Name,
+			Completed:   card.DueComplete,
+			Archived:    card.Closed || closedLists[card.IDList],
+		}
+		if list := lists[card.IDList]; list != "" {
+			it.Labels = append(it.Labels, list)
+		}
+		for _, id := range card.IDLabels {
+			if label := labels[id]; label != "" {
+				it.Labels = append(it.Labels, label)
+			}
+		}
+		if due, err := parseTime(card.Due, loc); err != nil {
+			it.errorf("due: %v", err)
+		} else {
+			it.DueDate = due
+		}
+		archived[card.ID] = it.Archived
+		items = append(items, it)
+	}
+
+	for _, checklist := range doc.Checklists {
+		for _, check := range checklist.CheckItems {
+			items = append(items, Item{
+				Row:       len(items) + 1,
+				Ref:       check.ID,
+				ParentRef: checklist.IDCard,
+				Title:     check.Name,
+				Project:   doc.Name,
+				Completed: check.State == "complete",
+				Archived:  archived[checklist.IDCard],
+			})
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Task is a unit of background work. It is called once per tick.
+type Task func(ctx context.Context) error
+
+// Schedule runs task every interval in its own goroutine until ctx is cancelled.
+// The first run happens immediately. Errors are logged and do not stop the schedule.
+func Schedule(ctx context.Context, name string, interval time.Duration, task Task) {
+	go func() {
+		slog.Info("Background job scheduled", "job", name, "interval", interval.String())
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			start := time.Now()
+			if err := task(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("Background job failed", "job", name, slog.Any("error", err))
+			} else {
+				slog.Debug("Background job finished", "job", name, "duration", time.Since(start).String())
+			}
+
+			select {
+			case <-ctx.Done():
+				slog.Info("Background job stopped", "job", name)
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,260 @@
+// Package markdown renders the small subset of Markdown used in comments to HTML.
+//
+// Rendering is escape-first: every character of the input is HTML-escaped as it is
+// written out, and markup is only produced for recognised syntax. Raw HTML in the
+// input is therefore always shown as text, and links are limited to a few safe schemes.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+// allowedSchemes lists the URL schemes links may use. Anything else, including
+// relative URLs, is rendered as plain text.
+var allowedSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+
+// Render converts Markdown to sanitised HTML. Supported syntax: paragraphs, line breaks,
+// headings, bullet and numbered lists, block quotes, fenced code blocks, inline code,
+// bold, italics and links.
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	lines := strings.Split(src, "\n")
+
+	var b strings.Builder
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case strings.HasPrefix(trimmed, "```"):
+			i++
+			var code []string
+			for i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```") {
+				code = append(code, lines[i])
+				i++
+			}
+			i++ // Closing fence; an unclosed block runs to the end of the input
+			b.WriteString("<pre><code>")
+			b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+			b.WriteString("</code></pre>\n")
+
+		case headingLevel(trimmed) > 0:
+			level := headingLevel(trimmed)
+			tag := "h" + string(rune('0'+level))
+			b.WriteString("<" + tag + ">")
+			renderInline(&b, strings.TrimSpace(trimmed[level:]))
+			b.WriteString("</" + tag + ">\n")
+			i++
+
+		case listItem(trimmed, false) != "" || listItem(trimmed, true) != "":
+			ordered := listItem(trimmed, false) == ""
+			tag := "ul"
+			if ordered {
+				tag = "ol"
+			}
+			b.WriteString("<" + tag + ">\n")
+			for i < len(lines) {
+				item := listItem(strings.TrimSpace(lines[i]), ordered)
+				if item == "" {
+					break
+				}
+				b.WriteString("<li>")
+				renderInline(&b, item)
+				b.WriteString("</li>\n")
+				i++
+			}
+			b.WriteString("</" + tag + ">\n")
+
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">") {
+				quoted = append(quoted, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")))
+				i++
+			}
+			b.WriteString("<blockquote>")
+			renderLines(&b, quoted)
+			b.WriteString("</blockquote>\n")
+
+		default:
+			var para []string
+			for i < len(lines) && startsParagraphLine(lines[i]) {
+				para = append(para, strings.TrimSpace(lines[i]))
+				i++
+			}
+			b.WriteString("<p>")
+			renderLines(&b, para)
+			b.WriteString("</p>\n")
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// startsParagraphLine reports whether a line continues a paragraph rather than ending
+// it or starting another kind of block.
+func startsParagraphLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed != "" &&
+		!strings.HasPrefix(trimmed, "```") &&
+		!strings.HasPrefix(trimmed, ">") &&
+		headingLevel(trimmed) == 0 &&
+		listItem(trimmed, false) == "" &&
+		listItem(trimmed, true) == ""
+}
+
+// renderLines renders consecutive lines of one block, separated by line breaks.
+func renderLines(b *strings.Builder, lines []string) {
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("<br>\n")
+		}
+		renderInline(b, line)
+	}
+}
+
+// headingLevel returns the level of an ATX heading ("# Title"), or 0 if line isn't one.
+func headingLevel(line string) int {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level >= len(line) || line[level] != ' ' {
+		return 0
+	}
+	return level
+}
+
+// listItem returns the text of a bullet ("- item") or numbered ("1. item") list item,
+// or "" if line isn't an item of that kind.
+func listItem(line string, ordered bool) string {
+	if !ordered {
+		if len(line) > 2 && (line[0] == '-' || line[0] == '*' || line[0] == '+') && line[1] == ' ' {
+			return strings.TrimSpace(line[2:])
+		}
+		return ""
+	}
+
+	digits := 0
+	for digits < len(line) && line[digits] >= '0' && line[digits] <= '9' {
+		digits++
+	}
+	if digits == 0 || digits > 9 || len(line) < digits+3 || line[digits] != '.' || line[digits+1] != ' ' {
+		return ""
+	}
+	return strings.TrimSpace(line[digits+2:])
+}
+
+// renderInline renders the inline syntax of a single line.
+func renderInline(b *strings.Builder, s string) {
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_[]()#>-+.!", s[i+1]) >= 0:
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+				b.WriteString("<code>")
+				b.WriteString(html.EscapeString(s[i+1 : i+1+end]))
+				b.WriteString("</code>")
+				i += end + 2
+				continue
+			}
+
+		case (c == '*' || c == '_') && strings.HasPrefix(s[i:], string([]byte{c, c})):
+			marker := string([]byte{c, c})
+			if end := strings.Index(s[i+2:], marker); end > 0 {
+				b.WriteString("<strong>")
+				renderInline(b, s[i+2:i+2+end])
+				b.WriteString("</strong>")
+				i += end + 4
+				continue
+			}
+
+		case c == '*' || (c == '_' && (i == 0 || !isWordByte(s[i-1]))):
+			// An underscore only opens emphasis at the start of a word, so that
+			// identifiers like snake_case_names are left alone.
+			if end := emphasisEnd(s[i+1:], c); end > 0 {
+				b.WriteString("<em>")
+				renderInline(b, s[i+1:i+1+end])
+				b.WriteString("</em>")
+				i += end + 2
+				continue
+			}
+
+		case c == '[':
+			if text, href, n, ok := parseLink(s[i:]); ok {
+				b.WriteString(`<a href="`)
+				b.WriteString(html.EscapeString(href))
+				b.WriteString(`" rel="nofollow noopener noreferrer">`)
+				renderInline(b, text)
+				b.WriteString("</a>")
+				i += n
+				continue
+			}
+		}
+
+		b.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+}
+
+// emphasisEnd finds the marker closing an emphasis span in s, returning its index or -1.
+// The span must not start with a space, and a closing underscore must end a word.
+func emphasisEnd(s string, marker byte) int {
+	if s == "" || s[0] == ' ' {
+		return -1
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] != marker || s[i-1] == ' ' {
+			continue
+		}
+		if marker == '_' && i+1 < len(s) && isWordByte(s[i+1]) {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// parseLink parses "[text](url)" at the start of s. It returns the link text, the URL,
+// the number of bytes consumed and whether s starts with a link to an allowed scheme.
+func parseLink(s string) (text, href string, n int, ok bool) {
+	closeText := strings.Index(s, "](")
+	if closeText < 1 {
+		return "", "", 0, false
+	}
+	closeURL := strings.IndexByte(s[closeText+2:], ')')
+	if closeURL < 0 {
+		return "", "", 0, false
+	}
+
+	text = s[1:closeText]
+	href = strings.TrimSpace(s[closeText+2 : closeText+2+closeURL])
+	if strings.ContainsAny(text, "[]") || !safeURL(href) {
+		return "", "", 0, false
+	}
+	return text, href, closeText + 3 + closeURL, true
+}
+
+// safeURL reports whether href is an absolute URL with an allowed scheme.
+func safeURL(href string) bool {
+	if strings.ContainsAny(href, " \t\n\"'<>") {
+		return false
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+	return allowedSchemes[strings.ToLower(u.Scheme)]
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
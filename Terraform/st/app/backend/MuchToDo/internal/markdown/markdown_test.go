@@ -0,0 +1,53 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRenderInline provides unit tests for inline markup.
+func TestRenderInline(t *testing.T) {
+	cases := map[string]string{
+		"plain text":                                "<p>plain text</p>",
+		"**bold** and *italic*":                     "<p><strong>bold</strong> and <em>italic</em></p>",
+		"_also italic_ but snake_case_id":           "<p><em>also italic</em> but snake_case_id</p>",
+		"run `go test ./...`":                       "<p>run <code>go test ./...</code></p>",
+		"`<b>` stays code":                          "<p><code>&lt;b&gt;</code> stays code</p>",
+		`\*not italic\*`:                            "<p>*not italic*</p>",
+		"2 * 3 * 4":                                 "<p>2 * 3 * 4</p>",
+		"see [docs](https://example.com/a?b=1&c=2)": `<p>see <a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer">docs</a></p>`,
+		"line one\nline two":                        "<p>line one<br>\nline two</p>",
+	}
+	for input, want := range cases {
+		assert.Equal(t, want, Render(input), input)
+	}
+}
+
+// TestRenderBlocks provides unit tests for block-level markup.
+func TestRenderBlocks(t *testing.T) {
+	input := "# Plan\n\n- one\n- **two**\n\n1. first\n2. second\n\n> quoted\n> text\n\n```\nif a < b {\n```\nafter"
+	want := "<h1>Plan</h1>\n" +
+		"<ul>\n<li>one</li>\n<li><strong>two</strong></li>\n</ul>\n" +
+		"<ol>\n<li>first</li>\n<li>second</li>\n</ol>\n" +
+		"<blockquote>quoted<br>\ntext</blockquote>\n" +
+		"<pre><code>if a &lt; b {</code></pre>\n" +
+		"<p>after</p>"
+	assert.Equal(t, want, Render(input))
+}
+
+// TestRenderSanitises checks that raw HTML and unsafe links never reach the output.
+func TestRenderSanitises(t *testing.T) {
+	cases := map[string]string{
+		"<script>alert(1)</script>":           "<p>&lt;script&gt;alert(1)&lt;/script&gt;</p>",
+		`<img src=x onerror="alert(1)">`:      "<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>",
+		"[click](javascript:alert(1))":        "<p>[click](javascript:alert(1))</p>",
+		"[click](JaVaScRiPt:alert(1))":        "<p>[click](JaVaScRiPt:alert(1))</p>",
+		"[rel](/admin)":                       "<p>[rel](/admin)</p>",
+		`[q](https://x.test/"onmouseover="a)`: `<p>[q](https://x.test/&#34;onmouseover=&#34;a)</p>`,
+		"**<i>nested</i>**":                   "<p><strong>&lt;i&gt;nested&lt;/i&gt;</strong></p>",
+	}
+	for input, want := range cases {
+		assert.Equal(t, want, Render(input), input)
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Attachment is a file uploaded to a todo. The file itself lives in the blob store.
+type Attachment struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TodoID      primitive.ObjectID `bson:"todoId" json:"todoId"`
+	UserID      primitive.ObjectID `bson:"userId" json:"userId"`
+	Filename    string             `bson:"filename" json:"filename"`
+	ContentType string             `bson:"contentType" json:"contentType"`
+	Size        int64              `bson:"size" json:"size"` // In bytes
+	StorageKey  string             `bson:"storageKey" json:"-"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
@@ -0,0 +1,45 @@
+package models
+
+// Actions supported by the bulk endpoint.
+const (
+	BulkActionComplete    = "complete"
+	BulkActionUncomplete  = "uncomplete"
+	BulkActionDelete      = "delete"
+	BulkActionMove        = "move"
+	BulkActionAddLabel    = "addLabel"
+	BulkActionRemoveLabel = "removeLabel"
+)
+
+// Per-item statuses reported by the bulk endpoint.
+const (
+	BulkStatusOK        = "ok"
+	BulkStatusUnchanged = "unchanged"
+	BulkStatusNotFound  = "not_found"
+	BulkStatusInvalidID = "invalid_id"
+	BulkStatusBlocked   = "blocked"
+)
+
+// BulkRequestDTO is the Data Transfer Object for running several operations at once.
+type BulkRequestDTO struct {
+	Operations []BulkOperationDTO `json:"operations" binding:"required,min=1,max=100,dive"`
+}
+
+// BulkOperationDTO applies one action either to an explicit list of todo IDs or to
+// every todo matching a filter. Exactly one of IDs and Filter must be set.
+type BulkOperationDTO struct {
+	Action  string         `json:"action" binding:"required,oneof=complete uncomplete delete move addLabel removeLabel"`
+	IDs     []string       `json:"ids" binding:"max=500"`
+	Filter  *TodoFilterDTO `json:"filter"`
+	Project string         `json:"project"` // Target project for "move"; empty removes the project
+	Label   string         `json:"label"`   // Label for "addLabel" and "removeLabel"
+}
+
+// BulkItemResult reports what happened to a single todo in a bulk request.
+type BulkItemResult struct {
+	Operation int    `json:"operation"` // Index into the request's operations
+	ID        string `json:"id"`
+	Status    string `json:"status"` // One of the BulkStatus* constants
+}
+
+// BulkUndoAction is the action recorded on undo operations issued by the bulk endpoint.
+const BulkUndoAction = "bulk"
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AppPassword is a password for a single app, such as a CalDAV client, that can't log
+// in with a token. Only its hash is stored; the password itself is shown once.
+type AppPassword struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"userId" json:"-"`
+	Name       string             `bson:"name" json:"name"`
+	Hash       string             `bson:"hash" json:"-"` // Hex-encoded SHA-256 of the normalized password
+	CreatedAt  time.Time          `bson:"createdAt" json:"createdAt"`
+	LastUsedAt *time.Time         `bson:"lastUsedAt,omitempty" json:"lastUsedAt,omitempty"` // Updated at most hourly
+}
+
+// NewAppPassword is an app password as returned right after it was created, the only
+// time the password can be seen.
+type NewAppPassword struct {
+	AppPassword
+	Password string `json:"password"`
+}
+
+// CreateAppPasswordDTO is the Data Transfer Object for creating an app password.
+type CreateAppPasswordDTO struct {
+	Name string `json:"name" binding:"required,max=100"` // e.g. "Phone" or "Thunderbird"
+}
+
+// TodoTombstone records that a todo was purged, so that CalDAV clients syncing after
+// the fact learn that it is gone. Tombstones expire after a while.
+type TodoTombstone struct {
+	TodoID    primitive.ObjectID `bson:"todoId"`
+	UserID    primitive.ObjectID `bson:"userId"`
+	DAVName   string             `bson:"davName,omitempty"`
+	ChangeSeq int64              `bson:"changeSeq"` // The user's change sequence number of the purge
+	PurgedAt  time.Time          `bson:"purgedAt"`
+}
+
+// TodoChanges numbers the changes to a user's todos, for CalDAV sync tokens. The number
+// is advanced in the transaction of every change, so that transactions changing the
+// same user's todos conflict on it and commit in the order of their numbers.
+type TodoChanges struct {
+	UserID    primitive.ObjectID `bson:"_id"`
+	Seq       int64              `bson:"seq"`
+	ChangedAt time.Time          `bson:"changedAt"` // When the latest change was made
+}
+
+// TombstoneRetention is how long tombstones are kept. CalDAV clients that last synced
+// longer ago than this have to sync from scratch.
+const TombstoneRetention = 90 * 24 * time.Hour
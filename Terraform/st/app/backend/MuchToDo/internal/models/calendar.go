@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Component types the calendar feed can render todos as.
+const (
+	CalendarTypeEvent = "event" // All-day or timed events, for calendar apps
+	CalendarTypeTodo  = "todo"  // Tasks, for apps that understand VTODO
+	CalendarTypeBoth  = "both"
+)
+
+// CalendarToken is the secret that authorizes a user's calendar feed. Only its hash is
+// stored, so a leaked database doesn't leak feed URLs.
+type CalendarToken struct {
+	Hash      string    `bson:"hash"` // Hex-encoded SHA-256 of the token
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+// CalendarFeed describes a user's calendar feed. The URL is only known right after the
+// token was generated.
+type CalendarFeed struct {
+	Enabled   bool       `json:"enabled"`
+	URL       string     `json:"url,omitempty"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Comment is a message in a todo's discussion. Replies point at the comment they answer,
+// forming threads. The body is stored as raw Markdown and rendered to sanitised HTML
+// whenever the comment is returned.
+type Comment struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`
+	TodoID    primitive.ObjectID  `bson:"todoId" json:"todoId"`
+	UserID    primitive.ObjectID  `bson:"userId" json:"userId"`     // Owner of the todo
+	AuthorID  primitive.ObjectID  `bson:"authorId" json:"authorId"` // Only the author may edit or delete
+	ParentID  *primitive.ObjectID `bson:"parentId,omitempty" json:"parentId,omitempty"`
+	Body      string              `bson:"body" json:"body"`
+	BodyHTML  string              `bson:"-" json:"bodyHtml"`
+	Mentions  []Mention           `bson:"mentions,omitempty" json:"mentions,omitempty"`
+	Replies   []*Comment          `bson:"-" json:"replies,omitempty"`
+	CreatedAt time.Time           `bson:"createdAt" json:"createdAt"`
+	EditedAt  *time.Time          `bson:"editedAt,omitempty" json:"editedAt,omitempty"`
+	DeletedAt *time.Time          `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"` // Set when a comment with replies is deleted
+}
+
+// Mention is a user referred to as @username in a comment.
+type Mention struct {
+	UserID   primitive.ObjectID `bson:"userId" json:"userId"`
+	Username string             `bson:"username" json:"username"`
+}
+
+// --- DTOs (Data Transfer Objects) for API input/output ---
+
+// CreateCommentDTO is the Data Transfer Object for posting a comment.
+type CreateCommentDTO struct {
+	Body     string `json:"body" binding:"required,max=10000"`
+	ParentID string `json:"parentId"` // Comment being replied to; empty starts a new thread
+}
+
+// UpdateCommentDTO is the Data Transfer Object for editing a comment.
+type UpdateCommentDTO struct {
+	Body string `json:"body" binding:"required,max=10000"`
+}
+
+// Kinds of entries in the activity feed.
+const (
+	ActivityTodoChange = "todo"    // A change to one of the user's todos
+	ActivityComment    = "comment" // A comment on one of the user's todos
+	ActivityMention    = "mention" // A comment elsewhere that mentions the user
+	ActivityDataExport = "export"  // A data export that finished, successfully or not
+)
+
+// ActivityItem is one entry in a user's activity feed.
+type ActivityItem struct {
+	Type      string              `bson:"type" json:"type"` // One of the Activity* constants
+	At        time.Time           `bson:"at" json:"at"`
+	TodoID    primitive.ObjectID  `bson:"todoId" json:"todoId,omitzero"` // Not set for data exports
+	TodoTitle string              `bson:"todoTitle" json:"todoTitle,omitempty"`
+	ActorID   primitive.ObjectID  `bson:"actorId" json:"actorId"`                         // NilObjectID for background jobs
+	Action    string              `bson:"action,omitempty" json:"action,omitempty"`       // For todo changes, one of the TodoAction* constants; for data exports, the DataExport* state
+	Version   int                 `bson:"version,omitempty" json:"version,omitempty"`     // For todo changes
+	Changes   []FieldChange       `bson:"changes,omitempty" json:"changes,omitempty"`     // For todo changes
+	CommentID *primitive.ObjectID `bson:"commentId,omitempty" json:"commentId,omitempty"` // For comments and mentions
+	ExportID  *primitive.ObjectID `bson:"exportId,omitempty" json:"exportId,omitempty"`   // For data exports
+	Body      string              `bson:"body,omitempty" json:"-"`
+	BodyHTML  string              `bson:"-" json:"bodyHtml,omitempty"` // For comments and mentions
+}
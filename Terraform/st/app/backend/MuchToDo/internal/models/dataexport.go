@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// States of a data export.
+const (
+	DataExportPending = "pending" // Waiting to be built
+	DataExportRunning = "running" // Being built
+	DataExportReady   = "ready"   // The archive can be downloaded
+	DataExportFailed  = "failed"
+	DataExportExpired = "expired" // The archive has been deleted
+)
+
+// DataExportVersion is the version of the data export archive's layout.
+const DataExportVersion = 1
+
+// DataExport is a user's request for a copy of all of their data. The archive is built
+// in the background and deleted once it expires, but the record is kept as evidence of
+// when the request was made and fulfilled.
+type DataExport struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         primitive.ObjectID `bson:"userId" json:"userId"`
+	Status         string             `bson:"status" json:"status"`      // One of the DataExport* states
+	Active         bool               `bson:"active,omitempty" json:"-"` // Set while pending or running; a user can have one active export
+	Attempts       int                `bson:"attempts" json:"-"`
+	RequestedAt    time.Time          `bson:"requestedAt" json:"requestedAt"`
+	StartedAt      *time.Time         `bson:"startedAt,omitempty" json:"-"`
+	CompletedAt    *time.Time         `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+	ExpiresAt      *time.Time         `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"` // When the archive is deleted
+	ExpiredAt      *time.Time         `bson:"expiredAt,omitempty" json:"expiredAt,omitempty"`
+	Size           int64              `bson:"size,omitempty" json:"size,omitempty"` // Of the archive, in bytes
+	StorageKey     string             `bson:"storageKey,omitempty" json:"-"`
+	Error          string             `bson:"error,omitempty" json:"error,omitempty"`
+	Downloads      int                `bson:"downloads" json:"downloads"` // Download links issued
+	LastDownloadAt *time.Time         `bson:"lastDownloadAt,omitempty" json:"lastDownloadAt,omitempty"`
+}
+
+// DataExportManifest describes the contents of a data export archive. It is stored in
+// the archive as manifest.json.
+type DataExportManifest struct {
+	Version    int            `json:"version"`
+	ExportID   string         `json:"exportId"`
+	UserID     string         `json:"userId"`
+	ExportedAt time.Time      `json:"exportedAt"`
+	Files      map[string]int `json:"files"` // Number of records in each JSON file
+}
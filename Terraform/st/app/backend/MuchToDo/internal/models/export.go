@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Formats supported by the todo export.
+const (
+	ExportFormatCSV      = "csv"
+	ExportFormatJSON     = "json"
+	ExportFormatNDJSON   = "ndjson"
+	ExportFormatMarkdown = "markdown"
+)
+
+// ExportVersion is the version of the JSON export document. It is increased whenever
+// the document changes in a way older importers can't read.
+const ExportVersion = 1
+
+// TodoExport is the document produced by the JSON export.
+type TodoExport struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Todos      []Todo    `json:"todos"`
+}
+
+// TodoCSVColumns are the columns of the CSV export, in order. Labels and blockers are
+// comma-separated lists, the recurrence is a JSON object and timestamps are RFC 3339.
+var TodoCSVColumns = []string{
+	"id", "title", "description", "completed", "project", "labels", "dueDate", "priority",
+	"recurrence", "parentId", "blockedBy", "completedAt", "archivedAt", "createdAt", "updatedAt",
+}
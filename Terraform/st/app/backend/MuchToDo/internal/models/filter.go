@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SavedFilter is a named filter expression a user can run again later.
+type SavedFilter struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Name      string             `bson:"name" json:"name"`   // Unique per user
+	Query     string             `bson:"query" json:"query"` // Filter expression, see package query
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// SavedFilterDTO is the Data Transfer Object for creating or replacing a saved filter.
+type SavedFilterDTO struct {
+	Name  string `json:"name" binding:"required,max=100"`
+	Query string `json:"query" binding:"required"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Actions recorded in a todo's history.
+const (
+	TodoActionCreated    = "created"
+	TodoActionUpdated    = "updated"
+	TodoActionDeleted    = "deleted"
+	TodoActionRestored   = "restored"
+	TodoActionArchived   = "archived"
+	TodoActionUnarchived = "unarchived"
+	TodoActionReverted   = "reverted"
+	TodoActionUndone     = "undone"
+)
+
+// TodoVersion is one entry in a todo's change history.
+type TodoVersion struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TodoID    primitive.ObjectID `bson:"todoId" json:"todoId"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`       // Owner of the todo
+	Version   int                `bson:"version" json:"version"`     // Starts at 1 and increases by one per change
+	Action    string             `bson:"action" json:"action"`       // One of the TodoAction* constants
+	ChangedBy primitive.ObjectID `bson:"changedBy" json:"changedBy"` // NilObjectID for background jobs
+	ChangedAt time.Time          `bson:"changedAt" json:"changedAt"`
+	Changes   []FieldChange      `bson:"changes" json:"changes"`
+	Snapshot  Todo               `bson:"snapshot" json:"snapshot"` // The todo as it was after this change
+}
+
+// FieldChange describes how a single field changed between two versions.
+type FieldChange struct {
+	Field string      `bson:"field" json:"field"`
+	Old   interface{} `bson:"old" json:"old"`
+	New   interface{} `bson:"new" json:"new"`
+}
@@ -0,0 +1,43 @@
+package models
+
+// Per-row statuses reported by the import endpoint.
+const (
+	ImportStatusOK        = "ok" // Imported, or would be in a dry run
+	ImportStatusDuplicate = "duplicate"
+	ImportStatusInvalid   = "invalid"
+)
+
+// What the import endpoint does with todos that already exist.
+const (
+	ImportDuplicatesSkip   = "skip"
+	ImportDuplicatesImport = "import"
+)
+
+// ImportOptionsDTO holds the form fields sent alongside an import file. Projects and
+// labels are renamed with projectMap[old]=new and labelMap[old]=new fields; mapping a
+// label to an empty name drops it.
+type ImportOptionsDTO struct {
+	Format     string `form:"format" binding:"omitempty,oneof=csv json ndjson todoist trello markdown"` // Detected from the file if empty
+	DryRun     bool   `form:"dryRun"`                                                                   // Validate and report without importing anything
+	Duplicates string `form:"duplicates" binding:"omitempty,oneof=skip import"`                         // One of the ImportDuplicates* constants; defaults to skip
+}
+
+// ImportRowResult reports what happened to one todo of an import file.
+type ImportRowResult struct {
+	Row         int      `json:"row"` // CSV line, or 1-based position in a JSON file
+	Title       string   `json:"title"`
+	Status      string   `json:"status"`                // One of the ImportStatus* constants
+	ID          string   `json:"id,omitempty"`          // The new todo; not set in a dry run
+	DuplicateOf string   `json:"duplicateOf,omitempty"` // The existing todo a duplicate matches, if it isn't in the file itself
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// ImportResult summarises an import.
+type ImportResult struct {
+	Format     string            `json:"format"`
+	DryRun     bool              `json:"dryRun"`
+	Imported   int               `json:"imported"` // Todos created, or that would be in a dry run
+	Duplicates int               `json:"duplicates"`
+	Invalid    int               `json:"invalid"`
+	Rows       []ImportRowResult `json:"rows"`
+}
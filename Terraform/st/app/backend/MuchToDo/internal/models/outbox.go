@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Kinds of aggregate that outbox records describe changes to.
+const (
+	AggregateTodo = "todo"
+	AggregateUser = "user"
+)
+
+// OutboxRetention is how long an outbox record is kept once every sink has received it.
+const OutboxRetention = 3 * 24 * time.Hour
+
+// OutboxRecord is an event waiting to be published, written in the same transaction as
+// the change it describes so that the event is published if and only if the change is
+// saved. Records of one aggregate are published in the order of their sequence numbers.
+type OutboxRecord struct {
+	ID            primitive.ObjectID `bson:"_id" json:"id"` // The event's ID
+	AggregateType string             `bson:"aggregateType" json:"aggregateType"`
+	AggregateID   primitive.ObjectID `bson:"aggregateId" json:"aggregateId"`
+	Sequence      int64              `bson:"sequence" json:"sequence"` // Counts up from 1 for each aggregate
+	EventType     string             `bson:"eventType" json:"eventType"`
+	UserID        primitive.ObjectID `bson:"userId" json:"userId"`
+	Payload       string             `bson:"payload" json:"payload"` // The event, as JSON
+	CreatedAt     time.Time          `bson:"createdAt" json:"createdAt"`
+	PublishedTo   []string           `bson:"publishedTo" json:"publishedTo"`                     // The sinks that have received it
+	FailedTo      []string           `bson:"failedTo,omitempty" json:"failedTo,omitempty"`       // The sinks that gave up on it
+	Attempts      map[string]int     `bson:"attempts,omitempty" json:"attempts,omitempty"`       // Failed attempts, by sink
+	PublishedAt   *time.Time         `bson:"publishedAt,omitempty" json:"publishedAt,omitempty"` // When the last sink received or gave up on it
+	LastError     string             `bson:"lastError,omitempty" json:"lastError,omitempty"`
+}
+
+// Types of user events.
+const (
+	UserEventCreated           = "user.created"
+	UserEventUpdated           = "user.updated" // The profile or password changed
+	UserEventDeletionScheduled = "user.deletion_scheduled"
+	UserEventRestored          = "user.restored"
+	UserEventDeleted           = "user.deleted" // The account and its data are gone for good
+)
+
+// UserEvent describes a change to an account.
+type UserEvent struct {
+	ID         primitive.ObjectID `json:"id"`
+	Type       string             `json:"type"` // One of the UserEvent* types
+	UserID     primitive.ObjectID `json:"userId"`
+	Changes    []string           `json:"changes,omitempty"` // The fields that changed, for user.updated
+	OccurredAt time.Time          `json:"occurredAt"`
+	DeleteAt   *time.Time         `json:"deleteAt,omitempty"` // For user.deletion_scheduled
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Days a week can start on.
+const (
+	WeekStartMonday = "monday"
+	WeekStartSunday = "sunday"
+)
+
+// Preferences holds a user's regional settings. They are stored on the user document;
+// fields left empty fall back to the defaults from DefaultPreferences.
+type Preferences struct {
+	Timezone       string `bson:"timezone,omitempty" json:"timezone"`             // IANA name, e.g. Europe/Berlin
+	Locale         string `bson:"locale,omitempty" json:"locale"`                 // BCP 47 tag, e.g. en-GB
+	WeekStart      string `bson:"weekStart,omitempty" json:"weekStart"`           // One of the WeekStart* constants
+	DateFormat     string `bson:"dateFormat,omitempty" json:"dateFormat"`         // Display format for clients, e.g. DD/MM/YYYY
+	DefaultProject string `bson:"defaultProject,omitempty" json:"defaultProject"` // Project for new todos that don't name one
+}
+
+// DefaultPreferences returns the preferences of a user who hasn't set any.
+func DefaultPreferences() Preferences {
+	return Preferences{
+		Timezone:   "UTC",
+		Locale:     "en",
+		WeekStart:  WeekStartMonday,
+		DateFormat: "YYYY-MM-DD",
+	}
+}
+
+// WithDefaults returns the preferences with empty fields set to their defaults.
+func (p Preferences) WithDefaults() Preferences {
+	defaults := DefaultPreferences()
+	if p.Timezone == "" {
+		p.Timezone = defaults.Timezone
+	}
+	if p.Locale == "" {
+		p.Locale = defaults.Locale
+	}
+	if p.WeekStart == "" {
+		p.WeekStart = defaults.WeekStart
+	}
+	if p.DateFormat == "" {
+		p.DateFormat = defaults.DateFormat
+	}
+	return p
+}
+
+// Location returns the user's time zone, or UTC if it is unset or unknown.
+func (p Preferences) Location() *time.Location {
+	if p.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// UpdatePreferencesDTO is the Data Transfer Object for changing preferences. Fields that
+// are omitted are left unchanged; an empty string resets a field to its default.
+type UpdatePreferencesDTO struct {
+	Timezone       *string `json:"timezone"`
+	Locale         *string `json:"locale" binding:"omitempty,max=35"`
+	WeekStart      *string `json:"weekStart" binding:"omitempty,oneof=monday sunday"`
+	DateFormat     *string `json:"dateFormat" binding:"omitempty,oneof=YYYY-MM-DD DD/MM/YYYY MM/DD/YYYY DD.MM.YYYY"`
+	DefaultProject *string `json:"defaultProject" binding:"omitempty,max=100"`
+}
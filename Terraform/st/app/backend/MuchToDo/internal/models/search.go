@@ -0,0 +1,8 @@
+package models
+
+// TodoSearchResult is a todo matched by a full-text search.
+type TodoSearchResult struct {
+	Todo       `bson:",inline"`
+	Score      float64           `bson:"score" json:"score"`            // Text relevance, higher is better
+	Highlights map[string]string `bson:"-" json:"highlights,omitempty"` // HTML-escaped snippets with matches wrapped in <mark>
+}
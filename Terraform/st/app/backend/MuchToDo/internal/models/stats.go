@@ -0,0 +1,40 @@
+package models
+
+// TodoStats summarises a user's productivity over a range of days.
+type TodoStats struct {
+	From      string `json:"from"` // First day of the range, YYYY-MM-DD
+	To        string `json:"to"`   // Last day of the range, inclusive
+	Timezone  string `json:"timezone"`
+	WeekStart string `json:"weekStart"` // One of the WeekStart* constants
+
+	Created   int64 `json:"created"`   // Todos created in the range
+	Completed int64 `json:"completed"` // Todos completed in the range
+	Open      int64 `json:"open"`      // Todos not completed yet
+	Overdue   int64 `json:"overdue"`   // Open todos due before today
+
+	// Average time from creation to completion of the todos completed in the range;
+	// nil if none were completed.
+	AvgTimeToCompleteSeconds *float64 `json:"avgTimeToCompleteSeconds"`
+
+	CurrentStreak int `json:"currentStreak"` // Consecutive days with a completion, up to today or yesterday
+	LongestStreak int `json:"longestStreak"` // Longest run of such days ever
+
+	PerDay    []StatsBucket    `json:"perDay"`  // Completions per day, including days without any
+	PerWeek   []StatsBucket    `json:"perWeek"` // Completions per week, keyed by the week's first day
+	ByLabel   []StatsBreakdown `json:"byLabel"`
+	ByProject []StatsBreakdown `json:"byProject"` // Todos without a project are listed under ""
+}
+
+// StatsBucket counts completions in one day or week.
+type StatsBucket struct {
+	Key   string `bson:"_id" json:"key"`
+	Count int64  `bson:"count" json:"count"`
+}
+
+// StatsBreakdown counts todos sharing a label or project.
+type StatsBreakdown struct {
+	Key       string `bson:"_id" json:"key"`
+	Completed int64  `bson:"completed" json:"completed"` // Completed in the range
+	Open      int64  `bson:"open" json:"open"`
+	Overdue   int64  `bson:"overdue" json:"overdue"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Template is a reusable list of todos, such as an onboarding checklist.
+type Template struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID      primitive.ObjectID `bson:"userId" json:"userId"`
+	Name        string             `bson:"name" json:"name"` // Unique per user
+	Description string             `bson:"description" json:"description"`
+	Project     string             `bson:"project,omitempty" json:"project,omitempty"` // Default project for created todos
+	Items       []TemplateItem     `bson:"items" json:"items"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// TemplateItem describes one todo created from a template.
+type TemplateItem struct {
+	Title         string         `bson:"title" json:"title" binding:"required,max=200"`
+	Description   string         `bson:"description,omitempty" json:"description,omitempty"`
+	Labels        []string       `bson:"labels,omitempty" json:"labels,omitempty"`
+	Priority      int            `bson:"priority,omitempty" json:"priority,omitempty" binding:"min=0,max=4"`
+	DueOffsetDays *int           `bson:"dueOffsetDays,omitempty" json:"dueOffsetDays,omitempty" binding:"omitempty,min=-365,max=3650"` // Days after the start date; none for no due date
+	Subtasks      []TemplateItem `bson:"subtasks,omitempty" json:"subtasks,omitempty" binding:"max=50,dive"`
+}
+
+// TemplateDTO is the Data Transfer Object for creating or replacing a template.
+type TemplateDTO struct {
+	Name        string         `json:"name" binding:"required,max=100"`
+	Description string         `json:"description"`
+	Project     string         `json:"project"`
+	Items       []TemplateItem `json:"items" binding:"required,min=1,max=100,dive"`
+}
+
+// InstantiateTemplateDTO is the Data Transfer Object for creating todos from a template.
+type InstantiateTemplateDTO struct {
+	StartDate string `json:"startDate" binding:"required"` // YYYY-MM-DD or RFC 3339; due offsets count from here
+	Project   string `json:"project"`                      // Overrides the template's project
+}
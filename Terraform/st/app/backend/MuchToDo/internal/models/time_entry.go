@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TimeEntry is a span of time spent on a todo, either tracked with a timer or entered by hand.
+type TimeEntry struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	TodoID    primitive.ObjectID `bson:"todoId" json:"todoId"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Start     time.Time          `bson:"start" json:"start"`
+	End       *time.Time         `bson:"end,omitempty" json:"end,omitempty"` // Nil while the timer is running
+	Seconds   int64              `bson:"seconds" json:"seconds"`             // Set when the entry ends
+	Running   bool               `bson:"running,omitempty" json:"running"`   // At most one running entry per user
+	Manual    bool               `bson:"manual" json:"manual"`
+	Note      string             `bson:"note,omitempty" json:"note,omitempty"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// Ways the time report can group entries.
+const (
+	TimeGroupTodo    = "todo"
+	TimeGroupProject = "project"
+	TimeGroupLabel   = "label"
+	TimeGroupDay     = "day"
+)
+
+// --- DTOs (Data Transfer Objects) for API input/output ---
+
+// StartTimerDTO is the Data Transfer Object for starting a timer.
+type StartTimerDTO struct {
+	Note string `json:"note" binding:"max=500"`
+}
+
+// CreateTimeEntryDTO is the Data Transfer Object for entering time by hand. Start is
+// required, and exactly one of End and Minutes gives the length of the entry.
+type CreateTimeEntryDTO struct {
+	Start   string `json:"start" binding:"required"`         // RFC 3339 timestamp
+	End     string `json:"end"`                              // RFC 3339 timestamp
+	Minutes int    `json:"minutes" binding:"min=0,max=1440"` // At most a day, like every entry
+	Note    string `json:"note" binding:"max=500"`
+}
+
+// TimeReportRow is one group of entries in a time report.
+type TimeReportRow struct {
+	Key     string `bson:"key" json:"key"`                       // Todo ID, project, label or YYYY-MM-DD day
+	Name    string `bson:"name,omitempty" json:"name,omitempty"` // Todo title when grouping by todo
+	Seconds int64  `bson:"seconds" json:"seconds"`
+	Entries int64  `bson:"entries" json:"entries"`
+}
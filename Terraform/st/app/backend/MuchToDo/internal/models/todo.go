@@ -2,30 +2,97 @@ package models
 
 import (
 	"time" // Import the standard time package
+
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // Todo represents a single task in the ToDo list.
 type Todo struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	UserID      primitive.ObjectID `bson:"userId" json:"userId"` // Link to the User
-	Title       string             `bson:"title" json:"title" binding:"required"`
-	Description string             `bson:"description" json:"description"`
-	Completed   bool               `bson:"completed" json:"completed"`
-	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
-	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+	ID          primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID      primitive.ObjectID   `bson:"userId" json:"userId"` // Link to the User
+	Title       string               `bson:"title" json:"title" binding:"required"`
+	Description string               `bson:"description" json:"description"`
+	Completed   bool                 `bson:"completed" json:"completed"`
+	ParentID    *primitive.ObjectID  `bson:"parentId,omitempty" json:"parentId,omitempty"` // Set on subtasks
+	Project     string               `bson:"project,omitempty" json:"project,omitempty"`
+	Labels      []string             `bson:"labels,omitempty" json:"labels,omitempty"`
+	BlockedBy   []primitive.ObjectID `bson:"blockedBy,omitempty" json:"blockedBy,omitempty"` // Todos that must be completed first
+	IsBlocked   bool                 `bson:"-" json:"isBlocked"`                             // Computed, never stored
+	DueDate     *time.Time           `bson:"dueDate,omitempty" json:"dueDate,omitempty"`
+	Priority    int                  `bson:"priority,omitempty" json:"priority,omitempty"` // 1 (highest) to 4, 0 for none
+	Recurrence  *Recurrence          `bson:"recurrence,omitempty" json:"recurrence,omitempty"`
+	CompletedAt *time.Time           `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+	ArchivedAt  *time.Time           `bson:"archivedAt,omitempty" json:"archivedAt,omitempty"` // Archived todos are hidden from the main list
+	DeletedAt   *time.Time           `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`   // Set while the todo is in the trash
+	ICalUID     string               `bson:"icalUid,omitempty" json:"-"`                       // UID a CalDAV client gave the todo; others use <id>@muchtodo
+	DAVName     string               `bson:"davName,omitempty" json:"-"`                       // Resource name a CalDAV client stored the todo under
+	ChangeSeq   int64                `bson:"changeSeq,omitempty" json:"-"`                     // The user's change sequence number of the todo's last change, for CalDAV sync
+	CreatedAt   time.Time            `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time            `bson:"updatedAt" json:"updatedAt"`
+}
+
+// Recurrence frequencies.
+const (
+	RecurrenceDaily   = "daily"
+	RecurrenceWeekly  = "weekly"
+	RecurrenceMonthly = "monthly"
+	RecurrenceYearly  = "yearly"
+)
+
+// Recurrence describes how often a todo repeats.
+type Recurrence struct {
+	Frequency string `bson:"frequency" json:"frequency" binding:"omitempty,oneof=daily weekly monthly yearly"`
+	Interval  int    `bson:"interval" json:"interval" binding:"min=0,max=365"`                           // Every n periods; 0 is treated as 1
+	Weekday   *int   `bson:"weekday,omitempty" json:"weekday,omitempty" binding:"omitempty,min=0,max=6"` // For weekly todos; 0 is Sunday
+	MonthDay  int    `bson:"monthDay,omitempty" json:"monthDay,omitempty" binding:"min=0,max=31"`        // For monthly todos
+}
+
+// QuickAddDTO is the Data Transfer Object for creating a todo from free text.
+type QuickAddDTO struct {
+	Text    string `json:"text" binding:"required,max=500"`
+	Locale  string `json:"locale"`  // e.g. "en" or "de-DE"; defaults to the Accept-Language header
+	Preview bool   `json:"preview"` // Only parse the text, don't create the todo
 }
 
 // CreateTodoDTO is the Data Transfer Object for creating a new Todo.
 type CreateTodoDTO struct {
-	Title       string `json:"title" binding:"required"`
-	Description string `json:"description"`
+	Title       string      `json:"title" binding:"required"`
+	Description string      `json:"description"`
+	Project     string      `json:"project"`
+	Labels      []string    `json:"labels"`
+	DueDate     string      `json:"dueDate"` // YYYY-MM-DD or RFC 3339
+	Priority    int         `json:"priority" binding:"min=0,max=4"`
+	Recurrence  *Recurrence `json:"recurrence"`
+	ParentID    string      `json:"parentId"` // Makes the new todo a subtask of this one
 }
 
 // UpdateTodoDTO is the Data Transfer Object for updating an existing Todo.
 type UpdateTodoDTO struct {
-	Title       *string `json:"title"`
-	Description *string `json:"description"`
-	Completed   *bool   `json:"completed"`
+	Title       *string     `json:"title"`
+	Description *string     `json:"description"`
+	Completed   *bool       `json:"completed"`
+	Project     *string     `json:"project"`
+	Labels      *[]string   `json:"labels"`
+	DueDate     *string     `json:"dueDate"` // YYYY-MM-DD or RFC 3339; empty clears the due date
+	Priority    *int        `json:"priority" binding:"omitempty,min=0,max=4"`
+	Recurrence  *Recurrence `json:"recurrence"` // An empty frequency clears the recurrence
+}
+
+// AddBlockerDTO is the Data Transfer Object for marking a todo as blocked by another.
+type AddBlockerDTO struct {
+	BlockerID string `json:"blockerId" binding:"required"`
 }
 
+// ArchiveCompletedDTO is the Data Transfer Object for archiving completed todos in bulk.
+type ArchiveCompletedDTO struct {
+	OlderThanDays int `json:"olderThanDays" binding:"min=0"`
+}
+
+// TodoFilterDTO narrows down a set of todos. It is bound from the query string on
+// list endpoints and from JSON in bulk requests.
+type TodoFilterDTO struct {
+	Completed *bool   `json:"completed" form:"completed"`
+	Project   *string `json:"project" form:"project"`
+	Label     *string `json:"label" form:"label"`
+	Query     string  `json:"query" form:"filter"` // Filter expression, see package query
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UndoOperation records how to reverse a recent mutation. It is identified by an
+// opaque token handed to the client and expires after a short window.
+type UndoOperation struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Token     string             `bson:"token" json:"token"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Action    string             `bson:"action" json:"action"` // The TodoAction* the operation recorded
+	Items     []UndoItem         `bson:"items" json:"-"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"` // Backed by a TTL index
+}
+
+// UndoItem captures one todo as it was before an undoable operation.
+type UndoItem struct {
+	Before  Todo `bson:"before"`
+	Version int  `bson:"version"` // History version written by the operation; undo is refused if the todo has moved on
+}
@@ -9,13 +9,19 @@ import (
 
 // User represents a user in the system.
 type User struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	FirstName string             `bson:"firstName" json:"firstName" binding:"required"`
-	LastName  string             `bson:"lastName" json:"lastName" binding:"required"`
-	Username  string             `bson:"username" json:"username" binding:"required"`
-	Password  string             `bson:"password" json:"-"` // Never return password
-	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
-	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	FirstName   string             `bson:"firstName" json:"firstName" binding:"required"`
+	LastName    string             `bson:"lastName" json:"lastName" binding:"required"`
+	Username    string             `bson:"username" json:"username" binding:"required"`
+	Password    string             `bson:"password" json:"-"` // Never return password
+	Preferences Preferences        `bson:"preferences" json:"preferences"`
+	Calendar    *CalendarToken     `bson:"calendarToken,omitempty" json:"-"` // Set while the calendar feed is enabled
+	// Set while the account is scheduled for deletion. The account is disabled until it
+	// is restored or, at DeleteAt, deleted for good.
+	DeletionRequestedAt *time.Time `bson:"deletionRequestedAt,omitempty" json:"-"`
+	DeleteAt            *time.Time `bson:"deleteAt,omitempty" json:"-"`
+	CreatedAt           time.Time  `bson:"createdAt" json:"createdAt"`
+	UpdatedAt           time.Time  `bson:"updatedAt" json:"updatedAt"`
 }
 
 // HashPassword hashes the user's password using bcrypt.
@@ -67,4 +73,4 @@ type UpdateUserDTO struct {
 type ChangePasswordDTO struct {
 	OldPassword string `json:"oldPassword" binding:"required"`
 	NewPassword string `json:"newPassword" binding:"required,min=8"`
-}
\ No newline at end of file
+}
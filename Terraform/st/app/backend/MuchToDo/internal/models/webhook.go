@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Types of todo events. Each change to a todo is one event: todo.completed when it was
+// completed, todo.created and todo.deleted when it was created or moved to the trash,
+// and todo.updated for anything else, including restores, archiving and undo.
+const (
+	TodoEventCreated   = "todo.created"
+	TodoEventUpdated   = "todo.updated"
+	TodoEventCompleted = "todo.completed"
+	TodoEventDeleted   = "todo.deleted"
+)
+
+// TodoEventTypes lists every todo event type.
+var TodoEventTypes = []string{TodoEventCreated, TodoEventUpdated, TodoEventCompleted, TodoEventDeleted}
+
+// TodoEvent describes a change to a todo, as published through the outbox.
+type TodoEvent struct {
+	ID         primitive.ObjectID `bson:"_id" json:"id"`
+	Type       string             `bson:"type" json:"type"` // One of the TodoEvent* types
+	UserID     primitive.ObjectID `bson:"userId" json:"userId"`
+	TodoID     primitive.ObjectID `bson:"todoId" json:"todoId"`
+	Action     string             `bson:"action" json:"action"`   // The TodoAction* of the history version behind the event
+	Version    int                `bson:"version" json:"version"` // The todo's history version
+	Changes    []FieldChange      `bson:"changes,omitempty" json:"changes,omitempty"`
+	OccurredAt time.Time          `bson:"occurredAt" json:"occurredAt"`
+	Todo       Todo               `bson:"todo" json:"todo"` // The todo after the change
+}
+
+// Webhook is an endpoint that todo events are posted to.
+type Webhook struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID `bson:"userId" json:"userId"`
+	URL         string             `bson:"url" json:"url"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	Events      []string           `bson:"events" json:"events"` // The TodoEvent* types it subscribes to
+	Active      bool               `bson:"active" json:"active"` // Inactive webhooks receive no new deliveries
+	Secret      string             `bson:"secret" json:"-"`      // Signs the deliveries
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+// NewWebhook is a newly created webhook along with its signing secret, which is only
+// shown once.
+type NewWebhook struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookDTO is the Data Transfer Object for creating or replacing a webhook.
+type WebhookDTO struct {
+	URL         string   `json:"url" binding:"required,url,max=2000"`
+	Description string   `json:"description" binding:"max=200"`
+	Events      []string `json:"events" binding:"required,min=1,dive,oneof=todo.created todo.updated todo.completed todo.deleted"`
+	Active      *bool    `json:"active"` // Defaults to true
+}
+
+// States of a webhook delivery.
+const (
+	WebhookDeliveryPending   = "pending" // Waiting for its next attempt
+	WebhookDeliverySending   = "sending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed" // Given up on after too many attempts
+)
+
+// WebhookDeliveryRetention is how long webhook deliveries are kept in the log.
+const WebhookDeliveryRetention = 30 * 24 * time.Hour
+
+// WebhookDelivery is one event posted to one webhook, with a log of every attempt.
+type WebhookDelivery struct {
+	ID            primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	WebhookID     primitive.ObjectID  `bson:"webhookId" json:"webhookId"`
+	UserID        primitive.ObjectID  `bson:"userId" json:"userId"`
+	EventID       primitive.ObjectID  `bson:"eventId" json:"eventId"` // The same for replays of a delivery
+	EventType     string              `bson:"eventType" json:"eventType"`
+	Payload       string              `bson:"payload" json:"payload"` // The request body
+	Status        string              `bson:"status" json:"status"`   // One of the WebhookDelivery* states
+	Attempts      []WebhookAttempt    `bson:"attempts" json:"attempts"`
+	NextAttemptAt *time.Time          `bson:"nextAttemptAt,omitempty" json:"nextAttemptAt,omitempty"`
+	ResponseCode  int                 `bson:"responseCode,omitempty" json:"responseCode,omitempty"` // Of the last attempt
+	ReplayOf      *primitive.ObjectID `bson:"replayOf,omitempty" json:"replayOf,omitempty"`
+	CreatedAt     time.Time           `bson:"createdAt" json:"createdAt"`
+	CompletedAt   *time.Time          `bson:"completedAt,omitempty" json:"completedAt,omitempty"`
+}
+
+// WebhookAttempt is one attempt at a webhook delivery.
+type WebhookAttempt struct {
+	At           time.Time `bson:"at" json:"at"`
+	ResponseCode int       `bson:"responseCode,omitempty" json:"responseCode,omitempty"` // None if no response was received
+	Error        string    `bson:"error,omitempty" json:"error,omitempty"`
+	DurationMs   int64     `bson:"durationMs" json:"durationMs"`
+}
@@ -0,0 +1,87 @@
+// Package outbox publishes events reliably. Events are written to an outbox collection
+// in the same transaction as the change they describe, and a relay worker publishes them
+// to sinks afterwards, so an event is never lost or published for a change that was
+// rolled back. Delivery is at least once: a sink may receive an event again if the relay
+// stops between publishing it and recording that it did, so sinks and their consumers
+// should ignore events whose ID they have seen. The events of one aggregate, such as a
+// todo, reach each sink in the order they happened.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// Outbox holds the events waiting to be published.
+type Outbox struct {
+	records   *mongo.Collection
+	sequences *mongo.Collection // The last sequence number of each aggregate
+	leases    *mongo.Collection // Decides which server's relay runs
+	notify    chan struct{}
+}
+
+// New creates an outbox stored in db.
+func New(db *mongo.Database) *Outbox {
+	return &Outbox{
+		records:   db.Collection("outbox"),
+		sequences: db.Collection("outbox_sequences"),
+		leases:    db.Collection("outbox_leases"),
+		notify:    make(chan struct{}, 1),
+	}
+}
+
+// Append adds event to the outbox as the next event of its aggregate. The ID, aggregate,
+// event type and user of record must be set; the rest is filled in. ctx must be the
+// session context of the transaction that saves the change the event describes.
+func (o *Outbox) Append(ctx context.Context, record models.OutboxRecord, event interface{}) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	// Allocating the number updates the aggregate's counter, so transactions appending
+	// to one aggregate conflict, and the later one is retried after the earlier commits.
+	var sequence struct {
+		Last int64 `bson:"last"`
+	}
+	key := record.AggregateType + ":" + record.AggregateID.Hex()
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	if err := o.sequences.FindOneAndUpdate(ctx, bson.M{"_id": key}, bson.M{"$inc": bson.M{"last": 1}}, opts).Decode(&sequence); err != nil {
+		return err
+	}
+
+	record.Sequence = sequence.Last
+	record.Payload = string(payload)
+	record.CreatedAt = time.Now()
+	record.PublishedTo = []string{}
+	_, err = o.records.InsertOne(ctx, record)
+	return err
+}
+
+// Forget removes the sequence counters of aggregates that will have no more events,
+// such as purged todos. Their records already in the outbox are published as usual.
+func (o *Outbox) Forget(ctx context.Context, aggregateType string, ids []primitive.ObjectID) error {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = aggregateType + ":" + id.Hex()
+	}
+	_, err := o.sequences.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": keys}})
+	return err
+}
+
+// Notify wakes this server's relay, so that events appended by a transaction that has
+// committed are published without waiting for its next poll.
+func (o *Outbox) Notify() {
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+}
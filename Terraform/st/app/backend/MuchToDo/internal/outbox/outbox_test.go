@@ -0,0 +1,108 @@
+package outbox
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/config"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+func TestSinks(t *testing.T) {
+	record := models.OutboxRecord{
+		ID:            primitive.NewObjectID(),
+		AggregateType: models.AggregateTodo,
+		AggregateID:   primitive.NewObjectID(),
+		Sequence:      3,
+		EventType:     models.TodoEventCompleted,
+		UserID:        primitive.NewObjectID(),
+		Payload:       `{"type":"todo.completed","todo":{"title":"Ship it"}}`,
+		CreatedAt:     time.Now(),
+	}
+
+	t.Run("Envelope", func(t *testing.T) {
+		encoded, err := json.Marshal(NewEnvelope(record))
+		assert.NoError(t, err)
+
+		var envelope map[string]interface{}
+		assert.NoError(t, json.Unmarshal(encoded, &envelope))
+		assert.Equal(t, record.ID.Hex(), envelope["id"])
+		assert.Equal(t, "todo.completed", envelope["type"])
+		assert.Equal(t, record.AggregateID.Hex(), envelope["aggregateId"])
+		assert.Equal(t, float64(3), envelope["sequence"])
+		// The event is embedded as JSON, not as a string.
+		assert.Equal(t, "Ship it", envelope["data"].(map[string]interface{})["todo"].(map[string]interface{})["title"])
+	})
+
+	t.Run("Webhook", func(t *testing.T) {
+		var received *http.Request
+		var body []byte
+		status := http.StatusOK
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(status)
+		}))
+		defer receiver.Close()
+
+		sink := NewWebhookSink(receiver.URL, "secret")
+		assert.NoError(t, sink.Publish(t.Context(), record))
+		assert.Equal(t, record.ID.Hex(), received.Header.Get(DeliveryHeader))
+		assert.Equal(t, "todo.completed", received.Header.Get(EventHeader))
+		timestamp := received.Header.Get(TimestampHeader)
+		assert.Equal(t, Signature("secret", timestamp, body), received.Header.Get(SignatureHeader))
+
+		var envelope Envelope
+		assert.NoError(t, json.Unmarshal(body, &envelope))
+		assert.Equal(t, record.ID.Hex(), envelope.ID)
+
+		// Without a secret the requests are unsigned.
+		assert.NoError(t, NewWebhookSink(receiver.URL, "").Publish(t.Context(), record))
+		assert.Empty(t, received.Header.Get(SignatureHeader))
+
+		// Anything but a 2xx is retried.
+		status = http.StatusServiceUnavailable
+		assert.Error(t, sink.Publish(t.Context(), record))
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		sinks, err := NewSinks(config.Config{}, nil)
+		assert.NoError(t, err)
+		assert.Empty(t, sinks)
+
+		sinks, err = NewSinks(config.Config{OutboxSinks: []string{"log", "Webhook"}, OutboxWebhookURL: "http://localhost/events"}, nil)
+		assert.NoError(t, err)
+		assert.Len(t, sinks, 2)
+		assert.Equal(t, "log", sinks[0].Name())
+		assert.Equal(t, "webhook", sinks[1].Name())
+
+		rdb := redis.NewClient(&redis.Options{Addr: "localhost:0"})
+		defer rdb.Close()
+		sinks, err = NewSinks(config.Config{OutboxSinks: []string{"redis"}, OutboxRedisStream: "events"}, rdb)
+		assert.NoError(t, err)
+		assert.Equal(t, "redis", sinks[0].Name())
+
+		_, err = NewSinks(config.Config{OutboxSinks: []string{"webhook"}}, nil)
+		assert.Error(t, err)
+		_, err = NewSinks(config.Config{OutboxSinks: []string{"redis"}}, nil)
+		assert.Error(t, err)
+		_, err = NewSinks(config.Config{OutboxSinks: []string{"kafka"}}, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("Backoff", func(t *testing.T) {
+		assert.Equal(t, time.Second, relayBackoff(1))
+		assert.Equal(t, 2*time.Second, relayBackoff(2))
+		assert.Equal(t, 32*time.Second, relayBackoff(6))
+		assert.Equal(t, relayMaxBackoff, relayBackoff(7))
+		assert.Equal(t, relayMaxBackoff, relayBackoff(100))
+	})
+}
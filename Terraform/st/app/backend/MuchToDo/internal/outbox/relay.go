@@ -0,0 +1,306 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+const (
+	relayInterval   = time.Second      // How often the relay polls for events appended on other servers
+	relayBatch      = 100              // Records read at once
+	relayLeaseTTL   = 30 * time.Second // How long a relay that stops renewing its lease blocks the others
+	relayLeaseID    = "relay"
+	relayMaxBackoff = time.Minute // Longest wait before retrying a failing sink
+	// relayMaxAttempts is how many times a sink that accepts other records can fail to
+	// publish one before it gives up on it.
+	relayMaxAttempts = 10
+)
+
+// errLeaseLost is returned when another server's relay has taken over.
+var errLeaseLost = errors.New("outbox relay lease lost")
+
+// Sink is somewhere events are published to.
+type Sink interface {
+	// Name identifies the sink in the records it has received, so it must not change.
+	Name() string
+	// Publish publishes an event. It returns an error if the event may not have been
+	// published, in which case it is retried.
+	Publish(ctx context.Context, record models.OutboxRecord) error
+}
+
+// Relay publishes the records in an outbox to its sinks. Every server runs one, but only
+// the one holding the lease publishes at any time. Each sink receives the records of an
+// aggregate in sequence; a sink that fails is retried with exponential backoff, and does
+// not hold up the other sinks. A record a sink fails to publish only holds up the later
+// records of its aggregate, and once it has failed relayMaxAttempts times while the sink
+// accepted other records, the sink gives up on it: it is left in the outbox, with its
+// error, in failedTo.
+type Relay struct {
+	outbox     *Outbox
+	sinks      []Sink
+	instance   string    // Identifies this relay as the lease holder
+	leaseUntil time.Time // When the lease this relay holds runs out
+	failures   map[string]int
+	retryAt    map[string]time.Time
+}
+
+// NewRelay creates a relay publishing the outbox's records to sinks.
+func (o *Outbox) NewRelay(sinks ...Sink) *Relay {
+	return &Relay{
+		outbox:   o,
+		sinks:    sinks,
+		instance: primitive.NewObjectID().Hex(),
+		failures: map[string]int{},
+		retryAt:  map[string]time.Time{},
+	}
+}
+
+// Run publishes records until ctx is cancelled, whenever it is notified of new ones and
+// every relayInterval.
+func (r *Relay) Run(ctx context.Context) {
+	slog.Info("Outbox relay started", "sinks", r.sinkNames())
+	ticker := time.NewTicker(relayInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.relay(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Outbox relay failed", slog.Any("error", err))
+		}
+
+		select {
+		case <-ctx.Done():
+			r.releaseLease()
+			slog.Info("Outbox relay stopped")
+			return
+		case <-ticker.C:
+		case <-r.outbox.notify:
+		}
+	}
+}
+
+// relay publishes the pending records to every sink that isn't waiting for a retry, if
+// this relay holds the lease.
+func (r *Relay) relay(ctx context.Context) error {
+	held, err := r.holdLease(ctx)
+	if err != nil || !held {
+		return err
+	}
+
+	for _, sink := range r.sinks {
+		name := sink.Name()
+		if time.Now().Before(r.retryAt[name]) {
+			continue
+		}
+		err := r.relayTo(ctx, sink)
+		if errors.Is(err, errLeaseLost) || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			r.failures[name]++
+			r.retryAt[name] = time.Now().Add(relayBackoff(r.failures[name]))
+			slog.Warn("Failed to publish outbox events", "sink", name, "failures", r.failures[name], slog.Any("error", err))
+			continue
+		}
+		r.failures[name] = 0
+	}
+
+	// Records every sink has received or given up on are done with, and expire.
+	filter := bson.M{"publishedAt": nil}
+	if len(r.sinks) > 0 {
+		handled := bson.M{"$setUnion": bson.A{bson.M{"$ifNull": bson.A{"$publishedTo", bson.A{}}}, bson.M{"$ifNull": bson.A{"$failedTo", bson.A{}}}}}
+		filter["$expr"] = bson.M{"$setIsSubset": bson.A{r.sinkNames(), handled}}
+	}
+	_, err = r.outbox.records.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"publishedAt": time.Now()}})
+	return err
+}
+
+// relayTo publishes the records a sink hasn't received, aggregate by aggregate, starting
+// with those that have waited longest. An aggregate whose next record fails is skipped
+// until the next pass, and the first failure is returned once the others are published.
+func (r *Relay) relayTo(ctx context.Context, sink Sink) error {
+	name := sink.Name()
+	filter := bson.M{"publishedAt": nil, "publishedTo": bson.M{"$ne": name}, "failedTo": bson.M{"$ne": name}}
+	seen := map[string]bool{}
+	var failures []relayFailure
+	var firstErr error
+	delivered := false
+	for {
+		var heads []models.OutboxRecord
+		opts := options.Find().
+			SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).
+			SetLimit(relayBatch).
+			SetProjection(bson.M{"aggregateType": 1, "aggregateId": 1})
+		cursor, err := r.outbox.records.Find(ctx, filter, opts)
+		if err == nil {
+			err = cursor.All(ctx, &heads)
+		}
+		if err != nil {
+			return err
+		}
+
+		progressed := false
+		for _, head := range heads {
+			key := head.AggregateType + ":" + head.AggregateID.Hex()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			progressed = true
+
+			held, err := r.holdLease(ctx)
+			if err != nil {
+				return err
+			}
+			if !held {
+				return errLeaseLost
+			}
+			published, failed, err := r.relayAggregate(ctx, sink, head.AggregateType, head.AggregateID)
+			if published > 0 {
+				delivered = true
+			}
+			if err != nil {
+				if ctx.Err() != nil || failed == nil {
+					return err
+				}
+				failures = append(failures, relayFailure{record: *failed, err: err})
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+		}
+
+		// A page of aggregates that have all been tried, or failed, ends the pass.
+		if len(heads) < relayBatch || !progressed {
+			break
+		}
+	}
+
+	for _, failure := range failures {
+		if err := r.recordFailure(ctx, name, failure, delivered); err != nil {
+			return err
+		}
+	}
+	return firstErr
+}
+
+// relayAggregate publishes the records of one aggregate that a sink hasn't received, in
+// sequence, stopping at the first that fails. It returns how many it published and the
+// record that failed, if publishing it was the error.
+func (r *Relay) relayAggregate(ctx context.Context, sink Sink, aggregateType string, aggregateID primitive.ObjectID) (int, *models.OutboxRecord, error) {
+	name := sink.Name()
+	filter := bson.M{
+		"aggregateType": aggregateType,
+		"aggregateId":   aggregateID,
+		"publishedAt":   nil,
+		"publishedTo":   bson.M{"$ne": name},
+		"failedTo":      bson.M{"$ne": name},
+	}
+
+	var records []models.OutboxRecord
+	cursor, err := r.outbox.records.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}}).SetLimit(relayBatch))
+	if err == nil {
+		err = cursor.All(ctx, &records)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for i, record := range records {
+		if err := sink.Publish(ctx, record); err != nil {
+			return i, &records[i], err
+		}
+		update := bson.M{"$addToSet": bson.M{"publishedTo": name}, "$unset": bson.M{"lastError": ""}}
+		if _, err := r.outbox.records.UpdateOne(ctx, bson.M{"_id": record.ID}, update); err != nil {
+			return i, nil, err
+		}
+	}
+	return len(records), nil, nil
+}
+
+// relayFailure is a record a sink failed to publish, and the error.
+type relayFailure struct {
+	record models.OutboxRecord
+	err    error
+}
+
+// recordFailure saves the error of a record a sink failed to publish. The attempt only
+// counts towards giving up on the record if the sink accepted other records in the same
+// pass, so that an outage of the sink doesn't make it give up on everything.
+func (r *Relay) recordFailure(ctx context.Context, name string, failure relayFailure, counts bool) error {
+	record := failure.record
+	update := bson.M{"$set": bson.M{"lastError": name + ": " + failure.err.Error()}}
+	if counts {
+		update["$inc"] = bson.M{"attempts." + name: 1}
+		if record.Attempts[name]+1 >= relayMaxAttempts {
+			update["$addToSet"] = bson.M{"failedTo": name}
+			slog.Error("Gave up publishing outbox record", "sink", name, "record", record.ID.Hex(), "type", record.EventType, slog.Any("error", failure.err))
+		}
+	}
+	_, err := r.outbox.records.UpdateOne(ctx, bson.M{"_id": record.ID}, update)
+	return err
+}
+
+// holdLease takes or renews the lease that lets this relay publish, and reports whether
+// it holds it. The lease is only renewed once half of it has run out.
+func (r *Relay) holdLease(ctx context.Context) (bool, error) {
+	now := time.Now()
+	if now.Add(relayLeaseTTL / 2).Before(r.leaseUntil) {
+		return true, nil
+	}
+
+	until := now.Add(relayLeaseTTL)
+	filter := bson.M{"_id": relayLeaseID, "$or": bson.A{bson.M{"holder": r.instance}, bson.M{"expiresAt": bson.M{"$lt": now}}}}
+	update := bson.M{"$set": bson.M{"holder": r.instance, "expiresAt": until}}
+	_, err := r.outbox.leases.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// Another relay holds the lease, so the upsert tried to insert a second one.
+		r.leaseUntil = time.Time{}
+		return false, nil
+	}
+	if err != nil {
+		r.leaseUntil = time.Time{}
+		return false, err
+	}
+	r.leaseUntil = until
+	return true, nil
+}
+
+// releaseLease gives up the lease, so that another server's relay can take over at once.
+func (r *Relay) releaseLease() {
+	if r.leaseUntil.IsZero() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	update := bson.M{"$set": bson.M{"expiresAt": time.Now()}}
+	if _, err := r.outbox.leases.UpdateOne(ctx, bson.M{"_id": relayLeaseID, "holder": r.instance}, update); err != nil {
+		slog.Warn("Failed to release outbox relay lease", slog.Any("error", err))
+	}
+	r.leaseUntil = time.Time{}
+}
+
+func (r *Relay) sinkNames() []string {
+	names := make([]string, len(r.sinks))
+	for i, sink := range r.sinks {
+		names[i] = sink.Name()
+	}
+	return names
+}
+
+// relayBackoff returns how long to wait before retrying a sink that has failed n times
+// in a row.
+func relayBackoff(failures int) time.Duration {
+	if failures > 6 {
+		return relayMaxBackoff
+	}
+	return min(time.Second<<(failures-1), relayMaxBackoff)
+}
@@ -0,0 +1,205 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/config"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+// Headers sent with events posted to webhooks.
+const (
+	EventHeader     = "X-MuchToDo-Event"
+	DeliveryHeader  = "X-MuchToDo-Delivery"
+	TimestampHeader = "X-MuchToDo-Timestamp"
+	SignatureHeader = "X-MuchToDo-Signature"
+)
+
+// NewSinks creates the sinks named in the OUTBOX_SINKS setting. rdb is the Redis
+// connection, or nil if there is none.
+func NewSinks(cfg config.Config, rdb *redis.Client) ([]Sink, error) {
+	var sinks []Sink
+	for _, name := range cfg.OutboxSinks {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+		case "log":
+			sinks = append(sinks, LogSink{})
+		case "webhook":
+			if cfg.OutboxWebhookURL == "" {
+				return nil, errors.New("the webhook outbox sink needs OUTBOX_WEBHOOK_URL")
+			}
+			sinks = append(sinks, NewWebhookSink(cfg.OutboxWebhookURL, cfg.OutboxWebhookSecret))
+		case "redis":
+			if rdb == nil {
+				return nil, errors.New("the redis outbox sink needs Redis; set ENABLE_CACHE and REDIS_ADDR")
+			}
+			sinks = append(sinks, NewRedisStreamSink(rdb, cfg.OutboxRedisStream, cfg.OutboxRedisStreamMaxLen))
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q", name)
+		}
+	}
+	return sinks, nil
+}
+
+// Envelope is how sinks outside the application present an event: the record, with the
+// event itself as data.
+type Envelope struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregateType"`
+	AggregateID   string          `json:"aggregateId"`
+	Sequence      int64           `json:"sequence"`
+	UserID        string          `json:"userId"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// NewEnvelope wraps a record's event in an envelope.
+func NewEnvelope(record models.OutboxRecord) Envelope {
+	return Envelope{
+		ID:            record.ID.Hex(),
+		Type:          record.EventType,
+		AggregateType: record.AggregateType,
+		AggregateID:   record.AggregateID.Hex(),
+		Sequence:      record.Sequence,
+		UserID:        record.UserID.Hex(),
+		CreatedAt:     record.CreatedAt,
+		Data:          json.RawMessage(record.Payload),
+	}
+}
+
+// LogSink writes events to the application log.
+type LogSink struct{}
+
+// Name implements Sink.
+func (LogSink) Name() string { return "log" }
+
+// Publish implements Sink.
+func (LogSink) Publish(ctx context.Context, record models.OutboxRecord) error {
+	slog.InfoContext(ctx, "Event",
+		"id", record.ID.Hex(),
+		"type", record.EventType,
+		"aggregateType", record.AggregateType,
+		"aggregateId", record.AggregateID.Hex(),
+		"sequence", record.Sequence,
+		"userId", record.UserID.Hex(),
+		"data", record.Payload,
+	)
+	return nil
+}
+
+// WebhookSink posts events, in envelopes, to one URL. If it has a secret, the requests
+// are signed like the deliveries of users' webhooks.
+type WebhookSink struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+// NewWebhookSink creates a sink posting events to url.
+func NewWebhookSink(url string, secret string) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		secret: secret,
+		client: &http.Client{
+			Timeout:       10 * time.Second,
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// Name implements Sink.
+func (s *WebhookSink) Name() string { return "webhook" }
+
+// Publish implements Sink. Any response but a 2xx is an error.
+func (s *WebhookSink) Publish(ctx context.Context, record models.OutboxRecord) error {
+	body, err := json.Marshal(NewEnvelope(record))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "MuchToDo-Webhooks/1.0")
+	req.Header.Set(EventHeader, record.EventType)
+	req.Header.Set(DeliveryHeader, record.ID.Hex())
+	if s.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, Signature(s.secret, timestamp, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	return nil
+}
+
+// RedisStreamSink appends events to a Redis stream, trimmed to about maxLen entries.
+// Each entry holds the envelope's fields, with the event as JSON in "data".
+type RedisStreamSink struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+// NewRedisStreamSink creates a sink appending events to stream.
+func NewRedisStreamSink(client *redis.Client, stream string, maxLen int64) *RedisStreamSink {
+	return &RedisStreamSink{client: client, stream: stream, maxLen: maxLen}
+}
+
+// Name implements Sink.
+func (s *RedisStreamSink) Name() string { return "redis" }
+
+// Publish implements Sink.
+func (s *RedisStreamSink) Publish(ctx context.Context, record models.OutboxRecord) error {
+	envelope := NewEnvelope(record)
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.stream,
+		MaxLen: s.maxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"id":            envelope.ID,
+			"type":          envelope.Type,
+			"aggregateType": envelope.AggregateType,
+			"aggregateId":   envelope.AggregateID,
+			"sequence":      envelope.Sequence,
+			"userId":        envelope.UserID,
+			"createdAt":     envelope.CreatedAt.Format(time.RFC3339Nano),
+			"data":          record.Payload,
+		},
+	}).Err()
+}
+
+// Signature returns the X-MuchToDo-Signature header for a request: "sha256=" and the
+// hex HMAC-SHA256, keyed with secret, of the timestamp header, a period and the body.
+func Signature(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
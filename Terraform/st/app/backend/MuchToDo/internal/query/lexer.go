@@ -0,0 +1,87 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+	tokenColon
+)
+
+// token is a lexical unit of a filter expression. Pos is the byte offset in the input.
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// describe returns a human-readable name for the token, used in error messages.
+func (t token) describe() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of filter"
+	case tokenWord:
+		return "\"" + t.value + "\""
+	default:
+		return "'" + t.value + "'"
+	}
+}
+
+var punctuation = map[rune]tokenKind{
+	'&': tokenAnd,
+	'|': tokenOr,
+	'!': tokenNot,
+	'(': tokenLParen,
+	')': tokenRParen,
+	':': tokenColon,
+}
+
+// lex splits a filter expression into tokens. Words run until whitespace or punctuation;
+// "double quotes" allow either inside a word.
+func lex(input string) ([]token, error) {
+	var tokens []token
+	for pos := 0; pos < len(input); {
+		r, size := utf8.DecodeRuneInString(input[pos:])
+
+		switch {
+		case unicode.IsSpace(r):
+			pos += size
+
+		case punctuation[r] != tokenEOF:
+			tokens = append(tokens, token{kind: punctuation[r], value: string(r), pos: pos})
+			pos += size
+
+		case r == '"':
+			end := strings.IndexByte(input[pos+1:], '"')
+			if end < 0 {
+				return nil, &Error{Pos: pos, Msg: "unterminated quoted value"}
+			}
+			tokens = append(tokens, token{kind: tokenWord, value: input[pos+1 : pos+1+end], pos: pos})
+			pos += end + 2
+
+		default:
+			start := pos
+			for pos < len(input) {
+				r, size := utf8.DecodeRuneInString(input[pos:])
+				if unicode.IsSpace(r) || r == '"' || punctuation[r] != tokenEOF {
+					break
+				}
+				pos += size
+			}
+			tokens = append(tokens, token{kind: tokenWord, value: input[start:pos], pos: start})
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(input)}), nil
+}
@@ -0,0 +1,238 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/dates"
+)
+
+// Parse parses a filter expression, returning an *Error describing the first problem found.
+func Parse(input string) (*Expr, error) {
+	if len(input) > MaxLength {
+		return nil, &Error{Pos: MaxLength, Msg: fmt.Sprintf("filter is longer than %d characters", MaxLength)}
+	}
+	if strings.TrimSpace(input) == "" {
+		return nil, &Error{Pos: 0, Msg: "filter is empty"}
+	}
+
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, &Error{Pos: next.pos, Msg: fmt.Sprintf("unexpected %s, expected '&', '|' or end of filter", next.describe())}
+	}
+
+	return &Expr{root: root}, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr(depth int) (node, error) {
+	first, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := orNode{first}
+	for p.peek().kind == tokenOr {
+		p.next()
+		n, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+
+	if len(nodes) == 1 {
+		return first, nil
+	}
+	return nodes, nil
+}
+
+func (p *parser) parseAnd(depth int) (node, error) {
+	first, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := andNode{first}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		n, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+
+	if len(nodes) == 1 {
+		return first, nil
+	}
+	return nodes, nil
+}
+
+func (p *parser) parseUnary(depth int) (node, error) {
+	t := p.next()
+	if depth > maxDepth {
+		return nil, &Error{Pos: t.pos, Msg: "filter is nested too deeply"}
+	}
+
+	switch t.kind {
+	case tokenNot:
+		child, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return notNode{child: child}, nil
+
+	case tokenLParen:
+		inner, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, &Error{Pos: closing.pos, Msg: fmt.Sprintf("expected ')' to close '(' at position %d, found %s", t.pos+1, closing.describe())}
+		}
+		return inner, nil
+
+	case tokenWord:
+		return p.parseTerm(t)
+
+	default:
+		return nil, &Error{Pos: t.pos, Msg: fmt.Sprintf("unexpected %s, expected a term such as label:work", t.describe())}
+	}
+}
+
+// parseTerm parses a field and its colon-separated values and builds the matching condition.
+func (p *parser) parseTerm(field token) (node, error) {
+	var values []token
+	for p.peek().kind == tokenColon {
+		colon := p.next()
+		value := p.next()
+		if value.kind != tokenWord {
+			return nil, &Error{Pos: colon.pos + 1, Msg: fmt.Sprintf("expected a value after '%s:'", field.value)}
+		}
+		values = append(values, value)
+	}
+
+	name := strings.ToLower(field.value)
+	switch name {
+	case "completed":
+		if len(values) > 0 {
+			return nil, &Error{Pos: values[0].pos, Msg: "completed takes no value; use !completed for open todos"}
+		}
+		return termNode(func(time.Time) bson.M { return bson.M{"completed": true} }), nil
+
+	case "label", "project", "title":
+		if len(values) != 1 {
+			return nil, needsOneValue(field, values, name+":work")
+		}
+		value := values[0].value
+		switch name {
+		case "label":
+			label := strings.ToLower(strings.TrimSpace(value))
+			return termNode(func(time.Time) bson.M { return bson.M{"labels": label} }), nil
+		case "project":
+			project := strings.TrimSpace(value)
+			return termNode(func(time.Time) bson.M { return bson.M{"project": project} }), nil
+		default:
+			pattern := "(?i)" + regexp.QuoteMeta(value)
+			return termNode(func(time.Time) bson.M {
+				return bson.M{"title": bson.M{"$regex": pattern}}
+			}), nil
+		}
+
+	case "due", "created":
+		return parseDayTerm(field, values)
+
+	default:
+		return nil, &Error{Pos: field.pos, Msg: fmt.Sprintf("unknown field %q; expected completed, label, project, title, due or created", field.value)}
+	}
+}
+
+// parseDayTerm handles due:<...> and created:<...>.
+func parseDayTerm(field token, values []token) (node, error) {
+	name := strings.ToLower(field.value)
+	key := "dueDate"
+	if name == "created" {
+		key = "createdAt"
+	}
+
+	if len(values) == 1 && name == "due" {
+		switch strings.ToLower(values[0].value) {
+		case "none":
+			return termNode(func(time.Time) bson.M { return bson.M{key: nil} }), nil
+		case "any":
+			return termNode(func(time.Time) bson.M { return bson.M{key: bson.M{"$ne": nil}} }), nil
+		}
+	}
+
+	var op string
+	var day token
+	switch len(values) {
+	case 1:
+		day = values[0]
+	case 2:
+		op, day = strings.ToLower(values[0].value), values[1]
+		if op != "before" && op != "after" {
+			return nil, &Error{Pos: values[0].pos, Msg: fmt.Sprintf("expected before or after, found %q", values[0].value)}
+		}
+	default:
+		return nil, needsOneValue(field, values, name+":before:friday")
+	}
+
+	// Weekday and relative names are valid whatever the current day, so checking
+	// against the parse time is enough to reject bad input early.
+	if _, err := dates.ResolveDay(day.value, time.Now()); err != nil {
+		return nil, &Error{Pos: day.pos, Msg: err.Error()}
+	}
+
+	ref := day.value
+	return termNode(func(now time.Time) bson.M {
+		start, _ := dates.ResolveDay(ref, now)
+		end := start.AddDate(0, 0, 1)
+		switch op {
+		case "before":
+			return bson.M{key: bson.M{"$lt": start}}
+		case "after":
+			return bson.M{key: bson.M{"$gte": end}}
+		default:
+			return bson.M{key: bson.M{"$gte": start, "$lt": end}}
+		}
+	}), nil
+}
+
+// needsOneValue reports a term with the wrong number of values.
+func needsOneValue(field token, values []token, example string) error {
+	if len(values) > 1 {
+		return &Error{Pos: values[len(values)-1].pos, Msg: fmt.Sprintf("too many values for %s, e.g. %s", field.value, example)}
+	}
+	return &Error{Pos: field.pos + len(field.value), Msg: fmt.Sprintf("%s needs a value, e.g. %s", field.value, example)}
+}
@@ -0,0 +1,96 @@
+// Package query implements the filter expressions used to narrow down todo lists,
+// e.g. `due:before:friday & label:work & !completed`, and compiles them to MongoDB filters.
+//
+// Grammar, loosest binding first:
+//
+//	expr    = and { "|" and }
+//	and     = unary { "&" unary }
+//	unary   = "!" unary | "(" expr ")" | term
+//	term    = field [ ":" value [ ":" value ] ]
+//
+// Supported terms:
+//
+//	completed                       completed todos
+//	label:<name>                    todos with the label
+//	project:<name>                  todos in the project
+//	title:<text>                    title contains text (case-insensitive)
+//	due:<day>, created:<day>        on that day
+//	due:before:<day>, due:after:<day> (same for created)
+//	due:none, due:any               without / with a due date
+//
+// A <day> is today, tomorrow, yesterday, a weekday name or YYYY-MM-DD. Values containing
+// spaces or punctuation can be "double quoted".
+package query
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MaxLength is the longest filter expression accepted.
+const MaxLength = 1000
+
+// maxDepth limits how deeply expressions may nest.
+const maxDepth = 32
+
+// Error describes why a filter expression could not be parsed.
+type Error struct {
+	Pos int // Byte offset in the expression where the problem was found
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("at position %d: %s", e.Pos+1, e.Msg)
+}
+
+// Expr is a parsed filter expression.
+type Expr struct {
+	root node
+}
+
+// Mongo compiles the expression to a MongoDB filter. Relative days such as "friday" are
+// resolved against now, in now's location.
+func (e *Expr) Mongo(now time.Time) bson.M {
+	return e.root.mongo(now)
+}
+
+// node is an element of a parsed expression.
+type node interface {
+	mongo(now time.Time) bson.M
+}
+
+type andNode []node
+
+func (n andNode) mongo(now time.Time) bson.M {
+	parts := make(bson.A, len(n))
+	for i, child := range n {
+		parts[i] = child.mongo(now)
+	}
+	return bson.M{"$and": parts}
+}
+
+type orNode []node
+
+func (n orNode) mongo(now time.Time) bson.M {
+	parts := make(bson.A, len(n))
+	for i, child := range n {
+		parts[i] = child.mongo(now)
+	}
+	return bson.M{"$or": parts}
+}
+
+type notNode struct{ child node }
+
+func (n notNode) mongo(now time.Time) bson.M {
+	return bson.M{"$nor": bson.A{n.child.mongo(now)}}
+}
+
+// termNode is a single field condition. Its filter is built lazily so that relative days
+// are resolved when the expression is run rather than when it is parsed.
+type termNode func(now time.Time) bson.M
+
+func (n termNode) mongo(now time.Time) bson.M {
+	return n(now)
+}
@@ -0,0 +1,113 @@
+package query
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestCompile provides unit tests for compiling filter expressions to MongoDB filters.
+func TestCompile(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2025, 3, 12, 15, 30, 0, 0, time.UTC)
+	friday := time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name  string
+		input string
+		want  bson.M
+	}{
+		{
+			name:  "Single term",
+			input: "label:Work",
+			want:  bson.M{"labels": "work"},
+		},
+		{
+			name:  "Example from the docs",
+			input: "due:before:friday & label:work & !completed",
+			want: bson.M{"$and": bson.A{
+				bson.M{"dueDate": bson.M{"$lt": friday}},
+				bson.M{"labels": "work"},
+				bson.M{"$nor": bson.A{bson.M{"completed": true}}},
+			}},
+		},
+		{
+			name:  "And binds tighter than or",
+			input: "label:a | label:b & completed",
+			want: bson.M{"$or": bson.A{
+				bson.M{"labels": "a"},
+				bson.M{"$and": bson.A{bson.M{"labels": "b"}, bson.M{"completed": true}}},
+			}},
+		},
+		{
+			name:  "Parentheses group",
+			input: "(label:a | label:b) & completed",
+			want: bson.M{"$and": bson.A{
+				bson.M{"$or": bson.A{bson.M{"labels": "a"}, bson.M{"labels": "b"}}},
+				bson.M{"completed": true},
+			}},
+		},
+		{
+			name:  "Quoted values",
+			input: `project:"Home & Garden"`,
+			want:  bson.M{"project": "Home & Garden"},
+		},
+		{
+			name:  "Title match is escaped",
+			input: "title:a.b",
+			want:  bson.M{"title": bson.M{"$regex": `(?i)a\.b`}},
+		},
+		{
+			name:  "Day ranges",
+			input: "due:friday | created:after:2025-03-01 | due:none",
+			want: bson.M{"$or": bson.A{
+				bson.M{"dueDate": bson.M{"$gte": friday, "$lt": friday.AddDate(0, 0, 1)}},
+				bson.M{"createdAt": bson.M{"$gte": time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)}},
+				bson.M{"dueDate": nil},
+			}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			expr, err := Parse(tc.input)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, expr.Mongo(now))
+		})
+	}
+}
+
+// TestParseErrors checks that invalid expressions are rejected with a useful position.
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{input: "", pos: 0, msg: "empty"},
+		{input: "label:work &", pos: 12, msg: "expected a term"},
+		{input: "(label:work", pos: 11, msg: "expected ')'"},
+		{input: "label:work completed", pos: 11, msg: "expected '&', '|'"},
+		{input: "colour:red", pos: 0, msg: "unknown field"},
+		{input: "label", pos: 5, msg: "needs a value"},
+		{input: "label:", pos: 6, msg: "expected a value"},
+		{input: "due:soon", pos: 4, msg: "unknown day"},
+		{input: "due:during:friday", pos: 4, msg: "expected before or after"},
+		{input: "completed:yes", pos: 10, msg: "takes no value"},
+		{input: `title:"open`, pos: 6, msg: "unterminated"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			_, err := Parse(tc.input)
+			var perr *Error
+			require.True(t, errors.As(err, &perr), "expected a parse error, got %v", err)
+			assert.Equal(t, tc.pos, perr.Pos)
+			assert.Contains(t, perr.Msg, tc.msg)
+		})
+	}
+}
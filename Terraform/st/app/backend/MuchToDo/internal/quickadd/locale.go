@@ -0,0 +1,161 @@
+package quickadd
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// unit is a calendar period used in relative dates and recurrences.
+type unit int
+
+const (
+	unitDay unit = iota
+	unitWeek
+	unitMonth
+	unitYear
+)
+
+// locale holds the words the parser understands in one language. Multi-word phrases are
+// written with single spaces and matched word by word.
+type locale struct {
+	today      []string
+	tomorrow   []string
+	in         []string // "in 3 days"
+	next       []string // "next friday"; may come before or after the weekday
+	every      []string // "every week"
+	other      []string // "every other week"
+	connectors []string // Words that may introduce a date and are dropped with it
+	weekdays   map[string]time.Weekday
+	months     map[string]time.Month
+	units      map[string]unit
+	adverbs    map[string]unit // "weekly"
+	ordinal    *regexp.Regexp  // Day of the month, e.g. "1st"; the first group is the number
+	bareDays   bool            // Whether a plain number after a connector is a day of the month
+}
+
+var locales = map[string]*locale{
+	"en": {
+		today:      []string{"today"},
+		tomorrow:   []string{"tomorrow", "tmrw", "tmr"},
+		in:         []string{"in"},
+		next:       []string{"next"},
+		every:      []string{"every", "each"},
+		other:      []string{"other"},
+		connectors: []string{"on", "by", "due", "the", "on the", "by the"},
+		weekdays: map[string]time.Weekday{
+			"sunday": time.Sunday,
+			"monday": time.Monday, "mon": time.Monday,
+			"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+			"wednesday": time.Wednesday, "wed": time.Wednesday,
+			"thursday": time.Thursday, "thu": time.Thursday, "thurs": time.Thursday,
+			"friday": time.Friday, "fri": time.Friday,
+			"saturday": time.Saturday,
+		},
+		months: map[string]time.Month{
+			"january": time.January, "jan": time.January,
+			"february": time.February, "feb": time.February,
+			"march": time.March, "mar": time.March,
+			"april": time.April, "apr": time.April,
+			"may":  time.May,
+			"june": time.June, "jun": time.June,
+			"july": time.July, "jul": time.July,
+			"august": time.August, "aug": time.August,
+			"september": time.September, "sep": time.September, "sept": time.September,
+			"october": time.October, "oct": time.October,
+			"november": time.November, "nov": time.November,
+			"december": time.December, "dec": time.December,
+		},
+		units: map[string]unit{
+			"day": unitDay, "days": unitDay,
+			"week": unitWeek, "weeks": unitWeek,
+			"month": unitMonth, "months": unitMonth,
+			"year": unitYear, "years": unitYear,
+		},
+		adverbs: map[string]unit{
+			"daily": unitDay, "weekly": unitWeek, "monthly": unitMonth, "yearly": unitYear, "annually": unitYear,
+		},
+		ordinal: regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)$`),
+	},
+	"de": {
+		today:      []string{"heute"},
+		tomorrow:   []string{"morgen"},
+		in:         []string{"in"},
+		next:       []string{"nächsten", "nächste", "nächster", "naechsten"},
+		every:      []string{"jeden", "jede", "jedes", "alle"},
+		connectors: []string{"am", "bis", "zum", "bis zum", "fällig"},
+		weekdays: map[string]time.Weekday{
+			"sonntag": time.Sunday, "montag": time.Monday, "dienstag": time.Tuesday, "mittwoch": time.Wednesday,
+			"donnerstag": time.Thursday, "freitag": time.Friday, "samstag": time.Saturday, "sonnabend": time.Saturday,
+		},
+		months: map[string]time.Month{
+			"januar": time.January, "jan": time.January,
+			"februar": time.February, "feb": time.February,
+			"märz": time.March, "maerz": time.March,
+			"april": time.April, "apr": time.April,
+			"mai":    time.May,
+			"juni":   time.June,
+			"juli":   time.July,
+			"august": time.August, "aug": time.August,
+			"september": time.September, "sep": time.September, "sept": time.September,
+			"oktober": time.October, "okt": time.October,
+			"november": time.November, "nov": time.November,
+			"dezember": time.December, "dez": time.December,
+		},
+		units: map[string]unit{
+			"tag": unitDay, "tage": unitDay, "tagen": unitDay,
+			"woche": unitWeek, "wochen": unitWeek,
+			"monat": unitMonth, "monate": unitMonth, "monaten": unitMonth,
+			"jahr": unitYear, "jahre": unitYear, "jahren": unitYear,
+		},
+		adverbs: map[string]unit{
+			"täglich": unitDay, "wöchentlich": unitWeek, "monatlich": unitMonth, "jährlich": unitYear,
+		},
+		ordinal: regexp.MustCompile(`^(\d{1,2})\.$`),
+	},
+	"fr": {
+		today:      []string{"aujourd'hui", "aujourd’hui"},
+		tomorrow:   []string{"demain"},
+		in:         []string{"dans"},
+		next:       []string{"prochain", "prochaine"},
+		every:      []string{"chaque", "tous les", "toutes les"},
+		connectors: []string{"le", "pour", "pour le", "avant le"},
+		weekdays: map[string]time.Weekday{
+			"dimanche": time.Sunday, "lundi": time.Monday, "mardi": time.Tuesday, "mercredi": time.Wednesday,
+			"jeudi": time.Thursday, "vendredi": time.Friday, "samedi": time.Saturday,
+		},
+		months: map[string]time.Month{
+			"janvier": time.January, "février": time.February, "fevrier": time.February, "mars": time.March,
+			"avril": time.April, "mai": time.May, "juin": time.June, "juillet": time.July,
+			"août": time.August, "aout": time.August, "septembre": time.September, "octobre": time.October,
+			"novembre": time.November, "décembre": time.December, "decembre": time.December,
+		},
+		units: map[string]unit{
+			"jour": unitDay, "jours": unitDay,
+			"semaine": unitWeek, "semaines": unitWeek,
+			"mois": unitMonth,
+			"an":   unitYear, "ans": unitYear, "année": unitYear, "années": unitYear,
+		},
+		adverbs: map[string]unit{
+			"quotidien": unitDay, "hebdomadaire": unitWeek, "mensuel": unitMonth, "annuel": unitYear,
+		},
+		ordinal:  regexp.MustCompile(`^(\d{1,2})(?:er|e)$`),
+		bareDays: true,
+	},
+}
+
+// defaultLanguage is used when the requested language isn't supported.
+const defaultLanguage = "en"
+
+// Language picks the supported language for a locale tag or Accept-Language header value,
+// e.g. "de-DE" or "fr-CH,fr;q=0.9" (only the first entry is considered).
+func Language(tag string) string {
+	tag = strings.SplitN(tag, ",", 2)[0]
+	tag = strings.SplitN(tag, ";", 2)[0]
+	tag = strings.SplitN(tag, "-", 2)[0]
+	tag = strings.SplitN(tag, "_", 2)[0]
+	if lang := strings.ToLower(strings.TrimSpace(tag)); locales[lang] != nil {
+		return lang
+	}
+	return defaultLanguage
+}
@@ -0,0 +1,360 @@
+// Package quickadd turns a line of free text such as "Pay rent every month on the 1st #home p1"
+// into a todo. Parsing is deterministic: the same text, language and day always give the
+// same result.
+//
+// These markers work in every language:
+//
+//	#label      adds a label
+//	@project    sets the project
+//	p1 … p4     sets the priority, p1 being the highest
+//	2025-03-14  sets the due date
+//
+// Relative days ("tomorrow"), weekdays ("next friday"), offsets ("in 3 days"), days of the
+// month ("on the 1st", "march 5") and recurrences ("every 2 weeks", "daily") are read using
+// the word lists of the selected language. Whatever isn't recognised becomes the title.
+package quickadd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/dates"
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+var priorityPattern = regexp.MustCompile(`^p([1-4])$`)
+
+var unitFrequencies = map[unit]string{
+	unitDay:   models.RecurrenceDaily,
+	unitWeek:  models.RecurrenceWeekly,
+	unitMonth: models.RecurrenceMonthly,
+	unitYear:  models.RecurrenceYearly,
+}
+
+// word is one whitespace-separated piece of the input.
+type word struct {
+	raw   string // As typed, used for the title
+	lower string // Lowercased, without trailing , ; ! or ?
+	key   string // lower without a trailing full stop either
+}
+
+func newWord(raw string) word {
+	lower := strings.TrimRight(strings.ToLower(raw), ",;!?")
+	return word{raw: raw, lower: lower, key: strings.TrimRight(lower, ".")}
+}
+
+// Parse extracts the todo described by text. lang is a locale tag such as "en" or "de-DE";
+// unsupported languages fall back to English. Relative dates are resolved against now,
+// in now's location. Due dates are returned as YYYY-MM-DD.
+func Parse(text, lang string, now time.Time) models.CreateTodoDTO {
+	p := &parser{loc: locales[Language(lang)], today: dates.StartOfDay(now)}
+	for _, raw := range strings.Fields(text) {
+		p.words = append(p.words, newWord(raw))
+	}
+
+	for i := 0; i < len(p.words); {
+		if n := p.match(i); n > 0 {
+			i += n
+			continue
+		}
+		p.title = append(p.title, p.words[i].raw)
+		i++
+	}
+
+	return p.result(text)
+}
+
+type parser struct {
+	loc   *locale
+	today time.Time
+	words []word
+
+	title      []string
+	labels     []string
+	project    string
+	priority   int
+	due        *time.Time
+	recurrence *models.Recurrence
+}
+
+// match tries every rule at word i and returns the number of words consumed, or 0.
+// Only the first due date, recurrence, project and priority found are used; later
+// ones are kept in the title.
+func (p *parser) match(i int) int {
+	w := p.words[i]
+
+	switch {
+	case len(w.key) > 1 && w.key[0] == '#':
+		p.labels = append(p.labels, w.key[1:])
+		return 1
+	case len(w.key) > 1 && w.key[0] == '@' && p.project == "":
+		p.project = strings.TrimRight(w.raw[1:], ",;!?.")
+		return 1
+	case p.priority == 0 && priorityPattern.MatchString(w.key):
+		p.priority = int(w.key[1] - '0')
+		return 1
+	}
+
+	if p.recurrence == nil {
+		if n := p.matchRecurrence(i); n > 0 {
+			return n
+		}
+	}
+	if p.due == nil {
+		if n := p.matchDue(i); n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// matchRecurrence recognises "every [n|other] <unit>", "every <weekday>" and adverbs like "weekly".
+func (p *parser) matchRecurrence(i int) int {
+	if u, ok := p.loc.adverbs[p.words[i].key]; ok {
+		p.recurrence = &models.Recurrence{Frequency: unitFrequencies[u], Interval: 1}
+		return 1
+	}
+
+	n := p.phrase(i, p.loc.every)
+	if n == 0 {
+		return 0
+	}
+
+	j, interval := i+n, 1
+	if k := p.phrase(j, p.loc.other); k > 0 {
+		j, interval = j+k, 2
+	} else if number, ok := p.number(j, 1, 365); ok {
+		j, interval = j+1, number
+	}
+	if j >= len(p.words) {
+		return 0
+	}
+
+	key := p.words[j].key
+	if u, ok := p.loc.units[key]; ok {
+		p.recurrence = &models.Recurrence{Frequency: unitFrequencies[u], Interval: interval}
+		return j + 1 - i
+	}
+	if weekday, ok := p.loc.weekdays[key]; ok && interval == 1 {
+		day := int(weekday)
+		p.recurrence = &models.Recurrence{Frequency: models.RecurrenceWeekly, Interval: 1, Weekday: &day}
+		return j + 1 - i
+	}
+	return 0
+}
+
+// matchDue recognises a date, optionally introduced by a connector such as "on the".
+func (p *parser) matchDue(i int) int {
+	if due, n := p.date(i, false); n > 0 {
+		p.due = &due
+		return n
+	}
+
+	c := p.phrase(i, p.loc.connectors)
+	if c == 0 {
+		return 0
+	}
+	if due, n := p.date(i+c, true); n > 0 {
+		p.due = &due
+		return c + n
+	}
+	return 0
+}
+
+// date parses a date starting at word i, returning it and the number of words used.
+func (p *parser) date(i int, afterConnector bool) (time.Time, int) {
+	if i >= len(p.words) {
+		return time.Time{}, 0
+	}
+	key := p.words[i].key
+
+	if n := p.phrase(i, p.loc.today); n > 0 {
+		return p.today, n
+	}
+	if n := p.phrase(i, p.loc.tomorrow); n > 0 {
+		return p.today.AddDate(0, 0, 1), n
+	}
+	if day, err := time.ParseInLocation("2006-01-02", key, p.today.Location()); err == nil {
+		return day, 1
+	}
+
+	// "next friday" or "vendredi prochain"
+	if n := p.phrase(i, p.loc.next); n > 0 && i+n < len(p.words) {
+		if weekday, ok := p.loc.weekdays[p.words[i+n].key]; ok {
+			return nextWeekday(p.today, weekday, false), n + 1
+		}
+	}
+	if weekday, ok := p.loc.weekdays[key]; ok {
+		if n := p.phrase(i+1, p.loc.next); n > 0 {
+			return nextWeekday(p.today, weekday, false), n + 1
+		}
+		return nextWeekday(p.today, weekday, true), 1
+	}
+
+	// "in 3 days"
+	if n := p.phrase(i, p.loc.in); n > 0 {
+		if amount, ok := p.number(i+n, 1, 1000); ok && i+n+1 < len(p.words) {
+			if u, ok := p.loc.units[p.words[i+n+1].key]; ok {
+				return addUnits(p.today, u, amount), n + 2
+			}
+		}
+	}
+
+	// "march 5th" and "5 march"
+	if month, ok := p.loc.months[key]; ok {
+		if day, ok := p.dayOfMonth(i+1, true); ok {
+			if due, ok := nextMonthDay(p.today, month, day); ok {
+				return due, 2
+			}
+		}
+	}
+	if day, ok := p.dayOfMonth(i, true); ok && i+1 < len(p.words) {
+		if month, ok := p.loc.months[p.words[i+1].key]; ok {
+			if due, ok := nextMonthDay(p.today, month, day); ok {
+				return due, 2
+			}
+		}
+	}
+
+	// "the 1st"
+	if day, ok := p.dayOfMonth(i, afterConnector && p.loc.bareDays); ok {
+		if due, ok := nextMonthDay(p.today, 0, day); ok {
+			return due, 1
+		}
+	}
+
+	return time.Time{}, 0
+}
+
+// dayOfMonth reads an ordinal day such as "1st" at word i, or a plain number if allowPlain.
+func (p *parser) dayOfMonth(i int, allowPlain bool) (int, bool) {
+	if i >= len(p.words) {
+		return 0, false
+	}
+	w := p.words[i]
+	for _, s := range []string{w.lower, w.key} {
+		if m := p.loc.ordinal.FindStringSubmatch(s); m != nil {
+			day, _ := strconv.Atoi(m[1])
+			return day, day >= 1 && day <= 31
+		}
+	}
+	if allowPlain {
+		return p.number(i, 1, 31)
+	}
+	return 0, false
+}
+
+// number reads an integer between min and max at word i.
+func (p *parser) number(i, min, max int) (int, bool) {
+	if i >= len(p.words) {
+		return 0, false
+	}
+	n, err := strconv.Atoi(p.words[i].key)
+	if err != nil || n < min || n > max {
+		return 0, false
+	}
+	return n, true
+}
+
+// phrase returns the number of words in the longest of phrases found at word i, or 0.
+func (p *parser) phrase(i int, phrases []string) int {
+	longest := 0
+	for _, phrase := range phrases {
+		parts := strings.Fields(phrase)
+		if len(parts) <= longest || i+len(parts) > len(p.words) {
+			continue
+		}
+		matched := true
+		for k, part := range parts {
+			if p.words[i+k].key != part {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			longest = len(parts)
+		}
+	}
+	return longest
+}
+
+// result assembles the todo, filling in a first due date for recurrences that lack one
+// and the recurrence day from an explicit due date.
+func (p *parser) result(text string) models.CreateTodoDTO {
+	if r := p.recurrence; r != nil {
+		if p.due == nil {
+			due := p.today
+			switch {
+			case r.Frequency == models.RecurrenceWeekly && r.Weekday != nil:
+				due = nextWeekday(p.today, time.Weekday(*r.Weekday), true)
+			case r.Frequency == models.RecurrenceMonthly && r.MonthDay > 0:
+				due, _ = nextMonthDay(p.today, 0, r.MonthDay)
+			}
+			p.due = &due
+		}
+		if r.Frequency == models.RecurrenceWeekly && r.Weekday == nil {
+			day := int(p.due.Weekday())
+			r.Weekday = &day
+		}
+		if r.Frequency == models.RecurrenceMonthly && r.MonthDay == 0 {
+			r.MonthDay = p.due.Day()
+		}
+	}
+
+	dto := models.CreateTodoDTO{
+		Title:      strings.Join(p.title, " "),
+		Project:    p.project,
+		Labels:     p.labels,
+		Priority:   p.priority,
+		Recurrence: p.recurrence,
+	}
+	if dto.Title == "" {
+		dto.Title = strings.TrimSpace(text)
+	}
+	if p.due != nil {
+		dto.DueDate = p.due.Format("2006-01-02")
+	}
+	return dto
+}
+
+// nextWeekday returns the next given weekday after today, or today itself if includeToday.
+func nextWeekday(today time.Time, weekday time.Weekday, includeToday bool) time.Time {
+	ahead := (int(weekday) - int(today.Weekday()) + 7) % 7
+	if ahead == 0 && !includeToday {
+		ahead = 7
+	}
+	return today.AddDate(0, 0, ahead)
+}
+
+// nextMonthDay returns the first date on or after today falling on the given day of the
+// month, restricted to month unless it is 0. Months too short for the day are skipped.
+func nextMonthDay(today time.Time, month time.Month, day int) (time.Time, bool) {
+	for k := 0; k <= 24; k++ {
+		first := time.Date(today.Year(), today.Month()+time.Month(k), 1, 0, 0, 0, 0, today.Location())
+		if month != 0 && first.Month() != month {
+			continue
+		}
+		candidate := first.AddDate(0, 0, day-1)
+		if candidate.Month() != first.Month() || candidate.Before(today) {
+			continue
+		}
+		return candidate, true
+	}
+	return time.Time{}, false
+}
+
+// addUnits moves day forward by amount units.
+func addUnits(day time.Time, u unit, amount int) time.Time {
+	switch u {
+	case unitWeek:
+		return day.AddDate(0, 0, 7*amount)
+	case unitMonth:
+		return day.AddDate(0, amount, 0)
+	case unitYear:
+		return day.AddDate(amount, 0, 0)
+	default:
+		return day.AddDate(0, 0, amount)
+	}
+}
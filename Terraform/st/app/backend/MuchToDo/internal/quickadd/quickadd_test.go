@@ -0,0 +1,129 @@
+package quickadd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+func intPtr(i int) *int { return &i }
+
+// TestParse provides unit tests for quick-add parsing in each supported language.
+func TestParse(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2025, 3, 12, 9, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name string
+		text string
+		lang string
+		want models.CreateTodoDTO
+	}{
+		{
+			name: "Monthly with day, label and priority",
+			text: "Pay rent every month on the 1st #home p1",
+			lang: "en",
+			want: models.CreateTodoDTO{
+				Title: "Pay rent", Labels: []string{"home"}, Priority: 1, DueDate: "2025-04-01",
+				Recurrence: &models.Recurrence{Frequency: models.RecurrenceMonthly, Interval: 1, MonthDay: 1},
+			},
+		},
+		{
+			name: "Relative day",
+			text: "Call mum tomorrow",
+			want: models.CreateTodoDTO{Title: "Call mum", DueDate: "2025-03-13"},
+		},
+		{
+			name: "Next weekday and project",
+			text: "Submit report next wednesday @Work #urgent",
+			want: models.CreateTodoDTO{Title: "Submit report", Project: "Work", Labels: []string{"urgent"}, DueDate: "2025-03-19"},
+		},
+		{
+			name: "Plain weekday includes today",
+			text: "Standup wednesday",
+			want: models.CreateTodoDTO{Title: "Standup", DueDate: "2025-03-12"},
+		},
+		{
+			name: "Month and day",
+			text: "Dentist on March 20th",
+			want: models.CreateTodoDTO{Title: "Dentist", DueDate: "2025-03-20"},
+		},
+		{
+			name: "Past month day rolls over to next year",
+			text: "Renew passport 5 feb",
+			want: models.CreateTodoDTO{Title: "Renew passport", DueDate: "2026-02-05"},
+		},
+		{
+			name: "Offset",
+			text: "Follow up in 2 weeks",
+			want: models.CreateTodoDTO{Title: "Follow up", DueDate: "2025-03-26"},
+		},
+		{
+			name: "Interval recurrence starts today",
+			text: "Water plants every 3 days",
+			want: models.CreateTodoDTO{
+				Title: "Water plants", DueDate: "2025-03-12",
+				Recurrence: &models.Recurrence{Frequency: models.RecurrenceDaily, Interval: 3},
+			},
+		},
+		{
+			name: "Weekly on a weekday",
+			text: "Team sync every monday",
+			want: models.CreateTodoDTO{
+				Title: "Team sync", DueDate: "2025-03-17",
+				Recurrence: &models.Recurrence{Frequency: models.RecurrenceWeekly, Interval: 1, Weekday: intPtr(1)},
+			},
+		},
+		{
+			name: "Numbers and unknown markers stay in the title",
+			text: "Buy 3 apples on sale p5",
+			want: models.CreateTodoDTO{Title: "Buy 3 apples on sale p5"},
+		},
+		{
+			name: "German",
+			text: "Miete zahlen jeden Monat am 1. #haushalt p2",
+			lang: "de-DE",
+			want: models.CreateTodoDTO{
+				Title: "Miete zahlen", Labels: []string{"haushalt"}, Priority: 2, DueDate: "2025-04-01",
+				Recurrence: &models.Recurrence{Frequency: models.RecurrenceMonthly, Interval: 1, MonthDay: 1},
+			},
+		},
+		{
+			name: "French",
+			text: "Payer le loyer chaque mois le 1er #maison",
+			lang: "fr-FR,fr;q=0.9",
+			want: models.CreateTodoDTO{
+				Title: "Payer le loyer", Labels: []string{"maison"}, DueDate: "2025-04-01",
+				Recurrence: &models.Recurrence{Frequency: models.RecurrenceMonthly, Interval: 1, MonthDay: 1},
+			},
+		},
+		{
+			name: "French weekday with trailing next",
+			text: "Réunion vendredi prochain",
+			lang: "fr",
+			want: models.CreateTodoDTO{Title: "Réunion", DueDate: "2025-03-14"},
+		},
+		{
+			name: "Only markers keeps the text as title",
+			text: "#inbox tomorrow",
+			want: models.CreateTodoDTO{Title: "#inbox tomorrow", Labels: []string{"inbox"}, DueDate: "2025-03-13"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Parse(tc.text, tc.lang, now))
+		})
+	}
+}
+
+// TestLanguage checks locale tag negotiation.
+func TestLanguage(t *testing.T) {
+	assert.Equal(t, "de", Language("de-DE,de;q=0.9,en;q=0.8"))
+	assert.Equal(t, "fr", Language("FR_ch"))
+	assert.Equal(t, "en", Language(""))
+	assert.Equal(t, "en", Language("xx-YY"))
+}
@@ -0,0 +1,178 @@
+// Package realtime pushes todo events to the clients connected to the API, such as the
+// Server-Sent Events streams of GET /events. The outbox relay publishes each event to
+// the Hub, which forwards it to every server over Redis pub/sub when the servers share
+// a Redis connection, and each server's hub hands it to its own subscribers. Without
+// Redis, events only reach the subscribers of the server running the relay, which is
+// only enough for a single server.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+const (
+	// Channel is the Redis pub/sub channel the servers share events on.
+	Channel = "muchtodo:realtime"
+	// subscriptionBuffer is how many events a subscriber can fall behind by before it
+	// is closed, so that one slow client can't hold up the others.
+	subscriptionBuffer = 64
+)
+
+// Hub fans events out to the subscribers of each user.
+type Hub struct {
+	client *redis.Client // nil if the hub only serves this server
+
+	mu          sync.Mutex
+	subscribers map[primitive.ObjectID]map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewHub creates a hub sharing events over client, or serving only this server if
+// client is nil.
+func NewHub(client *redis.Client) *Hub {
+	return &Hub{
+		client:      client,
+		subscribers: map[primitive.ObjectID]map[*Subscription]struct{}{},
+	}
+}
+
+// Subscription receives the events of one user until it is closed.
+type Subscription struct {
+	hub    *Hub
+	userID primitive.ObjectID
+	events chan models.OutboxRecord
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Events returns the user's events.
+func (s *Subscription) Events() <-chan models.OutboxRecord { return s.events }
+
+// Done is closed when the subscription is closed, either by Close, because the
+// subscriber fell too far behind, or because the server is shutting down.
+func (s *Subscription) Done() <-chan struct{} { return s.done }
+
+// Close stops the subscription.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// Subscribe starts receiving userID's events. The subscription must be closed.
+func (h *Hub) Subscribe(userID primitive.ObjectID) *Subscription {
+	s := &Subscription{
+		hub:    h,
+		userID: userID,
+		events: make(chan models.OutboxRecord, subscriptionBuffer),
+		done:   make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(s.done)
+		return s
+	}
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = map[*Subscription]struct{}{}
+	}
+	h.subscribers[userID][s] = struct{}{}
+	return s
+}
+
+// Close closes every subscription, and refuses new ones, so that the streams end and
+// the server can shut down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for _, subscriptions := range h.subscribers {
+		for s := range subscriptions {
+			h.remove(s)
+		}
+	}
+}
+
+// remove closes a subscription. h.mu must be held.
+func (h *Hub) remove(s *Subscription) {
+	s.once.Do(func() { close(s.done) })
+	subscriptions := h.subscribers[s.userID]
+	delete(subscriptions, s)
+	if len(subscriptions) == 0 {
+		delete(h.subscribers, s.userID)
+	}
+}
+
+// Name implements outbox.Sink.
+func (h *Hub) Name() string { return "realtime" }
+
+// Publish implements outbox.Sink. Only todo events are pushed to clients. An event can
+// be lost if Redis is unreachable for a moment while a server is listening, which a
+// client recovers from by reconnecting with the last event ID it received.
+func (h *Hub) Publish(ctx context.Context, record models.OutboxRecord) error {
+	if record.AggregateType != models.AggregateTodo {
+		return nil
+	}
+	if h.client == nil {
+		h.broadcast(record)
+		return nil
+	}
+	message, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return h.client.Publish(ctx, Channel, message).Err()
+}
+
+// Run forwards the events the servers share over Redis to this server's subscribers,
+// until ctx is cancelled. It returns at once if the hub doesn't use Redis.
+func (h *Hub) Run(ctx context.Context) {
+	if h.client == nil {
+		return
+	}
+	pubsub := h.client.Subscribe(ctx, Channel)
+	defer pubsub.Close()
+	slog.Info("Listening for realtime events", "channel", Channel)
+
+	// The channel reconnects to Redis by itself if the connection drops.
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message, ok := <-messages:
+			if !ok {
+				return
+			}
+			var record models.OutboxRecord
+			if err := json.Unmarshal([]byte(message.Payload), &record); err != nil {
+				slog.Warn("Ignoring malformed realtime event", slog.Any("error", err))
+				continue
+			}
+			h.broadcast(record)
+		}
+	}
+}
+
+// broadcast hands an event to its user's subscribers on this server. A subscriber that
+// has fallen too far behind is closed instead; its client reconnects and catches up.
+func (h *Hub) broadcast(record models.OutboxRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscribers[record.UserID] {
+		select {
+		case s.events <- record:
+		default:
+			h.remove(s)
+		}
+	}
+}
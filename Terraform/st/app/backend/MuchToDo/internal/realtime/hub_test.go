@@ -0,0 +1,66 @@
+package realtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/models"
+)
+
+func TestHub(t *testing.T) {
+	userID := primitive.NewObjectID()
+	record := func(aggregateType string) models.OutboxRecord {
+		return models.OutboxRecord{ID: primitive.NewObjectID(), AggregateType: aggregateType, UserID: userID, EventType: models.TodoEventCreated}
+	}
+
+	t.Run("Fanout", func(t *testing.T) {
+		hub := NewHub(nil)
+		first, second := hub.Subscribe(userID), hub.Subscribe(userID)
+		other := hub.Subscribe(primitive.NewObjectID())
+		defer first.Close()
+		defer second.Close()
+		defer other.Close()
+
+		event := record(models.AggregateTodo)
+		assert.NoError(t, hub.Publish(t.Context(), event))
+		assert.Equal(t, event.ID, (<-first.Events()).ID)
+		assert.Equal(t, event.ID, (<-second.Events()).ID)
+		assert.Empty(t, other.Events())
+
+		// Only todo events are streamed.
+		assert.NoError(t, hub.Publish(t.Context(), record(models.AggregateUser)))
+		assert.Empty(t, first.Events())
+	})
+
+	t.Run("SlowSubscriber", func(t *testing.T) {
+		hub := NewHub(nil)
+		slow := hub.Subscribe(userID)
+		defer slow.Close()
+		for range subscriptionBuffer + 1 {
+			assert.NoError(t, hub.Publish(t.Context(), record(models.AggregateTodo)))
+		}
+		select {
+		case <-slow.Done():
+		case <-time.After(time.Second):
+			t.Fatal("a subscriber that fell behind wasn't closed")
+		}
+		assert.Empty(t, hub.subscribers)
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		hub := NewHub(nil)
+		subscription := hub.Subscribe(userID)
+		subscription.Close()
+		subscription.Close()
+		assert.Empty(t, hub.subscribers)
+
+		subscription = hub.Subscribe(userID)
+		hub.Close()
+		<-subscription.Done()
+		// Once the hub is closed, new subscriptions end at once.
+		<-hub.Subscribe(userID).Done()
+	})
+}
@@ -18,12 +18,45 @@ func RegisterRoutes(
 	router *gin.Engine,
 	userHandler *handlers.UserHandler,
 	todoHandler *handlers.TodoHandler,
+	filterHandler *handlers.FilterHandler,
+	templateHandler *handlers.TemplateHandler,
+	attachmentHandler *handlers.AttachmentHandler,
+	commentHandler *handlers.CommentHandler,
+	timeHandler *handlers.TimeHandler,
+	calendarHandler *handlers.CalendarHandler,
+	appPasswordHandler *handlers.AppPasswordHandler,
+	caldavHandler *handlers.CalDAVHandler,
+	dataExportHandler *handlers.DataExportHandler,
+	webhookHandler *handlers.WebhookHandler,
+	eventHandler *handlers.EventHandler,
 	healthHandler *handlers.HealthHandler,
 	authMiddleware gin.HandlerFunc,
+	adminMiddleware gin.HandlerFunc,
 ) {
 	// Public routes
 	router.GET("/health", healthHandler.CheckHealth)
 
+	// Signed attachment downloads; the signature in the URL authorizes the request
+	router.GET("/blobs/*key", attachmentHandler.ServeBlob)
+
+	// Calendar feeds; the secret token in the URL authorizes the request
+	router.GET("/calendar/:token", calendarHandler.ServeCalendar)
+
+	// CalDAV task sync; clients sign in with the username and an app password
+	router.GET("/.well-known/caldav", caldavHandler.WellKnown)
+	router.Handle("PROPFIND", "/.well-known/caldav", caldavHandler.WellKnown)
+	router.OPTIONS("/dav/*path", caldavHandler.Options)
+	davRoutes := router.Group("/dav")
+	davRoutes.Use(appPasswordHandler.BasicAuthMiddleware(), userHandler.ActiveAccountMiddleware(), userHandler.PreferencesMiddleware())
+	{
+		davRoutes.Handle("PROPFIND", "/*path", caldavHandler.Propfind)
+		davRoutes.Handle("REPORT", "/*path", caldavHandler.Report)
+		davRoutes.GET("/*path", caldavHandler.Get)
+		davRoutes.HEAD("/*path", caldavHandler.Get)
+		davRoutes.PUT("/*path", caldavHandler.Put)
+		davRoutes.DELETE("/*path", caldavHandler.Delete)
+	}
+
 	// Swagger documentation route
 	router.GET("/swagger/*any", func(c *gin.Context) {
 		scheme := "http"
@@ -46,24 +79,123 @@ func RegisterRoutes(
 		authRoutes.GET("/username-check/:username", userHandler.CheckUsernameAvailability)
 	}
 
+	// Admin routes, authorized by the admin API key
+	adminRoutes := router.Group("/admin")
+	adminRoutes.Use(adminMiddleware)
+	{
+		adminRoutes.DELETE("/users/:id", userHandler.AdminDeleteUser)
+	}
+
+	// Accounts scheduled for deletion can only restore themselves
+	router.POST("/users/me/restore", authMiddleware, userHandler.RestoreUser)
+
 	// Protected routes
 	protected := router.Group("")
-	protected.Use(authMiddleware)
+	protected.Use(authMiddleware, userHandler.ActiveAccountMiddleware(), userHandler.PreferencesMiddleware())
 	{
 		// Protected task routes (using /tasks to avoid conflict with frontend /todos route)
 		taskRoutes := protected.Group("/tasks")
 		{
 			taskRoutes.POST("", todoHandler.CreateTodo)
 			taskRoutes.GET("", todoHandler.GetAllTodos)
+			taskRoutes.GET("/search", todoHandler.SearchTodos)
+			taskRoutes.GET("/export", todoHandler.ExportTodos)
+			taskRoutes.POST("/import", todoHandler.ImportTodos)
+			taskRoutes.GET("/next", todoHandler.GetNextTodos)
+			taskRoutes.POST("/quick", todoHandler.QuickAddTodo)
+			taskRoutes.POST("/bulk", todoHandler.BulkTodos)
+			taskRoutes.POST("/archive", todoHandler.ArchiveCompleted)
+			taskRoutes.GET("/trash", todoHandler.GetTrash)
+			taskRoutes.DELETE("/trash", todoHandler.EmptyTrash)
+			taskRoutes.DELETE("/trash/:id", todoHandler.PurgeTodo)
 			taskRoutes.GET("/:id", todoHandler.GetTodoByID)
 			taskRoutes.PUT("/:id", todoHandler.UpdateTodo)
 			taskRoutes.DELETE("/:id", todoHandler.DeleteTodo)
+			taskRoutes.POST("/:id/restore", todoHandler.RestoreTodo)
+			taskRoutes.POST("/:id/unarchive", todoHandler.UnarchiveTodo)
+			taskRoutes.GET("/:id/history", todoHandler.GetTodoHistory)
+			taskRoutes.POST("/:id/history/:version/revert", todoHandler.RevertTodo)
+			taskRoutes.POST("/:id/blockers", todoHandler.AddBlocker)
+			taskRoutes.DELETE("/:id/blockers/:blockerId", todoHandler.RemoveBlocker)
+			taskRoutes.POST("/:id/attachments", attachmentHandler.UploadAttachment)
+			taskRoutes.GET("/:id/attachments", attachmentHandler.GetAttachments)
+			taskRoutes.GET("/:id/attachments/:attachmentId", attachmentHandler.GetAttachmentURL)
+			taskRoutes.DELETE("/:id/attachments/:attachmentId", attachmentHandler.DeleteAttachment)
+			taskRoutes.POST("/:id/comments", commentHandler.CreateComment)
+			taskRoutes.GET("/:id/comments", commentHandler.GetComments)
+			taskRoutes.PUT("/:id/comments/:commentId", commentHandler.UpdateComment)
+			taskRoutes.DELETE("/:id/comments/:commentId", commentHandler.DeleteComment)
+			taskRoutes.POST("/:id/timer/start", timeHandler.StartTimer)
+			taskRoutes.POST("/:id/timer/stop", timeHandler.StopTimer)
+			taskRoutes.POST("/:id/time", timeHandler.CreateTimeEntry)
+			taskRoutes.GET("/:id/time", timeHandler.GetTimeEntries)
+			taskRoutes.DELETE("/:id/time/:entryId", timeHandler.DeleteTimeEntry)
+		}
+
+		// Saved filters
+		filterRoutes := protected.Group("/filters")
+		{
+			filterRoutes.POST("", filterHandler.CreateFilter)
+			filterRoutes.GET("", filterHandler.GetFilters)
+			filterRoutes.GET("/:id", filterHandler.GetFilterByID)
+			filterRoutes.PUT("/:id", filterHandler.UpdateFilter)
+			filterRoutes.DELETE("/:id", filterHandler.DeleteFilter)
+			filterRoutes.GET("/:id/todos", filterHandler.RunFilter)
+		}
+
+		// Todo templates
+		templateRoutes := protected.Group("/templates")
+		{
+			templateRoutes.POST("", templateHandler.CreateTemplate)
+			templateRoutes.GET("", templateHandler.GetTemplates)
+			templateRoutes.GET("/:id", templateHandler.GetTemplateByID)
+			templateRoutes.PUT("/:id", templateHandler.UpdateTemplate)
+			templateRoutes.DELETE("/:id", templateHandler.DeleteTemplate)
+			templateRoutes.POST("/:id/instantiate", templateHandler.InstantiateTemplate)
 		}
 
+		// Webhooks for todo events
+		webhookRoutes := protected.Group("/webhooks")
+		{
+			webhookRoutes.POST("", webhookHandler.CreateWebhook)
+			webhookRoutes.GET("", webhookHandler.GetWebhooks)
+			webhookRoutes.GET("/:id", webhookHandler.GetWebhook)
+			webhookRoutes.PUT("/:id", webhookHandler.UpdateWebhook)
+			webhookRoutes.DELETE("/:id", webhookHandler.DeleteWebhook)
+			webhookRoutes.GET("/:id/deliveries", webhookHandler.GetDeliveries)
+			webhookRoutes.POST("/:id/deliveries/:deliveryId/replay", webhookHandler.ReplayDelivery)
+		}
+
+		// Live todo events, as Server-Sent Events
+		protected.GET("/events", eventHandler.StreamEvents)
+
+		// Activity feed across the user's todos and comments
+		protected.GET("/activity", commentHandler.GetActivity)
+
+		// Time tracking across todos
+		protected.GET("/timer", timeHandler.GetRunningTimer)
+		protected.GET("/time/report", timeHandler.GetTimeReport)
+
+		// Undo for recent task operations
+		protected.POST("/undo/:token", todoHandler.Undo)
+
 		// Protected user routes
 		userRoutes := protected.Group("/users")
 		{
 			userRoutes.GET("/me", userHandler.GetCurrentUser)
+			userRoutes.GET("/me/stats", todoHandler.GetStats)
+			userRoutes.GET("/me/preferences", userHandler.GetPreferences)
+			userRoutes.PUT("/me/preferences", userHandler.UpdatePreferences)
+			userRoutes.GET("/me/calendar", calendarHandler.GetCalendarFeed)
+			userRoutes.POST("/me/calendar", calendarHandler.RegenerateCalendarToken)
+			userRoutes.DELETE("/me/calendar", calendarHandler.DisableCalendarFeed)
+			userRoutes.POST("/me/app-passwords", appPasswordHandler.CreateAppPassword)
+			userRoutes.GET("/me/app-passwords", appPasswordHandler.GetAppPasswords)
+			userRoutes.DELETE("/me/app-passwords/:id", appPasswordHandler.DeleteAppPassword)
+			userRoutes.POST("/me/export", dataExportHandler.RequestExport)
+			userRoutes.GET("/me/exports", dataExportHandler.GetExports)
+			userRoutes.GET("/me/exports/:id", dataExportHandler.GetExport)
+			userRoutes.GET("/me/exports/:id/download", dataExportHandler.DownloadExport)
 			userRoutes.PUT("/me", userHandler.UpdateUser)
 			userRoutes.PUT("/me/password", userHandler.ChangePassword)
 			userRoutes.DELETE("/me", userHandler.DeleteUser)
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalURLPrefix is the path under which the API serves signed local downloads.
+const LocalURLPrefix = "/blobs/"
+
+// LocalStore keeps blobs as files under a directory. Downloads are served by the store
+// itself (it is an http.Handler) and authorised with HMAC-signed URLs.
+type LocalStore struct {
+	dir       string
+	secret    []byte
+	urlPrefix string
+	now       func() time.Time
+}
+
+// NewLocalStore creates a store rooted at dir, creating the directory if needed.
+// Download URLs start with urlPrefix and are signed with secret.
+func NewLocalStore(dir string, secret []byte, urlPrefix string) (*LocalStore, error) {
+	if dir == "" {
+		return nil, errors.New("local storage directory is not set")
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("local storage signing key is not set")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir, secret: secret, urlPrefix: urlPrefix, now: time.Now}, nil
+}
+
+// path maps a key to a file inside the store's directory, rejecting keys that would escape it.
+func (s *LocalStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+// Put implements BlobStore. The blob is written to a temporary file first so that readers
+// never see a partial file.
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if written != size {
+		return fmt.Errorf("expected %d bytes, got %d", size, written)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get implements BlobStore.
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete implements BlobStore.
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// SignedURL implements BlobStore.
+func (s *LocalStore) SignedURL(key string, opts DownloadOptions) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(s.now().Add(opts.Expires).Unix(), 10)
+	q := url.Values{}
+	q.Set("expires", expires)
+	q.Set("name", opts.Filename)
+	q.Set("type", opts.ContentType)
+	q.Set("sig", s.signature(key, expires, opts.Filename, opts.ContentType))
+
+	return s.urlPrefix + key + "?" + q.Encode(), nil
+}
+
+func (s *LocalStore) signature(key, expires, name, contentType string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strings.Join([]string{key, expires, name, contentType}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ServeHTTP serves a blob requested through a URL from SignedURL.
+func (s *LocalStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, s.urlPrefix)
+	q := r.URL.Query()
+	expires, name, contentType := q.Get("expires"), q.Get("name"), q.Get("type")
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	valid := err == nil && s.now().Unix() <= unix &&
+		hmac.Equal([]byte(q.Get("sig")), []byte(s.signature(key, expires, name, contentType)))
+	if !valid {
+		http.Error(w, "invalid or expired link", http.StatusForbidden)
+		return
+	}
+
+	path, err := s.path(key)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
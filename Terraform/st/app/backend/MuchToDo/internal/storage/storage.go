@@ -0,0 +1,59 @@
+// Package storage provides blob storage for file attachments, backed either by the local
+// disk or by an S3-compatible object store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Innocent9712/much-to-do/Server/MuchToDo/internal/config"
+)
+
+// ErrNotFound is returned when a blob does not exist.
+var ErrNotFound = errors.New("blob not found")
+
+// DownloadOptions controls how a signed download URL presents the blob to the client.
+type DownloadOptions struct {
+	Filename    string        // Suggested file name for the download
+	ContentType string        // Content-Type to serve the blob with
+	Expires     time.Duration // How long the URL stays valid
+}
+
+// BlobStore stores opaque blobs under string keys. Keys may contain slashes.
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing any existing blob.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the blob stored under key. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob under key. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a URL that allows downloading the blob without authentication
+	// until it expires. Local URLs are relative to the API's base URL.
+	SignedURL(key string, opts DownloadOptions) (string, error)
+}
+
+// New creates the blob store selected by the configuration.
+func New(cfg config.Config) (BlobStore, error) {
+	switch cfg.StorageDriver {
+	case "", "local":
+		secret := cfg.StorageSigningKey
+		if secret == "" {
+			secret = cfg.JWTSecretKey
+		}
+		return NewLocalStore(cfg.StorageLocalDir, []byte(secret), LocalURLPrefix)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+	}
+}